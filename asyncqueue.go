@@ -0,0 +1,117 @@
+package vkg
+
+import (
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// queueHandoff holds the semaphores signaled by compute and transfer submissions which the next
+// graphics submission must wait on
+type queueHandoff struct {
+	waits []SemaphoreWait
+	// Binary semaphores which are waited on by the pending waits, those in flight and those free for reuse
+	pending  []vk.Semaphore
+	inFlight []vk.Semaphore
+	free     []vk.Semaphore
+}
+
+func (h *queueHandoff) semaphore(d *Device) (vk.Semaphore, error) {
+	if len(h.free) > 0 {
+		s := h.free[len(h.free)-1]
+		h.free = h.free[:len(h.free)-1]
+		return s, nil
+	}
+	return d.VKCreateSemaphore()
+}
+
+// takeWaits returns the pending waits for a graphics submission
+func (h *queueHandoff) takeWaits() []SemaphoreWait {
+	waits := h.waits
+	h.waits = nil
+	h.inFlight = append(h.inFlight, h.pending...)
+	h.pending = nil
+	return waits
+}
+
+// recycle makes the semaphores waited on by previous graphics submissions available again, the
+// graphics queue must be idle
+func (h *queueHandoff) recycle() {
+	h.free = append(h.free, h.inFlight...)
+	h.inFlight = nil
+}
+
+func (h *queueHandoff) destroy(d *Device) {
+	for _, l := range [][]vk.Semaphore{h.pending, h.inFlight, h.free} {
+		for _, s := range l {
+			d.VKDestroySemaphore(s)
+		}
+	}
+	*h = queueHandoff{}
+}
+
+// HasAsyncCompute returns true if compute work is submitted to a queue separate from the graphics queue
+func (p *GraphicsApp) HasAsyncCompute() bool {
+	return p.ComputeQueue != nil && p.ComputeQueue != p.GraphicsQueue
+}
+
+// HasTransferQueue returns true if transfers are submitted to a queue separate from the graphics queue
+func (p *GraphicsApp) HasTransferQueue() bool {
+	return p.TransferQueue != nil && p.TransferQueue != p.GraphicsQueue
+}
+
+// SubmitCompute submits command buffers allocated from ComputeCommandPool, the next frame drawn waits for
+// them to complete before executing the graphics stages in waitStage. The work overlaps rendering of the
+// current frame when the device has a dedicated compute family, otherwise it is submitted to the graphics queue.
+// Buffers written by compute and read by graphics must be released with ReleaseToGraphics unless they are
+// created with vk.SharingModeConcurrent.
+func (p *GraphicsApp) SubmitCompute(waitStage vk.PipelineStageFlags, buffers ...*CommandBuffer) error {
+	return p.submitHandoff(p.ComputeQueue, waitStage, buffers)
+}
+
+// SubmitTransfer submits command buffers allocated from TransferCommandPool, the next frame drawn waits for
+// them to complete before executing the graphics stages in waitStage
+func (p *GraphicsApp) SubmitTransfer(waitStage vk.PipelineStageFlags, buffers ...*CommandBuffer) error {
+	return p.submitHandoff(p.TransferQueue, waitStage, buffers)
+}
+
+func (p *GraphicsApp) submitHandoff(queue *Queue, waitStage vk.PipelineStageFlags, buffers []*CommandBuffer) error {
+	if queue == p.GraphicsQueue {
+		// Submission order and the barriers recorded by Require are enough on a single queue
+		return queue.SubmitWithSemaphores(nil, nil, nil, buffers...)
+	}
+
+	if p.Timeline != nil {
+		point, err := p.Timeline.Submit(queue, buffers)
+		if err != nil {
+			return err
+		}
+		p.handoff.waits = append(p.handoff.waits, point.WaitInfo(waitStage))
+		return nil
+	}
+
+	sema, err := p.handoff.semaphore(p.Device)
+	if err != nil {
+		return err
+	}
+	err = queue.SubmitWithSemaphores(nil, []SemaphoreSignal{{Semaphore: sema}}, nil, buffers...)
+	if err != nil {
+		p.handoff.free = append(p.handoff.free, sema)
+		return err
+	}
+	p.handoff.pending = append(p.handoff.pending, sema)
+	p.handoff.waits = append(p.handoff.waits, SemaphoreWait{Semaphore: sema, StageMask: waitStage})
+	return nil
+}
+
+// ReleaseToGraphics records the release of a resource from a compute or transfer command buffer to the
+// graphics queue family, the graphics command buffer acquires it with CommandBuffer.Require for the same
+// usage. Nothing is recorded when the queues share a family.
+func (p *GraphicsApp) ReleaseToGraphics(cmd *CommandBuffer, resource TrackedResource, usage ResourceUsage) error {
+	return cmd.Release(resource, p.GraphicsQueue.QueueFamily, usage)
+}
+
+// ReleaseToCompute records the release of a resource from a graphics or transfer command buffer to the
+// compute queue family. The compute work which acquires it must be submitted after the releasing work
+// has completed, such as after DrawFrameSync returns.
+func (p *GraphicsApp) ReleaseToCompute(cmd *CommandBuffer, resource TrackedResource, usage ResourceUsage) error {
+	return cmd.Release(resource, p.ComputeQueue.QueueFamily, usage)
+}
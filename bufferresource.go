@@ -17,6 +17,8 @@ type BufferResource struct {
 	ResourcePool    *BufferResourcePool
 	Allocation      *Allocation
 	StagingResource *BufferResource
+	// State is the access and ownership of the buffer as recorded by CommandBuffer.Require
+	State ResourceState
 }
 
 // VKMappedMemoryRange is provided so that the buffer implements MappedMemoryRange
@@ -72,6 +74,7 @@ func (r *BufferResource) FreeStagingResource() {
 // CmdCopyBufferFromStagedResource will populate this buffer from the previously
 // allocated staged resource
 func (c *CommandBuffer) CmdCopyBufferFromStagedResource(resource *BufferResource) {
+	c.FlushBarriers()
 	vk.CmdCopyBuffer(c.VK(), resource.StagingResource.Buffer.VKBuffer, resource.Buffer.VKBuffer, 1, []vk.BufferCopy{
 		vk.BufferCopy{
 			SrcOffset: 0,
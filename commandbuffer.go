@@ -1,6 +1,9 @@
 package vkg
 
 import (
+	"fmt"
+	"unsafe"
+
 	vk "github.com/vulkan-go/vulkan"
 )
 
@@ -10,15 +13,21 @@ import (
 // must call the native vulkan command APIs.
 type CommandBuffer struct {
 	VKCommandBuffer vk.CommandBuffer
+	// QueueFamily of the pool the buffer was allocated from, used to track resource ownership
+	QueueFamily *QueueFamily
+
+	barriers barrierBatch
 }
 
 // ResetAndRelease will reset this commandbuffer and release the associated resources
 func (c *CommandBuffer) ResetAndRelease() error {
+	c.barriers.reset()
 	return vk.Error(vk.ResetCommandBuffer(c.VKCommandBuffer, vk.CommandBufferResetFlags(vk.CommandBufferResetReleaseResourcesBit)))
 }
 
 // Reset this command buffer
 func (c *CommandBuffer) Reset() error {
+	c.barriers.reset()
 	return vk.Error(vk.ResetCommandBuffer(c.VKCommandBuffer, 0))
 }
 
@@ -79,10 +88,247 @@ func (c *CommandBuffer) CmdBindDescriptorSets(bindPoint vk.PipelineBindPoint, la
 }
 
 func (c *CommandBuffer) CmdDispatch(x, y, z int) {
+	c.FlushBarriers()
 	vk.CmdDispatch(c.VKCommandBuffer, uint32(x), uint32(y), uint32(z))
 }
 
+// CmdDispatchIndirect dispatches compute work using vk.DispatchIndirectCommand parameters read from a buffer
+func (c *CommandBuffer) CmdDispatchIndirect(buffer *BufferResource, offset uint64) {
+	c.FlushBarriers()
+	vk.CmdDispatchIndirect(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset))
+}
+
+// CmdBeginRenderPass begins a render pass covering the extent of the framebuffer, clear values are
+// required for each attachment which is cleared, see vk.NewClearValue and vk.NewClearDepthStencil
+func (c *CommandBuffer) CmdBeginRenderPass(renderPass vk.RenderPass, framebuffer vk.Framebuffer, extent vk.Extent2D, contents vk.SubpassContents, clearValues ...vk.ClearValue) {
+	c.CmdBeginRenderPassWithArea(renderPass, framebuffer, vk.Rect2D{Extent: extent}, contents, clearValues...)
+}
+
+// CmdBeginRenderPassWithArea begins a render pass limited to the specified area of the framebuffer
+func (c *CommandBuffer) CmdBeginRenderPassWithArea(renderPass vk.RenderPass, framebuffer vk.Framebuffer, area vk.Rect2D, contents vk.SubpassContents, clearValues ...vk.ClearValue) {
+	renderPassBeginInfo := vk.RenderPassBeginInfo{
+		SType:           vk.StructureTypeRenderPassBeginInfo,
+		RenderPass:      renderPass,
+		Framebuffer:     framebuffer,
+		RenderArea:      area,
+		ClearValueCount: uint32(len(clearValues)),
+		PClearValues:    clearValues,
+	}
+	c.FlushBarriers()
+	vk.CmdBeginRenderPass(c.VKCommandBuffer, &renderPassBeginInfo, contents)
+}
+
+// CmdBeginRenderTarget begins the render pass of an offscreen render target
+func (c *CommandBuffer) CmdBeginRenderTarget(target *RenderTarget, contents vk.SubpassContents, clearValues ...vk.ClearValue) {
+	c.CmdBeginRenderPass(target.VKRenderPass, target.VKFramebuffer, target.Extent(), contents, clearValues...)
+}
+
+// CmdNextSubpass moves to the next subpass of the current render pass
+func (c *CommandBuffer) CmdNextSubpass(contents vk.SubpassContents) {
+	vk.CmdNextSubpass(c.VKCommandBuffer, contents)
+}
+
+// CmdEndRenderPass ends the current render pass
+func (c *CommandBuffer) CmdEndRenderPass() {
+	vk.CmdEndRenderPass(c.VKCommandBuffer)
+}
+
+// CmdExecuteCommands executes secondary command buffers from this primary command buffer
+func (c *CommandBuffer) CmdExecuteCommands(buffers ...*CommandBuffer) {
+	if len(buffers) == 0 {
+		return
+	}
+	vkBuffers := make([]vk.CommandBuffer, len(buffers))
+	for i := range buffers {
+		vkBuffers[i] = buffers[i].VKCommandBuffer
+	}
+	c.FlushBarriers()
+	vk.CmdExecuteCommands(c.VKCommandBuffer, uint32(len(vkBuffers)), vkBuffers)
+}
+
+// CmdBindPipeline binds a pipeline to the specified bind point
+func (c *CommandBuffer) CmdBindPipeline(bindPoint vk.PipelineBindPoint, pipeline vk.Pipeline) {
+	vk.CmdBindPipeline(c.VKCommandBuffer, bindPoint, pipeline)
+}
+
+// CmdBindGraphicsPipeline binds a graphics pipeline, such as one from GraphicsApp.GraphicsPipelines
+func (c *CommandBuffer) CmdBindGraphicsPipeline(pipeline vk.Pipeline) {
+	vk.CmdBindPipeline(c.VKCommandBuffer, vk.PipelineBindPointGraphics, pipeline)
+}
+
+// CmdBindVertexBuffers binds the buffers to consecutive vertex input bindings starting at firstBinding
+func (c *CommandBuffer) CmdBindVertexBuffers(firstBinding int, buffers ...*BufferResource) {
+	c.CmdBindVertexBuffersWithOffsets(firstBinding, buffers, nil)
+}
+
+// CmdBindVertexBuffersWithOffsets binds the buffers to consecutive vertex input bindings starting at firstBinding,
+// offsets may be nil in which case each buffer is bound from its start
+func (c *CommandBuffer) CmdBindVertexBuffersWithOffsets(firstBinding int, buffers []*BufferResource, offsets []uint64) {
+	vkBuffers := make([]vk.Buffer, len(buffers))
+	vkOffsets := make([]vk.DeviceSize, len(buffers))
+	for i, b := range buffers {
+		vkBuffers[i] = b.VKBuffer
+		if i < len(offsets) {
+			vkOffsets[i] = vk.DeviceSize(offsets[i])
+		}
+	}
+	vk.CmdBindVertexBuffers(c.VKCommandBuffer, uint32(firstBinding), uint32(len(vkBuffers)), vkBuffers, vkOffsets)
+}
+
+// CmdBindIndexBuffer binds a buffer containing the specified indices, the index type is taken from the indices
+func (c *CommandBuffer) CmdBindIndexBuffer(buffer *BufferResource, indices IndexSourcer) {
+	c.CmdBindIndexBufferWithType(buffer, 0, indices.IndexType())
+}
+
+// CmdBindIndexBufferWithType binds a buffer containing indices of the specified type
+func (c *CommandBuffer) CmdBindIndexBufferWithType(buffer *BufferResource, offset uint64, indexType vk.IndexType) {
+	vk.CmdBindIndexBuffer(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset), indexType)
+}
+
+// IndexCount returns the number of indices provided by the index sourcer
+func IndexCount(indices IndexSourcer) int {
+	size := 2
+	if indices.IndexType() == vk.IndexTypeUint32 {
+		size = 4
+	}
+	return len(indices.Bytes()) / size
+}
+
+// CmdPushConstants updates the push constants of the pipeline layout for the specified stages
+func (c *CommandBuffer) CmdPushConstants(layout *PipelineLayout, stages vk.ShaderStageFlags, offset int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	vk.CmdPushConstants(c.VKCommandBuffer, layout.VKPipelineLayout, stages, uint32(offset), uint32(len(data)), unsafe.Pointer(&data[0]))
+}
+
+// CmdDraw draws non-indexed primitives
+func (c *CommandBuffer) CmdDraw(vertexCount, instanceCount, firstVertex, firstInstance int) {
+	c.FlushBarriers()
+	vk.CmdDraw(c.VKCommandBuffer, uint32(vertexCount), uint32(instanceCount), uint32(firstVertex), uint32(firstInstance))
+}
+
+// CmdDrawIndexed draws indexed primitives using the bound index buffer
+func (c *CommandBuffer) CmdDrawIndexed(indexCount, instanceCount, firstIndex, vertexOffset, firstInstance int) {
+	c.FlushBarriers()
+	vk.CmdDrawIndexed(c.VKCommandBuffer, uint32(indexCount), uint32(instanceCount), uint32(firstIndex), int32(vertexOffset), uint32(firstInstance))
+}
+
+// CmdDrawIndirect draws using vk.DrawIndirectCommand parameters read from a buffer
+func (c *CommandBuffer) CmdDrawIndirect(buffer *BufferResource, offset uint64, drawCount, stride int) {
+	c.FlushBarriers()
+	vk.CmdDrawIndirect(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset), uint32(drawCount), uint32(stride))
+}
+
+// CmdDrawIndexedIndirect draws using vk.DrawIndexedIndirectCommand parameters read from a buffer
+func (c *CommandBuffer) CmdDrawIndexedIndirect(buffer *BufferResource, offset uint64, drawCount, stride int) {
+	c.FlushBarriers()
+	vk.CmdDrawIndexedIndirect(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset), uint32(drawCount), uint32(stride))
+}
+
+// CmdDrawIndirectCount is like CmdDrawIndirect but reads the number of draws from countBuffer, up to
+// maxDrawCount. The device must have been created with EnableDrawIndirectCount.
+func (c *CommandBuffer) CmdDrawIndirectCount(buffer *BufferResource, offset uint64, countBuffer *BufferResource, countOffset uint64, maxDrawCount, stride int) error {
+	if err := validateDrawIndirectCount(buffer); err != nil {
+		return err
+	}
+	c.FlushBarriers()
+	vk.CmdDrawIndirectCountKHR(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset),
+		countBuffer.VKBuffer, vk.DeviceSize(countOffset), uint32(maxDrawCount), uint32(stride))
+	return nil
+}
+
+// CmdDrawIndexedIndirectCount is like CmdDrawIndexedIndirect but reads the number of draws from countBuffer,
+// up to maxDrawCount. The device must have been created with EnableDrawIndirectCount.
+func (c *CommandBuffer) CmdDrawIndexedIndirectCount(buffer *BufferResource, offset uint64, countBuffer *BufferResource, countOffset uint64, maxDrawCount, stride int) error {
+	if err := validateDrawIndirectCount(buffer); err != nil {
+		return err
+	}
+	c.FlushBarriers()
+	vk.CmdDrawIndexedIndirectCountKHR(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset),
+		countBuffer.VKBuffer, vk.DeviceSize(countOffset), uint32(maxDrawCount), uint32(stride))
+	return nil
+}
+
+// CmdSetViewport sets the viewports starting at the first viewport, the pipeline must have
+// vk.DynamicStateViewport enabled
+func (c *CommandBuffer) CmdSetViewport(viewports ...vk.Viewport) {
+	vk.CmdSetViewport(c.VKCommandBuffer, 0, uint32(len(viewports)), viewports)
+}
+
+// CmdSetScissor sets the scissors starting at the first viewport, the pipeline must have
+// vk.DynamicStateScissor enabled
+func (c *CommandBuffer) CmdSetScissor(scissors ...vk.Rect2D) {
+	vk.CmdSetScissor(c.VKCommandBuffer, 0, uint32(len(scissors)), scissors)
+}
+
+// ViewportFromExtent returns a viewport covering the extent with a depth range of 0 to 1
+func ViewportFromExtent(extent vk.Extent2D) vk.Viewport {
+	return vk.Viewport{
+		Width:    float32(extent.Width),
+		Height:   float32(extent.Height),
+		MinDepth: 0.0,
+		MaxDepth: 1.0,
+	}
+}
+
+// CmdFillBuffer fills size bytes of the buffer starting at offset with the repeated data value, a size
+// of 0 fills to the end of the buffer
+func (c *CommandBuffer) CmdFillBuffer(buffer *BufferResource, offset, size uint64, data uint32) error {
+	if err := validateFillBuffer(buffer, offset, size); err != nil {
+		return err
+	}
+	vkSize := vk.DeviceSize(size)
+	if size == 0 {
+		vkSize = vk.DeviceSize(vk.WholeSize)
+	}
+	c.FlushBarriers()
+	vk.CmdFillBuffer(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset), vkSize, data)
+	return nil
+}
+
+func validateFillBuffer(buffer *BufferResource, offset, size uint64) error {
+	if offset%4 != 0 || size%4 != 0 {
+		return fmt.Errorf("fill offset %d and size %d must be multiples of 4", offset, size)
+	}
+	if offset+size > buffer.Size {
+		return fmt.Errorf("fill of %d bytes at offset %d exceeds buffer size %d", size, offset, buffer.Size)
+	}
+	return nil
+}
+
+// MaxUpdateBufferSize is the largest amount of data which can be written with CmdUpdateBuffer
+const MaxUpdateBufferSize = 65536
+
+// CmdUpdateBuffer writes a small amount of data inline into the buffer, for larger updates a staging
+// buffer should be used
+func (c *CommandBuffer) CmdUpdateBuffer(buffer *BufferResource, offset uint64, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := validateUpdateBuffer(buffer, offset, data); err != nil {
+		return err
+	}
+	c.FlushBarriers()
+	vk.CmdUpdateBuffer(c.VKCommandBuffer, buffer.VKBuffer, vk.DeviceSize(offset), vk.DeviceSize(len(data)), unsafe.Pointer(&data[0]))
+	return nil
+}
+
+func validateUpdateBuffer(buffer *BufferResource, offset uint64, data []byte) error {
+	if offset%4 != 0 || len(data)%4 != 0 {
+		return fmt.Errorf("update offset %d and size %d must be multiples of 4", offset, len(data))
+	}
+	if len(data) > MaxUpdateBufferSize {
+		return fmt.Errorf("update of %d bytes exceeds the maximum of %d", len(data), MaxUpdateBufferSize)
+	}
+	if offset+uint64(len(data)) > buffer.Size {
+		return fmt.Errorf("update of %d bytes at offset %d exceeds buffer size %d", len(data), offset, buffer.Size)
+	}
+	return nil
+}
+
 // End describing work for this command buffer
 func (c *CommandBuffer) End() error {
+	c.FlushBarriers()
 	return vk.Error(vk.EndCommandBuffer(c.VKCommandBuffer))
 }
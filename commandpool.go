@@ -55,6 +55,7 @@ func (c *CommandPool) AllocateBuffers(count int, level vk.CommandBufferLevel) ([
 	for i := range ret {
 		ret[i] = &CommandBuffer{}
 		ret[i].VKCommandBuffer = cmdBuffers[i]
+		ret[i].QueueFamily = c.QueueFamily
 	}
 
 	return ret, nil
@@ -85,6 +86,11 @@ func (c *CommandPool) FreeBuffer(b *CommandBuffer) {
 	vk.FreeCommandBuffers(c.Device.VKDevice, c.VKCommandPool, 1, []vk.CommandBuffer{b.VKCommandBuffer})
 }
 
+// Reset resets all of the command buffers allocated from this pool, which must not be in use by the GPU
+func (c *CommandPool) Reset() error {
+	return vk.Error(vk.ResetCommandPool(c.Device.VKDevice, c.VKCommandPool, 0))
+}
+
 // Destroy this command pool
 func (c *CommandPool) Destroy() {
 	vk.DestroyCommandPool(c.Device.VKDevice, c.VKCommandPool, nil)
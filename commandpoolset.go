@@ -0,0 +1,199 @@
+package vkg
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// CommandPoolSet provides a command pool for each worker goroutine and frame in flight. Command pools
+// must not be used by more than one goroutine at a time, so each goroutine recording commands uses its
+// own worker index. Once the GPU has finished with a frame ResetFrame resets all of its pools at once,
+// and the command buffers allocated from them are reused for later frames rather than freed.
+type CommandPoolSet struct {
+	Device      *Device
+	QueueFamily *QueueFamily
+	// Workers is the number of goroutines RecordSecondary records with, it defaults to the number of CPUs
+	Workers int
+
+	mutex  sync.Mutex
+	frames [][]*workerCommandPool
+}
+
+// workerCommandPool is the pool of a single worker and frame along with the buffers allocated from it
+type workerCommandPool struct {
+	pool    *CommandPool
+	buffers map[vk.CommandBufferLevel][]*CommandBuffer
+	used    map[vk.CommandBufferLevel]int
+}
+
+// CreateCommandPoolSet creates a set of command pools for the queue family, pools are created as they
+// are first used
+func (d *Device) CreateCommandPoolSet(q *QueueFamily, framesInFlight int) *CommandPoolSet {
+	var ret CommandPoolSet
+	ret.Device = d
+	ret.QueueFamily = q
+	ret.Workers = runtime.NumCPU()
+	ret.frames = make([][]*workerCommandPool, framesInFlight)
+	return &ret
+}
+
+func (s *CommandPoolSet) worker(frame, worker int) (*workerCommandPool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if frame < 0 || frame >= len(s.frames) {
+		return nil, fmt.Errorf("frame %d is out of range of %d frames in flight", frame, len(s.frames))
+	}
+	if worker < 0 {
+		return nil, fmt.Errorf("invalid worker %d", worker)
+	}
+	for len(s.frames[frame]) <= worker {
+		s.frames[frame] = append(s.frames[frame], nil)
+	}
+
+	w := s.frames[frame][worker]
+	if w == nil {
+		pool, err := s.Device.CreateCommandPool(s.QueueFamily)
+		if err != nil {
+			return nil, err
+		}
+		w = &workerCommandPool{
+			pool:    pool,
+			buffers: make(map[vk.CommandBufferLevel][]*CommandBuffer),
+			used:    make(map[vk.CommandBufferLevel]int),
+		}
+		s.frames[frame][worker] = w
+	}
+	return w, nil
+}
+
+// Pool returns the command pool of a worker for the specified frame
+func (s *CommandPoolSet) Pool(frame, worker int) (*CommandPool, error) {
+	w, err := s.worker(frame, worker)
+	if err != nil {
+		return nil, err
+	}
+	return w.pool, nil
+}
+
+// Allocate returns a command buffer from the worker's pool for the frame, buffers are valid until the
+// frame is reset. It must only be called from the goroutine using the worker index.
+func (s *CommandPoolSet) Allocate(frame, worker int, level vk.CommandBufferLevel) (*CommandBuffer, error) {
+	w, err := s.worker(frame, worker)
+	if err != nil {
+		return nil, err
+	}
+
+	used := w.used[level]
+	if used < len(w.buffers[level]) {
+		w.used[level]++
+		return w.buffers[level][used], nil
+	}
+
+	b, err := w.pool.AllocateBuffer(level)
+	if err != nil {
+		return nil, err
+	}
+	w.buffers[level] = append(w.buffers[level], b)
+	w.used[level]++
+	return b, nil
+}
+
+// ResetFrame resets the pools of all workers for the frame, the GPU must have finished executing all
+// of the command buffers allocated for it
+func (s *CommandPoolSet) ResetFrame(frame int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if frame < 0 || frame >= len(s.frames) {
+		return fmt.Errorf("frame %d is out of range of %d frames in flight", frame, len(s.frames))
+	}
+	for _, w := range s.frames[frame] {
+		if w == nil {
+			continue
+		}
+		err := w.pool.Reset()
+		if err != nil {
+			return err
+		}
+		for level, buffers := range w.buffers {
+			for _, b := range buffers {
+				b.barriers.reset()
+			}
+			w.used[level] = 0
+		}
+	}
+	return nil
+}
+
+// RecordSecondary records secondary command buffers which continue the render pass in parallel, each
+// task is given its own command buffer which has been begun and is ended once the task returns. Tasks
+// are shared between Workers goroutines and the buffers are returned in the order of the tasks, ready
+// to be passed to CommandBuffer.CmdExecuteCommands from a render pass begun with
+// vk.SubpassContentsSecondaryCommandBuffers.
+func (s *CommandPoolSet) RecordSecondary(frame int, renderPass vk.RenderPass, framebuffer vk.Framebuffer, tasks ...func(cmd *CommandBuffer) error) ([]*CommandBuffer, error) {
+	buffers := make([]*CommandBuffer, len(tasks))
+	errs := make([]error, len(tasks))
+
+	workers := s.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; i < len(tasks); i += workers {
+				buffers[i], errs[i] = s.recordSecondary(frame, worker, renderPass, framebuffer, tasks[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffers, nil
+}
+
+func (s *CommandPoolSet) recordSecondary(frame, worker int, renderPass vk.RenderPass, framebuffer vk.Framebuffer, task func(cmd *CommandBuffer) error) (*CommandBuffer, error) {
+	cmd, err := s.Allocate(frame, worker, vk.CommandBufferLevelSecondary)
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.BeginContinueRenderPass(renderPass, framebuffer)
+	if err != nil {
+		return nil, err
+	}
+	err = task(cmd)
+	if err != nil {
+		cmd.End()
+		return nil, err
+	}
+	return cmd, cmd.End()
+}
+
+// Destroy destroys all of the pools, the GPU must have finished with all of the frames
+func (s *CommandPoolSet) Destroy() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, workers := range s.frames {
+		for _, w := range workers {
+			if w != nil {
+				w.pool.Destroy()
+			}
+		}
+		s.frames[i] = nil
+	}
+}
@@ -37,12 +37,12 @@ func (du *DescriptorSet) AddBuffer(dstBinding int, dtype vk.DescriptorType, b *B
 }
 
 // AddCombinedImageSampler adds an image layout, image view and sampler to support displaying a texture
-func (du *DescriptorSet) AddCombinedImageSampler(dstBinding int, layout vk.ImageLayout, imageView vk.ImageView, sampler vk.Sampler) {
+func (du *DescriptorSet) AddCombinedImageSampler(dstBinding int, layout vk.ImageLayout, imageView vk.ImageView, sampler *Sampler) {
 
 	var descriptorImageInfo = vk.DescriptorImageInfo{}
 	descriptorImageInfo.ImageView = imageView
 	descriptorImageInfo.ImageLayout = layout
-	descriptorImageInfo.Sampler = sampler
+	descriptorImageInfo.Sampler = sampler.VKSampler
 
 	var writeDescriptorSet = vk.WriteDescriptorSet{}
 	writeDescriptorSet.SType = vk.StructureTypeWriteDescriptorSet
@@ -58,6 +58,23 @@ func (du *DescriptorSet) AddCombinedImageSampler(dstBinding int, layout vk.Image
 
 }
 
+// AddImage adds an image view without a sampler, such as a storage image or a sampled image used with a
+// separate sampler
+func (du *DescriptorSet) AddImage(dstBinding int, dtype vk.DescriptorType, layout vk.ImageLayout, imageView vk.ImageView) {
+	var descriptorImageInfo = vk.DescriptorImageInfo{}
+	descriptorImageInfo.ImageView = imageView
+	descriptorImageInfo.ImageLayout = layout
+
+	var writeDescriptorSet = vk.WriteDescriptorSet{}
+	writeDescriptorSet.SType = vk.StructureTypeWriteDescriptorSet
+	writeDescriptorSet.DstBinding = uint32(dstBinding)
+	writeDescriptorSet.DescriptorCount = 1
+	writeDescriptorSet.DescriptorType = dtype
+	writeDescriptorSet.PImageInfo = []vk.DescriptorImageInfo{descriptorImageInfo}
+
+	du.VKWriteDiscriptorSet = append(du.VKWriteDiscriptorSet, writeDescriptorSet)
+}
+
 // Write modifies the descriptor set
 func (du *DescriptorSet) Write() {
 	for i := range du.VKWriteDiscriptorSet {
@@ -10,10 +10,24 @@ import (
 type Device struct {
 	PhysicalDevice *PhysicalDevice
 	VKDevice       vk.Device
+
+	// TimelineSemaphores is true when the device was created with timeline semaphores enabled
+	TimelineSemaphores bool
+	// timelineSemaphoresKHR is true when timeline semaphores come from VK_KHR_timeline_semaphore
+	// rather than Vulkan 1.2, so the extension's entry points must be used
+	timelineSemaphoresKHR bool
+	// DrawIndirectCount is true when the device was created with VK_KHR_draw_indirect_count enabled
+	DrawIndirectCount bool
+
+	samplers samplerCache
+
+	// createComputePipelines is replaced by tests, vkCreateComputePipelines is used when it is nil
+	createComputePipelines func(cache vk.PipelineCache, infos []vk.ComputePipelineCreateInfo, pipelines []vk.Pipeline) error
 }
 
 // Destroy destroys the device
 func (d *Device) Destroy() {
+	d.destroySamplers()
 	vk.DestroyDevice(d.VKDevice, nil)
 }
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"runtime"
 	"unsafe"
 
@@ -23,6 +24,8 @@ func orPanic(err error) {
 var Width = 800
 var Height = 600
 
+var hotReload = flag.Bool("hot-reload", false, "rebuild the pipeline when shaders/*.spv change")
+
 type Vertex struct {
 	Pos   lin.Vec3
 	Color lin.Vec3
@@ -261,6 +264,12 @@ func (c *CubeDemo) init() {
 	// now we need to tell the graphics app how to make command buffers
 	c.app.MakeCommandBuffer = c.MakeCommandBuffer
 
+	// when hot reloading, recompile the shaders (e.g. glslc shader.vert -o vert.spv) while the
+	// demo is running and the pipeline will be rebuilt before the next frame
+	if *hotReload {
+		c.app.EnableShaderHotReload()
+	}
+
 	// now that we've done all this ground work we can go draw some stuff on the screen
 	c.app.PrepareToDraw()
 
@@ -325,7 +334,7 @@ func (c *CubeDemo) createDescriptorSetLayout() *vkg.DescriptorSetLayout {
 
 }
 
-func (c *CubeDemo) MakeCommandBuffer(buffer *vkg.CommandBuffer, frame int) {
+func (c *CubeDemo) MakeCommandBuffer(buffer vkg.CommandRecorder, frame int) {
 	// notice the 'frame' parameter in our function signature?
 	// it's required because we are rotating through a small number of
 	// frame buffers. So if we build a command buffer with the same
@@ -352,48 +361,32 @@ func (c *CubeDemo) MakeCommandBuffer(buffer *vkg.CommandBuffer, frame int) {
 	buffer.Reset()
 
 	// clear values are used to clear the screen and depth buffer
-	clearValues := make([]vk.ClearValue, 2)
-	clearValues[0].SetColor([]float32{0.2, 0.2, 0.2, 1})
-	clearValues[1].SetDepthStencil(1, 0)
+	clearColor := vk.NewClearValue([]float32{0.2, 0.2, 0.2, 1})
+	clearDepth := vk.NewClearDepthStencil(1, 0)
 
 	// begin recording commands
 	buffer.Begin()
 
-	// create a render pass struct
-	renderPassBeginInfo := vk.RenderPassBeginInfo{
-		SType:       vk.StructureTypeRenderPassBeginInfo,
-		RenderPass:  c.app.VKRenderPass,
-		Framebuffer: c.app.Framebuffers[frame],
-		RenderArea: vk.Rect2D{
-			Offset: vk.Offset2D{
-				X: 0, Y: 0,
-			},
-			Extent: c.app.GetScreenExtent(),
-		},
-		ClearValueCount: 2,
-		PClearValues:    clearValues,
-	}
-
-	vk.CmdBeginRenderPass(buffer.VK(), &renderPassBeginInfo, vk.SubpassContentsInline)
+	// begin a render pass which draws into the frame buffer for this frame
+	buffer.CmdBeginRenderPass(c.app.VKRenderPass, c.app.Framebuffers[frame], c.app.GetScreenExtent(),
+		vk.SubpassContentsInline, clearColor, clearDepth)
 
 	// we tell vulkan which graphics pipeline we want to use - the one we defined above
-	vk.CmdBindPipeline(buffer.VK(), vk.PipelineBindPointGraphics, c.app.GraphicsPipelines["cube"])
+	buffer.CmdBindGraphicsPipeline(c.app.GraphicsPipelines["cube"])
 
 	// tell it which buffer our vertex data comes from
-	vk.CmdBindVertexBuffers(buffer.VK(), 0, 1, []vk.Buffer{c.mesh.VertexResource.VKBuffer}, []vk.DeviceSize{0})
+	buffer.CmdBindVertexBuffers(0, c.mesh.VertexResource)
 
 	// tell it which buffer our index data comes from
-	vk.CmdBindIndexBuffer(buffer.VK(), c.mesh.IndexResource.VKBuffer, vk.DeviceSize(0), vk.IndexTypeUint16)
+	buffer.CmdBindIndexBuffer(c.mesh.IndexResource, c.mesh.IndexData)
 
 	// tell vulkan about our descriptor sets which feed data to our shaders
-	vk.CmdBindDescriptorSets(buffer.VK(), vk.PipelineBindPointGraphics,
-		c.pipelineLayout.VKPipelineLayout, 0, 1,
-		[]vk.DescriptorSet{c.mesh.descriptorSet.VKDescriptorSet}, 0, nil)
+	buffer.CmdBindDescriptorSets(vk.PipelineBindPointGraphics, c.pipelineLayout, 0, c.mesh.descriptorSet)
 
 	// lastly tell vulkan which indexes to draw
-	vk.CmdDrawIndexed(buffer.VK(), uint32(len(c.mesh.IndexData)), 1, 0, 0, 0)
+	buffer.CmdDrawIndexed(len(c.mesh.IndexData), 1, 0, 0, 0)
 
-	vk.CmdEndRenderPass(buffer.VK())
+	buffer.CmdEndRenderPass()
 
 	buffer.End()
 }
@@ -422,6 +415,7 @@ func (c *CubeDemo) Destroy() {
 }
 
 func main() {
+	flag.Parse()
 	c := &CubeDemo{}
 	c.run()
 }
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+
+	vkg "github.com/celer/vkg"
+)
+
+const OBJECTS = 4096
+const WORKGROUP_SIZE = 64
+
+func orPanic(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Object matches the Object struct in shaders/cull.comp
+type Object struct {
+	Sphere       [4]float32
+	IndexCount   uint32
+	FirstIndex   uint32
+	VertexOffset int32
+	pad          uint32
+}
+
+// CullConstants matches the push constants in shaders/cull.comp
+type CullConstants struct {
+	Planes      [6][4]float32
+	ObjectCount uint32
+}
+
+// frustumPlanes returns the inward facing planes of a camera at the origin looking down -Z
+func frustumPlanes(fovY, aspect, near, far float64) [6][4]float32 {
+	v := fovY / 2
+	h := math.Atan(math.Tan(v) * aspect)
+	return [6][4]float32{
+		{float32(math.Cos(h)), 0, float32(-math.Sin(h)), 0},
+		{float32(-math.Cos(h)), 0, float32(-math.Sin(h)), 0},
+		{0, float32(math.Cos(v)), float32(-math.Sin(v)), 0},
+		{0, float32(-math.Cos(v)), float32(-math.Sin(v)), 0},
+		{0, 0, -1, float32(-near)},
+		{0, 0, 1, float32(far)},
+	}
+}
+
+func visible(planes [6][4]float32, o Object) bool {
+	for _, p := range planes {
+		if p[0]*o.Sphere[0]+p[1]*o.Sphere[1]+p[2]*o.Sphere[2]+p[3] < -o.Sphere[3] {
+			return false
+		}
+	}
+	return true
+}
+
+func main() {
+
+	err := vkg.InitializeForComputeOnly()
+	orPanic(err)
+
+	app := vkg.App{
+		Name: "Culling",
+	}
+
+	app.EnableDebugging()
+
+	instance, err := app.CreateInstance()
+	orPanic(err)
+
+	pdevices, err := instance.PhysicalDevices()
+	orPanic(err)
+
+	if len(pdevices) == 0 {
+		panic("no physical devices found")
+	}
+
+	pdevice := pdevices[0]
+
+	queues, err := pdevice.QueueFamilies()
+	orPanic(err)
+
+	ldevice, err := pdevice.CreateLogicalDevice(queues.FilterCompute())
+	orPanic(err)
+
+	computeQueue := ldevice.GetQueue(queues.FilterCompute()[0])
+
+	// A grid of objects spread around the camera, only some of which are inside the frustum
+	objects := make([]Object, OBJECTS)
+	for i := range objects {
+		x := float32(i%64) - 32
+		z := float32(i/64) - 32
+		objects[i] = Object{
+			Sphere:     [4]float32{x * 2, float32(i%7) - 3, z * 2, 0.75},
+			IndexCount: 36,
+			FirstIndex: uint32(i%4) * 36,
+		}
+	}
+
+	rm := ldevice.CreateResourceManager()
+
+	rpool, err := rm.AllocateBufferPoolWithOptions("culling", 1024*1024,
+		vk.MemoryPropertyHostCoherentBit|vk.MemoryPropertyHostVisibleBit,
+		vk.BufferUsageStorageBufferBit|vk.BufferUsageIndirectBufferBit|vk.BufferUsageTransferDstBit, vk.SharingModeExclusive)
+	orPanic(err)
+
+	_, err = rpool.Memory.Map()
+	orPanic(err)
+
+	objectBytes := len(objects) * int(unsafe.Sizeof(Object{}))
+	ores, err := rpool.AllocateBuffer(uint64(objectBytes), vk.BufferUsageStorageBufferBit)
+	orPanic(err)
+	copy(ores.Bytes(), vkg.ToBytes(unsafe.Pointer(&objects[0]), objectBytes))
+
+	// The shader appends a draw for each visible object
+	draws, err := rpool.AllocateIndirectBuffer(vkg.IndirectDrawIndexed, OBJECTS)
+	orPanic(err)
+
+	// The culling pass itself is dispatched indirectly, the group count could be written by an earlier pass
+	dispatch, err := rpool.AllocateIndirectBuffer(vkg.IndirectDispatch, 1)
+	orPanic(err)
+	err = dispatch.SetDispatchCommands([]vkg.DispatchIndirectCommand{
+		{X: uint32((OBJECTS + WORKGROUP_SIZE - 1) / WORKGROUP_SIZE), Y: 1, Z: 1},
+	})
+	orPanic(err)
+
+	dsl := &vkg.DescriptorSetLayout{}
+	for binding := 0; binding < 2; binding++ {
+		dsl.AddBinding(vk.DescriptorSetLayoutBinding{
+			Binding:         uint32(binding),
+			DescriptorType:  vk.DescriptorTypeStorageBuffer,
+			DescriptorCount: 1,
+			StageFlags:      vk.ShaderStageFlags(vk.ShaderStageComputeBit),
+		})
+	}
+
+	dsl, err = ldevice.CreateDescriptorSetLayout(dsl)
+	orPanic(err)
+
+	dpool := ldevice.NewDescriptorPool()
+	dpool.AddPoolSize(vk.DescriptorTypeStorageBuffer, 2)
+
+	_, err = ldevice.CreateDescriptorPool(dpool, 1)
+	orPanic(err)
+
+	dset, err := dpool.Allocate(dsl)
+	orPanic(err)
+
+	dset.AddBuffer(0, vk.DescriptorTypeStorageBuffer, &ores.Buffer, 0)
+	dset.AddBuffer(1, vk.DescriptorTypeStorageBuffer, &draws.Resource.Buffer, 0)
+
+	dset.Write()
+
+	shader, err := ldevice.LoadShaderModuleFromFile("shaders/cull.comp.spv")
+	orPanic(err)
+
+	constants := CullConstants{
+		Planes:      frustumPlanes(math.Pi/3, 16.0/9.0, 0.1, 50),
+		ObjectCount: OBJECTS,
+	}
+	constantBytes := vkg.ToBytes(unsafe.Pointer(&constants), int(unsafe.Sizeof(constants)))
+
+	pipelineLayout, err := ldevice.CreatePipelineLayoutWithPushConstants([]*vkg.DescriptorSetLayout{dsl}, []vk.PushConstantRange{
+		{
+			StageFlags: vk.ShaderStageFlags(vk.ShaderStageComputeBit),
+			Offset:     0,
+			Size:       uint32(len(constantBytes)),
+		},
+	})
+	orPanic(err)
+
+	computePipeline := &vkg.ComputePipeline{}
+	computePipeline.SetShaderStage("main", shader)
+	computePipeline.SetPipelineLayout(pipelineLayout)
+
+	cache, err := ldevice.CreatePipelineCache()
+	orPanic(err)
+
+	err = ldevice.CreateComputePipelines(cache, computePipeline)
+	orPanic(err)
+
+	cpool, err := ldevice.CreateCommandPool(queues.FilterCompute()[0])
+	orPanic(err)
+
+	cb, err := cpool.AllocateBuffer(vk.CommandBufferLevelPrimary)
+	orPanic(err)
+
+	err = cb.BeginOneTime()
+	orPanic(err)
+
+	orPanic(cb.Require(draws.Resource, vkg.UsageTransferDst))
+	orPanic(draws.CmdResetCount(cb))
+	orPanic(cb.Require(draws.Resource, vkg.UsageStorageWrite))
+	orPanic(cb.Require(dispatch.Resource, vkg.UsageIndirectBuffer))
+
+	cb.CmdBindComputePipeline(computePipeline)
+	cb.CmdBindDescriptorSets(vk.PipelineBindPointCompute, pipelineLayout, 0, dset)
+	cb.CmdPushConstants(pipelineLayout, vk.ShaderStageFlags(vk.ShaderStageComputeBit), 0, constantBytes)
+	orPanic(dispatch.CmdDispatch(cb, 0))
+
+	// A renderer would require vkg.UsageIndirectBuffer here and draw with draws.CmdDrawCount
+	orPanic(cb.Require(draws.Resource, vkg.UsageHostRead))
+
+	orPanic(cb.End())
+
+	fence, err := ldevice.CreateFence()
+	orPanic(err)
+
+	orPanic(computeQueue.SubmitWithFence(fence, cb))
+
+	orPanic(ldevice.WaitForFences(true, 10*time.Second, fence))
+
+	count, err := draws.Count()
+	orPanic(err)
+
+	commands, err := draws.DrawIndexedCommands(count)
+	orPanic(err)
+
+	expected := 0
+	for _, o := range objects {
+		if visible(constants.Planes, o) {
+			expected++
+		}
+	}
+	fmt.Printf("%d of %d objects are visible, %d expected\n", count, OBJECTS, expected)
+	for _, c := range commands {
+		if !visible(constants.Planes, objects[c.FirstInstance]) {
+			fmt.Printf("object %d was drawn but is outside the frustum\n", c.FirstInstance)
+		}
+	}
+
+	cpool.FreeBuffer(cb)
+
+	rpool.Memory.Unmap()
+	draws.Free()
+	dispatch.Free()
+	ores.Free()
+	fence.Destroy()
+	rpool.Destroy()
+	dpool.Free(dset)
+	dpool.Destroy()
+	pipelineLayout.Destroy()
+	computePipeline.Destroy()
+	cache.Destroy()
+	dsl.Destroy()
+	cpool.Destroy()
+	shader.Destroy()
+	ldevice.Destroy()
+	instance.Destroy()
+
+}
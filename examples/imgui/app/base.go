@@ -14,7 +14,7 @@ type IGraphicsModule interface {
 	NewFrame(base *AppBase)
 	PostFrame()
 	Destroy()
-	CreateCommandBuffers(renderPass vk.RenderPass, framebuffer vk.Framebuffer, app *AppBase) ([]vk.CommandBuffer, error)
+	CreateCommandBuffers(renderPass vk.RenderPass, framebuffer vk.Framebuffer, app *AppBase) ([]*vkg.CommandBuffer, error)
 }
 
 type IInputModule interface {
@@ -29,8 +29,6 @@ type AppBase struct {
 
 	GraphicsModules []IGraphicsModule
 	InputModules    []IInputModule
-
-	priorCommandBuffers []vk.CommandBuffer
 }
 
 func NewAppBase(appName string, width, height int) (*AppBase, error) {
@@ -112,7 +110,7 @@ func (b *AppBase) Init() error {
 		return fmt.Errorf("unable to initialize vulkan instance: %w", err)
 	}
 
-	b.MakeCommandBuffer = func(buffer *vkg.CommandBuffer, frame int) {
+	b.MakeCommandBuffer = func(buffer vkg.CommandRecorder, frame int) {
 		b.makeCommandBuffers(buffer, frame)
 	}
 
@@ -138,36 +136,17 @@ func (b *AppBase) AddInputModule(i IInputModule) {
 	b.InputModules = append(b.InputModules, i)
 }
 
-func (b *AppBase) makeCommandBuffers(buffer *vkg.CommandBuffer, frame int) {
-
-	clearValues := make([]vk.ClearValue, 2)
+func (b *AppBase) makeCommandBuffers(buffer vkg.CommandRecorder, frame int) {
 
-	clearValues[0].SetColor([]float32{0.2, 0.2, 0.2, 1})
-	clearValues[1].SetDepthStencil(1, 0)
+	clearColor := vk.NewClearValue([]float32{0.2, 0.2, 0.2, 1})
+	clearDepth := vk.NewClearDepthStencil(1, 0)
 
 	buffer.Begin()
 
-	renderPassBeginInfo := vk.RenderPassBeginInfo{
-		SType:       vk.StructureTypeRenderPassBeginInfo,
-		RenderPass:  b.VKRenderPass,
-		Framebuffer: b.Framebuffers[frame],
-		RenderArea: vk.Rect2D{
-			Offset: vk.Offset2D{
-				X: 0, Y: 0,
-			},
-			Extent: b.GetScreenExtent(),
-		},
-		ClearValueCount: 2,
-		PClearValues:    clearValues,
-	}
-
-	vk.CmdBeginRenderPass(buffer.VK(), &renderPassBeginInfo, vk.SubpassContentsSecondaryCommandBuffers)
-
-	if len(b.priorCommandBuffers) > 0 {
-		vk.FreeCommandBuffers(b.Device.VKDevice, b.GraphicsCommandPool.VKCommandPool, uint32(len(b.priorCommandBuffers)), b.priorCommandBuffers)
-	}
+	buffer.CmdBeginRenderPass(b.VKRenderPass, b.Framebuffers[frame], b.GetScreenExtent(),
+		vk.SubpassContentsSecondaryCommandBuffers, clearColor, clearDepth)
 
-	buffers := make([]vk.CommandBuffer, 0)
+	buffers := make([]*vkg.CommandBuffer, 0)
 	for _, g := range b.GraphicsModules {
 		cmds, err := g.CreateCommandBuffers(b.VKRenderPass, b.Framebuffers[frame], b)
 		if err != nil {
@@ -175,13 +154,12 @@ func (b *AppBase) makeCommandBuffers(buffer *vkg.CommandBuffer, frame int) {
 		}
 		buffers = append(buffers, cmds...)
 	}
-	b.priorCommandBuffers = buffers
 
 	if len(buffers) > 0 {
-		vk.CmdExecuteCommands(buffer.VK(), uint32(len(buffers)), buffers)
+		buffer.CmdExecuteCommands(buffers...)
 	}
 
-	vk.CmdEndRenderPass(buffer.VK())
+	buffer.CmdEndRenderPass()
 	buffer.End()
 }
 
@@ -284,14 +284,14 @@ func (c *CubeModule) Destroy() {
 	c.pipelineLayout.Destroy()
 	c.descriptorPool.Destroy()
 }
-func (c *CubeModule) CreateCommandBuffers(renderPass vk.RenderPass, framebuffer vk.Framebuffer, app *app.AppBase) ([]vk.CommandBuffer, error) {
+func (c *CubeModule) CreateCommandBuffers(renderPass vk.RenderPass, framebuffer vk.Framebuffer, app *app.AppBase) ([]*vkg.CommandBuffer, error) {
 	if c.spin {
 
 		c.mesh.UpdateUBO(app)
 
 	}
 
-	buffer, err := app.GraphicsCommandPool.AllocateBuffer(vk.CommandBufferLevelSecondary)
+	buffer, err := app.CommandPools.Allocate(app.CurrentFrame(), 0, vk.CommandBufferLevelSecondary)
 	if err != nil {
 		return nil, err
 	}
@@ -309,6 +309,6 @@ func (c *CubeModule) CreateCommandBuffers(renderPass vk.RenderPass, framebuffer
 	vk.CmdDrawIndexed(buffer.VK(), uint32(len(c.mesh.IndexData)), 1, 0, 0, 0)
 
 	buffer.End()
-	return []vk.CommandBuffer{buffer.VK()}, nil
+	return []*vkg.CommandBuffer{buffer}, nil
 
 }
@@ -3,6 +3,7 @@ package imgui
 import (
 	"math"
 
+	"github.com/celer/vkg"
 	"github.com/celer/vkg/examples/imgui/app"
 
 	"github.com/inkyblackness/imgui-go"
@@ -98,7 +99,7 @@ func (i *ImGUIModule) Destroy() {
 	i.renderer.Destroy()
 }
 
-func (i *ImGUIModule) CreateCommandBuffers(renderPass vk.RenderPass, framebuffer vk.Framebuffer, app *app.AppBase) ([]vk.CommandBuffer, error) {
+func (i *ImGUIModule) CreateCommandBuffers(renderPass vk.RenderPass, framebuffer vk.Framebuffer, app *app.AppBase) ([]*vkg.CommandBuffer, error) {
 	extent := app.GetScreenExtent()
 	i.io.SetDisplaySize(imgui.Vec2{X: float32(extent.Width), Y: float32(extent.Height)})
 	imgui.NewFrame()
@@ -30,7 +30,7 @@ type Renderer struct {
 
 	fontBuffer  *vkg.ImageResource
 	fontView    vk.ImageView
-	fontSampler vk.Sampler
+	fontSampler *vkg.Sampler
 
 	maxVertexes int
 	maxIndexes  int
@@ -138,7 +138,7 @@ func (r *Renderer) setupUBO() {
 
 }
 
-func (r *Renderer) Render(renderpass vk.RenderPass, framebuffer vk.Framebuffer, drawData imgui.DrawData) ([]vk.CommandBuffer, error) {
+func (r *Renderer) Render(renderpass vk.RenderPass, framebuffer vk.Framebuffer, drawData imgui.DrawData) ([]*vkg.CommandBuffer, error) {
 
 	extent := r.app.GetScreenExtent()
 
@@ -157,12 +157,12 @@ func (r *Renderer) Render(renderpass vk.RenderPass, framebuffer vk.Framebuffer,
 		indexType = vk.IndexTypeUint32
 	}
 
-	buffers := make([]vk.CommandBuffer, 0)
+	buffers := make([]*vkg.CommandBuffer, 0)
 
 	//fmt.Printf("drawData.CommandList()\n")
 	for _, list := range drawData.CommandLists() {
 
-		cmdb, err := r.app.GraphicsCommandPool.AllocateBuffer(vk.CommandBufferLevelSecondary)
+		cmdb, err := r.app.CommandPools.Allocate(r.app.CurrentFrame(), 0, vk.CommandBufferLevelSecondary)
 		if err != nil {
 			return nil, err
 		}
@@ -235,7 +235,7 @@ func (r *Renderer) Render(renderpass vk.RenderPass, framebuffer vk.Framebuffer,
 			offset += cmd.ElementCount()
 		}
 		cmdb.End()
-		buffers = append(buffers, cmdb.VK())
+		buffers = append(buffers, cmdb)
 	}
 
 	return buffers, nil
@@ -277,7 +277,7 @@ func (r *Renderer) Destroy() {
 	r.freeTransientBuffers()
 
 	r.app.Device.DestroyAny(r.fontView)
-	r.app.Device.DestroyAny(r.fontSampler)
+	r.fontSampler.Destroy()
 
 	r.descriptorPool.Destroy()
 	//r.pipelineLayout.Destroy()
@@ -430,21 +430,10 @@ func (r *Renderer) createFontTexture() error {
 		return err
 	}
 
-	var sampler vk.Sampler
-	vk.CreateSampler(r.app.Device.VKDevice, &vk.SamplerCreateInfo{
-		SType:                   vk.StructureTypeSamplerCreateInfo,
-		MagFilter:               vk.FilterLinear,
-		MinFilter:               vk.FilterLinear,
-		MipmapMode:              vk.SamplerMipmapModeLinear,
-		AddressModeU:            vk.SamplerAddressModeRepeat,
-		AddressModeV:            vk.SamplerAddressModeRepeat,
-		AddressModeW:            vk.SamplerAddressModeRepeat,
-		AnisotropyEnable:        vk.False,
-		MaxAnisotropy:           1,
-		CompareOp:               vk.CompareOpAlways,
-		BorderColor:             vk.BorderColorIntOpaqueBlack,
-		UnnormalizedCoordinates: vk.False,
-	}, nil, &sampler)
+	sampler, err := r.app.Device.CreateSampler(vkg.DefaultSamplerConfig())
+	if err != nil {
+		return err
+	}
 
 	r.fontSampler = sampler
 	r.fontView = imageView.VKImageView
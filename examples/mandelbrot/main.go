@@ -1,12 +1,11 @@
 package main
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"image/png"
-	"math"
 	"os"
-	"time"
 	"unsafe"
 
 	vk "github.com/vulkan-go/vulkan"
@@ -16,7 +15,9 @@ import (
 
 const WIDTH = 3200
 const HEIGHT = 2400
-const WORKGROUP_SIZE = 32
+
+// WORKGROUP_SIZE is the width and height of the shader's workgroups, set with specialization constants
+const WORKGROUP_SIZE = 16
 
 func orPanic(err error) {
 	if err != nil {
@@ -74,70 +75,14 @@ func main() {
 	bres, err := rpool.AllocateBuffer(bytesNeeded, vk.BufferUsageStorageBufferBit)
 	orPanic(err)
 
-	dsl := &vkg.DescriptorSetLayout{}
-
-	dsl.AddBinding(vk.DescriptorSetLayoutBinding{
-		Binding:         0,
-		DescriptorType:  vk.DescriptorTypeStorageBuffer,
-		DescriptorCount: 1,
-		StageFlags:      vk.ShaderStageFlags(vk.ShaderStageComputeBit),
-	})
-
-	dsl, err = ldevice.CreateDescriptorSetLayout(dsl)
-
-	dpool := ldevice.NewDescriptorPool()
-	dpool.AddPoolSize(vk.DescriptorTypeStorageBuffer, 1)
-
-	_, err = ldevice.CreateDescriptorPool(dpool, 1)
-	orPanic(err)
-
-	dset, err := dpool.Allocate(dsl)
-	orPanic(err)
-
-	dset.AddBuffer(0, vk.DescriptorTypeStorageBuffer, &bres.Buffer, 0)
-
-	dset.Write()
-
-	shader, err := ldevice.LoadShaderModuleFromFile("shaders/comp.spv")
-	orPanic(err)
-
-	pipelineLayout, err := ldevice.CreatePipelineLayout(dsl)
-	orPanic(err)
-
-	computePipeline := &vkg.ComputePipeline{}
-	computePipeline.SetShaderStage("main", shader)
-	computePipeline.SetPipelineLayout(pipelineLayout)
-
-	cache, err := ldevice.CreatePipelineCache()
-	orPanic(err)
-
-	err = ldevice.CreateComputePipelines(cache, computePipeline)
-	orPanic(err)
-
-	cpool, err := ldevice.CreateCommandPool(queues.FilterCompute()[0])
-	orPanic(err)
-
-	cb, err := cpool.AllocateBuffer(vk.CommandBufferLevelPrimary)
+	constants := vkg.NewSpecializationConstants().SetUint32(0, WORKGROUP_SIZE).SetUint32(1, WORKGROUP_SIZE)
+	kernel, err := ldevice.LoadKernelFromFile(computeQueue, "shaders/comp.spv", &vkg.KernelOptions{SpecializationConstants: constants})
 	orPanic(err)
 
-	err = cb.BeginOneTime()
-	orPanic(err)
-
-	cb.CmdBindComputePipeline(computePipeline)
-	cb.CmdBindDescriptorSets(vk.PipelineBindPointCompute, pipelineLayout, 0, dset)
-	cb.CmdDispatch(int(math.Ceil(float64(WIDTH/float32(WORKGROUP_SIZE)))),
-		int(math.Ceil(float64(HEIGHT/float32(WORKGROUP_SIZE)))), 1)
-
-	cb.End()
-
-	fence, err := ldevice.CreateFence()
-	orPanic(err)
-
-	computeQueue.SubmitWithFence(fence, cb)
-
-	ldevice.WaitForFences(true, 10*time.Second, fence)
+	orPanic(kernel.BindBuffer("buf", bres))
 
-	cpool.FreeBuffer(cb)
+	// The workgroup size is reflected from the shader and its specialization constants
+	orPanic(kernel.Dispatch(context.Background(), WIDTH, HEIGHT, 1))
 
 	rpool.Memory.Map()
 
@@ -147,16 +92,8 @@ func main() {
 
 	rpool.Memory.Unmap()
 	bres.Free()
-	fence.Destroy()
 	rpool.Destroy()
-	dpool.Free(dset)
-	dpool.Destroy()
-	pipelineLayout.Destroy()
-	computePipeline.Destroy()
-	cache.Destroy()
-	dsl.Destroy()
-	cpool.Destroy()
-	shader.Destroy()
+	kernel.Destroy()
 	ldevice.Destroy()
 	instance.Destroy()
 
@@ -38,31 +38,16 @@ func main() {
 	err = app.Init()
 	orPanic(err)
 
-	app.MakeCommandBuffer = func(buffer *vkg.CommandBuffer, frame int) {
+	app.MakeCommandBuffer = func(buffer vkg.CommandRecorder, frame int) {
 
-		clearValues := make([]vk.ClearValue, 2)
-
-		clearValues[0].SetColor([]float32{0.2, 0.2, 0.2, 1})
-		clearValues[1].SetDepthStencil(1, 0)
+		clearColor := vk.NewClearValue([]float32{0.2, 0.2, 0.2, 1})
+		clearDepth := vk.NewClearDepthStencil(1, 0)
 
 		buffer.Begin()
 
-		renderPassBeginInfo := vk.RenderPassBeginInfo{
-			SType:       vk.StructureTypeRenderPassBeginInfo,
-			RenderPass:  app.VKRenderPass,
-			Framebuffer: app.Framebuffers[frame],
-			RenderArea: vk.Rect2D{
-				Offset: vk.Offset2D{
-					X: 0, Y: 0,
-				},
-				Extent: app.GetScreenExtent(),
-			},
-			ClearValueCount: 2,
-			PClearValues:    clearValues,
-		}
-
-		vk.CmdBeginRenderPass(buffer.VK(), &renderPassBeginInfo, vk.SubpassContentsInline)
-		vk.CmdEndRenderPass(buffer.VK())
+		buffer.CmdBeginRenderPass(app.VKRenderPass, app.Framebuffers[frame], app.GetScreenExtent(),
+			vk.SubpassContentsInline, clearColor, clearDepth)
+		buffer.CmdEndRenderPass()
 		buffer.End()
 
 	}
@@ -102,7 +102,7 @@ type Mesh struct {
 	descriptorSet *vkg.DescriptorSet
 
 	textureView    vk.ImageView
-	textureSampler vk.Sampler
+	textureSampler *vkg.Sampler
 }
 
 func init() {
@@ -252,21 +252,8 @@ func (c *CubeDemo) loadTexture() {
 	imageView, err := textureResource.CreateImageViewWithAspectMask(vk.ImageAspectFlags(vk.ImageAspectColorBit))
 	orPanic(err)
 
-	var sampler vk.Sampler
-	vk.CreateSampler(c.app.Device.VKDevice, &vk.SamplerCreateInfo{
-		SType:                   vk.StructureTypeSamplerCreateInfo,
-		MagFilter:               vk.FilterLinear,
-		MinFilter:               vk.FilterLinear,
-		MipmapMode:              vk.SamplerMipmapModeLinear,
-		AddressModeU:            vk.SamplerAddressModeRepeat,
-		AddressModeV:            vk.SamplerAddressModeRepeat,
-		AddressModeW:            vk.SamplerAddressModeRepeat,
-		AnisotropyEnable:        vk.False,
-		MaxAnisotropy:           1,
-		CompareOp:               vk.CompareOpAlways,
-		BorderColor:             vk.BorderColorIntOpaqueBlack,
-		UnnormalizedCoordinates: vk.False,
-	}, nil, &sampler)
+	sampler, err := c.app.Device.CreateSampler(vkg.DefaultSamplerConfig())
+	orPanic(err)
 
 	c.mesh.textureSampler = sampler
 	c.mesh.textureView = imageView.VKImageView
@@ -404,46 +391,29 @@ func (c *CubeDemo) createDescriptorSet() {
 
 }
 
-func (c *CubeDemo) MakeCommandBuffer(buffer *vkg.CommandBuffer, frame int) {
+func (c *CubeDemo) MakeCommandBuffer(buffer vkg.CommandRecorder, frame int) {
 
 	c.mesh.UpdateUBO(c.app)
 
 	buffer.Reset()
 
-	clearValues := make([]vk.ClearValue, 2)
-
-	clearValues[0].SetColor([]float32{0.2, 0.2, 0.2, 1})
-	clearValues[1].SetDepthStencil(1, 0)
+	clearColor := vk.NewClearValue([]float32{0.2, 0.2, 0.2, 1})
+	clearDepth := vk.NewClearDepthStencil(1, 0)
 
 	buffer.Begin()
 
-	renderPassBeginInfo := vk.RenderPassBeginInfo{
-		SType:       vk.StructureTypeRenderPassBeginInfo,
-		RenderPass:  c.app.VKRenderPass,
-		Framebuffer: c.app.Framebuffers[frame],
-		RenderArea: vk.Rect2D{
-			Offset: vk.Offset2D{
-				X: 0, Y: 0,
-			},
-			Extent: c.app.GetScreenExtent(),
-		},
-		ClearValueCount: 2,
-		PClearValues:    clearValues,
-	}
-
-	vk.CmdBeginRenderPass(buffer.VK(), &renderPassBeginInfo, vk.SubpassContentsInline)
+	buffer.CmdBeginRenderPass(c.app.VKRenderPass, c.app.Framebuffers[frame], c.app.GetScreenExtent(),
+		vk.SubpassContentsInline, clearColor, clearDepth)
 
-	vk.CmdBindPipeline(buffer.VK(), vk.PipelineBindPointGraphics, c.app.GraphicsPipelines["cube"])
+	buffer.CmdBindGraphicsPipeline(c.app.GraphicsPipelines["cube"])
 
-	vk.CmdBindVertexBuffers(buffer.VK(), 0, 1, []vk.Buffer{c.mesh.VertexResource.VKBuffer}, []vk.DeviceSize{0})
+	buffer.CmdBindVertexBuffers(0, c.mesh.VertexResource)
 
-	vk.CmdBindDescriptorSets(buffer.VK(), vk.PipelineBindPointGraphics,
-		c.pipelineLayout.VKPipelineLayout, 0, 1,
-		[]vk.DescriptorSet{c.mesh.descriptorSet.VKDescriptorSet}, 0, nil)
+	buffer.CmdBindDescriptorSets(vk.PipelineBindPointGraphics, c.pipelineLayout, 0, c.mesh.descriptorSet)
 
-	vk.CmdDraw(buffer.VK(), uint32(len(c.mesh.VertexData)), 1, 0, 0)
+	buffer.CmdDraw(len(c.mesh.VertexData), 1, 0, 0)
 
-	vk.CmdEndRenderPass(buffer.VK())
+	buffer.CmdEndRenderPass()
 
 	buffer.End()
 }
@@ -0,0 +1,333 @@
+package vkg
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// FutureStatus is the state of GPU work tracked by a Future
+type FutureStatus int
+
+const (
+	// FuturePending is work which has been submitted but not yet completed
+	FuturePending FutureStatus = iota
+	// FutureComplete is work which has completed
+	FutureComplete
+	// FutureFailed is work whose completion could not be determined, typically because the device was lost
+	FutureFailed
+)
+
+func (s FutureStatus) String() string {
+	switch s {
+	case FuturePending:
+		return "Pending"
+	case FutureComplete:
+		return "Complete"
+	case FutureFailed:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+// Future is a handle to submitted GPU work, Done can be used in a select alongside other channels
+//
+//	select {
+//	case <-future.Done():
+//	case <-ctx.Done():
+//	}
+type Future struct {
+	fence *Fence
+	done  chan struct{}
+
+	mutex  sync.Mutex
+	status FutureStatus
+	err    error
+}
+
+func newFuture(fence *Fence) *Future {
+	return &Future{fence: fence, done: make(chan struct{})}
+}
+
+// Done returns a channel which is closed once the work has completed or failed
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Status returns the current status of the work
+func (f *Future) Status() FutureStatus {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.status
+}
+
+// Err returns the error the work failed with, it is nil while the work is pending or once it has completed
+func (f *Future) Err() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.err
+}
+
+// Wait blocks until the work completes or the context is done, cancelling the context does not cancel
+// the work on the GPU
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Future) complete(err error) {
+	f.mutex.Lock()
+	if f.status != FuturePending {
+		f.mutex.Unlock()
+		return
+	}
+	f.status = FutureComplete
+	if err != nil {
+		f.status = FutureFailed
+		f.err = err
+	}
+	f.mutex.Unlock()
+	close(f.done)
+}
+
+// DefaultFencePollInterval is the longest a FencePool waits on the device before checking for new work
+const DefaultFencePollInterval = 5 * time.Millisecond
+
+// minFencePollInterval stops a FencePool spinning on the device when PollInterval is zero
+const minFencePollInterval = time.Millisecond
+
+// FencePool recycles fences for submissions and completes their futures from a background goroutine,
+// so Go code doesn't have to block on the device to learn when work has completed
+type FencePool struct {
+	Device *Device
+	// PollInterval is the longest the poller waits on the device before picking up newly submitted work,
+	// intervals shorter than a millisecond are raised to a millisecond
+	PollInterval time.Duration
+
+	// createFence, waitFences, fenceStatus, resetFence and destroyFence are replaced by tests
+	createFence  func() (*Fence, error)
+	waitFences   func(fences []*Fence, waitForAll bool, timeout time.Duration)
+	fenceStatus  func(fence *Fence) vk.Result
+	resetFence   func(fence *Fence) vk.Result
+	destroyFence func(fence *Fence)
+
+	mutex   sync.Mutex
+	free    []*Fence
+	pending []*Future
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// CreateFencePool creates a fence pool and starts its poller, Destroy must be called to stop it
+func (d *Device) CreateFencePool() *FencePool {
+	var ret FencePool
+	ret.Device = d
+	ret.PollInterval = DefaultFencePollInterval
+	ret.createFence = d.CreateFence
+	ret.waitFences = ret.vkWaitFences
+	ret.fenceStatus = ret.vkFenceStatus
+	ret.resetFence = ret.vkResetFence
+	ret.destroyFence = (*Fence).Destroy
+	ret.start()
+	return &ret
+}
+
+func (p *FencePool) start() {
+	p.wake = make(chan struct{}, 1)
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	go p.poll()
+}
+
+// vkWaitFences waits for the fences to be signaled, a negative timeout waits forever
+func (p *FencePool) vkWaitFences(fences []*Fence, waitForAll bool, timeout time.Duration) {
+	vkFences := make([]vk.Fence, len(fences))
+	for i, f := range fences {
+		vkFences[i] = f.VKFence
+	}
+	all := vk.False
+	if waitForAll {
+		all = vk.True
+	}
+	ns := uint64(timeout.Nanoseconds())
+	if timeout < 0 {
+		ns = vk.MaxUint64
+	}
+	vk.WaitForFences(p.Device.VKDevice, uint32(len(vkFences)), vkFences, vk.Bool32(all), ns)
+}
+
+func (p *FencePool) vkFenceStatus(fence *Fence) vk.Result {
+	return vk.GetFenceStatus(p.Device.VKDevice, fence.VKFence)
+}
+
+func (p *FencePool) vkResetFence(fence *Fence) vk.Result {
+	return vk.ResetFences(p.Device.VKDevice, 1, []vk.Fence{fence.VKFence})
+}
+
+// pollInterval returns PollInterval raised to the minimum interval
+func (p *FencePool) pollInterval() time.Duration {
+	if p.PollInterval < minFencePollInterval {
+		return minFencePollInterval
+	}
+	return p.PollInterval
+}
+
+// Acquire returns an unsignaled fence from the pool, it is returned to the pool once the future
+// returned by Track completes
+func (p *FencePool) Acquire() (*Fence, error) {
+	p.mutex.Lock()
+	if n := len(p.free); n > 0 {
+		f := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.mutex.Unlock()
+		return f, nil
+	}
+	p.mutex.Unlock()
+	return p.createFence()
+}
+
+// Track returns a future which completes once the fence, acquired from this pool, has been signaled
+func (p *FencePool) Track(fence *Fence) *Future {
+	future := newFuture(fence)
+	p.mutex.Lock()
+	p.pending = append(p.pending, future)
+	p.mutex.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return future
+}
+
+// release returns a fence which failed to be submitted to the pool
+func (p *FencePool) release(fence *Fence) {
+	p.mutex.Lock()
+	p.free = append(p.free, fence)
+	p.mutex.Unlock()
+}
+
+// SubmitAsync submits the command buffers to the queue, returning a future which completes with them
+func (p *FencePool) SubmitAsync(queue *Queue, buffers ...*CommandBuffer) (*Future, error) {
+	return queue.NewSubmit().Buffers(buffers...).SubmitAsync(p)
+}
+
+// SubmitAsync submits the batches with a fence from the pool, returning a future which completes once
+// all of the batches have completed. Any fence set on the submission is replaced.
+func (s *Submit) SubmitAsync(pool *FencePool) (*Future, error) {
+	fence, err := pool.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	err = s.Fence(fence).Submit()
+	if err != nil {
+		pool.release(fence)
+		return nil, err
+	}
+	return pool.Track(fence), nil
+}
+
+func (p *FencePool) poll() {
+	defer close(p.stopped)
+	for {
+		p.mutex.Lock()
+		pending := append([]*Future(nil), p.pending...)
+		p.mutex.Unlock()
+
+		if len(pending) == 0 {
+			select {
+			case <-p.wake:
+				continue
+			case <-p.stop:
+				return
+			}
+		}
+
+		fences := make([]*Fence, len(pending))
+		for i, f := range pending {
+			fences[i] = f.fence
+		}
+		// Sleep on the device until any of the fences are signaled, the result is checked per fence below
+		p.waitFences(fences, false, p.pollInterval())
+
+		p.update(pending)
+
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+	}
+}
+
+// update completes the futures whose fences have been signaled
+func (p *FencePool) update(pending []*Future) {
+	var completed []*Future
+	for _, f := range pending {
+		res := p.fenceStatus(f.fence)
+		switch res {
+		case vk.NotReady:
+			continue
+		case vk.Success:
+			completed = append(completed, f)
+			f.complete(nil)
+		default:
+			completed = append(completed, f)
+			f.complete(fmt.Errorf("unable to determine the status of submitted work: %w", vk.Error(res)))
+		}
+	}
+	if len(completed) == 0 {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	remaining := p.pending[:0]
+	for _, f := range p.pending {
+		if f.Status() == FuturePending {
+			remaining = append(remaining, f)
+			continue
+		}
+		if f.Status() == FutureComplete && p.resetFence(f.fence) == vk.Success {
+			p.free = append(p.free, f.fence)
+		} else {
+			p.destroyFence(f.fence)
+		}
+	}
+	p.pending = remaining
+}
+
+// Destroy stops the poller and destroys the pool's fences, work which is still pending is waited on
+// first and its futures are completed
+func (p *FencePool) Destroy() {
+	close(p.stop)
+	<-p.stopped
+
+	p.mutex.Lock()
+	pending := p.pending
+	p.mutex.Unlock()
+
+	if len(pending) > 0 {
+		fences := make([]*Fence, len(pending))
+		for i, f := range pending {
+			fences[i] = f.fence
+		}
+		p.waitFences(fences, true, -1)
+		p.update(pending)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, f := range p.free {
+		p.destroyFence(f)
+	}
+	p.free = nil
+}
@@ -0,0 +1,150 @@
+package vkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestFuture(t *testing.T) {
+	f := newFuture(nil)
+	if f.Status() != FuturePending {
+		t.Errorf("expected a pending future, got %v", f.Status())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := f.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+
+	f.complete(nil)
+	select {
+	case <-f.Done():
+	default:
+		t.Fatalf("expected the done channel to be closed")
+	}
+	if err := f.Wait(context.Background()); err != nil || f.Status() != FutureComplete {
+		t.Errorf("expected a completed future, got %v %v", f.Status(), err)
+	}
+
+	failed := newFuture(nil)
+	lost := errors.New("device lost")
+	failed.complete(lost)
+	failed.complete(nil)
+	if err := failed.Wait(context.Background()); !errors.Is(err, lost) || failed.Status() != FutureFailed {
+		t.Errorf("expected a failed future, got %v %v", failed.Status(), err)
+	}
+}
+
+// fakeFences stands in for the device in FencePool tests
+type fakeFences struct {
+	mutex     sync.Mutex
+	status    map[*Fence]vk.Result
+	created   int
+	destroyed []*Fence
+	timeouts  []time.Duration
+}
+
+func newTestFencePool(fakes *fakeFences) *FencePool {
+	fakes.status = make(map[*Fence]vk.Result)
+	return &FencePool{
+		createFence: func() (*Fence, error) {
+			fakes.mutex.Lock()
+			defer fakes.mutex.Unlock()
+			fakes.created++
+			f := &Fence{}
+			fakes.status[f] = vk.NotReady
+			return f, nil
+		},
+		waitFences: func(fences []*Fence, waitForAll bool, timeout time.Duration) {
+			fakes.mutex.Lock()
+			fakes.timeouts = append(fakes.timeouts, timeout)
+			fakes.mutex.Unlock()
+			time.Sleep(100 * time.Microsecond)
+		},
+		fenceStatus: func(f *Fence) vk.Result {
+			fakes.mutex.Lock()
+			defer fakes.mutex.Unlock()
+			return fakes.status[f]
+		},
+		resetFence: func(f *Fence) vk.Result {
+			fakes.mutex.Lock()
+			defer fakes.mutex.Unlock()
+			fakes.status[f] = vk.NotReady
+			return vk.Success
+		},
+		destroyFence: func(f *Fence) {
+			fakes.mutex.Lock()
+			defer fakes.mutex.Unlock()
+			fakes.destroyed = append(fakes.destroyed, f)
+		},
+	}
+}
+
+func (f *fakeFences) signal(fence *Fence, res vk.Result) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.status[fence] = res
+}
+
+func TestFencePoolRecycling(t *testing.T) {
+	fakes := &fakeFences{}
+	p := newTestFencePool(fakes)
+
+	a, _ := p.Acquire()
+	b, _ := p.Acquire()
+	fa, fb := p.Track(a), p.Track(b)
+
+	fakes.signal(a, vk.Success)
+	fakes.signal(b, vk.ErrorDeviceLost)
+	p.update(p.pending)
+
+	if fa.Status() != FutureComplete || fb.Status() != FutureFailed {
+		t.Fatalf("unexpected statuses %v %v", fa.Status(), fb.Status())
+	}
+	if len(p.pending) != 0 {
+		t.Errorf("expected no pending futures, got %d", len(p.pending))
+	}
+	// The failed fence can't be trusted so it is destroyed rather than recycled
+	if len(fakes.destroyed) != 1 || fakes.destroyed[0] != b {
+		t.Errorf("expected the failed fence to be destroyed, got %v", fakes.destroyed)
+	}
+
+	if c, _ := p.Acquire(); c != a || fakes.created != 2 {
+		t.Errorf("expected the completed fence to be reused")
+	}
+}
+
+func TestFencePoolPoller(t *testing.T) {
+	fakes := &fakeFences{}
+	p := newTestFencePool(fakes)
+	p.start()
+
+	fence, _ := p.Acquire()
+	future := p.Track(fence)
+	time.AfterFunc(5*time.Millisecond, func() { fakes.signal(fence, vk.Success) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := future.Wait(ctx); err != nil {
+		t.Fatalf("expected the poller to complete the future, got %v", err)
+	}
+
+	p.Destroy()
+	fakes.mutex.Lock()
+	defer fakes.mutex.Unlock()
+	// A zero PollInterval is raised to the minimum instead of spinning
+	for _, timeout := range fakes.timeouts {
+		if timeout != minFencePollInterval {
+			t.Fatalf("expected the minimum poll interval, got %v", timeout)
+		}
+	}
+	if len(fakes.destroyed) != 1 || fakes.destroyed[0] != fence {
+		t.Errorf("expected the free fence to be destroyed, got %v", fakes.destroyed)
+	}
+}
@@ -34,15 +34,59 @@ type GraphicsApp struct {
 	// Generated from GraphicsPipelineConfigs
 	GraphicsPipelines map[string]vk.Pipeline
 
+	// ComputePipelines are created by the application and added with AddComputePipeline so they are
+	// rebuilt when shaders are hot reloaded
+	ComputePipelines map[string]*ComputePipeline
+
+	// ShaderReloader if set watches shader files for changes, see EnableShaderHotReload
+	ShaderReloader *ShaderReloader
+
 	ResourceManager *ResourceManager
 
 	GraphicsQueue *Queue
 	PresentQueue  *Queue
+
+	// PipelineCache is created by PrepareToDraw and kept until the app is destroyed
 	PipelineCache *PipelineCache
 
+	// PipelineLibrary creates graphics pipeline variants for the app's render pass on demand, see GetPipeline.
+	// It is created by PrepareToDraw.
+	PipelineLibrary *PipelineLibrary
+
+	// PipelineCacheFile if set is the file the pipeline cache is loaded from by PrepareToDraw and saved
+	// to by Destroy, data from another device or driver is discarded
+	PipelineCacheFile string
+
+	// EnableAsyncCompute requests a queue from a compute family without graphics support so compute
+	// work can overlap rendering, it must be set before Init is called
+	EnableAsyncCompute bool
+	// EnableTransferQueue requests a queue from a transfer only family for uploads, it must be set
+	// before Init is called
+	EnableTransferQueue bool
+
+	// ComputeQueue and TransferQueue are the graphics queue if the device has no dedicated family or
+	// one was not requested, see SubmitCompute and SubmitTransfer
+	ComputeQueue  *Queue
+	TransferQueue *Queue
+
+	// ComputeCommandPool and TransferCommandPool allocate command buffers for the compute and
+	// transfer queues, they are the graphics command pool when the queue is the graphics queue
+	ComputeCommandPool  *CommandPool
+	TransferCommandPool *CommandPool
+
+	handoff queueHandoff
+
+	// Timeline orders uploads, compute and graphics submissions, it is nil if the device
+	// does not support timeline semaphores
+	Timeline *GPUTimeline
+
 	GraphicsCommandPool    *CommandPool
 	GraphicsCommandBuffers []*CommandBuffer
 
+	// CommandPools provides per goroutine command pools on the graphics queue for each frame in flight,
+	// the pools of a frame are reset by DrawFrameSync before the frame is recorded, see CurrentFrame
+	CommandPools *CommandPoolSet
+
 	DefaultNumSwapchainImages int
 
 	presentCompleteSemaphore []vk.Semaphore
@@ -60,6 +104,28 @@ type GraphicsApp struct {
 	DepthImageView      *ImageView
 	Framebuffers        []vk.Framebuffer
 
+	// Samples is the requested number of samples per pixel, the highest count supported by
+	// the device up to this value is used. Defaults to vk.SampleCount1Bit which disables multisampling
+	Samples vk.SampleCountFlagBits
+
+	// EnableStencil requests a depth attachment with a stencil component
+	EnableStencil bool
+
+	// DepthFormatPreferences overrides the default order of preference for the depth attachment format
+	// see PhysicalDevice.FindDepthFormat
+	DepthFormatPreferences []vk.Format
+
+	// DepthFormat is the format of the depth attachment, which is chosen from the supported formats
+	// when PrepareToDraw is called
+	DepthFormat vk.Format
+
+	// ColorImage is the multisampled color attachment which is resolved into the swapchain
+	// image, it is only created when multisampling
+	ColorImage     *ImageResource
+	ColorImageView *ImageView
+
+	samples vk.SampleCountFlagBits
+
 	resized bool
 
 	VKRenderPass vk.RenderPass
@@ -67,7 +133,18 @@ type GraphicsApp struct {
 	// ConfigureRenderPass is a call back which can be supplied to
 	// allow for custimization of the render pass
 	ConfigureRenderPass func(renderPass vk.RenderPassCreateInfo)
-	MakeCommandBuffer   func(command *CommandBuffer, frame int)
+	// MakeCommandBuffer records the commands for a frame, it is passed a CommandRecorder so that it can
+	// also be called with a Recorder to test what it records
+	MakeCommandBuffer func(command CommandRecorder, frame int)
+
+	// RenderGraph if set is compiled against the swapchain each time it is created, and when
+	// MakeCommandBuffer is not set the graph is executed to record each frame
+	RenderGraph *RenderGraph
+
+	// Profiler if set is resolved by DrawFrameSync once each frame has completed, a profiler frame is begun
+	// as each command buffer is begun. When the render graph records frames each pass is measured as a scope,
+	// MakeCommandBuffer can measure its own scopes with the recorder it is passed
+	Profiler *Profiler
 }
 
 // NewGraphicsApp creates a new graphics app with the given name and version
@@ -209,8 +286,25 @@ func (p *GraphicsApp) Init() error {
 		enabledExtensions = []string{"VK_KHR_swapchain"}
 	}
 
-	ldevice, err := pdevice.CreateLogicalDeviceWithOptions(gqueues, &CreateDeviceOptions{
-		EnabledExtensions: enabledExtensions,
+	deviceQueues := append(QueueFamilySlice{}, gqueues...)
+
+	var computeFamily, transferFamily *QueueFamily
+	if p.EnableAsyncCompute {
+		if dq := queues.FilterDedicatedCompute(); len(dq) > 0 {
+			computeFamily = dq[0]
+			deviceQueues = append(deviceQueues, computeFamily)
+		}
+	}
+	if p.EnableTransferQueue {
+		if dq := queues.FilterDedicatedTransfer(); len(dq) > 0 {
+			transferFamily = dq[0]
+			deviceQueues = append(deviceQueues, transferFamily)
+		}
+	}
+
+	ldevice, err := pdevice.CreateLogicalDeviceWithOptions(deviceQueues, &CreateDeviceOptions{
+		EnabledExtensions:        enabledExtensions,
+		EnableTimelineSemaphores: pdevice.SupportsTimelineSemaphores(),
 	})
 
 	if err != nil {
@@ -220,6 +314,13 @@ func (p *GraphicsApp) Init() error {
 	p.Device = ldevice
 	p.PhysicalDevice = pdevice
 
+	if ldevice.TimelineSemaphores {
+		p.Timeline, err = ldevice.CreateGPUTimeline()
+		if err != nil {
+			return fmt.Errorf("unable to create timeline: %w", err)
+		}
+	}
+
 	if len(gqueues) == 1 {
 		// Single graphics and present queue
 		queue := ldevice.GetQueue(gqueues[0])
@@ -235,6 +336,15 @@ func (p *GraphicsApp) Init() error {
 		p.PresentQueue = ldevice.GetQueue(pq[0])
 	}
 
+	p.ComputeQueue = p.GraphicsQueue
+	if computeFamily != nil {
+		p.ComputeQueue = ldevice.GetQueue(computeFamily)
+	}
+	p.TransferQueue = p.GraphicsQueue
+	if transferFamily != nil {
+		p.TransferQueue = ldevice.GetQueue(transferFamily)
+	}
+
 	p.DefaultNumSwapchainImages, err = p.Device.DefaultNumSwapchainImages(p.VKSurface)
 	if err != nil {
 		return err
@@ -245,6 +355,23 @@ func (p *GraphicsApp) Init() error {
 		return err
 	}
 
+	p.CommandPools = p.Device.CreateCommandPoolSet(p.GraphicsQueue.QueueFamily, FrameLag)
+
+	p.ComputeCommandPool = p.GraphicsCommandPool
+	if p.HasAsyncCompute() {
+		p.ComputeCommandPool, err = p.Device.CreateCommandPool(p.ComputeQueue.QueueFamily)
+		if err != nil {
+			return err
+		}
+	}
+	p.TransferCommandPool = p.GraphicsCommandPool
+	if p.HasTransferQueue() {
+		p.TransferCommandPool, err = p.Device.CreateCommandPool(p.TransferQueue.QueueFamily)
+		if err != nil {
+			return err
+		}
+	}
+
 	p.ResourceManager = p.Device.CreateResourceManager()
 
 	return nil
@@ -281,15 +408,14 @@ func (p *GraphicsApp) PrepareToDraw() error {
 	if err != nil {
 		return err
 	}
-	p.fillCmdBuffers()
-	return nil
+	return p.fillCmdBuffers()
 }
 
 func (p *GraphicsApp) prepareToDraw() error {
 	var err error
 
-	if p.MakeCommandBuffer == nil {
-		return fmt.Errorf("no function to make command buffers has been configured")
+	if p.MakeCommandBuffer == nil && p.RenderGraph == nil {
+		return fmt.Errorf("no function to make command buffers or render graph has been configured")
 	}
 
 	err = p.createSwapchainAndImages()
@@ -297,21 +423,33 @@ func (p *GraphicsApp) prepareToDraw() error {
 		return err
 	}
 
+	p.samples = p.PhysicalDevice.MaxUsableSampleCount(p.Samples)
+
+	p.DepthFormat, err = p.PhysicalDevice.FindDepthFormat(p.EnableStencil, p.DepthFormatPreferences...)
+	if err != nil {
+		return fmt.Errorf("unable to find a supported depth format: %w", err)
+	}
+
 	err = p.createRenderer()
 	if err != nil {
 		return err
 	}
 
-	if p.PipelineCache != nil {
-		p.PipelineCache.Destroy()
+	err = p.createPipelineCache()
+	if err != nil {
+		return err
+	}
+
+	if p.PipelineLibrary == nil {
+		p.PipelineLibrary = p.Device.CreatePipelineLibrary(p.PipelineCache)
 	}
 
-	p.PipelineCache, err = p.Device.CreatePipelineCache()
+	err = p.createGraphicsPipelines()
 	if err != nil {
 		return err
 	}
 
-	err = p.createGraphicsPipelines()
+	err = p.createColorImage()
 	if err != nil {
 		return err
 	}
@@ -326,6 +464,11 @@ func (p *GraphicsApp) prepareToDraw() error {
 		return err
 	}
 
+	err = p.compileRenderGraph()
+	if err != nil {
+		return err
+	}
+
 	err = p.createCommandBuffers()
 	if err != nil {
 		return err
@@ -342,7 +485,57 @@ func (p *GraphicsApp) prepareToDraw() error {
 
 }
 
-func (p *GraphicsApp) resize(i int) {
+// compileRenderGraph compiles the render graph, if there is one, against the current swapchain
+func (p *GraphicsApp) compileRenderGraph() error {
+	if p.RenderGraph == nil {
+		return nil
+	}
+	p.RenderGraph.PipelineCache = p.PipelineCache
+	p.RenderGraph.Profiler = p.Profiler
+	err := p.RenderGraph.Compile(RenderGraphTarget{
+		Extent: p.Swapchain.Extent,
+		Format: p.Swapchain.Format,
+		Images: p.SwapchainImages,
+		Views:  p.SwapchainImageViews,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to compile render graph: %w", err)
+	}
+	return nil
+}
+
+// createPipelineCache creates the pipeline cache the first time the app is prepared to draw, it is kept
+// when the swapchain is recreated
+func (p *GraphicsApp) createPipelineCache() error {
+	if p.PipelineCache != nil {
+		return nil
+	}
+
+	var err error
+	if p.PipelineCacheFile == "" {
+		p.PipelineCache, err = p.Device.CreatePipelineCache()
+		return err
+	}
+
+	p.PipelineCache, err = p.Device.LoadPipelineCache(p.PipelineCacheFile)
+	if err != nil {
+		return fmt.Errorf("unable to load pipeline cache: %w", err)
+	}
+	if p.PipelineCache.LoadErr != nil {
+		log.Printf("discarding pipeline cache %s: %v", p.PipelineCacheFile, p.PipelineCache.LoadErr)
+	}
+	return nil
+}
+
+// SavePipelineCache saves the pipeline cache to PipelineCacheFile, it is called by Destroy
+func (p *GraphicsApp) SavePipelineCache() error {
+	if p.PipelineCacheFile == "" || p.PipelineCache == nil {
+		return nil
+	}
+	return p.PipelineCache.Save(p.PipelineCacheFile)
+}
+
+func (p *GraphicsApp) resize(i int) error {
 	//FIXME minimization
 
 	p.PresentQueue.WaitIdle()
@@ -350,12 +543,19 @@ func (p *GraphicsApp) resize(i int) {
 	p.Device.WaitIdle()
 
 	p.destroyFramebuffers()
+	p.destroyColorImage()
 	p.destroyDepthImage()
 
 	for _, c := range p.GraphicsCommandBuffers {
 		p.GraphicsCommandPool.FreeBuffer(c)
 	}
 	p.destroyGraphicsPipelines()
+
+	// The graph's framebuffers reference the swapchain's image views
+	if p.RenderGraph != nil {
+		p.RenderGraph.release()
+	}
+
 	p.destroyRenderer()
 
 	for _, views := range p.SwapchainImageViews {
@@ -366,17 +566,52 @@ func (p *GraphicsApp) resize(i int) {
 
 	p.refreshScreenExtent()
 
-	p.createSwapchainAndImages()
-	p.createRenderer()
-	p.createGraphicsPipelines()
-	p.createDepthImage()
-	p.createFramebuffers()
-	p.createCommandBuffers()
-	p.fillCmdBuffers()
-
 	p.resized = false
-
 	p.frameIndex = 0
+
+	var err error
+
+	err = p.createSwapchainAndImages()
+	if err != nil {
+		return err
+	}
+
+	err = p.createRenderer()
+	if err != nil {
+		return err
+	}
+
+	err = p.createGraphicsPipelines()
+	if err != nil {
+		return err
+	}
+
+	err = p.createColorImage()
+	if err != nil {
+		return err
+	}
+
+	err = p.createDepthImage()
+	if err != nil {
+		return err
+	}
+
+	err = p.createFramebuffers()
+	if err != nil {
+		return err
+	}
+
+	err = p.compileRenderGraph()
+	if err != nil {
+		return err
+	}
+
+	err = p.createCommandBuffers()
+	if err != nil {
+		return err
+	}
+
+	return p.fillCmdBuffers()
 }
 
 func (p *GraphicsApp) unprepareToDraw() {
@@ -389,33 +624,81 @@ func (p *GraphicsApp) unprepareToDraw() {
 
 	p.destroyFramebuffers()
 
+	p.destroyColorImage()
+
 	p.destroyDepthImage()
 
 	p.destroyGraphicsPipelines()
 
-	p.PipelineCache.Destroy()
-	p.PipelineCache = nil
+	if p.RenderGraph != nil {
+		p.RenderGraph.release()
+	}
 
 	p.destroyRenderer()
 	p.destroySwapchainAndImages()
 
 }
 
-func (p *GraphicsApp) fillCmdBuffers() {
+func (p *GraphicsApp) fillCmdBuffers() error {
 	for i := range p.GraphicsCommandBuffers {
-		p.MakeCommandBuffer(p.GraphicsCommandBuffers[i], i)
+		err := p.makeCommandBuffer(p.GraphicsCommandBuffers[i], i)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// makeCommandBuffer records a frame with MakeCommandBuffer, or by executing the render graph. If the
+// graph fails the command buffer is left unfinished, it must be reset before it is recorded again.
+func (p *GraphicsApp) makeCommandBuffer(command *CommandBuffer, frame int) error {
+	if p.MakeCommandBuffer != nil {
+		var recorder CommandRecorder = command
+		if p.Profiler != nil {
+			recorder = &profiledRecorder{CommandRecorder: command, profiler: p.Profiler, frame: p.frameIndex}
+		}
+		p.MakeCommandBuffer(recorder, frame)
+		return nil
+	}
+
+	err := command.Begin()
+	if err != nil {
+		return err
+	}
+	if p.Profiler != nil {
+		p.Profiler.BeginFrame(command, p.frameIndex)
+	}
+	err = p.RenderGraph.Execute(command, frame)
+	if err != nil {
+		return fmt.Errorf("unable to execute render graph: %w", err)
+	}
+	return command.End()
+}
+
+// RecordFrame calls MakeCommandBuffer with a Recorder instead of a command buffer, so the commands
+// recorded for a frame can be compared with a golden recording
+func (p *GraphicsApp) RecordFrame(frame int) (*Recorder, error) {
+	if p.MakeCommandBuffer == nil {
+		return nil, fmt.Errorf("no function to make command buffers has been configured")
+	}
+	ret := NewRecorder()
+	if p.GraphicsQueue != nil {
+		ret.QueueFamily = p.GraphicsQueue.QueueFamily
+	}
+	p.MakeCommandBuffer(ret, frame)
+	return ret, nil
 }
 
 func (p *GraphicsApp) recreateSwapchain() error {
 
 	p.unprepareToDraw()
 
-	p.prepareToDraw()
-
-	p.fillCmdBuffers()
+	err := p.prepareToDraw()
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return p.fillCmdBuffers()
 }
 
 func (p *GraphicsApp) getNextFrameToMakeCmdBufferFor(currentFrame int) int {
@@ -470,8 +753,7 @@ func (p *GraphicsApp) DrawFrameSync() error {
 	res := vk.AcquireNextImage(p.Device.VKDevice, p.Swapchain.VKSwapchain, vk.MaxUint64, p.presentCompleteSemaphore[p.frameIndex], vk.NullFence, &imageIndex)
 
 	if res == vk.ErrorOutOfDate || p.resized {
-		p.resize(1)
-		return nil
+		return p.resize(1)
 	}
 	err = vk.Error(res)
 
@@ -480,46 +762,46 @@ func (p *GraphicsApp) DrawFrameSync() error {
 	}
 
 	vk.WaitForFences(p.Device.VKDevice, 1, []vk.Fence{p.waitFences[p.frameIndex]}, vk.True, vk.MaxUint64)
-	vk.ResetFences(p.Device.VKDevice, 1, []vk.Fence{p.waitFences[p.frameIndex]})
 
-	p.GraphicsCommandBuffers[int(imageIndex)].Reset()
-	p.MakeCommandBuffer(p.GraphicsCommandBuffers[int(imageIndex)], int(imageIndex))
-
-	waitSemaphores := []vk.Semaphore{p.presentCompleteSemaphore[p.frameIndex]}
-	signalSemaphores := []vk.Semaphore{p.renderCompleteSemaphore[p.frameIndex]}
-	waitStages := []vk.PipelineStageFlags{vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)}
-
-	submitInfo := []vk.SubmitInfo{{
-		SType:                vk.StructureTypeSubmitInfo,
-		WaitSemaphoreCount:   1,
-		PWaitSemaphores:      waitSemaphores,
-		PWaitDstStageMask:    waitStages,
-		SignalSemaphoreCount: 1,
-		PSignalSemaphores:    signalSemaphores,
-		CommandBufferCount:   1,
-		PCommandBuffers:      []vk.CommandBuffer{p.GraphicsCommandBuffers[imageIndex].VKCommandBuffer},
-	}}
+	err = p.CommandPools.ResetFrame(p.frameIndex)
+	if err != nil {
+		return err
+	}
+
+	if p.Profiler != nil {
+		err = p.Profiler.Resolve(p.frameIndex)
+		if err != nil {
+			return err
+		}
+	}
 
-	err = vk.Error(vk.QueueSubmit(p.GraphicsQueue.VKQueue, 1, submitInfo, p.waitFences[p.frameIndex]))
+	p.GraphicsCommandBuffers[int(imageIndex)].Reset()
+	err = p.makeCommandBuffer(p.GraphicsCommandBuffers[int(imageIndex)], int(imageIndex))
 	if err != nil {
 		return err
 	}
 
-	imageIndices := []uint32{imageIndex}
-	presentInfo := vk.PresentInfo{
-		SType:              vk.StructureTypePresentInfo,
-		SwapchainCount:     1,
-		PSwapchains:        []vk.Swapchain{p.Swapchain.VKSwapchain},
-		WaitSemaphoreCount: 1,
-		PWaitSemaphores:    signalSemaphores,
-		PImageIndices:      imageIndices,
-		PResults:           nil,
+	// The fence is only reset once the frame will be submitted, so a failed frame doesn't leave it unsignaled
+	vk.ResetFences(p.Device.VKDevice, 1, []vk.Fence{p.waitFences[p.frameIndex]})
+
+	// Wait for the swapchain image and any compute or transfer work handed off to this frame
+	err = p.GraphicsQueue.NewSubmit().
+		Wait(p.presentCompleteSemaphore[p.frameIndex], vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)).
+		Waits(p.handoff.takeWaits()...).
+		Buffers(p.GraphicsCommandBuffers[imageIndex]).
+		Signal(p.renderCompleteSemaphore[p.frameIndex]).
+		Fence(&Fence{Device: p.Device, VKFence: p.waitFences[p.frameIndex]}).
+		Submit()
+	if err != nil {
+		return err
 	}
 
-	res = vk.QueuePresent(p.GraphicsQueue.VKQueue, &presentInfo)
+	res = p.PresentQueue.NewPresent().
+		Wait(p.renderCompleteSemaphore[p.frameIndex]).
+		Image(p.Swapchain, imageIndex).
+		Present()
 	if res == vk.ErrorOutOfDate || res == vk.Suboptimal || p.resized {
-		p.resize(2)
-		return nil
+		return p.resize(2)
 	} else {
 		err = vk.Error(res)
 
@@ -535,9 +817,26 @@ func (p *GraphicsApp) DrawFrameSync() error {
 	p.GraphicsQueue.WaitIdle()
 	p.Device.WaitIdle()
 
+	p.handoff.recycle()
+
+	// The device is idle so pipelines can be replaced before the next frame is recorded
+	if p.ShaderReloader != nil {
+		p.reloadShaders()
+	}
+
+	p.PipelineLibrary.NextFrame()
+	if p.PipelineLibrary.EvictAfter > 0 {
+		p.PipelineLibrary.EvictUnused(p.PipelineLibrary.EvictAfter)
+	}
+
 	return nil
 }
 
+// CurrentFrame returns the index of the frame in flight being recorded, for use with CommandPools
+func (p *GraphicsApp) CurrentFrame() int {
+	return p.frameIndex
+}
+
 func (p *GraphicsApp) createGraphicsPipelines() error {
 
 	configs := make([]vk.GraphicsPipelineCreateInfo, len(p.GraphicsPipelineConfigs))
@@ -549,11 +848,10 @@ func (p *GraphicsApp) createGraphicsPipelines() error {
 	}
 
 	for name, gconfig := range p.GraphicsPipelineConfigs {
-		config, err := gconfig.VKGraphicsPipelineCreateInfo(p.GetScreenExtent())
+		config, err := p.graphicsPipelineCreateInfo(name, gconfig)
 		if err != nil {
-			return fmt.Errorf("error generating graphics pipline config '%s' : %w", name, err)
+			return err
 		}
-		config.RenderPass = p.VKRenderPass
 		configs[i] = config
 		nameToID[name] = i
 		i++
@@ -578,6 +876,35 @@ func (p *GraphicsApp) createGraphicsPipelines() error {
 	return nil
 }
 
+// graphicsPipelineCreateInfo generates the create info for a config using the app's render pass
+func (p *GraphicsApp) graphicsPipelineCreateInfo(name string, gconfig IGraphicsPipelineConfig) (vk.GraphicsPipelineCreateInfo, error) {
+	config, err := gconfig.VKGraphicsPipelineCreateInfo(p.GetScreenExtent())
+	if err != nil {
+		return config, fmt.Errorf("error generating graphics pipline config '%s' : %w", name, err)
+	}
+	config.RenderPass = p.VKRenderPass
+	if config.PMultisampleState != nil {
+		// The pipeline must match the sample count of the render pass
+		config.PMultisampleState.RasterizationSamples = p.SampleCount()
+	}
+	return config, nil
+}
+
+// createGraphicsPipeline creates a single pipeline from a config without adding it to GraphicsPipelines
+func (p *GraphicsApp) createGraphicsPipeline(name string, gconfig IGraphicsPipelineConfig) (vk.Pipeline, error) {
+	config, err := p.graphicsPipelineCreateInfo(name, gconfig)
+	if err != nil {
+		return vk.NullPipeline, err
+	}
+	pipelines := make([]vk.Pipeline, 1)
+	err = vk.Error(vk.CreateGraphicsPipelines(p.Device.VKDevice, p.PipelineCache.VKPipelineCache,
+		1, []vk.GraphicsPipelineCreateInfo{config}, nil, pipelines))
+	if err != nil {
+		return vk.NullPipeline, fmt.Errorf("error creating graphics pipeline '%s': %w", name, err)
+	}
+	return pipelines[0], nil
+}
+
 func (p *GraphicsApp) destroyGraphicsPipelines() {
 	for _, g := range p.GraphicsPipelines {
 		vk.DestroyPipeline(p.Device.VKDevice, g, nil)
@@ -596,6 +923,32 @@ func (p *GraphicsApp) refreshScreenExtent() {
 
 }
 
+// PipelineTarget returns the target for pipelines used with the app's render pass, which is only valid
+// once PrepareToDraw has been called
+func (p *GraphicsApp) PipelineTarget() PipelineTarget {
+	return PipelineTarget{
+		RenderPass:     p.VKRenderPass,
+		RenderPassInfo: p.VKRenderPassCreateInfo(),
+		Extent:         p.GetScreenExtent(),
+		Samples:        p.SampleCount(),
+	}
+}
+
+// GetPipeline returns the variant of the config for the app's render pass from PipelineLibrary, creating
+// it if needed. Unlike GraphicsPipelines the config doesn't need to be added to the app.
+func (p *GraphicsApp) GetPipeline(config *GraphicsPipelineConfig) (vk.Pipeline, error) {
+	return p.PipelineLibrary.Get(config, p.PipelineTarget())
+}
+
+// SampleCount returns the number of samples per pixel used for rendering, which is only
+// valid once PrepareToDraw has been called
+func (p *GraphicsApp) SampleCount() vk.SampleCountFlagBits {
+	if p.samples == 0 {
+		return vk.SampleCount1Bit
+	}
+	return p.samples
+}
+
 // GetScreenExtent gets the current screen extents
 func (p *GraphicsApp) GetScreenExtent() vk.Extent2D {
 	return p.screenExtent
@@ -612,18 +965,56 @@ func (p *GraphicsApp) Destroy() {
 		g.Destroy()
 	}
 
+	for _, c := range p.ComputePipelines {
+		if c.Device != nil {
+			c.Destroy()
+		}
+	}
+
+	if p.RenderGraph != nil {
+		p.RenderGraph.Destroy()
+	}
+
+	if p.PipelineLibrary != nil {
+		p.PipelineLibrary.Destroy()
+	}
+
 	if p.PipelineCache != nil {
+		err := p.SavePipelineCache()
+		if err != nil {
+			log.Printf("unable to save pipeline cache %s: %v", p.PipelineCacheFile, err)
+		}
 		p.PipelineCache.Destroy()
 	}
 
+	if p.Profiler != nil {
+		p.Profiler.Destroy()
+	}
+
 	p.ResourceManager.Destroy()
 
+	p.destroyColorImage()
+
 	p.destroyDepthImage()
 
 	p.destroySwapchainAndImages()
 
 	p.destroySyncObjects()
 
+	if p.Timeline != nil {
+		p.Timeline.Destroy()
+	}
+
+	p.handoff.destroy(p.Device)
+
+	p.CommandPools.Destroy()
+
+	if p.HasAsyncCompute() {
+		p.ComputeCommandPool.Destroy()
+	}
+	if p.HasTransferQueue() {
+		p.TransferCommandPool.Destroy()
+	}
 	p.GraphicsCommandPool.Destroy()
 
 	vk.DestroySurface(p.Instance.VKInstance, p.VKSurface, nil)
@@ -635,24 +1026,39 @@ func (p *GraphicsApp) Destroy() {
 }
 
 // VKRenderPassCreateInfo is a utility function which creates the render pass info, the implementing application
-// can implement the ConfigureRenderPass function to customize the render pass
+// can implement the ConfigureRenderPass function to customize the render pass. When multisampling the first
+// attachment is the multisampled color image and a third attachment is added to resolve into the swapchain image.
 func (p *GraphicsApp) VKRenderPassCreateInfo() vk.RenderPassCreateInfo {
+	samples := p.SampleCount()
+
+	colorStoreOp := vk.AttachmentStoreOpStore
+	colorFinalLayout := vk.ImageLayoutPresentSrc
+	if samples != vk.SampleCount1Bit {
+		colorStoreOp = vk.AttachmentStoreOpDontCare
+		colorFinalLayout = vk.ImageLayoutColorAttachmentOptimal
+	}
+
+	stencilLoadOp := vk.AttachmentLoadOpDontCare
+	if HasStencilComponent(p.DepthFormat) {
+		stencilLoadOp = vk.AttachmentLoadOpClear
+	}
+
 	attachmentDescriptions := []vk.AttachmentDescription{{
 		Format:         p.Swapchain.Format,
-		Samples:        vk.SampleCount1Bit,
+		Samples:        samples,
 		LoadOp:         vk.AttachmentLoadOpClear,
-		StoreOp:        vk.AttachmentStoreOpStore,
+		StoreOp:        colorStoreOp,
 		StencilLoadOp:  vk.AttachmentLoadOpDontCare,
 		StencilStoreOp: vk.AttachmentStoreOpDontCare,
 		InitialLayout:  vk.ImageLayoutUndefined,
-		FinalLayout:    vk.ImageLayoutPresentSrc,
+		FinalLayout:    colorFinalLayout,
 	},
 		{
-			Format:         vk.FormatD32Sfloat,
-			Samples:        vk.SampleCount1Bit,
+			Format:         p.DepthFormat,
+			Samples:        samples,
 			LoadOp:         vk.AttachmentLoadOpClear,
 			StoreOp:        vk.AttachmentStoreOpDontCare,
-			StencilLoadOp:  vk.AttachmentLoadOpDontCare,
+			StencilLoadOp:  stencilLoadOp,
 			StencilStoreOp: vk.AttachmentStoreOpDontCare,
 			InitialLayout:  vk.ImageLayoutUndefined,
 			FinalLayout:    vk.ImageLayoutDepthStencilAttachmentOptimal,
@@ -676,6 +1082,23 @@ func (p *GraphicsApp) VKRenderPassCreateInfo() vk.RenderPassCreateInfo {
 		PDepthStencilAttachment: &depthAttachmentRef,
 	}}
 
+	if samples != vk.SampleCount1Bit {
+		attachmentDescriptions = append(attachmentDescriptions, vk.AttachmentDescription{
+			Format:         p.Swapchain.Format,
+			Samples:        vk.SampleCount1Bit,
+			LoadOp:         vk.AttachmentLoadOpDontCare,
+			StoreOp:        vk.AttachmentStoreOpStore,
+			StencilLoadOp:  vk.AttachmentLoadOpDontCare,
+			StencilStoreOp: vk.AttachmentStoreOpDontCare,
+			InitialLayout:  vk.ImageLayoutUndefined,
+			FinalLayout:    vk.ImageLayoutPresentSrc,
+		})
+		subpassDescriptions[0].PResolveAttachments = []vk.AttachmentReference{{
+			Attachment: 2,
+			Layout:     vk.ImageLayoutColorAttachmentOptimal,
+		}}
+	}
+
 	dependency := vk.SubpassDependency{
 		SrcSubpass:    vk.SubpassExternal,
 		DstSubpass:    0,
@@ -687,7 +1110,7 @@ func (p *GraphicsApp) VKRenderPassCreateInfo() vk.RenderPassCreateInfo {
 
 	renderPassCreateInfo := vk.RenderPassCreateInfo{
 		SType:           vk.StructureTypeRenderPassCreateInfo,
-		AttachmentCount: 2,
+		AttachmentCount: uint32(len(attachmentDescriptions)),
 		PAttachments:    attachmentDescriptions,
 		SubpassCount:    1,
 		PSubpasses:      subpassDescriptions,
@@ -789,9 +1212,13 @@ func (p *GraphicsApp) destroySwapchainAndImages() {
 func (p *GraphicsApp) createDepthImage() error {
 	var err error
 
-	p.DepthImage, err = p.ResourceManager.NewImageResourceWithOptions(p.Swapchain.Extent, vk.FormatD32Sfloat, vk.ImageTilingOptimal, vk.ImageUsageDepthStencilAttachmentBit, vk.SharingModeExclusive, vk.MemoryPropertyDeviceLocalBit)
+	p.DepthImage, err = p.ResourceManager.NewImageResource(p.Swapchain.Extent, p.DepthFormat, vk.ImageTilingOptimal, vk.ImageUsageDepthStencilAttachmentBit, vk.SharingModeExclusive, vk.MemoryPropertyDeviceLocalBit,
+		&CreateImageOptions{Samples: p.SampleCount()})
+	if err != nil {
+		return err
+	}
 
-	p.DepthImageView, err = p.DepthImage.CreateImageViewWithAspectMask(vk.ImageAspectFlags(vk.ImageAspectDepthBit))
+	p.DepthImageView, err = p.DepthImage.CreateImageViewWithAspectMask(FormatAspectMask(p.DepthFormat))
 	if err != nil {
 		return err
 	}
@@ -805,6 +1232,39 @@ func (p *GraphicsApp) destroyDepthImage() error {
 	return nil
 }
 
+// createColorImage creates the multisampled color attachment, if multisampling is enabled
+func (p *GraphicsApp) createColorImage() error {
+	if p.SampleCount() == vk.SampleCount1Bit {
+		return nil
+	}
+
+	var err error
+
+	p.ColorImage, err = p.ResourceManager.NewImageResource(p.Swapchain.Extent, p.Swapchain.Format, vk.ImageTilingOptimal, vk.ImageUsageColorAttachmentBit|vk.ImageUsageTransientAttachmentBit, vk.SharingModeExclusive, vk.MemoryPropertyDeviceLocalBit,
+		&CreateImageOptions{Samples: p.SampleCount()})
+	if err != nil {
+		return err
+	}
+
+	p.ColorImageView, err = p.ColorImage.CreateImageView()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *GraphicsApp) destroyColorImage() {
+	if p.ColorImageView != nil {
+		p.ColorImageView.Destroy()
+		p.ColorImageView = nil
+	}
+	if p.ColorImage != nil {
+		p.ColorImage.Destroy()
+		p.ColorImage = nil
+	}
+}
+
 func (p *GraphicsApp) createFramebuffers() error {
 	p.Framebuffers = make([]vk.Framebuffer, len(p.SwapchainImageViews))
 	for i, view := range p.SwapchainImageViews {
@@ -812,6 +1272,14 @@ func (p *GraphicsApp) createFramebuffers() error {
 			view.VKImageView,
 			p.DepthImageView.VKImageView,
 		}
+		if p.ColorImageView != nil {
+			// Render to the multisampled image and resolve into the swapchain image
+			attachments = []vk.ImageView{
+				p.ColorImageView.VKImageView,
+				p.DepthImageView.VKImageView,
+				view.VKImageView,
+			}
+		}
 		fbCreateInfo := vk.FramebufferCreateInfo{
 			SType:           vk.StructureTypeFramebufferCreateInfo,
 			RenderPass:      p.VKRenderPass,
@@ -1,6 +1,8 @@
 package vkg
 
 import (
+	"fmt"
+
 	vk "github.com/vulkan-go/vulkan"
 )
 
@@ -56,11 +58,36 @@ type GraphicsPipelineConfig struct {
 	// DepthWriteEnable defaults to true
 	DepthWriteEnable bool
 
+	// DepthCompareOp defaults to vk.CompareOpLess, which is also used when it is left as vk.CompareOpNever
+	// with DepthTestEnable set as a depth test which never passes would discard everything drawn
+	DepthCompareOp vk.CompareOp
+
+	// StencilTestEnable defaults to false, the render pass must have a stencil attachment
+	// see https://www.khronos.org/registry/vulkan/specs/1.1-extensions/man/html/VkStencilOpState.html
+	StencilTestEnable bool
+	StencilFront      vk.StencilOpState
+	StencilBack       vk.StencilOpState
+
+	// RasterizationSamples must match the sample count of the render pass the pipeline is used with, it
+	// is set automatically when the pipeline is created by GraphicsApp. Defaults to vk.SampleCount1Bit
+	RasterizationSamples vk.SampleCountFlagBits
+
+	// MinSampleShading enables sample shading when greater than zero, a value of 1.0 shades every sample
+	// see https://www.khronos.org/registry/vulkan/specs/1.1-extensions/html/vkspec.html#primsrast-sampleshading
+	// the device must support the sampleRateShading feature
+	MinSampleShading float32
+
+	// AlphaToCoverageEnable defaults to false
+	AlphaToCoverageEnable bool
+
 	VertexInputBindingDescriptions   []vk.VertexInputBindingDescription
 	VertexInputAttributeDescriptions []vk.VertexInputAttributeDescription
 
 	toDestroy []IDestructable
 
+	// stages are the shader modules of the stages added with AddShaderStage, in the same order as ShaderStages
+	stages []pipelineShaderStage
+
 	Viewport *vk.Viewport
 }
 
@@ -76,6 +103,8 @@ func (d *Device) CreateGraphicsPipelineConfig() *GraphicsPipelineConfig {
 		FrontFace:              vk.FrontFaceCounterClockwise,
 		DepthTestEnable:        true,
 		DepthWriteEnable:       true,
+		DepthCompareOp:         vk.CompareOpLess,
+		RasterizationSamples:   vk.SampleCount1Bit,
 	}
 }
 
@@ -107,6 +136,25 @@ func (g *GraphicsPipelineConfig) SetCullMode(mode vk.CullModeFlagBits) *Graphics
 	return g
 }
 
+// SetStencil enables the stencil test using the same state for front and back facing triangles
+func (g *GraphicsPipelineConfig) SetStencil(state vk.StencilOpState) *GraphicsPipelineConfig {
+	return g.SetStencilSeparate(state, state)
+}
+
+// SetStencilSeparate enables the stencil test with different state for front and back facing triangles
+func (g *GraphicsPipelineConfig) SetStencilSeparate(front, back vk.StencilOpState) *GraphicsPipelineConfig {
+	g.StencilTestEnable = true
+	g.StencilFront = front
+	g.StencilBack = back
+	return g
+}
+
+// SetSampleShading enables sample shading with the specified minimum fraction of samples to shade
+func (g *GraphicsPipelineConfig) SetSampleShading(minSampleShading float32) *GraphicsPipelineConfig {
+	g.MinSampleShading = minSampleShading
+	return g
+}
+
 // SetDynamicState specifies which part of the pipeline may be changed with command buffer commands
 func (g *GraphicsPipelineConfig) SetDynamicState(states ...vk.DynamicState) *GraphicsPipelineConfig {
 	g.DynamicState = states
@@ -119,13 +167,99 @@ func (g *GraphicsPipelineConfig) AddShaderStageFromFile(file, entryPoint string,
 	if err != nil {
 		return err
 	}
+	g.AddShaderStage(shader, entryPoint, stageType)
+	g.manageDestroy(shader)
+
+	return nil
+}
+
+// AddShaderStage adds a stage using a shader module which remains owned by the caller
+func (g *GraphicsPipelineConfig) AddShaderStage(shader *ShaderModule, entryPoint string, stageType vk.ShaderStageFlagBits) *GraphicsPipelineConfig {
 	if g.ShaderStages == nil {
 		g.ShaderStages = make([]vk.PipelineShaderStageCreateInfo, 0)
 	}
 	g.ShaderStages = append(g.ShaderStages, shader.VKPipelineShaderStageCreateInfo(stageType, entryPoint))
+	g.stages = append(g.stages, pipelineShaderStage{module: shader, entryPoint: entryPoint, stage: stageType})
+	return g
+}
 
-	g.manageDestroy(shader)
+// Reflect merges the reflection of the shader stages added with AddShaderStage or AddShaderStageFromFile
+func (g *GraphicsPipelineConfig) Reflect() (*PipelineReflection, error) {
+	if len(g.stages) != len(g.ShaderStages) {
+		return nil, fmt.Errorf("shader stages set with SetShaderStages have no reflection data")
+	}
+	stages := make([]ShaderStageReflection, len(g.stages))
+	for i, s := range g.stages {
+		var err error
+		stages[i], err = s.reflect()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return MergeShaderStages(stages...)
+}
+
+// usesShaderModule reports if any of the stages added with AddShaderStage use the module
+func (g *GraphicsPipelineConfig) usesShaderModule(m *ShaderModule) bool {
+	for _, s := range g.stages {
+		if s.module == m {
+			return true
+		}
+	}
+	return false
+}
 
+// shaderStageCreateInfos returns the shader stages with the current handles of the modules of stages added
+// with AddShaderStage, so copies made with Variant before a module was reloaded use its new code
+func (g *GraphicsPipelineConfig) shaderStageCreateInfos() []vk.PipelineShaderStageCreateInfo {
+	if len(g.stages) != len(g.ShaderStages) {
+		return g.ShaderStages
+	}
+	ret := append([]vk.PipelineShaderStageCreateInfo(nil), g.ShaderStages...)
+	for i, s := range g.stages {
+		ret[i].Module = s.module.VKShaderModule
+	}
+	return ret
+}
+
+// refreshShaderStages updates the stages added with AddShaderStage after their modules have been reloaded
+func (g *GraphicsPipelineConfig) refreshShaderStages() {
+	if len(g.stages) != len(g.ShaderStages) {
+		return
+	}
+	for i, s := range g.stages {
+		g.ShaderStages[i] = s.createInfo()
+	}
+}
+
+// ApplyReflection configures the pipeline from the reflection of its shader stages. Unless a pipeline
+// layout has been set, descriptor set layouts and a pipeline layout are created and destroyed with the
+// config. Unless vertex descriptions have been added, the vertex inputs are described as a single
+// interleaved binding.
+func (g *GraphicsPipelineConfig) ApplyReflection() error {
+	r, err := g.Reflect()
+	if err != nil {
+		return err
+	}
+
+	if g.PipelineLayout == nil {
+		layout, setLayouts, err := g.Device.CreateReflectedPipelineLayout(r)
+		if err != nil {
+			return err
+		}
+		g.DescriptorSetLayouts = setLayouts
+		g.PipelineLayout = layout
+		for _, l := range setLayouts {
+			g.manageDestroy(l)
+		}
+		g.manageDestroy(layout)
+	}
+
+	if len(g.VertexInputBindingDescriptions) == 0 && len(r.VertexInputs) > 0 {
+		binding, attributes := r.VertexInputDescriptions(0, vk.VertexInputRateVertex)
+		g.VertexInputBindingDescriptions = []vk.VertexInputBindingDescription{binding}
+		g.VertexInputAttributeDescriptions = attributes
+	}
 	return nil
 }
 
@@ -135,9 +269,10 @@ func (g *GraphicsPipelineConfig) SetPipelineLayout(layout *PipelineLayout) *Grap
 	return g
 }
 
-// SetShaderStages sets the shader stages directly
+// SetShaderStages sets the shader stages directly, stages set this way have no reflection data
 func (g *GraphicsPipelineConfig) SetShaderStages(shaderStages []vk.PipelineShaderStageCreateInfo) *GraphicsPipelineConfig {
 	g.ShaderStages = shaderStages
+	g.stages = nil
 	return g
 }
 
@@ -165,6 +300,22 @@ func (g *GraphicsPipelineConfig) AddDescriptorSetLayout(d *DescriptorSetLayout)
 	return g
 }
 
+// depthCompareOp returns the compare op used for the depth test
+func (g *GraphicsPipelineConfig) depthCompareOp() vk.CompareOp {
+	if g.DepthTestEnable && g.DepthCompareOp == vk.CompareOpNever {
+		return vk.CompareOpLess
+	}
+	return g.DepthCompareOp
+}
+
+// checkSampleShading returns an error if the features don't allow sample shading
+func checkSampleShading(features vk.PhysicalDeviceFeatures) error {
+	if features.SampleRateShading != vk.True {
+		return fmt.Errorf("sample shading requires the sampleRateShading device feature")
+	}
+	return nil
+}
+
 // VKGraphicsPipelineCreateInfo uses the provided config information to create a vulkank vk.GraphicsPipelineCreateInfo structure
 func (g *GraphicsPipelineConfig) VKGraphicsPipelineCreateInfo(extent vk.Extent2D) (vk.GraphicsPipelineCreateInfo, error) {
 
@@ -218,7 +369,21 @@ func (g *GraphicsPipelineConfig) VKGraphicsPipelineCreateInfo(extent vk.Extent2D
 	var multisampleState = vk.PipelineMultisampleStateCreateInfo{}
 	multisampleState.SType = vk.StructureTypePipelineMultisampleStateCreateInfo
 	multisampleState.SampleShadingEnable = vk.False
-	multisampleState.RasterizationSamples = vk.SampleCount1Bit
+	multisampleState.RasterizationSamples = g.RasterizationSamples
+	if multisampleState.RasterizationSamples == 0 {
+		multisampleState.RasterizationSamples = vk.SampleCount1Bit
+	}
+	if g.MinSampleShading > 0 {
+		err := checkSampleShading(g.Device.PhysicalDevice.VKPhysicalDeviceFeatures())
+		if err != nil {
+			return vk.GraphicsPipelineCreateInfo{}, err
+		}
+		multisampleState.SampleShadingEnable = vk.True
+		multisampleState.MinSampleShading = g.MinSampleShading
+	}
+	if g.AlphaToCoverageEnable {
+		multisampleState.AlphaToCoverageEnable = vk.True
+	}
 
 	blendAttachments := []vk.PipelineColorBlendAttachmentState{}
 	if g.BlendAttachments == nil {
@@ -256,22 +421,30 @@ func (g *GraphicsPipelineConfig) VKGraphicsPipelineCreateInfo(extent vk.Extent2D
 		SType:                 vk.StructureTypePipelineDepthStencilStateCreateInfo,
 		DepthTestEnable:       vk.Bool32(dte),
 		DepthWriteEnable:      vk.Bool32(dwe),
-		DepthCompareOp:        vk.CompareOpLess,
+		DepthCompareOp:        g.depthCompareOp(),
 		DepthBoundsTestEnable: vk.False,
 		MinDepthBounds:        0.0,
 		MaxDepthBounds:        1.0,
 		StencilTestEnable:     vk.False,
 	}
 
+	if g.StencilTestEnable {
+		depthStencil.StencilTestEnable = vk.True
+		depthStencil.Front = g.StencilFront
+		depthStencil.Back = g.StencilBack
+	}
+
 	var pipelineLayout vk.PipelineLayout
 	if g.PipelineLayout != nil {
 		pipelineLayout = g.PipelineLayout.VKPipelineLayout
 	}
 
+	shaderStages := g.shaderStageCreateInfos()
+
 	pipelineCreateInfos := vk.GraphicsPipelineCreateInfo{
 		SType:               vk.StructureTypeGraphicsPipelineCreateInfo,
-		StageCount:          uint32(len(g.ShaderStages)),
-		PStages:             g.ShaderStages,
+		StageCount:          uint32(len(shaderStages)),
+		PStages:             shaderStages,
 		PVertexInputState:   &vertexInputState,
 		PInputAssemblyState: &inputAssemblyState,
 		PDepthStencilState:  &depthStencil,
@@ -0,0 +1,31 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestCheckSampleShading(t *testing.T) {
+	if checkSampleShading(vk.PhysicalDeviceFeatures{}) == nil {
+		t.Errorf("expected an error without the sampleRateShading feature")
+	}
+	if err := checkSampleShading(vk.PhysicalDeviceFeatures{SampleRateShading: vk.True}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDepthCompareOpDefault(t *testing.T) {
+	g := &GraphicsPipelineConfig{DepthTestEnable: true}
+	if op := g.depthCompareOp(); op != vk.CompareOpLess {
+		t.Errorf("expected a zero compare op to default to less, got %v", op)
+	}
+	g.DepthCompareOp = vk.CompareOpGreater
+	if op := g.depthCompareOp(); op != vk.CompareOpGreater {
+		t.Errorf("expected the configured compare op, got %v", op)
+	}
+	g = &GraphicsPipelineConfig{}
+	if op := g.depthCompareOp(); op != vk.CompareOpNever {
+		t.Errorf("expected the compare op to be unchanged without a depth test, got %v", op)
+	}
+}
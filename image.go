@@ -6,28 +6,65 @@ import (
 
 // Image is analogous to a buffer, it is essentially a designation that a resource is an image.
 type Image struct {
-	Device   *Device
-	VKImage  vk.Image
-	VKFormat vk.Format
-	Size     uint64
-	Extent   vk.Extent2D
+	Device      *Device
+	VKImage     vk.Image
+	VKFormat    vk.Format
+	Size        uint64
+	Extent      vk.Extent2D
+	Usage       vk.ImageUsageFlagBits
+	Tiling      vk.ImageTiling
+	Samples     vk.SampleCountFlagBits
+	MipLevels   int
+	ArrayLayers int
+}
+
+// CreateImageOptions are less commonly used options for image creation
+type CreateImageOptions struct {
+	// Samples is the number of samples per pixel, defaults to vk.SampleCount1Bit
+	Samples vk.SampleCountFlagBits
+	// MipLevels defaults to 1
+	MipLevels int
+	// ArrayLayers defaults to 1
+	ArrayLayers int
 }
 
 // CreateImageWithOptions creates an image with some commonly used options
 func (d *Device) CreateImageWithOptions(extent vk.Extent2D, format vk.Format, tiling vk.ImageTiling, usage vk.ImageUsageFlagBits) (*Image, error) {
+	return d.CreateImage(extent, format, tiling, usage, nil)
+}
+
+// CreateImage creates an image, options may be nil in which case a single sampled image with
+// one mip level and one array layer is created
+func (d *Device) CreateImage(extent vk.Extent2D, format vk.Format, tiling vk.ImageTiling, usage vk.ImageUsageFlagBits, options *CreateImageOptions) (*Image, error) {
+	samples := vk.SampleCount1Bit
+	mipLevels := 1
+	arrayLayers := 1
+
+	if options != nil {
+		if options.Samples != 0 {
+			samples = options.Samples
+		}
+		if options.MipLevels > 0 {
+			mipLevels = options.MipLevels
+		}
+		if options.ArrayLayers > 0 {
+			arrayLayers = options.ArrayLayers
+		}
+	}
+
 	var imageInfo = vk.ImageCreateInfo{}
 	imageInfo.SType = vk.StructureTypeImageCreateInfo
 	imageInfo.ImageType = vk.ImageType2d
 	imageInfo.Extent.Width = extent.Width
 	imageInfo.Extent.Height = extent.Height
 	imageInfo.Extent.Depth = 1
-	imageInfo.MipLevels = 1
-	imageInfo.ArrayLayers = 1
+	imageInfo.MipLevels = uint32(mipLevels)
+	imageInfo.ArrayLayers = uint32(arrayLayers)
 	imageInfo.Format = format
 	imageInfo.Tiling = tiling
 	imageInfo.InitialLayout = vk.ImageLayoutUndefined
 	imageInfo.Usage = vk.ImageUsageFlags(usage)
-	imageInfo.Samples = vk.SampleCount1Bit
+	imageInfo.Samples = samples
 	imageInfo.SharingMode = vk.SharingModeExclusive
 
 	var image vk.Image
@@ -43,6 +80,11 @@ func (d *Device) CreateImageWithOptions(extent vk.Extent2D, format vk.Format, ti
 	ret.VKImage = image
 	ret.VKFormat = format
 	ret.Extent = extent
+	ret.Usage = usage
+	ret.Tiling = tiling
+	ret.Samples = samples
+	ret.MipLevels = mipLevels
+	ret.ArrayLayers = arrayLayers
 
 	return &ret, nil
 }
@@ -0,0 +1,543 @@
+package vkg
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// ImageRegion identifies a region within a single mip level of an image, zero values
+// select the whole of the first layer of the first mip level
+type ImageRegion struct {
+	// AspectMask defaults to the aspects of the image format
+	AspectMask     vk.ImageAspectFlags
+	MipLevel       int
+	BaseArrayLayer int
+	// LayerCount defaults to 1
+	LayerCount int
+	Offset     vk.Offset3D
+	// Extent defaults to the remainder of the mip level after the offset
+	Extent vk.Extent3D
+}
+
+// ImageCopyRegion describes a copy, blit or resolve between two images. For copies and resolves
+// the destination extent defaults to the source extent, for blits it defaults to the whole mip
+// level so the source is scaled to fit.
+type ImageCopyRegion struct {
+	Src ImageRegion
+	Dst ImageRegion
+}
+
+// BufferImageRegion describes a copy between a buffer and an image, see
+// https://www.khronos.org/registry/vulkan/specs/1.1-extensions/man/html/VkBufferImageCopy.html
+type BufferImageRegion struct {
+	BufferOffset uint64
+	// BufferRowLength and BufferImageHeight are in texels, zero means the buffer is tightly packed
+	BufferRowLength   int
+	BufferImageHeight int
+	Image             ImageRegion
+}
+
+// FormatTexelSize returns the size in bytes of a single texel of the specified format, or 0 if
+// the format is compressed or unknown
+func FormatTexelSize(format vk.Format) int {
+	switch format {
+	case vk.FormatR8Unorm, vk.FormatR8Snorm, vk.FormatR8Uint, vk.FormatR8Sint, vk.FormatR8Srgb,
+		vk.FormatS8Uint:
+		return 1
+	case vk.FormatR8g8Unorm, vk.FormatR8g8Snorm, vk.FormatR8g8Uint, vk.FormatR8g8Sint, vk.FormatR8g8Srgb,
+		vk.FormatR16Unorm, vk.FormatR16Uint, vk.FormatR16Sint, vk.FormatR16Sfloat,
+		vk.FormatR5g6b5UnormPack16, vk.FormatD16Unorm:
+		return 2
+	case vk.FormatD16UnormS8Uint:
+		return 3
+	case vk.FormatR8g8b8a8Unorm, vk.FormatR8g8b8a8Snorm, vk.FormatR8g8b8a8Uint, vk.FormatR8g8b8a8Sint,
+		vk.FormatR8g8b8a8Srgb, vk.FormatB8g8r8a8Unorm, vk.FormatB8g8r8a8Srgb, vk.FormatB8g8r8a8Uint,
+		vk.FormatR16g16Unorm, vk.FormatR16g16Uint, vk.FormatR16g16Sint, vk.FormatR16g16Sfloat,
+		vk.FormatR32Uint, vk.FormatR32Sint, vk.FormatR32Sfloat,
+		vk.FormatA2b10g10r10UnormPack32, vk.FormatA2r10g10b10UnormPack32,
+		vk.FormatB10g11r11UfloatPack32, vk.FormatE5b9g9r9UfloatPack32,
+		vk.FormatX8D24UnormPack32, vk.FormatD32Sfloat, vk.FormatD24UnormS8Uint:
+		return 4
+	case vk.FormatD32SfloatS8Uint:
+		return 5
+	case vk.FormatR16g16b16a16Unorm, vk.FormatR16g16b16a16Uint, vk.FormatR16g16b16a16Sint,
+		vk.FormatR16g16b16a16Sfloat, vk.FormatR32g32Uint, vk.FormatR32g32Sint, vk.FormatR32g32Sfloat,
+		vk.FormatR64Sfloat:
+		return 8
+	case vk.FormatR32g32b32Uint, vk.FormatR32g32b32Sint, vk.FormatR32g32b32Sfloat:
+		return 12
+	case vk.FormatR32g32b32a32Uint, vk.FormatR32g32b32a32Sint, vk.FormatR32g32b32a32Sfloat,
+		vk.FormatR64g64Sfloat:
+		return 16
+	case vk.FormatR64g64b64a64Sfloat:
+		return 32
+	}
+	return 0
+}
+
+// aspectTexelSize returns the size of a texel of a single aspect of a format as it is
+// laid out in a buffer, depth and stencil aspects are copied separately
+func aspectTexelSize(format vk.Format, aspect vk.ImageAspectFlags) int {
+	switch aspect {
+	case vk.ImageAspectFlags(vk.ImageAspectStencilBit):
+		return 1
+	case vk.ImageAspectFlags(vk.ImageAspectDepthBit):
+		if format == vk.FormatD16Unorm || format == vk.FormatD16UnormS8Uint {
+			return 2
+		}
+		return 4
+	}
+	return FormatTexelSize(format)
+}
+
+// MipExtent returns the extent of the specified mip level of this image
+func (i *Image) MipExtent(level int) vk.Extent3D {
+	extent := vk.Extent3D{
+		Width:  i.Extent.Width >> uint(level),
+		Height: i.Extent.Height >> uint(level),
+		Depth:  1,
+	}
+	if extent.Width == 0 {
+		extent.Width = 1
+	}
+	if extent.Height == 0 {
+		extent.Height = 1
+	}
+	return extent
+}
+
+func (i *Image) mipLevelCount() int {
+	if i.MipLevels == 0 {
+		return 1
+	}
+	return i.MipLevels
+}
+
+func (i *Image) arrayLayerCount() int {
+	if i.ArrayLayers == 0 {
+		return 1
+	}
+	return i.ArrayLayers
+}
+
+func (i *Image) sampleCount() vk.SampleCountFlagBits {
+	if i.Samples == 0 {
+		return vk.SampleCount1Bit
+	}
+	return i.Samples
+}
+
+// resolve fills in the defaults for this region and validates it against the image
+func (r ImageRegion) resolve(img *Image) (vk.ImageSubresourceLayers, vk.Offset3D, vk.Extent3D, error) {
+	var sub vk.ImageSubresourceLayers
+
+	if r.MipLevel < 0 || r.MipLevel >= img.mipLevelCount() {
+		return sub, r.Offset, r.Extent, fmt.Errorf("mip level %d out of range, image has %d levels", r.MipLevel, img.mipLevelCount())
+	}
+
+	layers := r.LayerCount
+	if layers == 0 {
+		layers = 1
+	}
+	if r.BaseArrayLayer < 0 || layers < 0 || r.BaseArrayLayer+layers > img.arrayLayerCount() {
+		return sub, r.Offset, r.Extent, fmt.Errorf("array layers %d-%d out of range, image has %d layers", r.BaseArrayLayer, r.BaseArrayLayer+layers-1, img.arrayLayerCount())
+	}
+
+	formatAspects := FormatAspectMask(img.VKFormat)
+	aspect := r.AspectMask
+	if aspect == 0 {
+		aspect = formatAspects
+	} else if aspect&^formatAspects != 0 {
+		return sub, r.Offset, r.Extent, fmt.Errorf("aspect mask %d is not present in image format %d", aspect, img.VKFormat)
+	}
+
+	mip := img.MipExtent(r.MipLevel)
+	offset := r.Offset
+	if offset.X < 0 || offset.Y < 0 || offset.Z != 0 || uint32(offset.X) > mip.Width || uint32(offset.Y) > mip.Height {
+		return sub, r.Offset, r.Extent, fmt.Errorf("offset %v is outside of mip level %d with extent %dx%d", offset, r.MipLevel, mip.Width, mip.Height)
+	}
+
+	extent := r.Extent
+	if extent.Width == 0 {
+		extent.Width = mip.Width - uint32(offset.X)
+	}
+	if extent.Height == 0 {
+		extent.Height = mip.Height - uint32(offset.Y)
+	}
+	if extent.Depth == 0 {
+		extent.Depth = 1
+	}
+	if uint32(offset.X)+extent.Width > mip.Width || uint32(offset.Y)+extent.Height > mip.Height || extent.Depth != 1 {
+		return sub, r.Offset, r.Extent, fmt.Errorf("region %dx%dx%d at %v exceeds mip level %d with extent %dx%d",
+			extent.Width, extent.Height, extent.Depth, offset, r.MipLevel, mip.Width, mip.Height)
+	}
+
+	sub.AspectMask = aspect
+	sub.MipLevel = uint32(r.MipLevel)
+	sub.BaseArrayLayer = uint32(r.BaseArrayLayer)
+	sub.LayerCount = uint32(layers)
+
+	return sub, offset, extent, nil
+}
+
+func requireImageUsage(img *Image, usage vk.ImageUsageFlagBits, name string) error {
+	if img.Usage != 0 && img.Usage&usage == 0 {
+		return fmt.Errorf("%s image was not created with the required usage %d", name, usage)
+	}
+	return nil
+}
+
+func requireBufferUsage(buf *Buffer, usage vk.BufferUsageFlagBits, name string) error {
+	if buf.Usage != 0 && buf.Usage&usage == 0 {
+		return fmt.Errorf("%s buffer was not created with the required usage %s", name, usageToString(usage))
+	}
+	return nil
+}
+
+// requireFormatFeatures checks the image's format supports the specified features for its tiling
+func requireFormatFeatures(img *Image, features vk.FormatFeatureFlagBits, name string) error {
+	if img.Device == nil || img.Device.PhysicalDevice == nil {
+		return nil
+	}
+	props := img.Device.PhysicalDevice.VKFormatProperties(img.VKFormat)
+	supported := props.OptimalTilingFeatures
+	if img.Tiling == vk.ImageTilingLinear {
+		supported = props.LinearTilingFeatures
+	}
+	if supported&vk.FormatFeatureFlags(features) != vk.FormatFeatureFlags(features) {
+		return fmt.Errorf("%s image format %d does not support features %d", name, img.VKFormat, features)
+	}
+	return nil
+}
+
+func copyRegions(regions []ImageCopyRegion) []ImageCopyRegion {
+	if len(regions) == 0 {
+		return []ImageCopyRegion{{}}
+	}
+	return regions
+}
+
+// resolveCopyRegions validates regions where the source and destination extents must match
+func resolveCopyRegions(src, dst *Image, regions []ImageCopyRegion) ([]vk.ImageCopy, error) {
+	regions = copyRegions(regions)
+	copies := make([]vk.ImageCopy, len(regions))
+	for i, r := range regions {
+		srcSub, srcOffset, extent, err := r.Src.resolve(src)
+		if err != nil {
+			return nil, fmt.Errorf("source region %d: %w", i, err)
+		}
+		if r.Dst.Extent.Width == 0 && r.Dst.Extent.Height == 0 {
+			r.Dst.Extent = extent
+		}
+		dstSub, dstOffset, dstExtent, err := r.Dst.resolve(dst)
+		if err != nil {
+			return nil, fmt.Errorf("destination region %d: %w", i, err)
+		}
+		if dstExtent != extent {
+			return nil, fmt.Errorf("region %d: source and destination extents differ, use CmdBlitImage to scale", i)
+		}
+		if srcSub.AspectMask != dstSub.AspectMask || srcSub.LayerCount != dstSub.LayerCount {
+			return nil, fmt.Errorf("region %d: source and destination aspects and layer counts must match", i)
+		}
+		copies[i] = vk.ImageCopy{
+			SrcSubresource: srcSub,
+			SrcOffset:      srcOffset,
+			DstSubresource: dstSub,
+			DstOffset:      dstOffset,
+			Extent:         extent,
+		}
+	}
+	return copies, nil
+}
+
+// VKImageCopies validates the regions against the images and creates the native structures for
+// vkCmdCopyImage, see CmdCopyImage
+func VKImageCopies(src, dst *Image, regions ...ImageCopyRegion) ([]vk.ImageCopy, error) {
+	srcSize, dstSize := FormatTexelSize(src.VKFormat), FormatTexelSize(dst.VKFormat)
+	if src.VKFormat != dst.VKFormat && (srcSize == 0 || srcSize != dstSize) {
+		return nil, fmt.Errorf("image formats %d and %d are not size compatible", src.VKFormat, dst.VKFormat)
+	}
+	if src.sampleCount() != dst.sampleCount() {
+		return nil, fmt.Errorf("images must have the same sample count to be copied")
+	}
+
+	return resolveCopyRegions(src, dst, regions)
+}
+
+// CmdCopyImage copies regions of one image to another without scaling or format conversion, the source
+// must be in srcLayout and the destination in dstLayout which are typically vk.ImageLayoutTransferSrcOptimal
+// and vk.ImageLayoutTransferDstOptimal. If no regions are given the first layer of the first mip level is copied.
+func (c *CommandBuffer) CmdCopyImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, regions ...ImageCopyRegion) error {
+	copies, err := imageCopyCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+
+	c.FlushBarriers()
+	vk.CmdCopyImage(c.VKCommandBuffer, src.VKImage, srcLayout, dst.VKImage, dstLayout, uint32(len(copies)), copies)
+	return nil
+}
+
+func imageCopyCommand(src, dst *ImageResource, regions []ImageCopyRegion) ([]vk.ImageCopy, error) {
+	if err := requireImageUsage(&src.Image, vk.ImageUsageTransferSrcBit, "source"); err != nil {
+		return nil, err
+	}
+	if err := requireImageUsage(&dst.Image, vk.ImageUsageTransferDstBit, "destination"); err != nil {
+		return nil, err
+	}
+	return VKImageCopies(&src.Image, &dst.Image, regions...)
+}
+
+// VKImageBlits validates the regions against the images and creates the native structures for
+// vkCmdBlitImage, see CmdBlitImage
+func VKImageBlits(src, dst *Image, filter vk.Filter, regions ...ImageCopyRegion) ([]vk.ImageBlit, error) {
+	if src.sampleCount() != vk.SampleCount1Bit || dst.sampleCount() != vk.SampleCount1Bit {
+		return nil, fmt.Errorf("multisampled images can not be blitted, use CmdResolveImage")
+	}
+	if IsDepthFormat(src.VKFormat) || HasStencilComponent(src.VKFormat) || IsDepthFormat(dst.VKFormat) || HasStencilComponent(dst.VKFormat) {
+		if src.VKFormat != dst.VKFormat {
+			return nil, fmt.Errorf("depth/stencil images can only be blitted to the same format")
+		}
+		if filter != vk.FilterNearest {
+			return nil, fmt.Errorf("depth/stencil images can only be blitted with vk.FilterNearest")
+		}
+	}
+
+	regions = copyRegions(regions)
+	blits := make([]vk.ImageBlit, len(regions))
+	for i, r := range regions {
+		srcSub, srcOffset, srcExtent, err := r.Src.resolve(src)
+		if err != nil {
+			return nil, fmt.Errorf("source region %d: %w", i, err)
+		}
+		dstSub, dstOffset, dstExtent, err := r.Dst.resolve(dst)
+		if err != nil {
+			return nil, fmt.Errorf("destination region %d: %w", i, err)
+		}
+		if srcSub.AspectMask != dstSub.AspectMask || srcSub.LayerCount != dstSub.LayerCount {
+			return nil, fmt.Errorf("region %d: source and destination aspects and layer counts must match", i)
+		}
+		blits[i] = vk.ImageBlit{
+			SrcSubresource: srcSub,
+			SrcOffsets: [2]vk.Offset3D{srcOffset, {
+				X: srcOffset.X + int32(srcExtent.Width),
+				Y: srcOffset.Y + int32(srcExtent.Height),
+				Z: srcOffset.Z + int32(srcExtent.Depth),
+			}},
+			DstSubresource: dstSub,
+			DstOffsets: [2]vk.Offset3D{dstOffset, {
+				X: dstOffset.X + int32(dstExtent.Width),
+				Y: dstOffset.Y + int32(dstExtent.Height),
+				Z: dstOffset.Z + int32(dstExtent.Depth),
+			}},
+		}
+	}
+	return blits, nil
+}
+
+// CmdBlitImage copies regions of one image to another, scaling and converting formats as required using
+// the specified filter. If no regions are given the first mip level of the source is scaled to fit the first
+// mip level of the destination.
+func (c *CommandBuffer) CmdBlitImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, filter vk.Filter, regions ...ImageCopyRegion) error {
+	blits, err := imageBlitCommand(src, dst, filter, regions)
+	if err != nil {
+		return err
+	}
+
+	c.FlushBarriers()
+	vk.CmdBlitImage(c.VKCommandBuffer, src.VKImage, srcLayout, dst.VKImage, dstLayout, uint32(len(blits)), blits, filter)
+	return nil
+}
+
+func imageBlitCommand(src, dst *ImageResource, filter vk.Filter, regions []ImageCopyRegion) ([]vk.ImageBlit, error) {
+	if err := requireImageUsage(&src.Image, vk.ImageUsageTransferSrcBit, "source"); err != nil {
+		return nil, err
+	}
+	if err := requireImageUsage(&dst.Image, vk.ImageUsageTransferDstBit, "destination"); err != nil {
+		return nil, err
+	}
+
+	srcFeatures := vk.FormatFeatureBlitSrcBit
+	if filter == vk.FilterLinear {
+		srcFeatures |= vk.FormatFeatureSampledImageFilterLinearBit
+	}
+	if err := requireFormatFeatures(&src.Image, srcFeatures, "source"); err != nil {
+		return nil, err
+	}
+	if err := requireFormatFeatures(&dst.Image, vk.FormatFeatureBlitDstBit, "destination"); err != nil {
+		return nil, err
+	}
+
+	return VKImageBlits(&src.Image, &dst.Image, filter, regions...)
+}
+
+// VKImageResolves validates the regions against the images and creates the native structures for
+// vkCmdResolveImage, see CmdResolveImage
+func VKImageResolves(src, dst *Image, regions ...ImageCopyRegion) ([]vk.ImageResolve, error) {
+	if src.sampleCount() == vk.SampleCount1Bit {
+		return nil, fmt.Errorf("source image of a resolve must be multisampled")
+	}
+	if dst.sampleCount() != vk.SampleCount1Bit {
+		return nil, fmt.Errorf("destination image of a resolve must not be multisampled")
+	}
+	if src.VKFormat != dst.VKFormat {
+		return nil, fmt.Errorf("resolve requires the same format, got %d and %d", src.VKFormat, dst.VKFormat)
+	}
+	if FormatAspectMask(src.VKFormat) != vk.ImageAspectFlags(vk.ImageAspectColorBit) {
+		return nil, fmt.Errorf("only color images can be resolved")
+	}
+
+	copies, err := resolveCopyRegions(src, dst, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	resolves := make([]vk.ImageResolve, len(copies))
+	for i, c := range copies {
+		resolves[i] = vk.ImageResolve{
+			SrcSubresource: c.SrcSubresource,
+			SrcOffset:      c.SrcOffset,
+			DstSubresource: c.DstSubresource,
+			DstOffset:      c.DstOffset,
+			Extent:         c.Extent,
+		}
+	}
+	return resolves, nil
+}
+
+// CmdResolveImage resolves a multisampled image into a single sampled image of the same format, if no
+// regions are given the first layer of the first mip level is resolved.
+func (c *CommandBuffer) CmdResolveImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, regions ...ImageCopyRegion) error {
+	resolves, err := imageResolveCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+
+	c.FlushBarriers()
+	vk.CmdResolveImage(c.VKCommandBuffer, src.VKImage, srcLayout, dst.VKImage, dstLayout, uint32(len(resolves)), resolves)
+	return nil
+}
+
+func imageResolveCommand(src, dst *ImageResource, regions []ImageCopyRegion) ([]vk.ImageResolve, error) {
+	if err := requireImageUsage(&dst.Image, vk.ImageUsageTransferDstBit, "destination"); err != nil {
+		return nil, err
+	}
+	return VKImageResolves(&src.Image, &dst.Image, regions...)
+}
+
+// VKBufferImageCopies validates the regions against the buffer and image and creates the native
+// structures for vkCmdCopyBufferToImage and vkCmdCopyImageToBuffer
+func VKBufferImageCopies(buf *Buffer, img *Image, regions ...BufferImageRegion) ([]vk.BufferImageCopy, error) {
+	if img.sampleCount() != vk.SampleCount1Bit {
+		return nil, fmt.Errorf("multisampled images can not be copied to or from buffers")
+	}
+
+	if len(regions) == 0 {
+		regions = []BufferImageRegion{{}}
+	}
+
+	copies := make([]vk.BufferImageCopy, len(regions))
+	for i, r := range regions {
+		if r.Image.AspectMask == 0 && FormatAspectMask(img.VKFormat) == vk.ImageAspectFlags(vk.ImageAspectDepthBit|vk.ImageAspectStencilBit) {
+			// Only a single aspect can be copied at once, so default to depth
+			r.Image.AspectMask = vk.ImageAspectFlags(vk.ImageAspectDepthBit)
+		}
+
+		sub, offset, extent, err := r.Image.resolve(img)
+		if err != nil {
+			return nil, fmt.Errorf("region %d: %w", i, err)
+		}
+		if sub.AspectMask == vk.ImageAspectFlags(vk.ImageAspectDepthBit|vk.ImageAspectStencilBit) {
+			return nil, fmt.Errorf("region %d: depth and stencil aspects must be copied separately", i)
+		}
+
+		if r.BufferOffset%4 != 0 {
+			return nil, fmt.Errorf("region %d: buffer offset %d must be a multiple of 4", i, r.BufferOffset)
+		}
+
+		rowLength := uint64(extent.Width)
+		if r.BufferRowLength != 0 {
+			if r.BufferRowLength < int(extent.Width) {
+				return nil, fmt.Errorf("region %d: buffer row length %d is less than the region width %d", i, r.BufferRowLength, extent.Width)
+			}
+			rowLength = uint64(r.BufferRowLength)
+		}
+		imageHeight := uint64(extent.Height)
+		if r.BufferImageHeight != 0 {
+			if r.BufferImageHeight < int(extent.Height) {
+				return nil, fmt.Errorf("region %d: buffer image height %d is less than the region height %d", i, r.BufferImageHeight, extent.Height)
+			}
+			imageHeight = uint64(r.BufferImageHeight)
+		}
+
+		if texelSize := uint64(aspectTexelSize(img.VKFormat, sub.AspectMask)); texelSize != 0 {
+			if r.BufferOffset%texelSize != 0 {
+				return nil, fmt.Errorf("region %d: buffer offset %d must be a multiple of the texel size %d", i, r.BufferOffset, texelSize)
+			}
+			texels := rowLength*imageHeight*uint64(sub.LayerCount-1) + rowLength*uint64(extent.Height-1) + uint64(extent.Width)
+			required := r.BufferOffset + texels*texelSize
+			if buf.Size != 0 && required > buf.Size {
+				return nil, fmt.Errorf("region %d: requires %d bytes but buffer is only %d bytes", i, required, buf.Size)
+			}
+		}
+
+		copies[i] = vk.BufferImageCopy{
+			BufferOffset:      vk.DeviceSize(r.BufferOffset),
+			BufferRowLength:   uint32(r.BufferRowLength),
+			BufferImageHeight: uint32(r.BufferImageHeight),
+			ImageSubresource:  sub,
+			ImageOffset:       offset,
+			ImageExtent:       extent,
+		}
+	}
+	return copies, nil
+}
+
+// CmdCopyBufferToImage copies regions of a buffer into an image which must be in dstLayout, typically
+// vk.ImageLayoutTransferDstOptimal. If no regions are given a tightly packed buffer is copied into the
+// first layer of the first mip level.
+func (c *CommandBuffer) CmdCopyBufferToImage(src *BufferResource, dst *ImageResource, dstLayout vk.ImageLayout, regions ...BufferImageRegion) error {
+	copies, err := bufferToImageCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+
+	c.FlushBarriers()
+	vk.CmdCopyBufferToImage(c.VKCommandBuffer, src.VKBuffer, dst.VKImage, dstLayout, uint32(len(copies)), copies)
+	return nil
+}
+
+func bufferToImageCommand(src *BufferResource, dst *ImageResource, regions []BufferImageRegion) ([]vk.BufferImageCopy, error) {
+	if err := requireBufferUsage(&src.Buffer, vk.BufferUsageTransferSrcBit, "source"); err != nil {
+		return nil, err
+	}
+	if err := requireImageUsage(&dst.Image, vk.ImageUsageTransferDstBit, "destination"); err != nil {
+		return nil, err
+	}
+	return VKBufferImageCopies(&src.Buffer, &dst.Image, regions...)
+}
+
+// CmdCopyImageToBuffer copies regions of an image which must be in srcLayout, typically
+// vk.ImageLayoutTransferSrcOptimal, into a buffer. If no regions are given the first layer of the
+// first mip level is copied into a tightly packed buffer.
+func (c *CommandBuffer) CmdCopyImageToBuffer(src *ImageResource, srcLayout vk.ImageLayout, dst *BufferResource, regions ...BufferImageRegion) error {
+	copies, err := imageToBufferCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+
+	c.FlushBarriers()
+	vk.CmdCopyImageToBuffer(c.VKCommandBuffer, src.VKImage, srcLayout, dst.VKBuffer, uint32(len(copies)), copies)
+	return nil
+}
+
+func imageToBufferCommand(src *ImageResource, dst *BufferResource, regions []BufferImageRegion) ([]vk.BufferImageCopy, error) {
+	if err := requireImageUsage(&src.Image, vk.ImageUsageTransferSrcBit, "source"); err != nil {
+		return nil, err
+	}
+	if err := requireBufferUsage(&dst.Buffer, vk.BufferUsageTransferDstBit, "destination"); err != nil {
+		return nil, err
+	}
+	return VKBufferImageCopies(&dst.Buffer, &src.Image, regions...)
+}
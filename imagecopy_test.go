@@ -0,0 +1,58 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestImageCopyValidation(t *testing.T) {
+	src := &Image{VKFormat: vk.FormatR8g8b8a8Unorm, Extent: vk.Extent2D{Width: 64, Height: 32}, MipLevels: 2}
+	dst := &Image{VKFormat: vk.FormatR8g8b8a8Srgb, Extent: vk.Extent2D{Width: 32, Height: 16}}
+
+	copies, err := VKImageCopies(src, dst, ImageCopyRegion{Src: ImageRegion{MipLevel: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copies[0].Extent.Width != 32 || copies[0].Extent.Height != 16 || copies[0].SrcSubresource.MipLevel != 1 {
+		t.Errorf("unexpected copy %+v", copies[0])
+	}
+
+	if _, err := VKImageCopies(src, dst); err == nil {
+		t.Error("expected copy of mismatched extents to fail")
+	}
+	if _, err := VKImageCopies(src, dst, ImageCopyRegion{Src: ImageRegion{MipLevel: 2}}); err == nil {
+		t.Error("expected copy of missing mip level to fail")
+	}
+	if _, err := VKImageCopies(src, &Image{VKFormat: vk.FormatR16g16b16a16Sfloat, Extent: dst.Extent}); err == nil {
+		t.Error("expected copy between incompatible formats to fail")
+	}
+
+	blits, err := VKImageBlits(src, dst, vk.FilterLinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blits[0].SrcOffsets[1].X != 64 || blits[0].DstOffsets[1].X != 32 {
+		t.Errorf("unexpected blit %+v", blits[0])
+	}
+}
+
+func TestBufferImageCopyValidation(t *testing.T) {
+	img := &Image{VKFormat: vk.FormatR8g8b8a8Unorm, Extent: vk.Extent2D{Width: 16, Height: 16}}
+
+	if _, err := VKBufferImageCopies(&Buffer{Size: 16 * 16 * 4}, img); err != nil {
+		t.Error(err)
+	}
+	if _, err := VKBufferImageCopies(&Buffer{Size: 16 * 16 * 4}, img, BufferImageRegion{BufferOffset: 4}); err == nil {
+		t.Error("expected copy past the end of the buffer to fail")
+	}
+
+	depth := &Image{VKFormat: vk.FormatD24UnormS8Uint, Extent: vk.Extent2D{Width: 16, Height: 16}}
+	copies, err := VKBufferImageCopies(&Buffer{Size: 16 * 16 * 4}, depth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copies[0].ImageSubresource.AspectMask != vk.ImageAspectFlags(vk.ImageAspectDepthBit) {
+		t.Error("expected depth/stencil copies to default to the depth aspect")
+	}
+}
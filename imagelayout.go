@@ -0,0 +1,268 @@
+package vkg
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// layoutUsage describes how an image in a specific layout is accessed, which is used
+// to derive the access masks and pipeline stages for a layout transition
+type layoutUsage struct {
+	// Access used when the layout is the source of a transition, only writes
+	// need to be made available so this is typically a subset of DstAccess
+	SrcAccess vk.AccessFlags
+	// Access used when the layout is the destination of a transition
+	DstAccess vk.AccessFlags
+	// Stages which can access an image in this layout
+	Stages vk.PipelineStageFlags
+	// Can this layout be transitioned to?
+	ValidAsNew bool
+}
+
+// layoutUsages is the table of common layouts used to derive barriers, see
+// https://www.khronos.org/registry/vulkan/specs/1.1-extensions/html/vkspec.html#synchronization-access-types-supported
+var layoutUsages = map[vk.ImageLayout]layoutUsage{
+	vk.ImageLayoutUndefined: {
+		Stages: vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit),
+	},
+	vk.ImageLayoutPreinitialized: {
+		SrcAccess: vk.AccessFlags(vk.AccessHostWriteBit),
+		Stages:    vk.PipelineStageFlags(vk.PipelineStageHostBit),
+	},
+	vk.ImageLayoutGeneral: {
+		SrcAccess:  vk.AccessFlags(vk.AccessShaderWriteBit | vk.AccessTransferWriteBit | vk.AccessColorAttachmentWriteBit),
+		DstAccess:  vk.AccessFlags(vk.AccessShaderReadBit | vk.AccessShaderWriteBit | vk.AccessTransferReadBit | vk.AccessTransferWriteBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutColorAttachmentOptimal: {
+		SrcAccess:  vk.AccessFlags(vk.AccessColorAttachmentWriteBit),
+		DstAccess:  vk.AccessFlags(vk.AccessColorAttachmentReadBit | vk.AccessColorAttachmentWriteBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutDepthStencilAttachmentOptimal: {
+		SrcAccess:  vk.AccessFlags(vk.AccessDepthStencilAttachmentWriteBit),
+		DstAccess:  vk.AccessFlags(vk.AccessDepthStencilAttachmentReadBit | vk.AccessDepthStencilAttachmentWriteBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageEarlyFragmentTestsBit | vk.PipelineStageLateFragmentTestsBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutDepthStencilReadOnlyOptimal: {
+		DstAccess:  vk.AccessFlags(vk.AccessDepthStencilAttachmentReadBit | vk.AccessShaderReadBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageEarlyFragmentTestsBit | vk.PipelineStageLateFragmentTestsBit | vk.PipelineStageFragmentShaderBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutShaderReadOnlyOptimal: {
+		DstAccess:  vk.AccessFlags(vk.AccessShaderReadBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageVertexShaderBit | vk.PipelineStageFragmentShaderBit | vk.PipelineStageComputeShaderBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutTransferSrcOptimal: {
+		DstAccess:  vk.AccessFlags(vk.AccessTransferReadBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutTransferDstOptimal: {
+		SrcAccess:  vk.AccessFlags(vk.AccessTransferWriteBit),
+		DstAccess:  vk.AccessFlags(vk.AccessTransferWriteBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+		ValidAsNew: true,
+	},
+	vk.ImageLayoutPresentSrc: {
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit),
+		ValidAsNew: true,
+	},
+}
+
+// IsDepthFormat returns true if the format has a depth component
+func IsDepthFormat(format vk.Format) bool {
+	switch format {
+	case vk.FormatD16Unorm, vk.FormatX8D24UnormPack32, vk.FormatD32Sfloat,
+		vk.FormatD16UnormS8Uint, vk.FormatD24UnormS8Uint, vk.FormatD32SfloatS8Uint:
+		return true
+	}
+	return false
+}
+
+// HasStencilComponent returns true if the format has a stencil component
+func HasStencilComponent(format vk.Format) bool {
+	switch format {
+	case vk.FormatS8Uint, vk.FormatD16UnormS8Uint, vk.FormatD24UnormS8Uint, vk.FormatD32SfloatS8Uint:
+		return true
+	}
+	return false
+}
+
+// FormatAspectMask returns the image aspects which make up the specified format, so
+// depth formats return the depth aspect, combined depth/stencil formats return both
+// and everything else is assumed to be a color format
+func FormatAspectMask(format vk.Format) vk.ImageAspectFlags {
+	var mask vk.ImageAspectFlagBits
+	if IsDepthFormat(format) {
+		mask |= vk.ImageAspectDepthBit
+	}
+	if HasStencilComponent(format) {
+		mask |= vk.ImageAspectStencilBit
+	}
+	if mask == 0 {
+		mask = vk.ImageAspectColorBit
+	}
+	return vk.ImageAspectFlags(mask)
+}
+
+// FullSubresourceRange returns a subresource range which covers all aspects, mip levels and
+// array layers of this image
+func (i *Image) FullSubresourceRange() vk.ImageSubresourceRange {
+	return vk.ImageSubresourceRange{
+		AspectMask:     FormatAspectMask(i.VKFormat),
+		BaseMipLevel:   0,
+		LevelCount:     vk.RemainingMipLevels,
+		BaseArrayLayer: 0,
+		LayerCount:     vk.RemainingArrayLayers,
+	}
+}
+
+// QueueOwnershipTransfer describes a transfer of an image or buffer between two queue families. A
+// transfer must be recorded twice, once as a release on a queue from the source family and once
+// as an acquire on a queue from the destination family, with a semaphore between the two submissions.
+type QueueOwnershipTransfer struct {
+	Src *QueueFamily
+	Dst *QueueFamily
+	// Acquire is set when recording the destination half of the transfer
+	Acquire bool
+}
+
+// ImageLayoutTransition describes the transition of an image from one layout to
+// another, the access masks and pipeline stages are derived from the layouts unless
+// they are explicitly provided
+type ImageLayoutTransition struct {
+	Image     *Image
+	OldLayout vk.ImageLayout
+	NewLayout vk.ImageLayout
+
+	// SubresourceRange defaults to the full image with the aspect derived from the image format
+	SubresourceRange *vk.ImageSubresourceRange
+
+	// SrcStageMask and DstStageMask override the stages derived from the layouts when non zero,
+	// for example to limit a transition to ShaderReadOnlyOptimal to the compute stage. When a command
+	// buffer records the transition the derived stages are limited to those its queue family supports.
+	SrcStageMask vk.PipelineStageFlags
+	DstStageMask vk.PipelineStageFlags
+
+	// Ownership if set will also transfer the image between queue families
+	Ownership *QueueOwnershipTransfer
+}
+
+// VKImageMemoryBarrier creates the image memory barrier and the source and destination stages
+// required for this transition, an error is returned if the transition is not supported
+func (t *ImageLayoutTransition) VKImageMemoryBarrier() (vk.ImageMemoryBarrier, vk.PipelineStageFlags, vk.PipelineStageFlags, error) {
+	return t.imageMemoryBarrier(nil)
+}
+
+// imageMemoryBarrier is VKImageMemoryBarrier for a barrier recorded on a queue of the family, which may be nil
+func (t *ImageLayoutTransition) imageMemoryBarrier(family *QueueFamily) (vk.ImageMemoryBarrier, vk.PipelineStageFlags, vk.PipelineStageFlags, error) {
+	var barrier = vk.ImageMemoryBarrier{}
+
+	src, ok := layoutUsages[t.OldLayout]
+	if !ok {
+		return barrier, 0, 0, fmt.Errorf("unsupported image layout transition from layout %d", t.OldLayout)
+	}
+	dst, ok := layoutUsages[t.NewLayout]
+	if !ok || !dst.ValidAsNew {
+		return barrier, 0, 0, fmt.Errorf("unsupported image layout transition to layout %d", t.NewLayout)
+	}
+
+	barrier.SType = vk.StructureTypeImageMemoryBarrier
+	barrier.OldLayout = t.OldLayout
+	barrier.NewLayout = t.NewLayout
+	barrier.SrcQueueFamilyIndex = vk.QueueFamilyIgnored
+	barrier.DstQueueFamilyIndex = vk.QueueFamilyIgnored
+	barrier.Image = t.Image.VKImage
+	barrier.SrcAccessMask = src.SrcAccess
+	barrier.DstAccessMask = dst.DstAccess
+
+	if t.SubresourceRange != nil {
+		barrier.SubresourceRange = *t.SubresourceRange
+	} else {
+		barrier.SubresourceRange = t.Image.FullSubresourceRange()
+	}
+
+	srcStage := family.SupportedStages(src.Stages)
+	if t.SrcStageMask != 0 {
+		srcStage = t.SrcStageMask
+	} else if srcStage == 0 {
+		// None of the stages which could have written the image run on this queue
+		barrier.SrcAccessMask = 0
+		srcStage = vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit)
+	}
+	dstStage := family.SupportedStages(dst.Stages)
+	if t.DstStageMask != 0 {
+		dstStage = t.DstStageMask
+	} else if dstStage == 0 {
+		// None of the stages which will read the image run on this queue
+		barrier.DstAccessMask = 0
+		dstStage = vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit)
+	}
+
+	if t.Ownership != nil {
+		if t.Ownership.Src == nil || t.Ownership.Dst == nil {
+			return barrier, 0, 0, fmt.Errorf("queue ownership transfer requires both a source and destination queue family")
+		}
+		barrier.SrcQueueFamilyIndex = uint32(t.Ownership.Src.Index)
+		barrier.DstQueueFamilyIndex = uint32(t.Ownership.Dst.Index)
+		if t.Ownership.Acquire {
+			// The source half of the barrier was executed by the release on the other queue
+			barrier.SrcAccessMask = 0
+			srcStage = vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit)
+		} else {
+			// The destination half of the barrier will be executed by the acquire on the other queue
+			barrier.DstAccessMask = 0
+			dstStage = vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit)
+		}
+	}
+
+	return barrier, srcStage, dstStage, nil
+}
+
+// CmdPipelineBarrier records a pipeline barrier with the specified memory, buffer and image barriers
+func (c *CommandBuffer) CmdPipelineBarrier(srcStage, dstStage vk.PipelineStageFlags, dependencyFlags vk.DependencyFlags, memoryBarriers []vk.MemoryBarrier, bufferBarriers []vk.BufferMemoryBarrier, imageBarriers []vk.ImageMemoryBarrier) {
+	c.FlushBarriers()
+	c.cmdPipelineBarrier(srcStage, dstStage, dependencyFlags, memoryBarriers, bufferBarriers, imageBarriers)
+}
+
+func (c *CommandBuffer) cmdPipelineBarrier(srcStage, dstStage vk.PipelineStageFlags, dependencyFlags vk.DependencyFlags, memoryBarriers []vk.MemoryBarrier, bufferBarriers []vk.BufferMemoryBarrier, imageBarriers []vk.ImageMemoryBarrier) {
+	vk.CmdPipelineBarrier(c.VKCommandBuffer, srcStage, dstStage, dependencyFlags,
+		uint32(len(memoryBarriers)), memoryBarriers,
+		uint32(len(bufferBarriers)), bufferBarriers,
+		uint32(len(imageBarriers)), imageBarriers)
+}
+
+// TransitionImageLayouts records a single pipeline barrier containing all of the specified transitions
+func (c *CommandBuffer) TransitionImageLayouts(transitions ...*ImageLayoutTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	var srcStage, dstStage vk.PipelineStageFlags
+	barriers := make([]vk.ImageMemoryBarrier, len(transitions))
+
+	for i, t := range transitions {
+		barrier, s, d, err := t.imageMemoryBarrier(c.QueueFamily)
+		if err != nil {
+			return err
+		}
+		barriers[i] = barrier
+		srcStage |= s
+		dstStage |= d
+	}
+
+	c.CmdPipelineBarrier(srcStage, dstStage, 0, nil, nil, barriers)
+
+	return nil
+}
+
+// TransitionImageLayoutWithOptions records a pipeline barrier for the specified transition
+func (c *CommandBuffer) TransitionImageLayoutWithOptions(t *ImageLayoutTransition) error {
+	return c.TransitionImageLayouts(t)
+}
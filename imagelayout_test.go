@@ -0,0 +1,86 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestFormatAspectMask(t *testing.T) {
+	if FormatAspectMask(vk.FormatR8g8b8a8Unorm) != vk.ImageAspectFlags(vk.ImageAspectColorBit) {
+		t.Error("expected color aspect for color format")
+	}
+	if FormatAspectMask(vk.FormatD32Sfloat) != vk.ImageAspectFlags(vk.ImageAspectDepthBit) {
+		t.Error("expected depth aspect for depth format")
+	}
+	if FormatAspectMask(vk.FormatD24UnormS8Uint) != vk.ImageAspectFlags(vk.ImageAspectDepthBit|vk.ImageAspectStencilBit) {
+		t.Error("expected depth and stencil aspect for depth/stencil format")
+	}
+}
+
+func TestImageLayoutTransition(t *testing.T) {
+	img := &Image{VKFormat: vk.FormatR8g8b8a8Unorm}
+
+	tr := &ImageLayoutTransition{Image: img, OldLayout: vk.ImageLayoutUndefined, NewLayout: vk.ImageLayoutTransferDstOptimal}
+	barrier, src, dst, err := tr.VKImageMemoryBarrier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if barrier.SrcAccessMask != 0 || barrier.DstAccessMask != vk.AccessFlags(vk.AccessTransferWriteBit) {
+		t.Errorf("unexpected access masks %v %v", barrier.SrcAccessMask, barrier.DstAccessMask)
+	}
+	if src != vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit) || dst != vk.PipelineStageFlags(vk.PipelineStageTransferBit) {
+		t.Errorf("unexpected stages %v %v", src, dst)
+	}
+
+	tr = &ImageLayoutTransition{Image: img, OldLayout: vk.ImageLayoutTransferDstOptimal, NewLayout: vk.ImageLayoutUndefined}
+	if _, _, _, err := tr.VKImageMemoryBarrier(); err == nil {
+		t.Error("expected transition to undefined layout to fail")
+	}
+
+	tr = &ImageLayoutTransition{
+		Image:     img,
+		OldLayout: vk.ImageLayoutTransferDstOptimal,
+		NewLayout: vk.ImageLayoutShaderReadOnlyOptimal,
+		Ownership: &QueueOwnershipTransfer{Src: &QueueFamily{Index: 1}, Dst: &QueueFamily{Index: 0}},
+	}
+	barrier, _, dst, err = tr.VKImageMemoryBarrier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if barrier.SrcQueueFamilyIndex != 1 || barrier.DstQueueFamilyIndex != 0 || barrier.DstAccessMask != 0 {
+		t.Errorf("unexpected release barrier %+v", barrier)
+	}
+	if dst != vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit) {
+		t.Errorf("unexpected release stage %v", dst)
+	}
+}
+
+func TestImageLayoutTransitionQueueFamily(t *testing.T) {
+	img := &Image{VKFormat: vk.FormatR8g8b8a8Unorm}
+	compute := &QueueFamily{VKQueueFamilyProperties: vk.QueueFamilyProperties{QueueFlags: vk.QueueFlags(vk.QueueComputeBit | vk.QueueTransferBit)}}
+	transfer := &QueueFamily{VKQueueFamilyProperties: vk.QueueFamilyProperties{QueueFlags: vk.QueueFlags(vk.QueueTransferBit)}}
+
+	tr := &ImageLayoutTransition{Image: img, OldLayout: vk.ImageLayoutTransferDstOptimal, NewLayout: vk.ImageLayoutShaderReadOnlyOptimal}
+	_, _, dst, err := tr.imageMemoryBarrier(compute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst != vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit) {
+		t.Errorf("expected only the compute stage on a compute queue, got %v", dst)
+	}
+
+	barrier, _, dst, err := tr.imageMemoryBarrier(transfer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst != vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit) || barrier.DstAccessMask != 0 {
+		t.Errorf("expected no shader stages on a transfer queue, got %v with access %v", dst, barrier.DstAccessMask)
+	}
+
+	// Explicit stages are used as is
+	tr.DstStageMask = vk.PipelineStageFlags(vk.PipelineStageFragmentShaderBit)
+	if _, _, dst, _ = tr.imageMemoryBarrier(compute); dst != tr.DstStageMask {
+		t.Errorf("expected the explicit stage, got %v", dst)
+	}
+}
@@ -13,14 +13,22 @@ type ImageResource struct {
 	StagingResource *BufferResource
 	// Does this resource have it's own pool it is responsible for?
 	IndividualPool bool
+	// State is the layout, access and ownership of the image as recorded by CommandBuffer.Require
+	State ResourceState
 }
 
 // NewImageResourceWithOptions will create a image resource which has it's own exclusive pool
 func (r *ResourceManager) NewImageResourceWithOptions(extent vk.Extent2D, format vk.Format, tiling vk.ImageTiling, usage vk.ImageUsageFlagBits, sharing vk.SharingMode, mprops vk.MemoryPropertyFlagBits) (*ImageResource, error) {
+	return r.NewImageResource(extent, format, tiling, usage, sharing, mprops, nil)
+}
+
+// NewImageResource will create a image resource which has it's own exclusive pool, the image options
+// can be used to create multisampled, mipmapped or layered images
+func (r *ResourceManager) NewImageResource(extent vk.Extent2D, format vk.Format, tiling vk.ImageTiling, usage vk.ImageUsageFlagBits, sharing vk.SharingMode, mprops vk.MemoryPropertyFlagBits, options *CreateImageOptions) (*ImageResource, error) {
 
 	ir := &ImageResource{}
 
-	img, err := r.Device.CreateImageWithOptions(extent, format, tiling, usage)
+	img, err := r.Device.CreateImage(extent, format, tiling, usage, options)
 	if err != nil {
 		return nil, err
 	}
@@ -45,10 +53,8 @@ func (r *ResourceManager) NewImageResourceWithOptions(extent vk.Extent2D, format
 	pool.Sharing = sharing
 	pool.Memory = memory
 
-	ir.VKImage = img.VKImage
-	ir.Device = img.Device
-	ir.VKFormat = format
-	ir.Extent = extent
+	ir.Image = *img
+	ir.Size = uint64(mr.Size)
 	ir.ResourcePool = pool
 	ir.IndividualPool = true
 
@@ -139,6 +145,7 @@ func (cb *CommandBuffer) StageImageResource(img *ImageResource) error {
 	if img.StagingResource == nil {
 		return fmt.Errorf("no staging resource has been allocated")
 	}
+	cb.FlushBarriers()
 	vk.CmdCopyBufferToImage(cb.VK(), img.StagingResource.VKBuffer, img.VKImage, vk.ImageLayoutTransferDstOptimal, 1, []vk.BufferImageCopy{
 		vk.BufferImageCopy{
 			BufferOffset:      0,
@@ -159,39 +166,24 @@ func (cb *CommandBuffer) StageImageResource(img *ImageResource) error {
 	return nil
 }
 
+// TransitionImageLayout transitions the entire image from one layout to another, the aspect of the image is
+// derived from the specified format. The access masks and pipeline stages are derived from the layouts, this
+// will panic if the transition is unsupported, see TransitionImageLayoutWithOptions for more control.
 func (cb *CommandBuffer) TransitionImageLayout(img *ImageResource, format vk.Format, oldLayout, newLayout vk.ImageLayout) {
-	var barrier = vk.ImageMemoryBarrier{}
-	barrier.SType = vk.StructureTypeImageMemoryBarrier
-	barrier.OldLayout = oldLayout
-	barrier.NewLayout = newLayout
-	barrier.SrcQueueFamilyIndex = vk.QueueFamilyIgnored
-	barrier.DstQueueFamilyIndex = vk.QueueFamilyIgnored
-	barrier.Image = img.VKImage
-	barrier.SubresourceRange.AspectMask = vk.ImageAspectFlags(vk.ImageAspectColorBit)
-	barrier.SubresourceRange.BaseMipLevel = 0
-	barrier.SubresourceRange.LevelCount = 1
-	barrier.SubresourceRange.BaseArrayLayer = 0
-	barrier.SubresourceRange.LayerCount = 1
-	barrier.SrcAccessMask = 0
-	barrier.DstAccessMask = 0
-
-	var sourceStage, destStage vk.PipelineStageFlags
-
-	if oldLayout == vk.ImageLayoutUndefined && newLayout == vk.ImageLayoutTransferDstOptimal {
-		barrier.SrcAccessMask = 0
-		barrier.DstAccessMask = vk.AccessFlags(vk.AccessTransferWriteBit)
-
-		sourceStage = vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit)
-		destStage = vk.PipelineStageFlags(vk.PipelineStageTransferBit)
-
-	} else if oldLayout == vk.ImageLayoutTransferDstOptimal && newLayout == vk.ImageLayoutShaderReadOnlyOptimal {
-		barrier.SrcAccessMask = vk.AccessFlags(vk.AccessTransferWriteBit)
-		barrier.DstAccessMask = vk.AccessFlags(vk.AccessShaderReadBit)
-
-		sourceStage = vk.PipelineStageFlags(vk.PipelineStageTransferBit)
-		destStage = vk.PipelineStageFlags(vk.PipelineStageFragmentShaderBit)
+	if format == vk.FormatUndefined {
+		format = img.VKFormat
 	}
 
-	vk.CmdPipelineBarrier(cb.VK(), sourceStage, destStage, 0, 0, nil, 0, nil, 1, []vk.ImageMemoryBarrier{barrier})
+	subresourceRange := img.FullSubresourceRange()
+	subresourceRange.AspectMask = FormatAspectMask(format)
 
+	err := cb.TransitionImageLayoutWithOptions(&ImageLayoutTransition{
+		Image:            &img.Image,
+		OldLayout:        oldLayout,
+		NewLayout:        newLayout,
+		SubresourceRange: &subresourceRange,
+	})
+	if err != nil {
+		panic(err)
+	}
 }
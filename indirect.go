@@ -0,0 +1,288 @@
+package vkg
+
+import (
+	"fmt"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// DrawIndirectCountExtension provides indirect draws whose count is read from a buffer
+const DrawIndirectCountExtension = "VK_KHR_draw_indirect_count"
+
+// DrawIndirectCommand has the memory layout of VkDrawIndirectCommand
+type DrawIndirectCommand struct {
+	VertexCount   uint32
+	InstanceCount uint32
+	FirstVertex   uint32
+	FirstInstance uint32
+}
+
+// DrawIndexedIndirectCommand has the memory layout of VkDrawIndexedIndirectCommand
+type DrawIndexedIndirectCommand struct {
+	IndexCount    uint32
+	InstanceCount uint32
+	FirstIndex    uint32
+	VertexOffset  int32
+	FirstInstance uint32
+}
+
+// DispatchIndirectCommand has the memory layout of VkDispatchIndirectCommand
+type DispatchIndirectCommand struct {
+	X uint32
+	Y uint32
+	Z uint32
+}
+
+// IndirectCommandType is the type of command held by an IndirectBuffer
+type IndirectCommandType int
+
+const (
+	IndirectDraw IndirectCommandType = iota
+	IndirectDrawIndexed
+	IndirectDispatch
+)
+
+func (t IndirectCommandType) String() string {
+	switch t {
+	case IndirectDraw:
+		return "Draw"
+	case IndirectDrawIndexed:
+		return "DrawIndexed"
+	case IndirectDispatch:
+		return "Dispatch"
+	}
+	return "Unknown"
+}
+
+// Stride is the size in bytes of a single command
+func (t IndirectCommandType) Stride() int {
+	switch t {
+	case IndirectDraw:
+		return int(unsafe.Sizeof(DrawIndirectCommand{}))
+	case IndirectDrawIndexed:
+		return int(unsafe.Sizeof(DrawIndexedIndirectCommand{}))
+	case IndirectDispatch:
+		return int(unsafe.Sizeof(DispatchIndirectCommand{}))
+	}
+	return 0
+}
+
+// IndirectCommandsOffset is the offset of the first command in an IndirectBuffer, the buffer starts with
+// the number of commands as a uint32 followed by padding. In GLSL the buffer can be declared as
+//
+//	layout(std430, binding = 0) buffer Draws {
+//		uint count;
+//		uint pad0, pad1, pad2;
+//		DrawIndexedIndirectCommand draws[];
+//	};
+const IndirectCommandsOffset = 16
+
+// IndirectBuffer is storage for indirect commands of a single type, which can be written by the host or
+// by shaders and read by the indirect draw and dispatch commands
+type IndirectBuffer struct {
+	Resource *BufferResource
+	Type     IndirectCommandType
+	// Capacity is the number of commands the buffer holds
+	Capacity int
+}
+
+// AllocateIndirectBuffer allocates an indirect buffer with room for capacity commands from the pool, the
+// buffer may be used as a storage buffer so that shaders can write the commands
+func (p *BufferResourcePool) AllocateIndirectBuffer(t IndirectCommandType, capacity int) (*IndirectBuffer, error) {
+	if t.Stride() == 0 {
+		return nil, fmt.Errorf("unknown indirect command type %d", t)
+	}
+	if capacity <= 0 {
+		return nil, fmt.Errorf("an indirect buffer must hold at least one command")
+	}
+	size := uint64(IndirectCommandsOffset + capacity*t.Stride())
+	resource, err := p.AllocateBuffer(size, vk.BufferUsageIndirectBufferBit|vk.BufferUsageStorageBufferBit|vk.BufferUsageTransferDstBit)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret IndirectBuffer
+	ret.Resource = resource
+	ret.Type = t
+	ret.Capacity = capacity
+	return &ret, nil
+}
+
+// Offset returns the offset of the command with the specified index
+func (b *IndirectBuffer) Offset(index int) uint64 {
+	return uint64(IndirectCommandsOffset + index*b.Type.Stride())
+}
+
+// Count reads the number of commands, the buffer must be host visible and mapped
+func (b *IndirectBuffer) Count() (int, error) {
+	data, err := b.bytes()
+	if err != nil {
+		return 0, err
+	}
+	return int(*(*uint32)(unsafe.Pointer(&data[0]))), nil
+}
+
+// SetCount writes the number of commands, the buffer must be host visible and mapped
+func (b *IndirectBuffer) SetCount(count int) error {
+	if count < 0 || count > b.Capacity {
+		return fmt.Errorf("count %d is out of range of the buffer's capacity of %d", count, b.Capacity)
+	}
+	data, err := b.bytes()
+	if err != nil {
+		return err
+	}
+	*(*uint32)(unsafe.Pointer(&data[0])) = uint32(count)
+	return nil
+}
+
+// SetDrawCommands writes the commands and their count, the buffer must hold IndirectDraw commands
+func (b *IndirectBuffer) SetDrawCommands(commands []DrawIndirectCommand) error {
+	if len(commands) == 0 {
+		return b.SetCount(0)
+	}
+	return b.write(IndirectDraw, unsafe.Pointer(&commands[0]), len(commands))
+}
+
+// SetDrawIndexedCommands writes the commands and their count, the buffer must hold IndirectDrawIndexed commands
+func (b *IndirectBuffer) SetDrawIndexedCommands(commands []DrawIndexedIndirectCommand) error {
+	if len(commands) == 0 {
+		return b.SetCount(0)
+	}
+	return b.write(IndirectDrawIndexed, unsafe.Pointer(&commands[0]), len(commands))
+}
+
+// SetDispatchCommands writes the commands and their count, the buffer must hold IndirectDispatch commands
+func (b *IndirectBuffer) SetDispatchCommands(commands []DispatchIndirectCommand) error {
+	if len(commands) == 0 {
+		return b.SetCount(0)
+	}
+	return b.write(IndirectDispatch, unsafe.Pointer(&commands[0]), len(commands))
+}
+
+// DrawCommands reads the first count commands, the buffer must hold IndirectDraw commands
+func (b *IndirectBuffer) DrawCommands(count int) ([]DrawIndirectCommand, error) {
+	ret := make([]DrawIndirectCommand, count)
+	if count == 0 {
+		return ret, nil
+	}
+	return ret, b.read(IndirectDraw, unsafe.Pointer(&ret[0]), count)
+}
+
+// DrawIndexedCommands reads the first count commands, the buffer must hold IndirectDrawIndexed commands
+func (b *IndirectBuffer) DrawIndexedCommands(count int) ([]DrawIndexedIndirectCommand, error) {
+	ret := make([]DrawIndexedIndirectCommand, count)
+	if count == 0 {
+		return ret, nil
+	}
+	return ret, b.read(IndirectDrawIndexed, unsafe.Pointer(&ret[0]), count)
+}
+
+// DispatchCommands reads the first count commands, the buffer must hold IndirectDispatch commands
+func (b *IndirectBuffer) DispatchCommands(count int) ([]DispatchIndirectCommand, error) {
+	ret := make([]DispatchIndirectCommand, count)
+	if count == 0 {
+		return ret, nil
+	}
+	return ret, b.read(IndirectDispatch, unsafe.Pointer(&ret[0]), count)
+}
+
+func (b *IndirectBuffer) bytes() ([]byte, error) {
+	data := b.Resource.Bytes()
+	if data == nil {
+		return nil, fmt.Errorf("indirect buffer is not host visible or its memory is not mapped")
+	}
+	return data, nil
+}
+
+func (b *IndirectBuffer) region(t IndirectCommandType, count int) ([]byte, error) {
+	if t != b.Type {
+		return nil, fmt.Errorf("indirect buffer holds %s commands not %s commands", b.Type, t)
+	}
+	if count > b.Capacity {
+		return nil, fmt.Errorf("%d commands exceed the buffer's capacity of %d", count, b.Capacity)
+	}
+	data, err := b.bytes()
+	if err != nil {
+		return nil, err
+	}
+	return data[IndirectCommandsOffset : IndirectCommandsOffset+count*t.Stride()], nil
+}
+
+func (b *IndirectBuffer) write(t IndirectCommandType, commands unsafe.Pointer, count int) error {
+	region, err := b.region(t, count)
+	if err != nil {
+		return err
+	}
+	copy(region, ToBytes(commands, len(region)))
+	return b.SetCount(count)
+}
+
+func (b *IndirectBuffer) read(t IndirectCommandType, commands unsafe.Pointer, count int) error {
+	region, err := b.region(t, count)
+	if err != nil {
+		return err
+	}
+	copy(ToBytes(commands, len(region)), region)
+	return nil
+}
+
+// CmdResetCount records setting the number of commands to zero, so shaders can append commands by
+// atomically incrementing the count
+func (b *IndirectBuffer) CmdResetCount(cmd CommandRecorder) error {
+	return cmd.CmdFillBuffer(b.Resource, 0, 4, 0)
+}
+
+// CmdDraw records drawing count commands starting at first, the buffer must hold IndirectDraw or
+// IndirectDrawIndexed commands
+func (b *IndirectBuffer) CmdDraw(cmd CommandRecorder, first, count int) error {
+	if first < 0 || first+count > b.Capacity {
+		return fmt.Errorf("commands %d to %d are out of range of the buffer's capacity of %d", first, first+count, b.Capacity)
+	}
+	switch b.Type {
+	case IndirectDraw:
+		cmd.CmdDrawIndirect(b.Resource, b.Offset(first), count, b.Type.Stride())
+	case IndirectDrawIndexed:
+		cmd.CmdDrawIndexedIndirect(b.Resource, b.Offset(first), count, b.Type.Stride())
+	default:
+		return fmt.Errorf("%s commands can not be drawn", b.Type)
+	}
+	return nil
+}
+
+// CmdDrawCount records drawing the number of commands held in the buffer's count, which is typically
+// written by a shader. The device must have been created with EnableDrawIndirectCount.
+func (b *IndirectBuffer) CmdDrawCount(cmd CommandRecorder) error {
+	switch b.Type {
+	case IndirectDraw:
+		return cmd.CmdDrawIndirectCount(b.Resource, b.Offset(0), b.Resource, 0, b.Capacity, b.Type.Stride())
+	case IndirectDrawIndexed:
+		return cmd.CmdDrawIndexedIndirectCount(b.Resource, b.Offset(0), b.Resource, 0, b.Capacity, b.Type.Stride())
+	}
+	return fmt.Errorf("%s commands can not be drawn", b.Type)
+}
+
+// CmdDispatch records dispatching the command with the specified index, the buffer must hold
+// IndirectDispatch commands
+func (b *IndirectBuffer) CmdDispatch(cmd CommandRecorder, index int) error {
+	if b.Type != IndirectDispatch {
+		return fmt.Errorf("%s commands can not be dispatched", b.Type)
+	}
+	if index < 0 || index >= b.Capacity {
+		return fmt.Errorf("command %d is out of range of the buffer's capacity of %d", index, b.Capacity)
+	}
+	cmd.CmdDispatchIndirect(b.Resource, b.Offset(index))
+	return nil
+}
+
+// Free frees the buffer's resource
+func (b *IndirectBuffer) Free() {
+	b.Resource.Free()
+}
+
+func validateDrawIndirectCount(buffer *BufferResource) error {
+	if buffer.Device == nil || !buffer.Device.DrawIndirectCount {
+		return fmt.Errorf("indirect draw counts require the device to be created with %s", DrawIndirectCountExtension)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package vkg
+
+import (
+	"testing"
+)
+
+func TestIndirectBufferCommands(t *testing.T) {
+	if IndirectDraw.Stride() != 16 || IndirectDrawIndexed.Stride() != 20 || IndirectDispatch.Stride() != 12 {
+		t.Fatalf("unexpected strides %d %d %d", IndirectDraw.Stride(), IndirectDrawIndexed.Stride(), IndirectDispatch.Stride())
+	}
+
+	device := &Device{}
+	draws := &IndirectBuffer{Resource: &BufferResource{}, Type: IndirectDrawIndexed, Capacity: 8}
+	draws.Resource.Device = device
+	dispatches := &IndirectBuffer{Resource: &BufferResource{}, Type: IndirectDispatch, Capacity: 2}
+
+	r := NewRecorder()
+	r.Name(draws.Resource, "draws")
+	r.Name(dispatches.Resource, "dispatches")
+
+	if err := draws.CmdDraw(r, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := draws.CmdDraw(r, 6, 3); err == nil {
+		t.Error("expected draws beyond the capacity to fail")
+	}
+	if err := dispatches.CmdDispatch(r, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := draws.CmdDispatch(r, 0); err == nil {
+		t.Error("expected dispatching draw commands to fail")
+	}
+	if err := draws.CmdDrawCount(r); err == nil {
+		t.Error("expected draw counts to fail without the extension")
+	}
+	device.DrawIndirectCount = true
+	if err := draws.CmdDrawCount(r); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "CmdDrawIndexedIndirect buffer=draws offset=56 drawCount=3 stride=20\n" +
+		"CmdDispatchIndirect buffer=dispatches offset=28\n" +
+		"CmdDrawIndexedIndirectCount buffer=draws offset=16 countBuffer=draws countOffset=0 maxDrawCount=8 stride=20\n"
+	if diff := DiffRecordings(expected, r.Text()); diff != "" {
+		t.Errorf("unexpected recording\n%s", diff)
+	}
+}
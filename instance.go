@@ -205,6 +205,7 @@ func (a *App) CreateInstance() (*Instance, error) {
 		return nil, err
 	}
 	vk.InitInstance(instance.VKInstance)
+	instance.apiVersion = appInfo.ApiVersion
 
 	return instance, nil
 }
@@ -227,6 +228,7 @@ func (i *Instance) PhysicalDevices() ([]*PhysicalDevice, error) {
 		return nil, err
 	}
 
+	instanceVersion := i.apiVersion
 	ret := make([]*PhysicalDevice, deviceCount)
 	for i, device := range devices {
 		ret[i] = &PhysicalDevice{}
@@ -235,6 +237,10 @@ func (i *Instance) PhysicalDevices() ([]*PhysicalDevice, error) {
 		vk.GetPhysicalDeviceProperties(device, &ret[i].VKPhysicalDeviceProperties)
 
 		ret[i].VKPhysicalDeviceProperties.Deref()
+		ret[i].apiVersion = ret[i].VKPhysicalDeviceProperties.ApiVersion
+		if instanceVersion < ret[i].apiVersion {
+			ret[i].apiVersion = instanceVersion
+		}
 		ret[i].DeviceName = fmt.Sprintf("%s", (ret[i].VKPhysicalDeviceProperties.DeviceName))
 	}
 	return ret, nil
@@ -285,6 +291,9 @@ func DefaultDebugCallback(flags vk.DebugReportFlags, objectType vk.DebugReportOb
 type Instance struct {
 	//VKInstance is the native Vulkan instance object
 	VKInstance vk.Instance
+
+	// apiVersion is the Vulkan version the instance was created with
+	apiVersion uint32
 }
 
 func (i *Instance) Destroy() error {
@@ -91,3 +91,68 @@ type VertexSourcer interface {
 	ByteSourcer
 	VertexDescriptor
 }
+
+// CommandRecorder is implemented by CommandBuffer and Recorder, so code which records commands
+// can be written against it and tested without a GPU
+type CommandRecorder interface {
+	Begin() error
+	BeginOneTime() error
+	End() error
+	Reset() error
+
+	CmdBeginRenderPass(renderPass vk.RenderPass, framebuffer vk.Framebuffer, extent vk.Extent2D, contents vk.SubpassContents, clearValues ...vk.ClearValue)
+	CmdBeginRenderPassWithArea(renderPass vk.RenderPass, framebuffer vk.Framebuffer, area vk.Rect2D, contents vk.SubpassContents, clearValues ...vk.ClearValue)
+	CmdBeginRenderTarget(target *RenderTarget, contents vk.SubpassContents, clearValues ...vk.ClearValue)
+	CmdNextSubpass(contents vk.SubpassContents)
+	CmdEndRenderPass()
+	CmdExecuteCommands(buffers ...*CommandBuffer)
+
+	CmdBindPipeline(bindPoint vk.PipelineBindPoint, pipeline vk.Pipeline)
+	CmdBindGraphicsPipeline(pipeline vk.Pipeline)
+	CmdBindComputePipeline(p *ComputePipeline)
+	CmdBindDescriptorSets(bindPoint vk.PipelineBindPoint, layout *PipelineLayout, firstSet int, descriptorSets ...*DescriptorSet)
+	CmdBindVertexBuffers(firstBinding int, buffers ...*BufferResource)
+	CmdBindVertexBuffersWithOffsets(firstBinding int, buffers []*BufferResource, offsets []uint64)
+	CmdBindIndexBuffer(buffer *BufferResource, indices IndexSourcer)
+	CmdBindIndexBufferWithType(buffer *BufferResource, offset uint64, indexType vk.IndexType)
+	CmdPushConstants(layout *PipelineLayout, stages vk.ShaderStageFlags, offset int, data []byte)
+
+	CmdDraw(vertexCount, instanceCount, firstVertex, firstInstance int)
+	CmdDrawIndexed(indexCount, instanceCount, firstIndex, vertexOffset, firstInstance int)
+	CmdDrawIndirect(buffer *BufferResource, offset uint64, drawCount, stride int)
+	CmdDrawIndexedIndirect(buffer *BufferResource, offset uint64, drawCount, stride int)
+	CmdDrawIndirectCount(buffer *BufferResource, offset uint64, countBuffer *BufferResource, countOffset uint64, maxDrawCount, stride int) error
+	CmdDrawIndexedIndirectCount(buffer *BufferResource, offset uint64, countBuffer *BufferResource, countOffset uint64, maxDrawCount, stride int) error
+	CmdDispatch(x, y, z int)
+	CmdDispatchIndirect(buffer *BufferResource, offset uint64)
+
+	CmdSetViewport(viewports ...vk.Viewport)
+	CmdSetScissor(scissors ...vk.Rect2D)
+
+	CmdFillBuffer(buffer *BufferResource, offset, size uint64, data uint32) error
+	CmdUpdateBuffer(buffer *BufferResource, offset uint64, data []byte) error
+
+	CmdPipelineBarrier(srcStage, dstStage vk.PipelineStageFlags, dependencyFlags vk.DependencyFlags, memoryBarriers []vk.MemoryBarrier, bufferBarriers []vk.BufferMemoryBarrier, imageBarriers []vk.ImageMemoryBarrier)
+	TransitionImageLayouts(transitions ...*ImageLayoutTransition) error
+	Require(resource TrackedResource, usage ResourceUsage) error
+	RequireWithStages(resource TrackedResource, usage ResourceUsage, stages vk.PipelineStageFlags) error
+	Release(resource TrackedResource, dst *QueueFamily, usage ResourceUsage) error
+	FlushBarriers()
+
+	CmdResetQueryPool(pool *QueryPool, first, count int)
+	CmdWriteTimestamp(stage vk.PipelineStageFlagBits, pool *QueryPool, query int)
+	CmdBeginQuery(pool *QueryPool, query int)
+	CmdEndQuery(pool *QueryPool, query int)
+
+	CmdCopyImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, regions ...ImageCopyRegion) error
+	CmdBlitImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, filter vk.Filter, regions ...ImageCopyRegion) error
+	CmdResolveImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, regions ...ImageCopyRegion) error
+	CmdCopyBufferToImage(src *BufferResource, dst *ImageResource, dstLayout vk.ImageLayout, regions ...BufferImageRegion) error
+	CmdCopyImageToBuffer(src *ImageResource, srcLayout vk.ImageLayout, dst *BufferResource, regions ...BufferImageRegion) error
+}
+
+// TrackedResource is an image or buffer whose synchronization state is tracked, so that
+// CommandBuffer.Require can derive the barriers needed to access it
+type TrackedResource interface {
+	TrackedState() *ResourceState
+}
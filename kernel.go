@@ -0,0 +1,542 @@
+package vkg
+
+import (
+	"context"
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// KernelOptions are the optional settings of a Kernel
+type KernelOptions struct {
+	// EntryPoint is the entry point of the shader, "main" if empty
+	EntryPoint string
+	// SpecializationConstants are applied to the shader, and override its workgroup size if it was
+	// declared with specialization constants
+	SpecializationConstants *SpecializationConstants
+	// PipelineCache is used to create the pipeline if it is set
+	PipelineCache *PipelineCache
+	// FencePool is used to track dispatches if it is set, otherwise the kernel creates its own
+	FencePool *FencePool
+}
+
+// Kernel is a compute shader which is ready to dispatch, its pipeline layout and descriptor sets are
+// created from the reflection of the shader so resources can be bound by the names used in the shader.
+// A kernel records into a single command buffer so it is not safe for concurrent use, and each
+// dispatch waits for the previous one to complete.
+type Kernel struct {
+	Device               *Device
+	Queue                *Queue
+	Shader               *ShaderModule
+	Pipeline             *ComputePipeline
+	PipelineLayout       *PipelineLayout
+	DescriptorSetLayouts []*DescriptorSetLayout
+	DescriptorSets       []*DescriptorSet
+	Reflection           *PipelineReflection
+	// LocalSize is the workgroup size of the shader after specialization
+	LocalSize [3]uint32
+
+	descriptorPool *DescriptorPool
+	commandPool    *CommandPool
+	commandBuffer  *CommandBuffer
+	fences         *FencePool
+	ownFences      bool
+
+	bound         map[[2]uint32]*kernelBinding
+	dirty         bool
+	pushConstants []byte
+	pending       *Future
+}
+
+type kernelBinding struct {
+	usage   ResourceUsage
+	buffer  *BufferResource
+	image   *ImageResource
+	view    vk.ImageView
+	sampler *Sampler
+}
+
+func (b *kernelBinding) resource() TrackedResource {
+	if b.buffer != nil {
+		return b.buffer
+	}
+	return b.image
+}
+
+// LoadKernelFromFile creates a kernel from a SPIR-V file, see CreateKernel
+func (d *Device) LoadKernelFromFile(queue *Queue, file string, options *KernelOptions) (*Kernel, error) {
+	shader, err := d.LoadShaderModuleFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return d.createKernel(queue, shader, options)
+}
+
+// CreateKernel creates a kernel from SPIR-V code which is dispatched on the queue, which must support
+// compute. The options may be nil.
+func (d *Device) CreateKernel(queue *Queue, code []byte, options *KernelOptions) (*Kernel, error) {
+	shader, err := d.CreateShaderModule(code)
+	if err != nil {
+		return nil, err
+	}
+	return d.createKernel(queue, shader, options)
+}
+
+// createKernel creates a kernel which owns the shader module, the module is destroyed if it fails
+func (d *Device) createKernel(queue *Queue, shader *ShaderModule, options *KernelOptions) (*Kernel, error) {
+	var ret Kernel
+	ret.Device = d
+	ret.Queue = queue
+	ret.Shader = shader
+	ret.bound = make(map[[2]uint32]*kernelBinding)
+
+	err := ret.create(options)
+	if err != nil {
+		ret.Destroy()
+		return nil, fmt.Errorf("unable to create kernel from %s: %w", shader.Description, err)
+	}
+	return &ret, nil
+}
+
+func (k *Kernel) create(options *KernelOptions) error {
+	if options == nil {
+		options = &KernelOptions{}
+	}
+	if !k.Queue.QueueFamily.IsCompute() {
+		return fmt.Errorf("queue family %d does not support compute", k.Queue.QueueFamily.Index)
+	}
+
+	entryPoint := options.EntryPoint
+	if entryPoint == "" {
+		entryPoint = "main"
+	}
+
+	k.Pipeline = &ComputePipeline{}
+	k.Pipeline.SetShaderStage(entryPoint, k.Shader)
+	if options.SpecializationConstants != nil {
+		err := k.Pipeline.SetSpecializationConstants(options.SpecializationConstants)
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	k.Reflection, err = k.Pipeline.Reflect()
+	if err != nil {
+		return err
+	}
+	k.LocalSize = kernelLocalSize(k.Reflection, options.SpecializationConstants)
+
+	k.PipelineLayout, k.DescriptorSetLayouts, err = k.Device.CreateReflectedPipelineLayout(k.Reflection)
+	if err != nil {
+		return err
+	}
+	k.Pipeline.SetPipelineLayout(k.PipelineLayout)
+
+	err = k.Device.CreateComputePipelines(options.PipelineCache, k.Pipeline)
+	if err != nil {
+		return err
+	}
+
+	if len(k.DescriptorSetLayouts) > 0 {
+		err = k.allocateDescriptorSets()
+		if err != nil {
+			return err
+		}
+	}
+
+	k.commandPool, err = k.Device.CreateCommandPool(k.Queue.QueueFamily)
+	if err != nil {
+		return err
+	}
+	k.commandBuffer, err = k.commandPool.AllocateBuffer(vk.CommandBufferLevelPrimary)
+	if err != nil {
+		return err
+	}
+
+	k.fences = options.FencePool
+	if k.fences == nil {
+		k.fences = k.Device.CreateFencePool()
+		k.ownFences = true
+	}
+	return nil
+}
+
+func (k *Kernel) allocateDescriptorSets() error {
+	counts := make(map[vk.DescriptorType]int)
+	var types []vk.DescriptorType
+	for _, b := range k.Reflection.Bindings {
+		if counts[b.Type] == 0 {
+			types = append(types, b.Type)
+		}
+		counts[b.Type] += int(b.Count)
+	}
+
+	pool := k.Device.NewDescriptorPool()
+	for _, t := range types {
+		pool.AddPoolSize(t, counts[t])
+	}
+	_, err := k.Device.CreateDescriptorPool(pool, len(k.DescriptorSetLayouts))
+	if err != nil {
+		return err
+	}
+	k.descriptorPool = pool
+
+	for _, l := range k.DescriptorSetLayouts {
+		set, err := pool.Allocate(l)
+		if err != nil {
+			return err
+		}
+		k.DescriptorSets = append(k.DescriptorSets, set)
+	}
+	return nil
+}
+
+// kernelLocalSize returns the workgroup size of the reflected shader with any dimensions declared as
+// specialization constants replaced by their values
+func kernelLocalSize(r *PipelineReflection, constants *SpecializationConstants) [3]uint32 {
+	ret := r.LocalSize
+	if constants == nil {
+		return ret
+	}
+	for i, id := range r.LocalSizeSpecIDs {
+		if id < 0 {
+			continue
+		}
+		if v, ok := constants.values[uint32(id)]; ok {
+			ret[i] = v.bits
+		}
+	}
+	return ret
+}
+
+// GroupCounts returns the number of workgroups needed to cover the total number of invocations in
+// each dimension
+func (k *Kernel) GroupCounts(totalX, totalY, totalZ int) (int, int, int) {
+	return groupCount(totalX, k.LocalSize[0]), groupCount(totalY, k.LocalSize[1]), groupCount(totalZ, k.LocalSize[2])
+}
+
+func groupCount(total int, localSize uint32) int {
+	if total <= 0 {
+		return 0
+	}
+	if localSize == 0 {
+		localSize = 1
+	}
+	return (total + int(localSize) - 1) / int(localSize)
+}
+
+// Binding returns the reflected binding with the specified name
+func (k *Kernel) Binding(name string) (DescriptorBinding, error) {
+	for _, b := range k.Reflection.Bindings {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return DescriptorBinding{}, fmt.Errorf("kernel %s has no binding named %q", k.Shader.Description, name)
+}
+
+// BindingAt returns the reflected binding with the specified set and binding number
+func (k *Kernel) BindingAt(set, binding uint32) (DescriptorBinding, error) {
+	for _, b := range k.Reflection.Bindings {
+		if b.Set == set && b.Binding == binding {
+			return b, nil
+		}
+	}
+	return DescriptorBinding{}, fmt.Errorf("kernel %s has no binding %d in set %d", k.Shader.Description, binding, set)
+}
+
+// kernelBindingUsage returns how a resource bound to the binding is used by the shader
+func kernelBindingUsage(b DescriptorBinding) (ResourceUsage, error) {
+	if b.Count != 1 {
+		return UsageNone, fmt.Errorf("binding %s (set %d binding %d) is an array, which is not supported", b.Name, b.Set, b.Binding)
+	}
+	switch b.Type {
+	case vk.DescriptorTypeStorageBuffer, vk.DescriptorTypeStorageImage:
+		if b.ReadOnly {
+			return UsageStorageRead, nil
+		}
+		return UsageStorageWrite, nil
+	case vk.DescriptorTypeUniformBuffer:
+		return UsageUniformBuffer, nil
+	case vk.DescriptorTypeCombinedImageSampler, vk.DescriptorTypeSampledImage:
+		return UsageSampled, nil
+	}
+	return UsageNone, fmt.Errorf("binding %s (set %d binding %d) has descriptor type %d, which is not supported", b.Name, b.Set, b.Binding, b.Type)
+}
+
+func isBufferDescriptor(t vk.DescriptorType) bool {
+	return t == vk.DescriptorTypeStorageBuffer || t == vk.DescriptorTypeUniformBuffer
+}
+
+// BindBuffer binds a buffer to the uniform or storage buffer with the specified name
+func (k *Kernel) BindBuffer(name string, buffer *BufferResource) error {
+	b, err := k.Binding(name)
+	if err != nil {
+		return err
+	}
+	return k.bindBuffer(b, buffer)
+}
+
+// BindBufferAt binds a buffer to the uniform or storage buffer with the specified set and binding number
+func (k *Kernel) BindBufferAt(set, binding uint32, buffer *BufferResource) error {
+	b, err := k.BindingAt(set, binding)
+	if err != nil {
+		return err
+	}
+	return k.bindBuffer(b, buffer)
+}
+
+func (k *Kernel) bindBuffer(b DescriptorBinding, buffer *BufferResource) error {
+	usage, err := kernelBindingUsage(b)
+	if err != nil {
+		return err
+	}
+	if !isBufferDescriptor(b.Type) {
+		return fmt.Errorf("binding %s (set %d binding %d) is not a buffer", b.Name, b.Set, b.Binding)
+	}
+	if buffer.Size < uint64(b.Size) {
+		return fmt.Errorf("buffer of %d bytes is smaller than binding %s (%d bytes)", buffer.Size, b.Name, b.Size)
+	}
+	k.bound[[2]uint32{b.Set, b.Binding}] = &kernelBinding{usage: usage, buffer: buffer}
+	k.dirty = true
+	return nil
+}
+
+// BindImage binds an image view to the storage image, sampled image or combined image sampler with the
+// specified name, the sampler is only used by combined image samplers
+func (k *Kernel) BindImage(name string, image *ImageResource, view *ImageView, sampler *Sampler) error {
+	b, err := k.Binding(name)
+	if err != nil {
+		return err
+	}
+	return k.bindImage(b, image, view, sampler)
+}
+
+// BindImageAt binds an image view to the image with the specified set and binding number, see BindImage
+func (k *Kernel) BindImageAt(set, binding uint32, image *ImageResource, view *ImageView, sampler *Sampler) error {
+	b, err := k.BindingAt(set, binding)
+	if err != nil {
+		return err
+	}
+	return k.bindImage(b, image, view, sampler)
+}
+
+func (k *Kernel) bindImage(b DescriptorBinding, image *ImageResource, view *ImageView, sampler *Sampler) error {
+	usage, err := kernelBindingUsage(b)
+	if err != nil {
+		return err
+	}
+	if isBufferDescriptor(b.Type) {
+		return fmt.Errorf("binding %s (set %d binding %d) is not an image", b.Name, b.Set, b.Binding)
+	}
+	if view == nil {
+		return fmt.Errorf("binding %s (set %d binding %d) requires an image view", b.Name, b.Set, b.Binding)
+	}
+	if b.Type == vk.DescriptorTypeCombinedImageSampler && sampler == nil {
+		return fmt.Errorf("binding %s (set %d binding %d) requires a sampler", b.Name, b.Set, b.Binding)
+	}
+	k.bound[[2]uint32{b.Set, b.Binding}] = &kernelBinding{usage: usage, image: image, view: view.VKImageView, sampler: sampler}
+	k.dirty = true
+	return nil
+}
+
+// pushConstantRange returns the offset, size and stages of the push constants used by the shader
+func (k *Kernel) pushConstantRange() (uint32, uint32, vk.ShaderStageFlags) {
+	if len(k.Reflection.PushConstants) == 0 {
+		return 0, 0, 0
+	}
+	offset := k.Reflection.PushConstants[0].Offset
+	var end uint32
+	var stages vk.ShaderStageFlags
+	for _, pc := range k.Reflection.PushConstants {
+		if pc.Offset < offset {
+			offset = pc.Offset
+		}
+		if pc.Offset+pc.Size > end {
+			end = pc.Offset + pc.Size
+		}
+		stages |= pc.Stages
+	}
+	return offset, end - offset, stages
+}
+
+// SetPushConstants sets the push constants used by subsequent dispatches, the data starts at the offset
+// of the shader's push constant block and must not be larger than it
+func (k *Kernel) SetPushConstants(data []byte) error {
+	_, size, _ := k.pushConstantRange()
+	if size == 0 {
+		return fmt.Errorf("kernel %s has no push constants", k.Shader.Description)
+	}
+	if uint32(len(data)) > size {
+		return fmt.Errorf("%d bytes of push constants is larger than the %d bytes used by kernel %s", len(data), size, k.Shader.Description)
+	}
+	k.pushConstants = append(k.pushConstants[:0], data...)
+	return nil
+}
+
+// writeDescriptors updates the descriptor sets with the bound resources
+func (k *Kernel) writeDescriptors() error {
+	for _, b := range k.Reflection.Bindings {
+		if k.bound[[2]uint32{b.Set, b.Binding}] == nil {
+			return fmt.Errorf("binding %s (set %d binding %d) of kernel %s is not bound", b.Name, b.Set, b.Binding, k.Shader.Description)
+		}
+	}
+	if !k.dirty {
+		return nil
+	}
+
+	for _, set := range k.DescriptorSets {
+		set.VKWriteDiscriptorSet = nil
+	}
+	for _, b := range k.Reflection.Bindings {
+		bound := k.bound[[2]uint32{b.Set, b.Binding}]
+		set := k.DescriptorSets[b.Set]
+		switch {
+		case bound.buffer != nil:
+			set.AddBuffer(int(b.Binding), b.Type, &bound.buffer.Buffer, 0)
+		case b.Type == vk.DescriptorTypeCombinedImageSampler:
+			set.AddCombinedImageSampler(int(b.Binding), resourceUsages[bound.usage].Layout, bound.view, bound.sampler)
+		default:
+			set.AddImage(int(b.Binding), b.Type, resourceUsages[bound.usage].Layout, bound.view)
+		}
+	}
+	for _, set := range k.DescriptorSets {
+		if len(set.VKWriteDiscriptorSet) > 0 {
+			set.Write()
+		}
+	}
+	k.dirty = false
+	return nil
+}
+
+// Wait waits for the last dispatch to complete, returning its error
+func (k *Kernel) Wait(ctx context.Context) error {
+	if k.pending == nil {
+		return nil
+	}
+	return k.pending.Wait(ctx)
+}
+
+// Dispatch runs enough workgroups to cover the total number of invocations in each dimension and waits
+// for them to complete. If the context is done first the work continues on the GPU, and the next
+// dispatch waits for it.
+func (k *Kernel) Dispatch(ctx context.Context, totalX, totalY, totalZ int) error {
+	if k.pending != nil {
+		select {
+		case <-k.pending.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	future, err := k.DispatchAsync(totalX, totalY, totalZ)
+	if err != nil {
+		return err
+	}
+	return future.Wait(ctx)
+}
+
+// DispatchAsync submits enough workgroups to cover the total number of invocations in each dimension,
+// returning a future which completes with them. Buffers written by the kernel which are host visible
+// can be read once the future completes. It blocks until any previous dispatch has completed.
+func (k *Kernel) DispatchAsync(totalX, totalY, totalZ int) (*Future, error) {
+	if totalX < 0 || totalY < 0 || totalZ < 0 {
+		return nil, fmt.Errorf("invalid dispatch size %dx%dx%d", totalX, totalY, totalZ)
+	}
+	if k.pending != nil {
+		<-k.pending.Done()
+		k.pending = nil
+	}
+
+	err := k.writeDescriptors()
+	if err != nil {
+		return nil, err
+	}
+
+	err = k.record(totalX, totalY, totalZ)
+	if err != nil {
+		return nil, err
+	}
+
+	future, err := k.fences.SubmitAsync(k.Queue, k.commandBuffer)
+	if err != nil {
+		return nil, err
+	}
+	k.pending = future
+	return future, nil
+}
+
+func (k *Kernel) record(totalX, totalY, totalZ int) error {
+	cb := k.commandBuffer
+	err := cb.Reset()
+	if err != nil {
+		return err
+	}
+	err = cb.BeginOneTime()
+	if err != nil {
+		return err
+	}
+
+	for _, b := range k.Reflection.Bindings {
+		bound := k.bound[[2]uint32{b.Set, b.Binding}]
+		err = cb.RequireWithStages(bound.resource(), bound.usage, vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit))
+		if err != nil {
+			return err
+		}
+	}
+
+	cb.CmdBindComputePipeline(k.Pipeline)
+	if len(k.DescriptorSets) > 0 {
+		cb.CmdBindDescriptorSets(vk.PipelineBindPointCompute, k.PipelineLayout, 0, k.DescriptorSets...)
+	}
+	if len(k.pushConstants) > 0 {
+		offset, _, stages := k.pushConstantRange()
+		cb.CmdPushConstants(k.PipelineLayout, stages, int(offset), k.pushConstants)
+	}
+	cb.CmdDispatch(k.GroupCounts(totalX, totalY, totalZ))
+
+	// Make the results visible to the host once the dispatch completes
+	for _, b := range k.Reflection.Bindings {
+		bound := k.bound[[2]uint32{b.Set, b.Binding}]
+		if bound.usage == UsageStorageWrite && bound.buffer != nil && !bound.buffer.RequiresStaging() {
+			err = cb.Require(bound.buffer, UsageHostRead)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return cb.End()
+}
+
+// Destroy waits for the last dispatch to complete and destroys the kernel
+func (k *Kernel) Destroy() {
+	if k.pending != nil {
+		<-k.pending.Done()
+		k.pending = nil
+	}
+	if k.ownFences && k.fences != nil {
+		k.fences.Destroy()
+	}
+	if k.commandPool != nil {
+		if k.commandBuffer != nil {
+			k.commandPool.FreeBuffer(k.commandBuffer)
+		}
+		k.commandPool.Destroy()
+	}
+	if k.descriptorPool != nil {
+		k.descriptorPool.Destroy()
+	}
+	if k.Pipeline != nil && k.Pipeline.Device != nil {
+		k.Pipeline.Destroy()
+	}
+	if k.PipelineLayout != nil {
+		k.PipelineLayout.Destroy()
+	}
+	for _, l := range k.DescriptorSetLayouts {
+		l.Destroy()
+	}
+	if k.Shader != nil {
+		k.Shader.Destroy()
+	}
+}
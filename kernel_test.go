@@ -0,0 +1,100 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestKernelGroupCounts(t *testing.T) {
+	k := &Kernel{LocalSize: [3]uint32{32, 32, 1}}
+	x, y, z := k.GroupCounts(3200, 2401, 1)
+	if x != 100 || y != 76 || z != 1 {
+		t.Errorf("expected 100x76x1 groups, got %dx%dx%d", x, y, z)
+	}
+	x, y, z = k.GroupCounts(0, 1, 5)
+	if x != 0 || y != 1 || z != 5 {
+		t.Errorf("expected 0x1x5 groups, got %dx%dx%d", x, y, z)
+	}
+	if n := groupCount(10, 0); n != 10 {
+		t.Errorf("expected an unknown local size to be treated as 1, got %d groups", n)
+	}
+}
+
+func TestKernelLocalSize(t *testing.T) {
+	r := &PipelineReflection{
+		LocalSize:        [3]uint32{8, 8, 1},
+		LocalSizeSpecIDs: [3]int{0, -1, -1},
+	}
+	if got := kernelLocalSize(r, nil); got != [3]uint32{8, 8, 1} {
+		t.Errorf("expected the declared local size, got %v", got)
+	}
+	constants := NewSpecializationConstants().SetUint32(0, 64).SetUint32(1, 16)
+	if got := kernelLocalSize(r, constants); got != [3]uint32{64, 8, 1} {
+		t.Errorf("expected only x to be specialized, got %v", got)
+	}
+}
+
+func TestKernelBindingUsage(t *testing.T) {
+	tests := []struct {
+		binding DescriptorBinding
+		usage   ResourceUsage
+		err     bool
+	}{
+		{DescriptorBinding{Type: vk.DescriptorTypeStorageBuffer, Count: 1}, UsageStorageWrite, false},
+		{DescriptorBinding{Type: vk.DescriptorTypeStorageBuffer, Count: 1, ReadOnly: true}, UsageStorageRead, false},
+		{DescriptorBinding{Type: vk.DescriptorTypeStorageImage, Count: 1}, UsageStorageWrite, false},
+		{DescriptorBinding{Type: vk.DescriptorTypeUniformBuffer, Count: 1}, UsageUniformBuffer, false},
+		{DescriptorBinding{Type: vk.DescriptorTypeCombinedImageSampler, Count: 1}, UsageSampled, false},
+		{DescriptorBinding{Type: vk.DescriptorTypeStorageBuffer, Count: 4}, UsageNone, true},
+		{DescriptorBinding{Type: vk.DescriptorTypeStorageTexelBuffer, Count: 1}, UsageNone, true},
+	}
+	for i, test := range tests {
+		usage, err := kernelBindingUsage(test.binding)
+		if (err != nil) != test.err {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+		if usage != test.usage {
+			t.Errorf("%d: expected usage %d, got %d", i, test.usage, usage)
+		}
+	}
+}
+
+func TestKernelSetPushConstants(t *testing.T) {
+	k := &Kernel{
+		Shader:     &ShaderModule{Description: "test.comp"},
+		Reflection: &PipelineReflection{},
+	}
+	if err := k.SetPushConstants([]byte{1}); err == nil {
+		t.Errorf("expected an error for a kernel without push constants")
+	}
+
+	k.Reflection.PushConstants = []PushConstantRange{
+		{Name: "params", Offset: 16, Size: 8, Stages: vk.ShaderStageFlags(vk.ShaderStageComputeBit)},
+	}
+	offset, size, stages := k.pushConstantRange()
+	if offset != 16 || size != 8 || stages != vk.ShaderStageFlags(vk.ShaderStageComputeBit) {
+		t.Errorf("unexpected push constant range %d+%d %d", offset, size, stages)
+	}
+	if err := k.SetPushConstants(make([]byte, 9)); err == nil {
+		t.Errorf("expected an error for push constants larger than the block")
+	}
+	if err := k.SetPushConstants(make([]byte, 8)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestKernelBindImageWithoutView(t *testing.T) {
+	k := &Kernel{bound: make(map[[2]uint32]*kernelBinding)}
+	b := DescriptorBinding{Name: "out", Type: vk.DescriptorTypeStorageImage, Count: 1}
+
+	if err := k.bindImage(b, &ImageResource{}, nil, nil); err == nil {
+		t.Errorf("expected an error binding an image without a view")
+	}
+	if len(k.bound) != 0 || k.dirty {
+		t.Errorf("expected nothing to be bound")
+	}
+	if err := k.bindImage(b, &ImageResource{}, &ImageView{}, nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
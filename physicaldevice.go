@@ -2,6 +2,7 @@ package vkg
 
 import (
 	"fmt"
+	"unsafe"
 
 	vk "github.com/vulkan-go/vulkan"
 )
@@ -35,6 +36,9 @@ type PhysicalDevice struct {
 	DeviceName                 string
 	VKPhysicalDevice           vk.PhysicalDevice
 	VKPhysicalDeviceProperties vk.PhysicalDeviceProperties
+
+	// apiVersion is the Vulkan version usable with this device, the lower of the instance and device versions
+	apiVersion uint32
 }
 
 func (p *PhysicalDevice) GetSurfacePresentModes(surface vk.Surface) (VKPresentModes, error) {
@@ -114,12 +118,27 @@ func (p *PhysicalDevice) QueueFamilies() (QueueFamilySlice, error) {
 type CreateDeviceOptions struct {
 	EnabledExtensions []string
 	EnabledLayers     []string
+
+	// EnableTimelineSemaphores enables the timeline semaphore feature, VK_KHR_timeline_semaphore is
+	// enabled as well when the device supports it as an extension
+	EnableTimelineSemaphores bool
+	// EnableDrawIndirectCount enables VK_KHR_draw_indirect_count when the device supports it, see
+	// Device.DrawIndirectCount
+	EnableDrawIndirectCount bool
 }
 
 func (p *PhysicalDevice) CreateLogicalDeviceWithOptions(qfs QueueFamilySlice, options *CreateDeviceOptions) (*Device, error) {
 
-	queueCreateInfos := make([]vk.DeviceQueueCreateInfo, len(qfs))
-	for j, q := range qfs {
+	// Each family may only be requested once, so duplicates in the slice are dropped
+	var families QueueFamilySlice
+	for _, q := range qfs {
+		if !families.Contains(q) {
+			families = append(families, q)
+		}
+	}
+
+	queueCreateInfos := make([]vk.DeviceQueueCreateInfo, len(families))
+	for j, q := range families {
 
 		queueCreateInfo := vk.DeviceQueueCreateInfo{
 			SType:            vk.StructureTypeDeviceQueueCreateInfo,
@@ -136,15 +155,38 @@ func (p *PhysicalDevice) CreateLogicalDeviceWithOptions(qfs QueueFamilySlice, op
 
 	deviceCreateInfo := vk.DeviceCreateInfo{
 		SType:                vk.StructureTypeDeviceCreateInfo,
-		QueueCreateInfoCount: uint32(len(qfs)),
+		QueueCreateInfoCount: uint32(len(families)),
 		PQueueCreateInfos:    queueCreateInfos,
 		PEnabledFeatures:     []vk.PhysicalDeviceFeatures{deviceFeatures},
 	}
 
+	var enabledExtensions []string
+	if options != nil && options.EnabledExtensions != nil {
+		enabledExtensions = append(enabledExtensions, options.EnabledExtensions...)
+	}
+
+	var timelineFeatures vk.PhysicalDeviceTimelineSemaphoreFeatures
+	if options != nil && options.EnableTimelineSemaphores {
+		if p.SupportsExtension(TimelineSemaphoreExtension) && !containsString(enabledExtensions, TimelineSemaphoreExtension) {
+			enabledExtensions = append(enabledExtensions, TimelineSemaphoreExtension)
+		}
+		timelineFeatures = vk.PhysicalDeviceTimelineSemaphoreFeatures{
+			SType:             vk.StructureTypePhysicalDeviceTimelineSemaphoreFeatures,
+			TimelineSemaphore: vk.True,
+		}
+		defer timelineFeatures.Free()
+		deviceCreateInfo.PNext = unsafe.Pointer(timelineFeatures.Ref())
+	}
+
+	if options != nil && options.EnableDrawIndirectCount && p.SupportsExtension(DrawIndirectCountExtension) &&
+		!containsString(enabledExtensions, DrawIndirectCountExtension) {
+		enabledExtensions = append(enabledExtensions, DrawIndirectCountExtension)
+	}
+
 	if options != nil {
-		if options.EnabledExtensions != nil {
-			deviceCreateInfo.EnabledExtensionCount = uint32(len(options.EnabledExtensions))
-			deviceCreateInfo.PpEnabledExtensionNames = safeStrings(options.EnabledExtensions)
+		if enabledExtensions != nil {
+			deviceCreateInfo.EnabledExtensionCount = uint32(len(enabledExtensions))
+			deviceCreateInfo.PpEnabledExtensionNames = safeStrings(enabledExtensions)
 		}
 		if options.EnabledLayers != nil {
 			deviceCreateInfo.EnabledLayerCount = uint32(len(options.EnabledLayers))
@@ -162,6 +204,9 @@ func (p *PhysicalDevice) CreateLogicalDeviceWithOptions(qfs QueueFamilySlice, op
 	var device Device
 	device.PhysicalDevice = p
 	device.VKDevice = ldevice
+	device.TimelineSemaphores = options != nil && options.EnableTimelineSemaphores
+	device.timelineSemaphoresKHR = device.TimelineSemaphores && containsString(enabledExtensions, TimelineSemaphoreExtension)
+	device.DrawIndirectCount = containsString(enabledExtensions, DrawIndirectCountExtension)
 
 	return &device, nil
 }
@@ -170,12 +215,138 @@ func (p *PhysicalDevice) CreateLogicalDevice(qfs QueueFamilySlice) (*Device, err
 	return p.CreateLogicalDeviceWithOptions(qfs, nil)
 }
 
+// VKPhysicalDeviceFeatures returns the features supported by this device
 func (p *PhysicalDevice) VKPhysicalDeviceFeatures() vk.PhysicalDeviceFeatures {
 	var deviceFeatures vk.PhysicalDeviceFeatures
 	vk.GetPhysicalDeviceFeatures(p.VKPhysicalDevice, &deviceFeatures)
+	deviceFeatures.Deref()
 	return deviceFeatures
 }
 
+// SupportsExtension returns true if the device supports the named extension
+func (p *PhysicalDevice) SupportsExtension(name string) bool {
+	extensions, err := p.SupportedExtensions()
+	if err != nil {
+		return false
+	}
+	for _, ext := range extensions {
+		ext.Deref()
+		if vk.ToString(ext.ExtensionName[:]) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsTimelineSemaphores returns true if the device supports timeline semaphores, either through
+// VK_KHR_timeline_semaphore or Vulkan 1.2
+func (p *PhysicalDevice) SupportsTimelineSemaphores() bool {
+	if p.SupportsExtension(TimelineSemaphoreExtension) {
+		// The extension requires the feature to be supported
+		return true
+	}
+	if p.apiVersion < vk.MakeVersion(1, 2, 0) {
+		// vkGetPhysicalDeviceFeatures2 can't be used before Vulkan 1.1 and the feature is core in 1.2
+		return false
+	}
+
+	timelineFeatures := vk.PhysicalDeviceTimelineSemaphoreFeatures{
+		SType: vk.StructureTypePhysicalDeviceTimelineSemaphoreFeatures,
+	}
+	defer timelineFeatures.Free()
+
+	features := vk.PhysicalDeviceFeatures2{
+		SType: vk.StructureTypePhysicalDeviceFeatures2,
+		PNext: unsafe.Pointer(timelineFeatures.Ref()),
+	}
+	vk.GetPhysicalDeviceFeatures2(p.VKPhysicalDevice, &features)
+	timelineFeatures.Deref()
+
+	return timelineFeatures.TimelineSemaphore == vk.True
+}
+
+// VKFormatProperties returns the features supported by this device for the specified format
+func (p *PhysicalDevice) VKFormatProperties(format vk.Format) vk.FormatProperties {
+	var props vk.FormatProperties
+	vk.GetPhysicalDeviceFormatProperties(p.VKPhysicalDevice, format, &props)
+	props.Deref()
+	return props
+}
+
+// DepthFormatPreferences is the default order of preference when picking a depth format
+var DepthFormatPreferences = []vk.Format{
+	vk.FormatD32Sfloat,
+	vk.FormatD32SfloatS8Uint,
+	vk.FormatD24UnormS8Uint,
+	vk.FormatX8D24UnormPack32,
+	vk.FormatD16Unorm,
+}
+
+// DepthStencilFormatPreferences is the default order of preference when picking a combined
+// depth/stencil format
+var DepthStencilFormatPreferences = []vk.Format{
+	vk.FormatD24UnormS8Uint,
+	vk.FormatD32SfloatS8Uint,
+	vk.FormatD16UnormS8Uint,
+}
+
+// FindSupportedFormat returns the first format from the candidates which supports all of the
+// features with the specified tiling
+func (p *PhysicalDevice) FindSupportedFormat(candidates []vk.Format, tiling vk.ImageTiling, features vk.FormatFeatureFlagBits) (vk.Format, error) {
+	for _, format := range candidates {
+		props := p.VKFormatProperties(format)
+		supported := props.OptimalTilingFeatures
+		if tiling == vk.ImageTilingLinear {
+			supported = props.LinearTilingFeatures
+		}
+		if supported&vk.FormatFeatureFlags(features) == vk.FormatFeatureFlags(features) {
+			return format, nil
+		}
+	}
+	return vk.FormatUndefined, fmt.Errorf("none of the formats %v support features %d", candidates, features)
+}
+
+// FindDepthFormat returns the most preferred depth format which can be used as an optimal tiling
+// depth attachment, if stencil is true only formats with a stencil component are considered. If no
+// preferences are given DepthFormatPreferences or DepthStencilFormatPreferences are used.
+func (p *PhysicalDevice) FindDepthFormat(stencil bool, preferences ...vk.Format) (vk.Format, error) {
+	if len(preferences) == 0 {
+		preferences = DepthFormatPreferences
+		if stencil {
+			preferences = DepthStencilFormatPreferences
+		}
+	}
+
+	candidates := make([]vk.Format, 0, len(preferences))
+	for _, f := range preferences {
+		if !IsDepthFormat(f) || (stencil && !HasStencilComponent(f)) {
+			continue
+		}
+		candidates = append(candidates, f)
+	}
+
+	return p.FindSupportedFormat(candidates, vk.ImageTilingOptimal, vk.FormatFeatureDepthStencilAttachmentBit)
+}
+
+// SupportedSampleCounts returns the sample counts supported by both color and depth framebuffer attachments
+func (p *PhysicalDevice) SupportedSampleCounts() vk.SampleCountFlags {
+	p.VKPhysicalDeviceProperties.Limits.Deref()
+	limits := p.VKPhysicalDeviceProperties.Limits
+	return limits.FramebufferColorSampleCounts & limits.FramebufferDepthSampleCounts
+}
+
+// MaxUsableSampleCount returns the highest sample count supported by this device which is no
+// greater than the requested count
+func (p *PhysicalDevice) MaxUsableSampleCount(requested vk.SampleCountFlagBits) vk.SampleCountFlagBits {
+	supported := p.SupportedSampleCounts()
+	for c := vk.SampleCount64Bit; c > vk.SampleCount1Bit; c >>= 1 {
+		if c <= requested && supported&vk.SampleCountFlags(c) != 0 {
+			return c
+		}
+	}
+	return vk.SampleCount1Bit
+}
+
 type MemoryTypeSlice []vk.MemoryType
 
 func (m MemoryTypeSlice) Filter(f func(properties vk.MemoryPropertyFlagBits) bool) MemoryTypeSlice {
@@ -1,6 +1,8 @@
 package vkg
 
 import (
+	"fmt"
+
 	vk "github.com/vulkan-go/vulkan"
 )
 
@@ -9,43 +11,52 @@ type ComputePipeline struct {
 	VKPipeline                      vk.Pipeline
 	VKPipelineShaderStageCreateInfo vk.PipelineShaderStageCreateInfo
 	VKPipelineLayout                vk.PipelineLayout
-}
+	PipelineLayout                  *PipelineLayout
 
-type PipelineCache struct {
-	Device          *Device
-	VKPipelineCache vk.PipelineCache
+	stage pipelineShaderStage
 }
 
-func (c *PipelineCache) Destroy() {
-	vk.DestroyPipelineCache(c.Device.VKDevice, c.VKPipelineCache, nil)
+func (c *ComputePipeline) SetPipelineLayout(layout *PipelineLayout) {
+	c.PipelineLayout = layout
+	c.VKPipelineLayout = layout.VKPipelineLayout
 }
 
-func (d *Device) CreatePipelineCache() (*PipelineCache, error) {
-	var pipelineCacheCreate = vk.PipelineCacheCreateInfo{}
-	pipelineCacheCreate.SType = vk.StructureTypePipelineCacheCreateInfo
-
-	var pipelineCache vk.PipelineCache
+func (c *ComputePipeline) SetShaderStage(entryPoint string, shaderModule *ShaderModule) {
+	c.VKPipelineShaderStageCreateInfo = shaderModule.VKPipelineShaderStageCreateInfo(vk.ShaderStageComputeBit, entryPoint)
+	c.stage = pipelineShaderStage{module: shaderModule, entryPoint: entryPoint, stage: vk.ShaderStageComputeBit}
+}
 
-	err := vk.Error(vk.CreatePipelineCache(d.VKDevice, &pipelineCacheCreate, nil, &pipelineCache))
+// Reflect returns the reflection of the shader stage set with SetShaderStage
+func (c *ComputePipeline) Reflect() (*PipelineReflection, error) {
+	if c.stage.module == nil {
+		return nil, fmt.Errorf("compute pipeline has no shader stage")
+	}
+	reflection, err := c.stage.reflect()
 	if err != nil {
 		return nil, err
 	}
-
-	var ret PipelineCache
-	ret.Device = d
-	ret.VKPipelineCache = pipelineCache
-	return &ret, nil
+	return MergeShaderStages(reflection)
 }
 
-func (c *ComputePipeline) SetPipelineLayout(layout *PipelineLayout) {
-	c.VKPipelineLayout = layout.VKPipelineLayout
-}
-
-func (c *ComputePipeline) SetShaderStage(entryPoint string, shaderModule *ShaderModule) {
-	c.VKPipelineShaderStageCreateInfo = shaderModule.VKPipelineShaderStageCreateInfo(vk.ShaderStageComputeBit, entryPoint)
+// ApplyReflection creates a pipeline layout and descriptor set layouts from the reflection of the
+// shader stage and sets the layout, the layouts must be destroyed by the caller
+func (c *ComputePipeline) ApplyReflection(d *Device) (*PipelineLayout, []*DescriptorSetLayout, error) {
+	r, err := c.Reflect()
+	if err != nil {
+		return nil, nil, err
+	}
+	layout, setLayouts, err := d.CreateReflectedPipelineLayout(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.SetPipelineLayout(layout)
+	return layout, setLayouts, nil
 }
 
 func (d *Device) CreateComputePipelines(pc *PipelineCache, cp ...*ComputePipeline) error {
+	if len(cp) == 0 {
+		return nil
+	}
 
 	pipelines := make([]vk.Pipeline, len(cp))
 
@@ -59,11 +70,16 @@ func (d *Device) CreateComputePipelines(pc *PipelineCache, cp ...*ComputePipelin
 		ci[i] = pipelineCreateInfo
 	}
 
-	err := vk.Error(vk.CreateComputePipelines(
-		d.VKDevice, pc.VKPipelineCache,
-		1, ci,
-		nil, pipelines))
+	var cache vk.PipelineCache
+	if pc != nil {
+		cache = pc.VKPipelineCache
+	}
 
+	create := d.createComputePipelines
+	if create == nil {
+		create = d.vkCreateComputePipelines
+	}
+	err := create(cache, ci, pipelines)
 	if err != nil {
 		return err
 	}
@@ -77,6 +93,10 @@ func (d *Device) CreateComputePipelines(pc *PipelineCache, cp ...*ComputePipelin
 
 }
 
+func (d *Device) vkCreateComputePipelines(cache vk.PipelineCache, infos []vk.ComputePipelineCreateInfo, pipelines []vk.Pipeline) error {
+	return vk.Error(vk.CreateComputePipelines(d.VKDevice, cache, uint32(len(infos)), infos, nil, pipelines))
+}
+
 func (c *ComputePipeline) Destroy() {
 	vk.DestroyPipeline(c.Device.VKDevice, c.VKPipeline, nil)
 }
@@ -0,0 +1,33 @@
+package vkg
+
+import (
+	"testing"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestCreateComputePipelines(t *testing.T) {
+	fakes := make([]byte, 3)
+	d := &Device{}
+	d.createComputePipelines = func(cache vk.PipelineCache, infos []vk.ComputePipelineCreateInfo, pipelines []vk.Pipeline) error {
+		if len(infos) != len(pipelines) {
+			t.Fatalf("expected a pipeline for each create info")
+		}
+		for i := range pipelines {
+			pipelines[i] = vk.Pipeline(unsafe.Pointer(&fakes[i]))
+		}
+		return nil
+	}
+
+	cp := []*ComputePipeline{{}, {}, {}}
+	err := d.CreateComputePipelines(nil, cp...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range cp {
+		if p.VKPipeline != vk.Pipeline(unsafe.Pointer(&fakes[i])) || p.Device != d {
+			t.Errorf("pipeline %d was not given its handle", i)
+		}
+	}
+}
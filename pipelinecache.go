@@ -0,0 +1,178 @@
+package vkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+type PipelineCache struct {
+	Device          *Device
+	VKPipelineCache vk.PipelineCache
+
+	// LoadErr is the reason the initial data was discarded by LoadPipelineCache, if it was
+	LoadErr error
+}
+
+// PipelineCacheHeaderSize is the size of version one of the pipeline cache header
+const PipelineCacheHeaderSize = 32
+
+// PipelineCacheHeader is the header at the start of pipeline cache data, which identifies the device
+// and driver the data was created by
+type PipelineCacheHeader struct {
+	HeaderSize    uint32
+	HeaderVersion vk.PipelineCacheHeaderVersion
+	VendorID      uint32
+	DeviceID      uint32
+	UUID          [vk.UuidSize]byte
+}
+
+// ParsePipelineCacheHeader reads the header of pipeline cache data, the fields of which are always
+// little endian
+func ParsePipelineCacheHeader(data []byte) (PipelineCacheHeader, error) {
+	var ret PipelineCacheHeader
+	if len(data) < PipelineCacheHeaderSize {
+		return ret, fmt.Errorf("pipeline cache data is too short (%d bytes) for a header", len(data))
+	}
+	ret.HeaderSize = binary.LittleEndian.Uint32(data[0:])
+	ret.HeaderVersion = vk.PipelineCacheHeaderVersion(binary.LittleEndian.Uint32(data[4:]))
+	ret.VendorID = binary.LittleEndian.Uint32(data[8:])
+	ret.DeviceID = binary.LittleEndian.Uint32(data[12:])
+	copy(ret.UUID[:], data[16:32])
+	if ret.HeaderSize < PipelineCacheHeaderSize || uint64(ret.HeaderSize) > uint64(len(data)) {
+		return ret, fmt.Errorf("invalid pipeline cache header size %d", ret.HeaderSize)
+	}
+	return ret, nil
+}
+
+// ValidatePipelineCacheData returns an error if the pipeline cache data was not created by this device and
+// driver, such data is ignored by the driver at best so it should be discarded
+func (p *PhysicalDevice) ValidatePipelineCacheData(data []byte) error {
+	header, err := ParsePipelineCacheHeader(data)
+	if err != nil {
+		return err
+	}
+	props := p.VKPhysicalDeviceProperties
+	switch {
+	case header.HeaderVersion != vk.PipelineCacheHeaderVersionOne:
+		return fmt.Errorf("unsupported pipeline cache header version %d", header.HeaderVersion)
+	case header.VendorID != props.VendorID:
+		return fmt.Errorf("pipeline cache is for vendor %#x not %#x", header.VendorID, props.VendorID)
+	case header.DeviceID != props.DeviceID:
+		return fmt.Errorf("pipeline cache is for device %#x not %#x", header.DeviceID, props.DeviceID)
+	case !bytes.Equal(header.UUID[:], props.PipelineCacheUUID[:]):
+		return fmt.Errorf("pipeline cache UUID %x does not match the driver's %x", header.UUID, props.PipelineCacheUUID)
+	}
+	return nil
+}
+
+func (c *PipelineCache) Destroy() {
+	vk.DestroyPipelineCache(c.Device.VKDevice, c.VKPipelineCache, nil)
+}
+
+func (d *Device) CreatePipelineCache() (*PipelineCache, error) {
+	return d.CreatePipelineCacheWithData(nil)
+}
+
+// CreatePipelineCacheWithData creates a pipeline cache with initial data from a previous cache, the data
+// must be validated with PhysicalDevice.ValidatePipelineCacheData first. See LoadPipelineCache.
+func (d *Device) CreatePipelineCacheWithData(data []byte) (*PipelineCache, error) {
+	var pipelineCacheCreate = vk.PipelineCacheCreateInfo{}
+	pipelineCacheCreate.SType = vk.StructureTypePipelineCacheCreateInfo
+	if len(data) > 0 {
+		pipelineCacheCreate.InitialDataSize = uint(len(data))
+		pipelineCacheCreate.PInitialData = unsafe.Pointer(&data[0])
+	}
+
+	var pipelineCache vk.PipelineCache
+
+	err := vk.Error(vk.CreatePipelineCache(d.VKDevice, &pipelineCacheCreate, nil, &pipelineCache))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret PipelineCache
+	ret.Device = d
+	ret.VKPipelineCache = pipelineCache
+	return &ret, nil
+}
+
+// LoadPipelineCache creates a pipeline cache with the data saved to the file by PipelineCache.Save. If
+// the file doesn't exist the cache is empty, if the data is for another device or driver, or is
+// corrupt, it is discarded and the reason is set as the cache's LoadErr.
+func (d *Device) LoadPipelineCache(file string) (*PipelineCache, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var loadErr error
+	if len(data) > 0 {
+		loadErr = d.PhysicalDevice.ValidatePipelineCacheData(data)
+		if loadErr != nil {
+			data = nil
+		}
+	}
+
+	ret, err := d.CreatePipelineCacheWithData(data)
+	if err != nil && data != nil {
+		// The driver rejected the data, so start again with an empty cache
+		loadErr = fmt.Errorf("unable to create pipeline cache from %s: %w", file, err)
+		ret, err = d.CreatePipelineCache()
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret.LoadErr = loadErr
+	return ret, nil
+}
+
+// Data returns the contents of the cache, which can be used as the initial data of a new cache
+func (c *PipelineCache) Data() ([]byte, error) {
+	var size uint
+	err := vk.Error(vk.GetPipelineCacheData(c.Device.VKDevice, c.VKPipelineCache, &size, nil))
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	data := make([]byte, size)
+	err = vk.Error(vk.GetPipelineCacheData(c.Device.VKDevice, c.VKPipelineCache, &size, unsafe.Pointer(&data[0])))
+	if err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+// Save writes the contents of the cache to a file so it can be loaded with LoadPipelineCache, the file
+// is replaced atomically so a crash while saving doesn't leave a truncated cache behind
+func (c *PipelineCache) Save(file string) error {
+	data, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package vkg
+
+import (
+	"encoding/binary"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func pipelineCacheData(version, vendor, device uint32, uuid byte) []byte {
+	data := make([]byte, PipelineCacheHeaderSize+8)
+	binary.LittleEndian.PutUint32(data[0:], PipelineCacheHeaderSize)
+	binary.LittleEndian.PutUint32(data[4:], version)
+	binary.LittleEndian.PutUint32(data[8:], vendor)
+	binary.LittleEndian.PutUint32(data[12:], device)
+	for i := 16; i < 32; i++ {
+		data[i] = uuid
+	}
+	return data
+}
+
+func TestValidatePipelineCacheData(t *testing.T) {
+	p := &PhysicalDevice{}
+	p.VKPhysicalDeviceProperties.VendorID = 0x10de
+	p.VKPhysicalDeviceProperties.DeviceID = 0x1b80
+	for i := range p.VKPhysicalDeviceProperties.PipelineCacheUUID {
+		p.VKPhysicalDeviceProperties.PipelineCacheUUID[i] = 7
+	}
+
+	header, err := ParsePipelineCacheHeader(pipelineCacheData(1, 0x10de, 0x1b80, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.HeaderVersion != vk.PipelineCacheHeaderVersionOne || header.VendorID != 0x10de || header.DeviceID != 0x1b80 || header.UUID[15] != 7 {
+		t.Errorf("unexpected header %+v", header)
+	}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		valid bool
+	}{
+		{"matching", pipelineCacheData(1, 0x10de, 0x1b80, 7), true},
+		{"short", pipelineCacheData(1, 0x10de, 0x1b80, 7)[:20], false},
+		{"version", pipelineCacheData(2, 0x10de, 0x1b80, 7), false},
+		{"vendor", pipelineCacheData(1, 0x1002, 0x1b80, 7), false},
+		{"device", pipelineCacheData(1, 0x10de, 0x1b81, 7), false},
+		{"driver", pipelineCacheData(1, 0x10de, 0x1b80, 8), false},
+	}
+	for _, test := range tests {
+		err := p.ValidatePipelineCacheData(test.data)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %v got %v", test.name, test.valid, err)
+		}
+	}
+
+	bad := pipelineCacheData(1, 0x10de, 0x1b80, 7)
+	binary.LittleEndian.PutUint32(bad[0:], 1024)
+	if _, err := ParsePipelineCacheHeader(bad); err == nil {
+		t.Errorf("expected an error for a header larger than the data")
+	}
+}
@@ -0,0 +1,530 @@
+package vkg
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"sort"
+	"sync"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// PipelineKey identifies a graphics pipeline variant, it is a hash of the normalized state of a
+// GraphicsPipelineConfig and the render pass it is used with
+type PipelineKey [sha256.Size]byte
+
+func (k PipelineKey) String() string {
+	return hex.EncodeToString(k[:8])
+}
+
+// PipelineTarget is the render pass a pipeline variant is created for
+type PipelineTarget struct {
+	RenderPass vk.RenderPass
+	// RenderPassInfo is what the render pass was created with, pipelines are reused with any render
+	// pass which has the same attachment formats, sample counts and subpass references
+	RenderPassInfo vk.RenderPassCreateInfo
+	Subpass        uint32
+	// Extent is used for the viewport and scissor unless they are dynamic state
+	Extent vk.Extent2D
+	// Samples overrides the RasterizationSamples of configs if set
+	Samples vk.SampleCountFlagBits
+}
+
+// PipelineLibrary creates graphics pipeline variants on demand, identical requests share a pipeline. It
+// is safe for concurrent use, a variant requested by several goroutines at once is only created once.
+type PipelineLibrary struct {
+	Device *Device
+	Cache  *PipelineCache
+
+	// UseDerivatives creates variants with the same shader stages and layout as derivatives of the first
+	// of them to be created, which can make creation cheaper on some drivers
+	UseDerivatives bool
+
+	// EvictAfter if set is the number of frames a variant can go unused before GraphicsApp destroys it
+	EvictAfter uint64
+
+	mutex    sync.Mutex
+	variants map[PipelineKey]*pipelineVariant
+	// parents are the variants which allow derivatives, keyed by the hash of their stages and layout
+	parents map[PipelineKey]PipelineKey
+	frame   uint64
+
+	// createPipeline and destroyPipeline are replaced by tests
+	createPipeline  func(info vk.GraphicsPipelineCreateInfo) (vk.Pipeline, error)
+	destroyPipeline func(pipeline vk.Pipeline)
+}
+
+type pipelineVariant struct {
+	pipeline vk.Pipeline
+	err      error
+	ready    chan struct{}
+	lastUsed uint64
+	family   PipelineKey
+	// modules are the shader modules of the stages added with AddShaderStage
+	modules []*ShaderModule
+	// pins is the number of derivatives being created from the variant, it isn't evicted while pinned
+	pins int
+}
+
+// CreatePipelineLibrary creates an empty library which creates pipelines with the cache, which may be nil
+func (d *Device) CreatePipelineLibrary(cache *PipelineCache) *PipelineLibrary {
+	l := &PipelineLibrary{
+		Device:   d,
+		Cache:    cache,
+		variants: make(map[PipelineKey]*pipelineVariant),
+		parents:  make(map[PipelineKey]PipelineKey),
+	}
+	l.createPipeline = l.vkCreatePipeline
+	l.destroyPipeline = l.vkDestroyPipeline
+	return l
+}
+
+func (l *PipelineLibrary) vkCreatePipeline(info vk.GraphicsPipelineCreateInfo) (vk.Pipeline, error) {
+	var cache vk.PipelineCache
+	if l.Cache != nil {
+		cache = l.Cache.VKPipelineCache
+	}
+	pipelines := make([]vk.Pipeline, 1)
+	err := vk.Error(vk.CreateGraphicsPipelines(l.Device.VKDevice, cache, 1, []vk.GraphicsPipelineCreateInfo{info}, nil, pipelines))
+	if err != nil {
+		return vk.NullPipeline, err
+	}
+	return pipelines[0], nil
+}
+
+func (l *PipelineLibrary) vkDestroyPipeline(pipeline vk.Pipeline) {
+	vk.DestroyPipeline(l.Device.VKDevice, pipeline, nil)
+}
+
+// Key returns the key of the variant, configs which produce the same pipeline for the target have the
+// same key. The Configure callback of the config is not part of the key.
+func (l *PipelineLibrary) Key(config *GraphicsPipelineConfig, target PipelineTarget) PipelineKey {
+	key, _ := pipelineKeys(config, target)
+	return key
+}
+
+// Get returns the pipeline for the config and target, creating it if it doesn't exist. The pipeline is
+// owned by the library and must not be destroyed. Changes made by the Configure callback of the config
+// are not part of the key, so configs which only differ in their callback share the first pipeline created.
+func (l *PipelineLibrary) Get(config *GraphicsPipelineConfig, target PipelineTarget) (vk.Pipeline, error) {
+	key, family := pipelineKeys(config, target)
+
+	l.mutex.Lock()
+	if v, ok := l.variants[key]; ok {
+		v.lastUsed = l.frame
+		l.mutex.Unlock()
+		<-v.ready
+		return v.pipeline, v.err
+	}
+	v := &pipelineVariant{ready: make(chan struct{}), lastUsed: l.frame, family: family}
+	for _, s := range config.stages {
+		v.modules = append(v.modules, s.module)
+	}
+	l.variants[key] = v
+
+	var flags vk.PipelineCreateFlags
+	var parent *pipelineVariant
+	for l.UseDerivatives {
+		if parentKey, ok := l.parents[family]; ok {
+			parent = l.variants[parentKey]
+		}
+		if parent == nil {
+			l.parents[family] = key
+			flags = vk.PipelineCreateFlags(vk.PipelineCreateAllowDerivativesBit)
+			break
+		}
+		// The parent must be created before its derivatives, and is pinned so it isn't evicted until
+		// they have been
+		parent.pins++
+		l.mutex.Unlock()
+		<-parent.ready
+		l.mutex.Lock()
+		if parent.err == nil {
+			flags = vk.PipelineCreateFlags(vk.PipelineCreateDerivativeBit)
+			break
+		}
+		// The parent failed, derive from the variant which replaced it or become the parent
+		parent.pins--
+		parent = nil
+	}
+	l.mutex.Unlock()
+
+	base := vk.NullPipeline
+	if parent != nil {
+		base = parent.pipeline
+	}
+	v.pipeline, v.err = l.create(config, target, flags, base)
+
+	l.mutex.Lock()
+	if parent != nil {
+		parent.pins--
+	}
+	if v.err != nil {
+		// Don't keep failures so the request can be retried
+		delete(l.variants, key)
+		if l.parents[family] == key {
+			delete(l.parents, family)
+		}
+	}
+	l.mutex.Unlock()
+	close(v.ready)
+	return v.pipeline, v.err
+}
+
+func (l *PipelineLibrary) create(config *GraphicsPipelineConfig, target PipelineTarget, flags vk.PipelineCreateFlags, base vk.Pipeline) (vk.Pipeline, error) {
+	info, err := config.VKGraphicsPipelineCreateInfo(target.Extent)
+	if err != nil {
+		return vk.NullPipeline, err
+	}
+	info.RenderPass = target.RenderPass
+	info.Subpass = target.Subpass
+	if target.Samples != 0 && info.PMultisampleState != nil {
+		info.PMultisampleState.RasterizationSamples = target.Samples
+	}
+	info.Flags |= flags
+	if base != vk.NullPipeline {
+		info.BasePipelineHandle = base
+		info.BasePipelineIndex = -1
+	}
+	pipeline, err := l.createPipeline(info)
+	if err != nil {
+		return vk.NullPipeline, fmt.Errorf("unable to create pipeline variant: %w", err)
+	}
+	return pipeline, nil
+}
+
+// Len returns the number of variants in the library
+func (l *PipelineLibrary) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.variants)
+}
+
+// NextFrame advances the frame counter used to find unused variants, see EvictUnused
+func (l *PipelineLibrary) NextFrame() {
+	l.mutex.Lock()
+	l.frame++
+	l.mutex.Unlock()
+}
+
+// EvictUnused destroys the variants which have not been requested in the specified number of frames and
+// aren't the parent of a derivative being created, returning the number destroyed. The GPU must have finished with the variants, so frames should be at
+// least the number of frames in flight.
+func (l *PipelineLibrary) EvictUnused(frames uint64) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	evicted := 0
+	for key, v := range l.variants {
+		if l.frame-v.lastUsed < frames || v.pins > 0 {
+			continue
+		}
+		select {
+		case <-v.ready:
+		default:
+			// Still being created
+			continue
+		}
+		l.evict(key, v)
+		evicted++
+	}
+	return evicted
+}
+
+// evict destroys a variant which has been created
+func (l *PipelineLibrary) evict(key PipelineKey, v *pipelineVariant) {
+	l.destroyPipeline(v.pipeline)
+	delete(l.variants, key)
+	if l.parents[v.family] == key {
+		// Later variants can't derive from a pipeline which no longer exists, the next one created becomes the parent
+		delete(l.parents, v.family)
+	}
+}
+
+// EvictShaderModule destroys the variants using the module so they are created again with its new code
+// after it has been reloaded, returning the number destroyed. It must not be called while variants are
+// being created.
+func (l *PipelineLibrary) EvictShaderModule(m *ShaderModule) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	evicted := 0
+	for key, v := range l.variants {
+		if !v.usesShaderModule(m) {
+			continue
+		}
+		select {
+		case <-v.ready:
+		default:
+			continue
+		}
+		l.evict(key, v)
+		evicted++
+	}
+	return evicted
+}
+
+func (v *pipelineVariant) usesShaderModule(m *ShaderModule) bool {
+	for _, module := range v.modules {
+		if module == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Destroy destroys all of the variants, it must not be called while variants are being created
+func (l *PipelineLibrary) Destroy() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, v := range l.variants {
+		if v.err == nil {
+			l.destroyPipeline(v.pipeline)
+		}
+	}
+	l.variants = make(map[PipelineKey]*pipelineVariant)
+	l.parents = make(map[PipelineKey]PipelineKey)
+}
+
+// Variant returns a copy of the config which can be modified to describe another variant, the copy
+// shares the shader modules and layouts of the config but destroying it doesn't destroy them
+func (g *GraphicsPipelineConfig) Variant() *GraphicsPipelineConfig {
+	ret := *g
+	ret.toDestroy = nil
+	ret.ShaderStages = append([]vk.PipelineShaderStageCreateInfo(nil), g.ShaderStages...)
+	ret.stages = append([]pipelineShaderStage(nil), g.stages...)
+	ret.DescriptorSetLayouts = append([]*DescriptorSetLayout(nil), g.DescriptorSetLayouts...)
+	ret.DynamicState = append([]vk.DynamicState(nil), g.DynamicState...)
+	ret.BlendAttachments = append([]vk.PipelineColorBlendAttachmentState(nil), g.BlendAttachments...)
+	ret.VertexInputBindingDescriptions = append([]vk.VertexInputBindingDescription(nil), g.VertexInputBindingDescriptions...)
+	ret.VertexInputAttributeDescriptions = append([]vk.VertexInputAttributeDescription(nil), g.VertexInputAttributeDescriptions...)
+	return &ret
+}
+
+// keyHasher writes values to a hash in a fixed layout
+type keyHasher struct {
+	h   hash.Hash
+	buf [8]byte
+}
+
+func (k *keyHasher) u32(v uint32) {
+	binary.LittleEndian.PutUint32(k.buf[:4], v)
+	k.h.Write(k.buf[:4])
+}
+
+func (k *keyHasher) i32(v int32) {
+	k.u32(uint32(v))
+}
+
+func (k *keyHasher) f32(v float32) {
+	k.u32(math.Float32bits(v))
+}
+
+func (k *keyHasher) bool(v bool) {
+	if v {
+		k.u32(1)
+	} else {
+		k.u32(0)
+	}
+}
+
+func (k *keyHasher) str(v string) {
+	k.u32(uint32(len(v)))
+	k.h.Write([]byte(v))
+}
+
+// handle writes a Vulkan handle, which are pointers or integers depending on the platform
+func (k *keyHasher) handle(v interface{}) {
+	k.str(fmt.Sprintf("%v", v))
+}
+
+func (k *keyHasher) sum() PipelineKey {
+	var ret PipelineKey
+	copy(ret[:], k.h.Sum(nil))
+	return ret
+}
+
+// pipelineKeys returns the key of the variant, and the key of its family of variants which have the same
+// shader stages and layout
+func pipelineKeys(g *GraphicsPipelineConfig, target PipelineTarget) (PipelineKey, PipelineKey) {
+	k := &keyHasher{h: sha256.New()}
+	hashShaderStages(k, g)
+	if g.PipelineLayout != nil {
+		k.handle(g.PipelineLayout.VKPipelineLayout)
+	} else {
+		k.str("")
+	}
+	family := k.sum()
+
+	hashRenderPass(k, target.RenderPassInfo)
+	k.u32(target.Subpass)
+
+	dynamic := make(map[vk.DynamicState]bool)
+	states := make([]int, 0, len(g.DynamicState))
+	for _, s := range g.DynamicState {
+		if !dynamic[s] {
+			dynamic[s] = true
+			states = append(states, int(s))
+		}
+	}
+	sort.Ints(states)
+	k.u32(uint32(len(states)))
+	for _, s := range states {
+		k.i32(int32(s))
+	}
+
+	// The viewport and scissor are only part of the pipeline if they aren't dynamic
+	if !dynamic[vk.DynamicStateViewport] {
+		if g.Viewport != nil {
+			v := *g.Viewport
+			for _, f := range []float32{v.X, v.Y, v.Width, v.Height, v.MinDepth, v.MaxDepth} {
+				k.f32(f)
+			}
+		} else {
+			k.u32(target.Extent.Width)
+			k.u32(target.Extent.Height)
+		}
+	}
+	if !dynamic[vk.DynamicStateScissor] {
+		k.u32(target.Extent.Width)
+		k.u32(target.Extent.Height)
+	}
+
+	bindings := append([]vk.VertexInputBindingDescription(nil), g.VertexInputBindingDescriptions...)
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Binding < bindings[j].Binding })
+	k.u32(uint32(len(bindings)))
+	for _, b := range bindings {
+		k.u32(b.Binding)
+		k.u32(b.Stride)
+		k.i32(int32(b.InputRate))
+	}
+	attributes := append([]vk.VertexInputAttributeDescription(nil), g.VertexInputAttributeDescriptions...)
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Location < attributes[j].Location })
+	k.u32(uint32(len(attributes)))
+	for _, a := range attributes {
+		k.u32(a.Location)
+		k.u32(a.Binding)
+		k.i32(int32(a.Format))
+		k.u32(a.Offset)
+	}
+
+	k.i32(int32(g.PrimitiveTopology))
+	k.u32(uint32(g.PrimitiveRestartEnable))
+	k.i32(int32(g.PolygonMode))
+	if !dynamic[vk.DynamicStateLineWidth] {
+		k.f32(g.LineWidth)
+	}
+	k.u32(uint32(g.CullMode))
+	k.i32(int32(g.FrontFace))
+
+	samples := g.RasterizationSamples
+	if target.Samples != 0 {
+		samples = target.Samples
+	}
+	if samples == 0 {
+		samples = vk.SampleCount1Bit
+	}
+	k.u32(uint32(samples))
+	k.f32(g.MinSampleShading)
+	k.bool(g.AlphaToCoverageEnable)
+
+	k.bool(g.DepthTestEnable)
+	k.bool(g.DepthWriteEnable)
+	if g.DepthTestEnable {
+		k.i32(int32(g.depthCompareOp()))
+	}
+	k.bool(g.StencilTestEnable)
+	if g.StencilTestEnable {
+		for _, s := range []vk.StencilOpState{g.StencilFront, g.StencilBack} {
+			k.i32(int32(s.FailOp))
+			k.i32(int32(s.PassOp))
+			k.i32(int32(s.DepthFailOp))
+			k.i32(int32(s.CompareOp))
+			k.u32(s.CompareMask)
+			k.u32(s.WriteMask)
+			k.u32(s.Reference)
+		}
+	}
+
+	blend := g.BlendAttachments
+	if blend == nil {
+		blend = []vk.PipelineColorBlendAttachmentState{{
+			ColorWriteMask: vk.ColorComponentFlags(vk.ColorComponentRBit | vk.ColorComponentGBit | vk.ColorComponentBBit | vk.ColorComponentABit),
+			BlendEnable:    vk.False,
+		}}
+	}
+	k.u32(uint32(len(blend)))
+	for _, b := range blend {
+		k.u32(uint32(b.ColorWriteMask))
+		k.u32(uint32(b.BlendEnable))
+		// Blend factors and operations are ignored unless blending is enabled
+		if b.BlendEnable == vk.True {
+			k.i32(int32(b.SrcColorBlendFactor))
+			k.i32(int32(b.DstColorBlendFactor))
+			k.i32(int32(b.ColorBlendOp))
+			k.i32(int32(b.SrcAlphaBlendFactor))
+			k.i32(int32(b.DstAlphaBlendFactor))
+			k.i32(int32(b.AlphaBlendOp))
+		}
+	}
+
+	return k.sum(), family
+}
+
+// hashShaderStages writes the modules, entry points and specialization constants of the stages. Modules
+// added with AddShaderStage are written as the module and the hash of its code, so a reloaded module gets
+// new keys even if the driver reuses its handle, other stages are written as their handle.
+func hashShaderStages(k *keyHasher, g *GraphicsPipelineConfig) {
+	tracked := len(g.stages) == len(g.ShaderStages)
+	k.u32(uint32(len(g.ShaderStages)))
+	for i, s := range g.ShaderStages {
+		k.u32(uint32(s.Stage))
+		if tracked {
+			m := g.stages[i].module
+			k.str(fmt.Sprintf("%p", m))
+			k.h.Write(m.codeHash[:])
+		} else {
+			k.handle(s.Module)
+		}
+		k.str(s.PName)
+		if tracked && g.stages[i].constants != nil {
+			entries, data := g.stages[i].constants.Data()
+			k.u32(uint32(len(entries)))
+			for _, e := range entries {
+				k.u32(e.ConstantID)
+			}
+			k.h.Write(data)
+		} else {
+			k.u32(0)
+		}
+	}
+}
+
+// hashRenderPass writes the parts of a render pass which determine if it is compatible with another, the
+// formats and sample counts of the attachments and the attachments used by each subpass
+func hashRenderPass(k *keyHasher, info vk.RenderPassCreateInfo) {
+	k.u32(uint32(len(info.PAttachments)))
+	for _, a := range info.PAttachments {
+		k.i32(int32(a.Format))
+		k.u32(uint32(a.Samples))
+	}
+	references := func(refs []vk.AttachmentReference) {
+		k.u32(uint32(len(refs)))
+		for _, r := range refs {
+			k.u32(r.Attachment)
+		}
+	}
+	k.u32(uint32(len(info.PSubpasses)))
+	for _, s := range info.PSubpasses {
+		references(s.PInputAttachments)
+		references(s.PColorAttachments)
+		references(s.PResolveAttachments)
+		if s.PDepthStencilAttachment != nil {
+			k.u32(s.PDepthStencilAttachment.Attachment)
+		} else {
+			k.u32(vk.AttachmentUnused)
+		}
+	}
+}
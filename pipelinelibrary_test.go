@@ -0,0 +1,306 @@
+package vkg
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func testRenderPassInfo(format vk.Format, load vk.AttachmentLoadOp) vk.RenderPassCreateInfo {
+	return vk.RenderPassCreateInfo{
+		PAttachments: []vk.AttachmentDescription{{Format: format, Samples: vk.SampleCount1Bit, LoadOp: load}},
+		PSubpasses: []vk.SubpassDescription{{
+			PColorAttachments: []vk.AttachmentReference{{Attachment: 0, Layout: vk.ImageLayoutColorAttachmentOptimal}},
+		}},
+	}
+}
+
+func TestPipelineLibraryKey(t *testing.T) {
+	l := (&Device{}).CreatePipelineLibrary(nil)
+	base := (&Device{}).CreateGraphicsPipelineConfig()
+	target := PipelineTarget{
+		RenderPassInfo: testRenderPassInfo(vk.FormatB8g8r8a8Unorm, vk.AttachmentLoadOpClear),
+		Extent:         vk.Extent2D{Width: 800, Height: 600},
+	}
+	key := l.Key(base, target)
+
+	if l.Key(base.Variant(), target) != key {
+		t.Errorf("a copy of the config should have the same key")
+	}
+
+	culled := base.Variant().SetCullMode(vk.CullModeNone)
+	if l.Key(culled, target) == key {
+		t.Errorf("changing the cull mode should change the key")
+	}
+
+	// Blend factors don't matter when blending is disabled
+	unblended := base.Variant()
+	unblended.AddBlendAttachment(vk.PipelineColorBlendAttachmentState{
+		ColorWriteMask:      vk.ColorComponentFlags(vk.ColorComponentRBit | vk.ColorComponentGBit | vk.ColorComponentBBit | vk.ColorComponentABit),
+		BlendEnable:         vk.False,
+		SrcColorBlendFactor: vk.BlendFactorSrcAlpha,
+	})
+	if l.Key(unblended, target) != key {
+		t.Errorf("disabled blend state should be normalized")
+	}
+	unblended.BlendAttachments[0].BlendEnable = vk.True
+	if l.Key(unblended, target) == key {
+		t.Errorf("enabling blending should change the key")
+	}
+
+	// Render passes which only differ by load operations are compatible
+	compatible := target
+	compatible.RenderPassInfo = testRenderPassInfo(vk.FormatB8g8r8a8Unorm, vk.AttachmentLoadOpLoad)
+	if l.Key(base, compatible) != key {
+		t.Errorf("compatible render passes should have the same key")
+	}
+	incompatible := target
+	incompatible.RenderPassInfo = testRenderPassInfo(vk.FormatR8g8b8a8Unorm, vk.AttachmentLoadOpClear)
+	if l.Key(base, incompatible) == key {
+		t.Errorf("incompatible render passes should have different keys")
+	}
+
+	// The extent is only part of the key if the viewport and scissor are not dynamic
+	resized := target
+	resized.Extent = vk.Extent2D{Width: 1024, Height: 768}
+	if l.Key(base, resized) == key {
+		t.Errorf("a static viewport should make the extent part of the key")
+	}
+	dynamic := base.Variant().SetDynamicState(vk.DynamicStateScissor, vk.DynamicStateViewport)
+	if l.Key(dynamic, target) != l.Key(dynamic.Variant().SetDynamicState(vk.DynamicStateViewport, vk.DynamicStateScissor), resized) {
+		t.Errorf("dynamic viewport and scissor should ignore the extent and the order of dynamic states")
+	}
+}
+
+func TestPipelineLibraryGet(t *testing.T) {
+	l := (&Device{}).CreatePipelineLibrary(nil)
+	l.UseDerivatives = true
+
+	var mutex sync.Mutex
+	var created []vk.GraphicsPipelineCreateInfo
+	fakes := make([]byte, 16)
+	l.createPipeline = func(info vk.GraphicsPipelineCreateInfo) (vk.Pipeline, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		created = append(created, info)
+		return vk.Pipeline(unsafe.Pointer(&fakes[len(created)])), nil
+	}
+	destroyed := 0
+	l.destroyPipeline = func(vk.Pipeline) { destroyed++ }
+
+	base := (&Device{}).CreateGraphicsPipelineConfig()
+	target := PipelineTarget{RenderPassInfo: testRenderPassInfo(vk.FormatB8g8r8a8Unorm, vk.AttachmentLoadOpClear)}
+
+	var wg sync.WaitGroup
+	pipelines := make([]vk.Pipeline, 8)
+	for i := range pipelines {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			pipelines[i], err = l.Get(base.Variant(), target)
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(created) != 1 || l.Len() != 1 {
+		t.Fatalf("expected identical requests to create one pipeline, created %d", len(created))
+	}
+	for _, p := range pipelines {
+		if p != pipelines[0] {
+			t.Errorf("expected identical requests to share a pipeline")
+		}
+	}
+	if created[0].Flags != vk.PipelineCreateFlags(vk.PipelineCreateAllowDerivativesBit) {
+		t.Errorf("expected the first variant to allow derivatives, got flags %v", created[0].Flags)
+	}
+
+	wireframe := base.Variant()
+	wireframe.PolygonMode = vk.PolygonModeLine
+	derived, err := l.Get(wireframe, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derived == pipelines[0] || len(created) != 2 {
+		t.Fatalf("expected a new variant")
+	}
+	if created[1].Flags != vk.PipelineCreateFlags(vk.PipelineCreateDerivativeBit) ||
+		created[1].BasePipelineHandle != pipelines[0] || created[1].BasePipelineIndex != -1 {
+		t.Errorf("expected the variant to derive from the first, got %+v", created[1])
+	}
+
+	l.NextFrame()
+	l.NextFrame()
+	if _, err := l.Get(wireframe, target); err != nil {
+		t.Fatal(err)
+	}
+	if n := l.EvictUnused(2); n != 1 || destroyed != 1 || l.Len() != 1 {
+		t.Errorf("expected the unused variant to be evicted, evicted %d", n)
+	}
+
+	// With the parent evicted the next variant becomes a parent
+	culled := base.Variant().SetCullMode(vk.CullModeNone)
+	if _, err := l.Get(culled, target); err != nil {
+		t.Fatal(err)
+	}
+	if created[2].Flags != vk.PipelineCreateFlags(vk.PipelineCreateAllowDerivativesBit) {
+		t.Errorf("expected a new parent, got flags %v", created[2].Flags)
+	}
+
+	l.Destroy()
+	if destroyed != 3 || l.Len() != 0 {
+		t.Errorf("expected all variants to be destroyed, destroyed %d", destroyed)
+	}
+}
+
+func TestPipelineLibraryShaderModules(t *testing.T) {
+	l := (&Device{}).CreatePipelineLibrary(nil)
+	var created []vk.GraphicsPipelineCreateInfo
+	fakes := make([]byte, 16)
+	l.createPipeline = func(info vk.GraphicsPipelineCreateInfo) (vk.Pipeline, error) {
+		created = append(created, info)
+		return vk.Pipeline(unsafe.Pointer(&fakes[len(created)])), nil
+	}
+	destroyed := 0
+	l.destroyPipeline = func(vk.Pipeline) { destroyed++ }
+	target := PipelineTarget{RenderPassInfo: testRenderPassInfo(vk.FormatB8g8r8a8Unorm, vk.AttachmentLoadOpClear)}
+
+	handles := make([]byte, 4)
+	vert := &ShaderModule{VKShaderModule: vk.ShaderModule(unsafe.Pointer(&handles[0])), codeHash: [32]byte{1}}
+	frag := &ShaderModule{VKShaderModule: vk.ShaderModule(unsafe.Pointer(&handles[1])), codeHash: [32]byte{2}}
+	other := &ShaderModule{VKShaderModule: vk.ShaderModule(unsafe.Pointer(&handles[1])), codeHash: [32]byte{2}}
+
+	base := (&Device{}).CreateGraphicsPipelineConfig().
+		AddShaderStage(vert, "main", vk.ShaderStageVertexBit).
+		AddShaderStage(frag, "main", vk.ShaderStageFragmentBit)
+	unrelated := (&Device{}).CreateGraphicsPipelineConfig().
+		AddShaderStage(vert, "main", vk.ShaderStageVertexBit).
+		AddShaderStage(other, "main", vk.ShaderStageFragmentBit)
+	key := l.Key(base, target)
+	if l.Key(unrelated, target) == key {
+		t.Errorf("different modules should have different keys even with the same handle")
+	}
+
+	variant := base.Variant().SetCullMode(vk.CullModeNone)
+	if _, err := l.Get(base, target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(variant, target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(unrelated, target); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the fragment shader, the copy must use the new module rather than the destroyed one
+	frag.VKShaderModule = vk.ShaderModule(unsafe.Pointer(&handles[2]))
+	frag.codeHash = [32]byte{3}
+	if l.Key(base, target) == key {
+		t.Errorf("changing the code of a module should change the key")
+	}
+	if n := l.EvictShaderModule(frag); n != 2 || destroyed != 2 || l.Len() != 1 {
+		t.Errorf("expected the variants using the module to be evicted, evicted %d", n)
+	}
+	if _, err := l.Get(variant, target); err != nil {
+		t.Fatal(err)
+	}
+	if created[3].PStages[1].Module != frag.VKShaderModule {
+		t.Errorf("expected the variant to be created with the reloaded module")
+	}
+}
+
+func TestPipelineLibraryParents(t *testing.T) {
+	l := (&Device{}).CreatePipelineLibrary(nil)
+	l.UseDerivatives = true
+	var mutex sync.Mutex
+	var created []vk.GraphicsPipelineCreateInfo
+	fakes := make([]byte, 16)
+	started := make(chan struct{})
+	release := make(chan error)
+	block := func(vk.GraphicsPipelineCreateInfo) bool { return false }
+	l.createPipeline = func(info vk.GraphicsPipelineCreateInfo) (vk.Pipeline, error) {
+		if block(info) {
+			started <- struct{}{}
+			if err := <-release; err != nil {
+				return vk.NullPipeline, err
+			}
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		created = append(created, info)
+		return vk.Pipeline(unsafe.Pointer(&fakes[len(created)])), nil
+	}
+	l.destroyPipeline = func(vk.Pipeline) {}
+
+	base := (&Device{}).CreateGraphicsPipelineConfig()
+	target := PipelineTarget{RenderPassInfo: testRenderPassInfo(vk.FormatB8g8r8a8Unorm, vk.AttachmentLoadOpClear)}
+	parentKey, family := pipelineKeys(base, target)
+	get := func(config *GraphicsPipelineConfig) chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := l.Get(config, target)
+			done <- err
+		}()
+		return done
+	}
+
+	// The parent isn't evicted while a derivative of it is being created
+	if _, err := l.Get(base, target); err != nil {
+		t.Fatal(err)
+	}
+	block = func(info vk.GraphicsPipelineCreateInfo) bool {
+		return info.Flags&vk.PipelineCreateFlags(vk.PipelineCreateDerivativeBit) != 0
+	}
+	done := get(base.Variant().SetCullMode(vk.CullModeNone))
+	<-started
+	l.NextFrame()
+	l.NextFrame()
+	if n := l.EvictUnused(2); n != 0 {
+		t.Errorf("expected the pinned parent not to be evicted, evicted %d", n)
+	}
+	release <- nil
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := l.EvictUnused(2); n != 2 || l.Len() != 0 {
+		t.Errorf("expected both variants to be evicted once created, evicted %d", n)
+	}
+
+	// A derivative waiting for a parent which fails becomes the parent
+	created = nil
+	blocked := false
+	block = func(vk.GraphicsPipelineCreateInfo) bool {
+		// Only the first parent is blocked
+		ret := !blocked
+		blocked = true
+		return ret
+	}
+	failed := get(base)
+	<-started
+	wireframe := base.Variant()
+	wireframe.PolygonMode = vk.PolygonModeLine
+	done = get(wireframe)
+	for pinned := false; !pinned; {
+		l.mutex.Lock()
+		pinned = l.variants[parentKey].pins == 1
+		l.mutex.Unlock()
+	}
+	release <- fmt.Errorf("failed")
+	if err := <-failed; err == nil {
+		t.Errorf("expected the parent to fail")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].Flags != vk.PipelineCreateFlags(vk.PipelineCreateAllowDerivativesBit) {
+		t.Fatalf("expected the derivative to be created as the parent, got %+v", created)
+	}
+	if l.parents[family] != l.Key(wireframe, target) {
+		t.Errorf("expected the derivative to become the parent of the family")
+	}
+}
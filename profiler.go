@@ -0,0 +1,382 @@
+package vkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// Profiler measures the time taken by named scopes of a command buffer on the GPU using timestamp
+// queries. Each frame in flight has its own query pool, BeginFrame resets the frame's queries and
+// Resolve reads them back once the frame's fence has signaled.
+//
+//	profiler.BeginFrame(cmd, frame)
+//	profiler.Begin(cmd, "shadows")
+//	...
+//	profiler.End(cmd)
+//
+// Scopes are recorded into the frame most recently begun and may be nested, scopes beyond the capacity
+// given to CreateProfiler are not measured.
+type Profiler struct {
+	Device *Device
+	// Window is the number of samples each scope's statistics are calculated over
+	Window int
+	// MaxEvents is the number of trace events kept for WriteChromeTrace, older events are discarded
+	MaxEvents int
+
+	// period is the number of nanoseconds per timestamp tick
+	period float64
+	// mask covers the valid bits of a timestamp
+	mask uint64
+
+	frames  []*profilerFrame
+	current *profilerFrame
+
+	mutex    sync.Mutex
+	scopes   map[string]*scopeSamples
+	names    []string
+	events   []TraceEvent
+	epoch    uint64
+	hasEpoch bool
+	resolved int
+}
+
+type profilerFrame struct {
+	pool   *QueryPool
+	scopes []profilerScope
+	stack  []int
+	next   int
+}
+
+type profilerScope struct {
+	name       string
+	begin, end int
+	depth      int
+}
+
+type scopeSamples struct {
+	count   int
+	samples []time.Duration
+	next    int
+}
+
+// ScopeStats are the statistics of a profiled scope over the profiler's window
+type ScopeStats struct {
+	Name  string
+	Count int
+	Last  time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+}
+
+// TraceEvent is a complete event in the Chrome trace event format, times are in microseconds
+type TraceEvent struct {
+	Name      string                 `json:"name"`
+	Phase     string                 `json:"ph"`
+	Timestamp float64                `json:"ts"`
+	Duration  float64                `json:"dur"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// CreateProfiler creates a profiler for command buffers submitted to queues of the specified family, with
+// room for maxScopes scopes in each of framesInFlight frames
+func (d *Device) CreateProfiler(family *QueueFamily, framesInFlight, maxScopes int) (*Profiler, error) {
+	validBits := family.VKQueueFamilyProperties.TimestampValidBits
+	if validBits == 0 {
+		return nil, fmt.Errorf("queue family %d does not support timestamps", family.Index)
+	}
+
+	d.PhysicalDevice.VKPhysicalDeviceProperties.Limits.Deref()
+	period := d.PhysicalDevice.VKPhysicalDeviceProperties.Limits.TimestampPeriod
+
+	var ret Profiler
+	ret.Device = d
+	ret.Window = 120
+	ret.MaxEvents = 10000
+	ret.period = float64(period)
+	ret.mask = timestampMask(validBits)
+	ret.scopes = make(map[string]*scopeSamples)
+
+	for i := 0; i < framesInFlight; i++ {
+		pool, err := d.CreateQueryPool(vk.QueryTypeTimestamp, maxScopes*2)
+		if err != nil {
+			ret.Destroy()
+			return nil, err
+		}
+		ret.frames = append(ret.frames, &profilerFrame{pool: pool})
+	}
+
+	return &ret, nil
+}
+
+func timestampMask(validBits uint32) uint64 {
+	if validBits >= 64 {
+		return ^uint64(0)
+	}
+	return (uint64(1) << validBits) - 1
+}
+
+func (p *Profiler) frame(frame int) *profilerFrame {
+	if frame < 0 || frame >= len(p.frames) {
+		return nil
+	}
+	return p.frames[frame]
+}
+
+// BeginFrame resets the queries of the frame, it must be recorded outside of a render pass before any
+// scopes. Scopes from the frame's previous use which have not been resolved are discarded.
+func (p *Profiler) BeginFrame(cmd CommandRecorder, frame int) {
+	f := p.frame(frame)
+	if f == nil {
+		return
+	}
+	f.scopes = f.scopes[:0]
+	f.stack = f.stack[:0]
+	f.next = 0
+	p.current = f
+	cmd.CmdResetQueryPool(f.pool, 0, f.pool.Count)
+}
+
+// Begin starts a named scope in the frame most recently begun with BeginFrame
+func (p *Profiler) Begin(cmd CommandRecorder, name string) {
+	f := p.current
+	if f == nil {
+		return
+	}
+	if f.next+2 > f.pool.Count {
+		// Out of queries, the scope is still pushed so End stays balanced
+		f.stack = append(f.stack, -1)
+		return
+	}
+	f.stack = append(f.stack, len(f.scopes))
+	f.scopes = append(f.scopes, profilerScope{name: name, begin: f.next, end: -1, depth: len(f.stack) - 1})
+	cmd.CmdWriteTimestamp(vk.PipelineStageTopOfPipeBit, f.pool, f.next)
+	f.next += 2
+}
+
+// End ends the most recently begun scope
+func (p *Profiler) End(cmd CommandRecorder) {
+	f := p.current
+	if f == nil || len(f.stack) == 0 {
+		return
+	}
+	i := f.stack[len(f.stack)-1]
+	f.stack = f.stack[:len(f.stack)-1]
+	if i < 0 {
+		return
+	}
+	s := &f.scopes[i]
+	s.end = s.begin + 1
+	cmd.CmdWriteTimestamp(vk.PipelineStageBottomOfPipeBit, f.pool, s.end)
+}
+
+// Scope measures the commands recorded by fn
+func (p *Profiler) Scope(cmd CommandRecorder, name string, fn func()) {
+	p.Begin(cmd, name)
+	fn()
+	p.End(cmd)
+}
+
+// profiledRecorder begins a frame of the profiler as soon as the command buffer is begun, so frames
+// recorded by GraphicsApp.MakeCommandBuffer can be profiled
+type profiledRecorder struct {
+	CommandRecorder
+	profiler *Profiler
+	frame    int
+}
+
+func (r *profiledRecorder) Begin() error {
+	err := r.CommandRecorder.Begin()
+	if err != nil {
+		return err
+	}
+	r.profiler.BeginFrame(r.CommandRecorder, r.frame)
+	return nil
+}
+
+func (r *profiledRecorder) BeginOneTime() error {
+	err := r.CommandRecorder.BeginOneTime()
+	if err != nil {
+		return err
+	}
+	r.profiler.BeginFrame(r.CommandRecorder, r.frame)
+	return nil
+}
+
+// Resolve reads the timestamps of the frame and updates the statistics, it should be called once the
+// frame's fence has signaled. If the results are not available yet they are left to be resolved later.
+func (p *Profiler) Resolve(frame int) error {
+	f := p.frame(frame)
+	if f == nil {
+		return fmt.Errorf("frame %d is out of range of %d frames in flight", frame, len(p.frames))
+	}
+	if f.next == 0 {
+		return nil
+	}
+
+	// Scopes which were never ended have no end timestamp, so each scope is read separately
+	values := make([]uint64, f.next)
+	for _, sc := range f.scopes {
+		if sc.end < 0 {
+			continue
+		}
+		v, ready, err := f.pool.Results(sc.begin, 2)
+		if err != nil {
+			return err
+		}
+		if !ready {
+			return nil
+		}
+		copy(values[sc.begin:], v)
+	}
+
+	p.record(f.scopes, values)
+	f.scopes = f.scopes[:0]
+	f.next = 0
+	return nil
+}
+
+// record updates the statistics and trace events from the timestamps of a frame's scopes
+func (p *Profiler) record(scopes []profilerScope, values []uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	frame := p.resolved
+	p.resolved++
+
+	for _, s := range scopes {
+		if s.end < 0 || s.end >= len(values) {
+			continue
+		}
+		begin := values[s.begin] & p.mask
+		ticks := (values[s.end] - values[s.begin]) & p.mask
+		duration := time.Duration(float64(ticks) * p.period)
+
+		if !p.hasEpoch {
+			p.epoch = begin
+			p.hasEpoch = true
+		}
+
+		samples, ok := p.scopes[s.name]
+		if !ok {
+			samples = &scopeSamples{}
+			p.scopes[s.name] = samples
+			p.names = append(p.names, s.name)
+		}
+		samples.add(duration, p.Window)
+
+		start := float64((begin-p.epoch)&p.mask) * p.period
+		p.events = append(p.events, TraceEvent{
+			Name:      s.name,
+			Phase:     "X",
+			Timestamp: start / 1000,
+			Duration:  float64(duration) / 1000,
+			PID:       1,
+			TID:       1,
+			Args:      map[string]interface{}{"frame": frame, "depth": s.depth},
+		})
+	}
+
+	if p.MaxEvents > 0 && len(p.events) > p.MaxEvents {
+		p.events = append(p.events[:0], p.events[len(p.events)-p.MaxEvents:]...)
+	}
+}
+
+func (s *scopeSamples) add(d time.Duration, window int) {
+	if window <= 0 {
+		window = 1
+	}
+	s.count++
+	if len(s.samples) < window {
+		s.samples = append(s.samples, d)
+		s.next = len(s.samples) % window
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % window
+}
+
+func (s *scopeSamples) stats(name string) ScopeStats {
+	ret := ScopeStats{Name: name, Count: s.count}
+	if len(s.samples) == 0 {
+		return ret
+	}
+	last := s.next - 1
+	if last < 0 {
+		last = len(s.samples) - 1
+	}
+	ret.Last = s.samples[last]
+	ret.Min = s.samples[0]
+	var total time.Duration
+	for _, d := range s.samples {
+		if d < ret.Min {
+			ret.Min = d
+		}
+		if d > ret.Max {
+			ret.Max = d
+		}
+		total += d
+	}
+	ret.Mean = total / time.Duration(len(s.samples))
+	return ret
+}
+
+// Stats returns the statistics of each scope in the order they were first seen
+func (p *Profiler) Stats() []ScopeStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ret := make([]ScopeStats, len(p.names))
+	for i, name := range p.names {
+		ret[i] = p.scopes[name].stats(name)
+	}
+	return ret
+}
+
+// Stat returns the statistics of the named scope
+func (p *Profiler) Stat(name string) (ScopeStats, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	s, ok := p.scopes[name]
+	if !ok {
+		return ScopeStats{}, false
+	}
+	return s.stats(name), true
+}
+
+// WriteChromeTrace writes the resolved scopes in the Chrome trace event format, which can be opened
+// with chrome://tracing or Perfetto
+func (p *Profiler) WriteChromeTrace(w io.Writer) error {
+	p.mutex.Lock()
+	events := append([]TraceEvent(nil), p.events...)
+	p.mutex.Unlock()
+
+	trace := struct {
+		TraceEvents     []TraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{
+		TraceEvents:     events,
+		DisplayTimeUnit: "ns",
+	}
+	if trace.TraceEvents == nil {
+		trace.TraceEvents = []TraceEvent{}
+	}
+	return json.NewEncoder(w).Encode(trace)
+}
+
+// Destroy destroys the query pools of the profiler
+func (p *Profiler) Destroy() {
+	for _, f := range p.frames {
+		f.pool.Destroy()
+	}
+	p.frames = nil
+	p.current = nil
+}
@@ -0,0 +1,88 @@
+package vkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestProfilerRecord(t *testing.T) {
+	p := &Profiler{Window: 2, MaxEvents: 3, period: 2, mask: timestampMask(32), scopes: make(map[string]*scopeSamples)}
+
+	scopes := []profilerScope{
+		{name: "frame", begin: 0, end: 1},
+		{name: "shadows", begin: 2, end: 3, depth: 1},
+		{name: "unended", begin: 4, end: -1},
+	}
+	p.record(scopes, []uint64{100, 600, 150, 250, 0, 0})
+	p.record(scopes[:1], []uint64{1000, 1100})
+	p.record(scopes[:1], []uint64{2000, 2300})
+
+	stats := p.Stats()
+	if len(stats) != 2 || stats[0].Name != "frame" || stats[1].Name != "shadows" {
+		t.Fatalf("unexpected scopes %+v", stats)
+	}
+	frame := stats[0]
+	// The window only holds the last two samples of 200ns and 600ns
+	if frame.Count != 3 || frame.Last != 600*time.Nanosecond || frame.Min != 200*time.Nanosecond || frame.Max != 600*time.Nanosecond || frame.Mean != 400*time.Nanosecond {
+		t.Errorf("unexpected frame stats %+v", frame)
+	}
+	if s, ok := p.Stat("shadows"); !ok || s.Last != 200*time.Nanosecond {
+		t.Errorf("unexpected shadows stats %+v", s)
+	}
+
+	// Timestamps wrap at the valid bits
+	wrapped := &Profiler{Window: 1, period: 1, mask: timestampMask(8), scopes: make(map[string]*scopeSamples)}
+	wrapped.record(scopes[:1], []uint64{250, 4})
+	if s, _ := wrapped.Stat("frame"); s.Last != 10*time.Nanosecond {
+		t.Errorf("expected a wrapped duration of 10ns, got %v", s.Last)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteChromeTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []TraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.TraceEvents) != 3 {
+		t.Fatalf("expected the trace to be limited to 3 events, got %d", len(trace.TraceEvents))
+	}
+	last := trace.TraceEvents[2]
+	if last.Phase != "X" || last.Timestamp != 3.8 || last.Duration != 0.6 {
+		t.Errorf("unexpected trace event %+v", last)
+	}
+}
+
+func TestProfiledRecorder(t *testing.T) {
+	pool := &QueryPool{Count: 4}
+	p := &Profiler{frames: []*profilerFrame{{pool: pool}}, scopes: make(map[string]*scopeSamples)}
+	rec := NewRecorder()
+
+	cmd := &profiledRecorder{CommandRecorder: rec, profiler: p, frame: 0}
+	if err := cmd.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	p.Scope(cmd, "draw", func() {})
+
+	var names []string
+	for _, c := range rec.Commands {
+		names = append(names, c.Name)
+	}
+	want := []string{"Begin", "CmdResetQueryPool", "CmdWriteTimestamp", "CmdWriteTimestamp"}
+	if len(names) != len(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, names)
+		}
+	}
+	if len(p.frames[0].scopes) != 1 || p.frames[0].scopes[0].end != 1 {
+		t.Errorf("unexpected scopes %+v", p.frames[0].scopes)
+	}
+}
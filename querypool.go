@@ -0,0 +1,115 @@
+package vkg
+
+import (
+	"fmt"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// QueryPool is a pool of queries which record information about the execution of commands, such as
+// timestamps, which can be read back once the commands have completed
+type QueryPool struct {
+	Device      *Device
+	VKQueryPool vk.QueryPool
+	Type        vk.QueryType
+	Count       int
+	// Statistics are the statistics counted by a pipeline statistics pool
+	Statistics vk.QueryPipelineStatisticFlags
+	// Precise is true if an occlusion pool counts the exact number of samples passed
+	Precise bool
+
+	// valuesPerQuery is the number of values written by each query
+	valuesPerQuery int
+}
+
+// CreateQueryPool creates a pool of count queries of the specified type
+func (d *Device) CreateQueryPool(queryType vk.QueryType, count int) (*QueryPool, error) {
+	return d.createQueryPool(queryType, count, 0, 1)
+}
+
+func (d *Device) createQueryPool(queryType vk.QueryType, count int, statistics vk.QueryPipelineStatisticFlags, valuesPerQuery int) (*QueryPool, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("a query pool must have at least one query")
+	}
+
+	createInfo := vk.QueryPoolCreateInfo{
+		SType:              vk.StructureTypeQueryPoolCreateInfo,
+		QueryType:          queryType,
+		QueryCount:         uint32(count),
+		PipelineStatistics: statistics,
+	}
+
+	var pool vk.QueryPool
+	err := vk.Error(vk.CreateQueryPool(d.VKDevice, &createInfo, nil, &pool))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret QueryPool
+	ret.Device = d
+	ret.VKQueryPool = pool
+	ret.Type = queryType
+	ret.Count = count
+	ret.Statistics = statistics
+	ret.valuesPerQuery = valuesPerQuery
+	return &ret, nil
+}
+
+// Results reads the results of count queries starting at first, returning false if any of the results are not
+// available yet. Each query writes one value, except for pipeline statistics queries which write one value per
+// statistic.
+func (q *QueryPool) Results(first, count int) ([]uint64, bool, error) {
+	return q.results(first, count, vk.QueryResultFlags(vk.QueryResult64Bit))
+}
+
+// WaitResults is like Results but blocks until all of the results are available
+func (q *QueryPool) WaitResults(first, count int) ([]uint64, error) {
+	values, _, err := q.results(first, count, vk.QueryResultFlags(vk.QueryResult64Bit|vk.QueryResultWaitBit))
+	return values, err
+}
+
+// results reads the values of the queries, with vk.QueryResultWithAvailabilityBit each query's values are
+// followed by a value which is non zero if the query was available
+func (q *QueryPool) results(first, count int, flags vk.QueryResultFlags) ([]uint64, bool, error) {
+	if first < 0 || count <= 0 || first+count > q.Count {
+		return nil, false, fmt.Errorf("queries %d to %d are out of range of the %d queries in the pool", first, first+count, q.Count)
+	}
+
+	perQuery := q.valuesPerQuery
+	if flags&vk.QueryResultFlags(vk.QueryResultWithAvailabilityBit) != 0 {
+		perQuery++
+	}
+	stride := 8 * perQuery
+	values := make([]uint64, count*perQuery)
+
+	res := vk.GetQueryPoolResults(q.Device.VKDevice, q.VKQueryPool, uint32(first), uint32(count),
+		uint(len(values)*8), unsafe.Pointer(&values[0]), vk.DeviceSize(stride), flags)
+	if res == vk.NotReady {
+		return values, false, nil
+	}
+	err := vk.Error(res)
+	if err != nil {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+// Destroy destroys the query pool
+func (q *QueryPool) Destroy() {
+	vk.DestroyQueryPool(q.Device.VKDevice, q.VKQueryPool, nil)
+}
+
+// CmdResetQueryPool resets count queries starting at first, queries must be reset before they are used
+// and this must be recorded outside of a render pass
+func (c *CommandBuffer) CmdResetQueryPool(pool *QueryPool, first, count int) {
+	c.FlushBarriers()
+	vk.CmdResetQueryPool(c.VKCommandBuffer, pool.VKQueryPool, uint32(first), uint32(count))
+}
+
+// CmdWriteTimestamp writes the time at which all previous commands have completed the specified stage
+// into the query
+func (c *CommandBuffer) CmdWriteTimestamp(stage vk.PipelineStageFlagBits, pool *QueryPool, query int) {
+	c.FlushBarriers()
+	vk.CmdWriteTimestamp(c.VKCommandBuffer, stage, pool.VKQueryPool, uint32(query))
+}
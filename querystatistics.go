@@ -0,0 +1,205 @@
+package vkg
+
+import (
+	"fmt"
+	"math/bits"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// PipelineStatistics are the counters written by a pipeline statistics query, only the statistics the
+// pool was created with are set
+type PipelineStatistics struct {
+	InputAssemblyVertices                   uint64
+	InputAssemblyPrimitives                 uint64
+	VertexShaderInvocations                 uint64
+	GeometryShaderInvocations               uint64
+	GeometryShaderPrimitives                uint64
+	ClippingInvocations                     uint64
+	ClippingPrimitives                      uint64
+	FragmentShaderInvocations               uint64
+	TessellationControlShaderPatches        uint64
+	TessellationEvaluationShaderInvocations uint64
+	ComputeShaderInvocations                uint64
+	// Available is false if the query had not completed when the results were read
+	Available bool
+}
+
+// counters returns the counters in the order of their vk.QueryPipelineStatisticFlagBits, which is the
+// order the device writes them in
+func (p *PipelineStatistics) counters() []*uint64 {
+	return []*uint64{
+		&p.InputAssemblyVertices,
+		&p.InputAssemblyPrimitives,
+		&p.VertexShaderInvocations,
+		&p.GeometryShaderInvocations,
+		&p.GeometryShaderPrimitives,
+		&p.ClippingInvocations,
+		&p.ClippingPrimitives,
+		&p.FragmentShaderInvocations,
+		&p.TessellationControlShaderPatches,
+		&p.TessellationEvaluationShaderInvocations,
+		&p.ComputeShaderInvocations,
+	}
+}
+
+// OcclusionResult is the result of an occlusion query
+type OcclusionResult struct {
+	// Samples is the number of samples which passed the depth and stencil tests, unless the pool is
+	// precise it is only guaranteed to be non zero if any samples passed
+	Samples uint64
+	// Available is false if the query had not completed when the results were read
+	Available bool
+}
+
+const geometryStatistics = vk.QueryPipelineStatisticFlags(vk.QueryPipelineStatisticGeometryShaderInvocationsBit |
+	vk.QueryPipelineStatisticGeometryShaderPrimitivesBit)
+
+const tessellationStatistics = vk.QueryPipelineStatisticFlags(vk.QueryPipelineStatisticTessellationControlShaderPatchesBit |
+	vk.QueryPipelineStatisticTessellationEvaluationShaderInvocationsBit)
+
+// checkPipelineStatistics returns an error if the features don't allow the statistics to be queried
+func checkPipelineStatistics(statistics vk.QueryPipelineStatisticFlags, features vk.PhysicalDeviceFeatures) error {
+	if features.PipelineStatisticsQuery != vk.True {
+		return fmt.Errorf("device does not support pipeline statistics queries")
+	}
+	if statistics&geometryStatistics != 0 && features.GeometryShader != vk.True {
+		return fmt.Errorf("geometry shader statistics require geometry shader support")
+	}
+	if statistics&tessellationStatistics != 0 && features.TessellationShader != vk.True {
+		return fmt.Errorf("tessellation statistics require tessellation shader support")
+	}
+	return nil
+}
+
+// checkPreciseOcclusion returns an error if the features don't allow precise occlusion queries
+func checkPreciseOcclusion(features vk.PhysicalDeviceFeatures) error {
+	if features.OcclusionQueryPrecise != vk.True {
+		return fmt.Errorf("device does not support precise occlusion queries")
+	}
+	return nil
+}
+
+// CreatePipelineStatisticsQueryPool creates a pool of count queries which count the specified statistics,
+// queries must be reset with CmdResetQueryPool before each use
+func (d *Device) CreatePipelineStatisticsQueryPool(statistics vk.QueryPipelineStatisticFlags, count int) (*QueryPool, error) {
+	if statistics == 0 {
+		return nil, fmt.Errorf("no pipeline statistics were specified")
+	}
+	err := checkPipelineStatistics(statistics, d.PhysicalDevice.VKPhysicalDeviceFeatures())
+	if err != nil {
+		return nil, err
+	}
+	return d.createQueryPool(vk.QueryTypePipelineStatistics, count, statistics, bits.OnesCount32(uint32(statistics)))
+}
+
+// CreateOcclusionQueryPool creates a pool of count occlusion queries, queries must be reset with
+// CmdResetQueryPool before each use. A precise pool counts the exact number of samples which pass.
+func (d *Device) CreateOcclusionQueryPool(count int, precise bool) (*QueryPool, error) {
+	if precise {
+		err := checkPreciseOcclusion(d.PhysicalDevice.VKPhysicalDeviceFeatures())
+		if err != nil {
+			return nil, err
+		}
+	}
+	pool, err := d.createQueryPool(vk.QueryTypeOcclusion, count, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	pool.Precise = precise
+	return pool, nil
+}
+
+// PipelineStatistics reads the statistics of count queries starting at first without waiting, queries
+// which have not completed are returned with Available set to false
+func (q *QueryPool) PipelineStatistics(first, count int) ([]PipelineStatistics, error) {
+	return q.pipelineStatistics(first, count, 0)
+}
+
+// WaitPipelineStatistics is like PipelineStatistics but blocks until all of the queries have completed,
+// the queries must have been begun and ended or this will never return
+func (q *QueryPool) WaitPipelineStatistics(first, count int) ([]PipelineStatistics, error) {
+	return q.pipelineStatistics(first, count, vk.QueryResultFlags(vk.QueryResultWaitBit))
+}
+
+func (q *QueryPool) pipelineStatistics(first, count int, flags vk.QueryResultFlags) ([]PipelineStatistics, error) {
+	if q.Type != vk.QueryTypePipelineStatistics {
+		return nil, fmt.Errorf("query pool does not hold pipeline statistics queries")
+	}
+	values, _, err := q.results(first, count, flags|vk.QueryResultFlags(vk.QueryResult64Bit|vk.QueryResultWithAvailabilityBit))
+	if err != nil {
+		return nil, err
+	}
+	return decodePipelineStatistics(q.Statistics, values, count), nil
+}
+
+// decodePipelineStatistics splits the values of count queries, each followed by its availability, into
+// the statistics which were counted
+func decodePipelineStatistics(statistics vk.QueryPipelineStatisticFlags, values []uint64, count int) []PipelineStatistics {
+	ret := make([]PipelineStatistics, count)
+	stride := len(values) / count
+	for i := range ret {
+		v := values[i*stride : (i+1)*stride]
+		n := 0
+		for bit, counter := range ret[i].counters() {
+			if statistics&(1<<uint(bit)) == 0 {
+				continue
+			}
+			*counter = v[n]
+			n++
+		}
+		ret[i].Available = v[stride-1] != 0
+	}
+	return ret
+}
+
+// OcclusionResults reads the results of count occlusion queries starting at first without waiting,
+// queries which have not completed are returned with Available set to false
+func (q *QueryPool) OcclusionResults(first, count int) ([]OcclusionResult, error) {
+	return q.occlusionResults(first, count, 0)
+}
+
+// WaitOcclusionResults is like OcclusionResults but blocks until all of the queries have completed,
+// the queries must have been begun and ended or this will never return
+func (q *QueryPool) WaitOcclusionResults(first, count int) ([]OcclusionResult, error) {
+	return q.occlusionResults(first, count, vk.QueryResultFlags(vk.QueryResultWaitBit))
+}
+
+func (q *QueryPool) occlusionResults(first, count int, flags vk.QueryResultFlags) ([]OcclusionResult, error) {
+	if q.Type != vk.QueryTypeOcclusion {
+		return nil, fmt.Errorf("query pool does not hold occlusion queries")
+	}
+	values, _, err := q.results(first, count, flags|vk.QueryResultFlags(vk.QueryResult64Bit|vk.QueryResultWithAvailabilityBit))
+	if err != nil {
+		return nil, err
+	}
+	return decodeOcclusionResults(values, count), nil
+}
+
+// decodeOcclusionResults splits the values of count queries, each followed by its availability
+func decodeOcclusionResults(values []uint64, count int) []OcclusionResult {
+	ret := make([]OcclusionResult, count)
+	for i := range ret {
+		ret[i].Samples = values[i*2]
+		ret[i].Available = values[i*2+1] != 0
+	}
+	return ret
+}
+
+// CmdBeginQuery begins a pipeline statistics or occlusion query, the query must have been reset since it
+// was last used. Occlusion queries from a precise pool count the exact number of samples.
+func (c *CommandBuffer) CmdBeginQuery(pool *QueryPool, query int) {
+	c.FlushBarriers()
+	var flags vk.QueryControlFlags
+	if pool.Precise {
+		flags = vk.QueryControlFlags(vk.QueryControlPreciseBit)
+	}
+	vk.CmdBeginQuery(c.VKCommandBuffer, pool.VKQueryPool, uint32(query), flags)
+}
+
+// CmdEndQuery ends a query begun with CmdBeginQuery, it must be recorded in the same subpass or outside of
+// a render pass if the query was begun outside of one
+func (c *CommandBuffer) CmdEndQuery(pool *QueryPool, query int) {
+	c.FlushBarriers()
+	vk.CmdEndQuery(c.VKCommandBuffer, pool.VKQueryPool, uint32(query))
+}
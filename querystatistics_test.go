@@ -0,0 +1,67 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestDecodePipelineStatistics(t *testing.T) {
+	statistics := vk.QueryPipelineStatisticFlags(vk.QueryPipelineStatisticVertexShaderInvocationsBit |
+		vk.QueryPipelineStatisticFragmentShaderInvocationsBit |
+		vk.QueryPipelineStatisticComputeShaderInvocationsBit)
+
+	// Three statistics and the availability for each of two queries
+	values := []uint64{3, 10, 0, 1, 4, 20, 7, 0}
+	stats := decodePipelineStatistics(statistics, values, 2)
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(stats))
+	}
+	want := PipelineStatistics{VertexShaderInvocations: 3, FragmentShaderInvocations: 10, Available: true}
+	if stats[0] != want {
+		t.Errorf("unexpected first result %+v", stats[0])
+	}
+	want = PipelineStatistics{VertexShaderInvocations: 4, FragmentShaderInvocations: 20, ComputeShaderInvocations: 7}
+	if stats[1] != want {
+		t.Errorf("unexpected second result %+v", stats[1])
+	}
+}
+
+func TestDecodeOcclusionResults(t *testing.T) {
+	results := decodeOcclusionResults([]uint64{12, 1, 0, 0}, 2)
+	if results[0] != (OcclusionResult{Samples: 12, Available: true}) || results[1] != (OcclusionResult{}) {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
+
+func TestQueryFeatureChecks(t *testing.T) {
+	vertex := vk.QueryPipelineStatisticFlags(vk.QueryPipelineStatisticVertexShaderInvocationsBit)
+	geometry := vk.QueryPipelineStatisticFlags(vk.QueryPipelineStatisticGeometryShaderInvocationsBit)
+	tessellation := vk.QueryPipelineStatisticFlags(vk.QueryPipelineStatisticTessellationControlShaderPatchesBit)
+
+	if checkPipelineStatistics(vertex, vk.PhysicalDeviceFeatures{}) == nil {
+		t.Errorf("expected an error without pipeline statistics support")
+	}
+	features := vk.PhysicalDeviceFeatures{PipelineStatisticsQuery: vk.True}
+	if err := checkPipelineStatistics(vertex, features); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if checkPipelineStatistics(geometry, features) == nil {
+		t.Errorf("expected an error without geometry shader support")
+	}
+	if checkPipelineStatistics(tessellation, features) == nil {
+		t.Errorf("expected an error without tessellation shader support")
+	}
+	features.GeometryShader = vk.True
+	features.TessellationShader = vk.True
+	if err := checkPipelineStatistics(geometry|tessellation, features); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	if checkPreciseOcclusion(vk.PhysicalDeviceFeatures{}) == nil {
+		t.Errorf("expected an error without precise occlusion support")
+	}
+	if err := checkPreciseOcclusion(vk.PhysicalDeviceFeatures{OcclusionQueryPrecise: vk.True}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
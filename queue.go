@@ -17,18 +17,7 @@ func (q *Queue) WaitIdle() error {
 }
 
 func (q *Queue) SubmitWaitIdle(buffers ...*CommandBuffer) error {
-	var submitInfo = vk.SubmitInfo{}
-	submitInfo.SType = vk.StructureTypeSubmitInfo
-	submitInfo.CommandBufferCount = uint32(len(buffers)) // submit a single command buffer
-
-	b := make([]vk.CommandBuffer, len(buffers))
-	for i, _ := range buffers {
-		b[i] = buffers[i].VKCommandBuffer
-	}
-
-	submitInfo.PCommandBuffers = b // the command buffer to submit.
-
-	err := vk.Error(vk.QueueSubmit(q.VKQueue, 1, []vk.SubmitInfo{submitInfo}, nil))
+	err := q.NewSubmit().Buffers(buffers...).Submit()
 	if err != nil {
 		return err
 	}
@@ -40,26 +29,34 @@ func (q *Queue) SubmitWaitIdle(buffers ...*CommandBuffer) error {
 }
 
 func (q *Queue) SubmitWithFence(fence *Fence, buffers ...*CommandBuffer) error {
-	var submitInfo = vk.SubmitInfo{}
-	submitInfo.SType = vk.StructureTypeSubmitInfo
-	submitInfo.CommandBufferCount = uint32(len(buffers)) // submit a single command buffer
-
-	b := make([]vk.CommandBuffer, len(buffers))
-	for i, _ := range buffers {
-		b[i] = buffers[i].VKCommandBuffer
-	}
-
-	submitInfo.PCommandBuffers = b // the command buffer to submit.
+	return q.NewSubmit().Buffers(buffers...).Fence(fence).Submit()
+}
 
-	err := vk.Error(vk.QueueSubmit(q.VKQueue, 1, []vk.SubmitInfo{submitInfo}, fence.VKFence))
-	if err != nil {
-		return err
-	}
+func (q *Queue) String() string {
+	return fmt.Sprintf("{Device: %s QueueFamily: %s}", q.Device.String(), q.QueueFamily.String())
+}
 
-	return nil
+// SemaphoreWait is a semaphore which a submission waits on before executing the stages in StageMask,
+// Value is only used for timeline semaphores
+type SemaphoreWait struct {
+	Semaphore vk.Semaphore
+	Value     uint64
+	StageMask vk.PipelineStageFlags
+}
 
+// SemaphoreSignal is a semaphore which is signaled once a submission completes, Value is only used
+// for timeline semaphores
+type SemaphoreSignal struct {
+	Semaphore vk.Semaphore
+	Value     uint64
 }
 
-func (q *Queue) String() string {
-	return fmt.Sprintf("{Device: %s QueueFamily: %s}", q.Device.String(), q.QueueFamily.String())
+// SubmitWithSemaphores submits the command buffers once the waits have been signaled, the signals
+// are signaled and the optional fence is signaled when the command buffers have completed. Binary and
+// timeline semaphores can be mixed, timeline values require the device to have timeline semaphores
+// enabled. See NewSubmit for submitting more than one batch.
+func (q *Queue) SubmitWithSemaphores(waits []SemaphoreWait, signals []SemaphoreSignal, fence *Fence, buffers ...*CommandBuffer) error {
+	s := q.NewSubmit().Fence(fence)
+	s.Batches = []*SubmitBatch{{Waits: waits, Buffers: buffers, Signals: signals}}
+	return s.Submit()
 }
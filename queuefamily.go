@@ -48,6 +48,32 @@ func (ql QueueFamilySlice) FilterTransfer() QueueFamilySlice {
 	})
 }
 
+// FilterDedicatedCompute returns the compute families which do not support graphics, queues from these
+// families can run compute work at the same time as rendering
+func (ql QueueFamilySlice) FilterDedicatedCompute() QueueFamilySlice {
+	return ql.Filter(func(q *QueueFamily) bool {
+		return q.IsCompute() && !q.IsGraphics()
+	})
+}
+
+// FilterDedicatedTransfer returns the transfer families which support neither graphics nor compute, these
+// are typically backed by DMA engines which copy data while the rest of the device is busy
+func (ql QueueFamilySlice) FilterDedicatedTransfer() QueueFamilySlice {
+	return ql.Filter(func(q *QueueFamily) bool {
+		return q.IsTransfer() && !q.IsGraphics() && !q.IsCompute()
+	})
+}
+
+// Contains returns true if a family with the same index is in the slice
+func (ql QueueFamilySlice) Contains(family *QueueFamily) bool {
+	for _, q := range ql {
+		if q.Index == family.Index {
+			return true
+		}
+	}
+	return false
+}
+
 type QueueFamily struct {
 	Index                   int
 	PhysicalDevice          *PhysicalDevice
@@ -67,6 +93,30 @@ func (q *QueueFamily) IsTransfer() bool {
 	return q.VKQueueFamilyProperties.QueueFlags&vk.QueueFlags(vk.QueueTransferBit) == vk.QueueFlags(vk.QueueTransferBit)
 }
 
+// graphicsStages are the pipeline stages only queues with graphics support can execute
+const graphicsStages = vk.PipelineStageVertexInputBit | vk.PipelineStageVertexShaderBit |
+	vk.PipelineStageTessellationControlShaderBit | vk.PipelineStageTessellationEvaluationShaderBit |
+	vk.PipelineStageGeometryShaderBit | vk.PipelineStageFragmentShaderBit | vk.PipelineStageEarlyFragmentTestsBit |
+	vk.PipelineStageLateFragmentTestsBit | vk.PipelineStageColorAttachmentOutputBit | vk.PipelineStageAllGraphicsBit
+
+// SupportedStages removes the stages which queues of the family can't execute, as barriers recorded on a
+// compute or transfer queue must not use graphics stages. A nil family supports every stage.
+func (q *QueueFamily) SupportedStages(stages vk.PipelineStageFlags) vk.PipelineStageFlags {
+	if q == nil {
+		return stages
+	}
+	if !q.IsGraphics() {
+		stages &^= vk.PipelineStageFlags(graphicsStages)
+	}
+	if !q.IsCompute() {
+		stages &^= vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit)
+		if !q.IsGraphics() {
+			stages &^= vk.PipelineStageFlags(vk.PipelineStageDrawIndirectBit)
+		}
+	}
+	return stages
+}
+
 func (q *QueueFamily) SupportsPresent(surface vk.Surface) bool {
 	var supportsPresent vk.Bool32
 	vk.GetPhysicalDeviceSurfaceSupport(q.PhysicalDevice.VKPhysicalDevice, uint32(q.Index), surface, &supportsPresent)
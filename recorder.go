@@ -0,0 +1,579 @@
+package vkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+var _ CommandRecorder = (*CommandBuffer)(nil)
+var _ CommandRecorder = (*Recorder)(nil)
+
+// RecordedArg is a single named argument of a recorded command
+type RecordedArg struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// RecordedCommand is a single command captured by a Recorder
+type RecordedCommand struct {
+	Name string        `json:"name"`
+	Args []RecordedArg `json:"args,omitempty"`
+}
+
+func (c RecordedCommand) String() string {
+	var sb strings.Builder
+	sb.WriteString(c.Name)
+	for _, a := range c.Args {
+		fmt.Fprintf(&sb, " %s=%+v", a.Name, a.Value)
+	}
+	return sb.String()
+}
+
+// Recorder is a CommandRecorder which captures commands in memory instead of sending them to a
+// device. Objects such as buffers, images and pipelines are recorded by name rather than by handle,
+// objects which have not been named with Name are given a name based on the order they are first seen.
+// Raw vulkan handles are named by value, so handles which have not been created on a device will
+// share a name.
+type Recorder struct {
+	Commands []RecordedCommand
+	// QueueFamily used to track the ownership of resources passed to Require
+	QueueFamily *QueueFamily
+
+	names    map[interface{}]string
+	counts   map[string]int
+	barriers barrierBatch
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		Commands: make([]RecordedCommand, 0),
+		names:    make(map[interface{}]string),
+		counts:   make(map[string]int),
+	}
+}
+
+// Name sets the name used when recording the specified object, which may be a package object
+// such as *BufferResource or *Image or a vulkan handle such as vk.Pipeline
+func (r *Recorder) Name(object interface{}, name string) {
+	if r.names == nil {
+		r.names = make(map[interface{}]string)
+	}
+	r.names[object] = name
+}
+
+func (r *Recorder) ref(kind string, object interface{}) string {
+	if r.names == nil {
+		r.names = make(map[interface{}]string)
+	}
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	if name, ok := r.names[object]; ok {
+		return name
+	}
+	r.counts[kind]++
+	name := fmt.Sprintf("%s#%d", kind, r.counts[kind])
+	r.names[object] = name
+	return name
+}
+
+// record appends a command, args are alternating names and values
+func (r *Recorder) record(name string, args ...interface{}) {
+	r.FlushBarriers()
+	cmd := RecordedCommand{Name: name}
+	for i := 0; i+1 < len(args); i += 2 {
+		cmd.Args = append(cmd.Args, RecordedArg{Name: args[i].(string), Value: args[i+1]})
+	}
+	r.Commands = append(r.Commands, cmd)
+}
+
+// Text returns the recorded commands, one per line
+func (r *Recorder) Text() string {
+	var sb strings.Builder
+	for _, c := range r.Commands {
+		sb.WriteString(c.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (r *Recorder) String() string {
+	return r.Text()
+}
+
+// JSON returns the recorded commands as indented JSON
+func (r *Recorder) JSON() ([]byte, error) {
+	return json.MarshalIndent(r.Commands, "", "  ")
+}
+
+// Diff returns the differences between the expected recording and this one, lines only in the
+// expected recording are prefixed with '-' and lines only in this recording with '+'. An empty
+// string is returned if the recordings are identical.
+func (r *Recorder) Diff(expected *Recorder) string {
+	return DiffRecordings(expected.Text(), r.Text())
+}
+
+// DiffRecordings returns a line based diff of two recordings in the format returned by Recorder.Text,
+// which allows recordings to be compared against golden files
+func DiffRecordings(expected, actual string) string {
+	a := strings.Split(strings.TrimRight(expected, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(actual, "\n"), "\n")
+
+	// Longest common subsequence of lines
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	changed := false
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&sb, "  %s\n", a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&sb, "+ %s\n", b[j])
+			changed = true
+			j++
+		default:
+			fmt.Fprintf(&sb, "- %s\n", a[i])
+			changed = true
+			i++
+		}
+	}
+
+	if !changed {
+		return ""
+	}
+	return sb.String()
+}
+
+func recordedClearValues(clearValues []vk.ClearValue) []string {
+	values := make([]string, len(clearValues))
+	for i := range clearValues {
+		// Color and depth/stencil clear values share the same storage, the first float is the depth
+		values[i] = fmt.Sprint(*(*[4]float32)(unsafe.Pointer(&clearValues[i])))
+	}
+	return values
+}
+
+type recordedImageBarrier struct {
+	Image            string
+	OldLayout        vk.ImageLayout
+	NewLayout        vk.ImageLayout
+	SrcAccessMask    vk.AccessFlags
+	DstAccessMask    vk.AccessFlags
+	SrcQueueFamily   uint32
+	DstQueueFamily   uint32
+	SubresourceRange vk.ImageSubresourceRange
+}
+
+type recordedBufferBarrier struct {
+	Buffer         string
+	SrcAccessMask  vk.AccessFlags
+	DstAccessMask  vk.AccessFlags
+	SrcQueueFamily uint32
+	DstQueueFamily uint32
+}
+
+// Begin starts a new recording
+func (r *Recorder) Begin() error {
+	r.record("Begin")
+	return nil
+}
+
+// BeginOneTime starts a new recording
+func (r *Recorder) BeginOneTime() error {
+	r.record("BeginOneTime")
+	return nil
+}
+
+// End finishes the recording
+func (r *Recorder) End() error {
+	r.record("End")
+	return nil
+}
+
+// Reset discards all recorded commands, names assigned to objects are kept
+func (r *Recorder) Reset() error {
+	r.barriers.reset()
+	r.Commands = make([]RecordedCommand, 0)
+	return nil
+}
+
+func (r *Recorder) CmdBeginRenderPass(renderPass vk.RenderPass, framebuffer vk.Framebuffer, extent vk.Extent2D, contents vk.SubpassContents, clearValues ...vk.ClearValue) {
+	r.CmdBeginRenderPassWithArea(renderPass, framebuffer, vk.Rect2D{Extent: extent}, contents, clearValues...)
+}
+
+func (r *Recorder) CmdBeginRenderPassWithArea(renderPass vk.RenderPass, framebuffer vk.Framebuffer, area vk.Rect2D, contents vk.SubpassContents, clearValues ...vk.ClearValue) {
+	r.record("CmdBeginRenderPass",
+		"renderPass", r.ref("renderPass", renderPass),
+		"framebuffer", r.ref("framebuffer", framebuffer),
+		"area", area,
+		"contents", contents,
+		"clearValues", recordedClearValues(clearValues))
+}
+
+func (r *Recorder) CmdBeginRenderTarget(target *RenderTarget, contents vk.SubpassContents, clearValues ...vk.ClearValue) {
+	r.record("CmdBeginRenderTarget",
+		"target", r.ref("renderTarget", target),
+		"extent", target.Extent(),
+		"contents", contents,
+		"clearValues", recordedClearValues(clearValues))
+}
+
+func (r *Recorder) CmdNextSubpass(contents vk.SubpassContents) {
+	r.record("CmdNextSubpass", "contents", contents)
+}
+
+func (r *Recorder) CmdEndRenderPass() {
+	r.record("CmdEndRenderPass")
+}
+
+func (r *Recorder) CmdExecuteCommands(buffers ...*CommandBuffer) {
+	names := make([]string, len(buffers))
+	for i, b := range buffers {
+		names[i] = r.ref("commandBuffer", b)
+	}
+	r.record("CmdExecuteCommands", "buffers", names)
+}
+
+func (r *Recorder) CmdBindPipeline(bindPoint vk.PipelineBindPoint, pipeline vk.Pipeline) {
+	r.record("CmdBindPipeline", "bindPoint", bindPoint, "pipeline", r.ref("pipeline", pipeline))
+}
+
+func (r *Recorder) CmdBindGraphicsPipeline(pipeline vk.Pipeline) {
+	r.CmdBindPipeline(vk.PipelineBindPointGraphics, pipeline)
+}
+
+func (r *Recorder) CmdBindComputePipeline(p *ComputePipeline) {
+	r.record("CmdBindPipeline", "bindPoint", vk.PipelineBindPointCompute, "pipeline", r.ref("computePipeline", p))
+}
+
+func (r *Recorder) CmdBindDescriptorSets(bindPoint vk.PipelineBindPoint, layout *PipelineLayout, firstSet int, descriptorSets ...*DescriptorSet) {
+	names := make([]string, len(descriptorSets))
+	for i, d := range descriptorSets {
+		names[i] = r.ref("descriptorSet", d)
+	}
+	r.record("CmdBindDescriptorSets",
+		"bindPoint", bindPoint,
+		"layout", r.ref("pipelineLayout", layout),
+		"firstSet", firstSet,
+		"descriptorSets", names)
+}
+
+func (r *Recorder) CmdBindVertexBuffers(firstBinding int, buffers ...*BufferResource) {
+	r.CmdBindVertexBuffersWithOffsets(firstBinding, buffers, nil)
+}
+
+func (r *Recorder) CmdBindVertexBuffersWithOffsets(firstBinding int, buffers []*BufferResource, offsets []uint64) {
+	names := make([]string, len(buffers))
+	recordedOffsets := make([]uint64, len(buffers))
+	for i, b := range buffers {
+		names[i] = r.ref("buffer", b)
+		if i < len(offsets) {
+			recordedOffsets[i] = offsets[i]
+		}
+	}
+	r.record("CmdBindVertexBuffers", "firstBinding", firstBinding, "buffers", names, "offsets", recordedOffsets)
+}
+
+func (r *Recorder) CmdBindIndexBuffer(buffer *BufferResource, indices IndexSourcer) {
+	r.CmdBindIndexBufferWithType(buffer, 0, indices.IndexType())
+}
+
+func (r *Recorder) CmdBindIndexBufferWithType(buffer *BufferResource, offset uint64, indexType vk.IndexType) {
+	r.record("CmdBindIndexBuffer", "buffer", r.ref("buffer", buffer), "offset", offset, "indexType", indexType)
+}
+
+func (r *Recorder) CmdPushConstants(layout *PipelineLayout, stages vk.ShaderStageFlags, offset int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	r.record("CmdPushConstants",
+		"layout", r.ref("pipelineLayout", layout),
+		"stages", stages,
+		"offset", offset,
+		"data", fmt.Sprintf("%x", data))
+}
+
+func (r *Recorder) CmdDraw(vertexCount, instanceCount, firstVertex, firstInstance int) {
+	r.record("CmdDraw",
+		"vertexCount", vertexCount,
+		"instanceCount", instanceCount,
+		"firstVertex", firstVertex,
+		"firstInstance", firstInstance)
+}
+
+func (r *Recorder) CmdDrawIndexed(indexCount, instanceCount, firstIndex, vertexOffset, firstInstance int) {
+	r.record("CmdDrawIndexed",
+		"indexCount", indexCount,
+		"instanceCount", instanceCount,
+		"firstIndex", firstIndex,
+		"vertexOffset", vertexOffset,
+		"firstInstance", firstInstance)
+}
+
+func (r *Recorder) CmdDrawIndirect(buffer *BufferResource, offset uint64, drawCount, stride int) {
+	r.record("CmdDrawIndirect", "buffer", r.ref("buffer", buffer), "offset", offset, "drawCount", drawCount, "stride", stride)
+}
+
+func (r *Recorder) CmdDrawIndexedIndirect(buffer *BufferResource, offset uint64, drawCount, stride int) {
+	r.record("CmdDrawIndexedIndirect", "buffer", r.ref("buffer", buffer), "offset", offset, "drawCount", drawCount, "stride", stride)
+}
+
+func (r *Recorder) CmdDrawIndirectCount(buffer *BufferResource, offset uint64, countBuffer *BufferResource, countOffset uint64, maxDrawCount, stride int) error {
+	if err := validateDrawIndirectCount(buffer); err != nil {
+		return err
+	}
+	r.record("CmdDrawIndirectCount", "buffer", r.ref("buffer", buffer), "offset", offset,
+		"countBuffer", r.ref("buffer", countBuffer), "countOffset", countOffset, "maxDrawCount", maxDrawCount, "stride", stride)
+	return nil
+}
+
+func (r *Recorder) CmdDrawIndexedIndirectCount(buffer *BufferResource, offset uint64, countBuffer *BufferResource, countOffset uint64, maxDrawCount, stride int) error {
+	if err := validateDrawIndirectCount(buffer); err != nil {
+		return err
+	}
+	r.record("CmdDrawIndexedIndirectCount", "buffer", r.ref("buffer", buffer), "offset", offset,
+		"countBuffer", r.ref("buffer", countBuffer), "countOffset", countOffset, "maxDrawCount", maxDrawCount, "stride", stride)
+	return nil
+}
+
+func (r *Recorder) CmdDispatch(x, y, z int) {
+	r.record("CmdDispatch", "x", x, "y", y, "z", z)
+}
+
+func (r *Recorder) CmdDispatchIndirect(buffer *BufferResource, offset uint64) {
+	r.record("CmdDispatchIndirect", "buffer", r.ref("buffer", buffer), "offset", offset)
+}
+
+func (r *Recorder) CmdSetViewport(viewports ...vk.Viewport) {
+	r.record("CmdSetViewport", "viewports", viewports)
+}
+
+func (r *Recorder) CmdSetScissor(scissors ...vk.Rect2D) {
+	r.record("CmdSetScissor", "scissors", scissors)
+}
+
+func (r *Recorder) CmdFillBuffer(buffer *BufferResource, offset, size uint64, data uint32) error {
+	if err := validateFillBuffer(buffer, offset, size); err != nil {
+		return err
+	}
+	r.record("CmdFillBuffer", "buffer", r.ref("buffer", buffer), "offset", offset, "size", size, "data", data)
+	return nil
+}
+
+func (r *Recorder) CmdUpdateBuffer(buffer *BufferResource, offset uint64, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := validateUpdateBuffer(buffer, offset, data); err != nil {
+		return err
+	}
+	r.record("CmdUpdateBuffer", "buffer", r.ref("buffer", buffer), "offset", offset, "data", fmt.Sprintf("%x", data))
+	return nil
+}
+
+func (r *Recorder) CmdPipelineBarrier(srcStage, dstStage vk.PipelineStageFlags, dependencyFlags vk.DependencyFlags, memoryBarriers []vk.MemoryBarrier, bufferBarriers []vk.BufferMemoryBarrier, imageBarriers []vk.ImageMemoryBarrier) {
+	images := make([]recordedImageBarrier, len(imageBarriers))
+	for i, b := range imageBarriers {
+		images[i] = recordedImageBarrier{
+			Image:            r.ref("vkImage", b.Image),
+			OldLayout:        b.OldLayout,
+			NewLayout:        b.NewLayout,
+			SrcAccessMask:    b.SrcAccessMask,
+			DstAccessMask:    b.DstAccessMask,
+			SrcQueueFamily:   b.SrcQueueFamilyIndex,
+			DstQueueFamily:   b.DstQueueFamilyIndex,
+			SubresourceRange: b.SubresourceRange,
+		}
+	}
+	r.record("CmdPipelineBarrier",
+		"srcStage", srcStage,
+		"dstStage", dstStage,
+		"dependencyFlags", dependencyFlags,
+		"memoryBarriers", len(memoryBarriers),
+		"bufferBarriers", len(bufferBarriers),
+		"imageBarriers", images)
+}
+
+// TransitionImageLayouts records the transitions as a single command, images are named by their *Image
+func (r *Recorder) TransitionImageLayouts(transitions ...*ImageLayoutTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	var srcStage, dstStage vk.PipelineStageFlags
+	images := make([]recordedImageBarrier, len(transitions))
+	for i, t := range transitions {
+		barrier, s, d, err := t.imageMemoryBarrier(r.QueueFamily)
+		if err != nil {
+			return err
+		}
+		srcStage |= s
+		dstStage |= d
+		images[i] = recordedImageBarrier{
+			Image:            r.ref("image", t.Image),
+			OldLayout:        barrier.OldLayout,
+			NewLayout:        barrier.NewLayout,
+			SrcAccessMask:    barrier.SrcAccessMask,
+			DstAccessMask:    barrier.DstAccessMask,
+			SrcQueueFamily:   barrier.SrcQueueFamilyIndex,
+			DstQueueFamily:   barrier.DstQueueFamilyIndex,
+			SubresourceRange: barrier.SubresourceRange,
+		}
+	}
+
+	r.record("TransitionImageLayouts", "srcStage", srcStage, "dstStage", dstStage, "imageBarriers", images)
+	return nil
+}
+
+// Require records the barrier needed before the resource is used, tracking its state as CommandBuffer.Require does
+func (r *Recorder) Require(resource TrackedResource, usage ResourceUsage) error {
+	return r.RequireWithStages(resource, usage, 0)
+}
+
+func (r *Recorder) RequireWithStages(resource TrackedResource, usage ResourceUsage, stages vk.PipelineStageFlags) error {
+	return r.barriers.require(r.QueueFamily, resource, usage, stages, r.FlushBarriers)
+}
+
+func (r *Recorder) Release(resource TrackedResource, dst *QueueFamily, usage ResourceUsage) error {
+	return r.barriers.release(r.QueueFamily, resource, dst, usage, r.FlushBarriers)
+}
+
+// FlushBarriers records the batched barriers as a single CmdPipelineBarrier, images and buffers are
+// named by their resource
+func (r *Recorder) FlushBarriers() {
+	if r.barriers.empty() {
+		return
+	}
+	b := r.barriers
+	r.barriers.reset()
+
+	images := make([]recordedImageBarrier, len(b.images))
+	for i, ib := range b.images {
+		images[i] = recordedImageBarrier{
+			Image:            r.ref("image", &b.imageResources[i].Image),
+			OldLayout:        ib.OldLayout,
+			NewLayout:        ib.NewLayout,
+			SrcAccessMask:    ib.SrcAccessMask,
+			DstAccessMask:    ib.DstAccessMask,
+			SrcQueueFamily:   ib.SrcQueueFamilyIndex,
+			DstQueueFamily:   ib.DstQueueFamilyIndex,
+			SubresourceRange: ib.SubresourceRange,
+		}
+	}
+	buffers := make([]recordedBufferBarrier, len(b.buffers))
+	for i, bb := range b.buffers {
+		buffers[i] = recordedBufferBarrier{
+			Buffer:         r.ref("buffer", b.bufferResources[i]),
+			SrcAccessMask:  bb.SrcAccessMask,
+			DstAccessMask:  bb.DstAccessMask,
+			SrcQueueFamily: bb.SrcQueueFamilyIndex,
+			DstQueueFamily: bb.DstQueueFamilyIndex,
+		}
+	}
+
+	r.record("CmdPipelineBarrier",
+		"srcStage", b.srcStages,
+		"dstStage", b.dstStages,
+		"bufferBarriers", buffers,
+		"imageBarriers", images)
+}
+
+func (r *Recorder) CmdResetQueryPool(pool *QueryPool, first, count int) {
+	r.record("CmdResetQueryPool", "pool", r.ref("queryPool", pool), "first", first, "count", count)
+}
+
+func (r *Recorder) CmdWriteTimestamp(stage vk.PipelineStageFlagBits, pool *QueryPool, query int) {
+	r.record("CmdWriteTimestamp", "stage", stage, "pool", r.ref("queryPool", pool), "query", query)
+}
+
+func (r *Recorder) CmdBeginQuery(pool *QueryPool, query int) {
+	r.record("CmdBeginQuery", "pool", r.ref("queryPool", pool), "query", query, "precise", pool.Precise)
+}
+
+func (r *Recorder) CmdEndQuery(pool *QueryPool, query int) {
+	r.record("CmdEndQuery", "pool", r.ref("queryPool", pool), "query", query)
+}
+
+func (r *Recorder) CmdCopyImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, regions ...ImageCopyRegion) error {
+	copies, err := imageCopyCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+	r.record("CmdCopyImage",
+		"src", r.ref("image", &src.Image), "srcLayout", srcLayout,
+		"dst", r.ref("image", &dst.Image), "dstLayout", dstLayout,
+		"regions", copies)
+	return nil
+}
+
+func (r *Recorder) CmdBlitImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, filter vk.Filter, regions ...ImageCopyRegion) error {
+	blits, err := imageBlitCommand(src, dst, filter, regions)
+	if err != nil {
+		return err
+	}
+	r.record("CmdBlitImage",
+		"src", r.ref("image", &src.Image), "srcLayout", srcLayout,
+		"dst", r.ref("image", &dst.Image), "dstLayout", dstLayout,
+		"filter", filter,
+		"regions", blits)
+	return nil
+}
+
+func (r *Recorder) CmdResolveImage(src *ImageResource, srcLayout vk.ImageLayout, dst *ImageResource, dstLayout vk.ImageLayout, regions ...ImageCopyRegion) error {
+	resolves, err := imageResolveCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+	r.record("CmdResolveImage",
+		"src", r.ref("image", &src.Image), "srcLayout", srcLayout,
+		"dst", r.ref("image", &dst.Image), "dstLayout", dstLayout,
+		"regions", resolves)
+	return nil
+}
+
+func (r *Recorder) CmdCopyBufferToImage(src *BufferResource, dst *ImageResource, dstLayout vk.ImageLayout, regions ...BufferImageRegion) error {
+	copies, err := bufferToImageCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+	r.record("CmdCopyBufferToImage",
+		"src", r.ref("buffer", src),
+		"dst", r.ref("image", &dst.Image), "dstLayout", dstLayout,
+		"regions", copies)
+	return nil
+}
+
+func (r *Recorder) CmdCopyImageToBuffer(src *ImageResource, srcLayout vk.ImageLayout, dst *BufferResource, regions ...BufferImageRegion) error {
+	copies, err := imageToBufferCommand(src, dst, regions)
+	if err != nil {
+		return err
+	}
+	r.record("CmdCopyImageToBuffer",
+		"src", r.ref("image", &src.Image), "srcLayout", srcLayout,
+		"dst", r.ref("buffer", dst),
+		"regions", copies)
+	return nil
+}
@@ -0,0 +1,62 @@
+package vkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	vertices := &BufferResource{}
+	r := NewRecorder()
+	r.Name(vertices, "vertices")
+
+	r.Begin()
+	r.CmdBindVertexBuffers(0, vertices, &BufferResource{})
+	r.CmdDraw(3, 1, 0, 0)
+	r.End()
+
+	expected := "Begin\n" +
+		"CmdBindVertexBuffers firstBinding=0 buffers=[vertices buffer#1] offsets=[0 0]\n" +
+		"CmdDraw vertexCount=3 instanceCount=1 firstVertex=0 firstInstance=0\n" +
+		"End\n"
+	if diff := DiffRecordings(expected, r.Text()); diff != "" {
+		t.Errorf("unexpected recording\n%s", diff)
+	}
+
+	other := NewRecorder()
+	other.Begin()
+	other.CmdDraw(3, 2, 0, 0)
+	other.End()
+
+	diff := other.Diff(r)
+	if !strings.Contains(diff, "- CmdDraw vertexCount=3 instanceCount=1") || !strings.Contains(diff, "+ CmdDraw vertexCount=3 instanceCount=2") {
+		t.Errorf("unexpected diff\n%s", diff)
+	}
+
+	if _, err := r.JSON(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGraphicsAppRecordFrame(t *testing.T) {
+	app := &GraphicsApp{}
+	if _, err := app.RecordFrame(0); err == nil {
+		t.Errorf("expected an error without MakeCommandBuffer")
+	}
+
+	app.MakeCommandBuffer = func(command CommandRecorder, frame int) {
+		command.Begin()
+		command.CmdDraw(3, frame+1, 0, 0)
+		command.End()
+	}
+	r, err := app.RecordFrame(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Begin\n" +
+		"CmdDraw vertexCount=3 instanceCount=2 firstVertex=0 firstInstance=0\n" +
+		"End\n"
+	if diff := DiffRecordings(expected, r.Text()); diff != "" {
+		t.Errorf("unexpected recording\n%s", diff)
+	}
+}
@@ -0,0 +1,341 @@
+package vkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// ShaderStageReflection is the reflection of an entry point of a shader module used as a pipeline stage
+type ShaderStageReflection struct {
+	Reflection *ShaderReflection
+	EntryPoint string
+	Stage      vk.ShaderStageFlagBits
+}
+
+// PipelineReflection is the combined interface of the shader stages of a pipeline
+type PipelineReflection struct {
+	// Bindings are ordered by set and binding, with the stages of every shader using them
+	Bindings []DescriptorBinding
+	// PushConstants has a range for each distinct range used by the stages
+	PushConstants []PushConstantRange
+	// SpecializationConstants are ordered by constant ID
+	SpecializationConstants []SpecializationConstant
+	// VertexInputs are the inputs of the vertex stage
+	VertexInputs []ShaderInput
+	// LocalSize and LocalSizeSpecIDs are those of the compute stage
+	LocalSize        [3]uint32
+	LocalSizeSpecIDs [3]int
+}
+
+// ReflectionConflicts are the incompatibilities found between the shader stages of a pipeline
+type ReflectionConflicts []string
+
+func (c ReflectionConflicts) Error() string {
+	return "shader stages conflict: " + strings.Join(c, "; ")
+}
+
+var shaderStageNames = []struct {
+	stage vk.ShaderStageFlagBits
+	name  string
+}{
+	{vk.ShaderStageVertexBit, "vertex"},
+	{vk.ShaderStageTessellationControlBit, "tessellation control"},
+	{vk.ShaderStageTessellationEvaluationBit, "tessellation evaluation"},
+	{vk.ShaderStageGeometryBit, "geometry"},
+	{vk.ShaderStageFragmentBit, "fragment"},
+	{vk.ShaderStageComputeBit, "compute"},
+}
+
+func shaderStagesString(stages vk.ShaderStageFlags) string {
+	var names []string
+	for _, s := range shaderStageNames {
+		if stages&vk.ShaderStageFlags(s.stage) != 0 {
+			names = append(names, s.name)
+		}
+	}
+	if len(names) == 0 {
+		return "no stages"
+	}
+	return strings.Join(names, "|")
+}
+
+// MergeShaderStages combines the reflection of the shader stages of a pipeline, returning
+// ReflectionConflicts if the stages declare the same binding, push constants or specialization
+// constant differently
+func MergeShaderStages(stages ...ShaderStageReflection) (*PipelineReflection, error) {
+	ret := &PipelineReflection{LocalSizeSpecIDs: [3]int{-1, -1, -1}}
+	var conflicts ReflectionConflicts
+	var seen vk.ShaderStageFlags
+
+	for _, s := range stages {
+		if s.Reflection == nil {
+			return nil, fmt.Errorf("%s stage has no reflection data", shaderStagesString(vk.ShaderStageFlags(s.Stage)))
+		}
+		entry, ok := s.Reflection.EntryPoint(s.EntryPoint)
+		if !ok {
+			return nil, fmt.Errorf("shader has no entry point %q", s.EntryPoint)
+		}
+		if entry.Stage != s.Stage {
+			return nil, fmt.Errorf("entry point %q is a %s shader not a %s shader", s.EntryPoint,
+				shaderStagesString(vk.ShaderStageFlags(entry.Stage)), shaderStagesString(vk.ShaderStageFlags(s.Stage)))
+		}
+		stage := vk.ShaderStageFlags(s.Stage)
+		if seen&stage != 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s stage is used more than once", shaderStagesString(stage)))
+			continue
+		}
+		seen |= stage
+
+		for _, b := range s.Reflection.Bindings {
+			conflicts = ret.mergeBinding(b, stage, conflicts)
+		}
+		for _, pc := range s.Reflection.PushConstants {
+			ret.mergePushConstants(pc, stage)
+		}
+		for _, sc := range s.Reflection.SpecializationConstants {
+			conflicts = ret.mergeSpecializationConstant(sc, stage, conflicts)
+		}
+
+		switch s.Stage {
+		case vk.ShaderStageVertexBit:
+			ret.VertexInputs = append([]ShaderInput(nil), entry.Inputs...)
+		case vk.ShaderStageComputeBit:
+			ret.LocalSize = entry.LocalSize
+			ret.LocalSizeSpecIDs = entry.LocalSizeSpecIDs
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+	sort.SliceStable(ret.Bindings, func(i, j int) bool {
+		a, b := ret.Bindings[i], ret.Bindings[j]
+		if a.Set != b.Set {
+			return a.Set < b.Set
+		}
+		return a.Binding < b.Binding
+	})
+	sort.SliceStable(ret.SpecializationConstants, func(i, j int) bool {
+		return ret.SpecializationConstants[i].ID < ret.SpecializationConstants[j].ID
+	})
+	return ret, nil
+}
+
+func (r *PipelineReflection) mergeBinding(b DescriptorBinding, stage vk.ShaderStageFlags, conflicts ReflectionConflicts) ReflectionConflicts {
+	b.Stages = stage
+	for i := range r.Bindings {
+		e := &r.Bindings[i]
+		if e.Set != b.Set || e.Binding != b.Binding {
+			continue
+		}
+		if e.Type != b.Type || e.Count != b.Count {
+			return append(conflicts, fmt.Sprintf("set %d binding %d is %d x %s in %s but %d x %s in %s",
+				b.Set, b.Binding, e.Count, descriptorTypeString(e.Type), shaderStagesString(e.Stages),
+				b.Count, descriptorTypeString(b.Type), shaderStagesString(stage)))
+		}
+		e.Stages |= stage
+		e.ReadOnly = e.ReadOnly && b.ReadOnly
+		if b.Size > e.Size {
+			e.Size = b.Size
+		}
+		if e.Name == "" {
+			e.Name = b.Name
+		}
+		return conflicts
+	}
+	r.Bindings = append(r.Bindings, b)
+	return conflicts
+}
+
+func (r *PipelineReflection) mergePushConstants(pc PushConstantRange, stage vk.ShaderStageFlags) {
+	for i := range r.PushConstants {
+		e := &r.PushConstants[i]
+		if e.Offset == pc.Offset && e.Size == pc.Size {
+			e.Stages |= stage
+			return
+		}
+	}
+	pc.Stages = stage
+	r.PushConstants = append(r.PushConstants, pc)
+}
+
+func (r *PipelineReflection) mergeSpecializationConstant(sc SpecializationConstant, stage vk.ShaderStageFlags, conflicts ReflectionConflicts) ReflectionConflicts {
+	sc.Stages = stage
+	for i := range r.SpecializationConstants {
+		e := &r.SpecializationConstants[i]
+		if e.ID != sc.ID {
+			continue
+		}
+		if e.Type != sc.Type || e.Width != sc.Width {
+			return append(conflicts, fmt.Sprintf("specialization constant %d is a %d bit %s in %s but a %d bit %s in %s",
+				sc.ID, e.Width, e.Type, shaderStagesString(e.Stages), sc.Width, sc.Type, shaderStagesString(stage)))
+		}
+		e.Stages |= stage
+		return conflicts
+	}
+	r.SpecializationConstants = append(r.SpecializationConstants, sc)
+	return conflicts
+}
+
+func descriptorTypeString(t vk.DescriptorType) string {
+	switch t {
+	case vk.DescriptorTypeSampler:
+		return "sampler"
+	case vk.DescriptorTypeCombinedImageSampler:
+		return "combined image sampler"
+	case vk.DescriptorTypeSampledImage:
+		return "sampled image"
+	case vk.DescriptorTypeStorageImage:
+		return "storage image"
+	case vk.DescriptorTypeUniformTexelBuffer:
+		return "uniform texel buffer"
+	case vk.DescriptorTypeStorageTexelBuffer:
+		return "storage texel buffer"
+	case vk.DescriptorTypeUniformBuffer:
+		return "uniform buffer"
+	case vk.DescriptorTypeStorageBuffer:
+		return "storage buffer"
+	case vk.DescriptorTypeInputAttachment:
+		return "input attachment"
+	}
+	return fmt.Sprintf("descriptor type %d", t)
+}
+
+// Binding returns the binding with the specified name
+func (r *PipelineReflection) Binding(name string) (*DescriptorBinding, bool) {
+	return findBinding(r.Bindings, name)
+}
+
+// SpecializationConstant returns the specialization constant with the specified ID
+func (r *PipelineReflection) SpecializationConstant(id uint32) (*SpecializationConstant, bool) {
+	return findSpecializationConstant(r.SpecializationConstants, id)
+}
+
+// DescriptorSetLayouts returns uncreated layouts for each set from 0 to the highest set used, so that
+// the index of each layout is its set number. Sets without any bindings have empty layouts.
+func (r *PipelineReflection) DescriptorSetLayouts() ([]*DescriptorSetLayout, error) {
+	var ret []*DescriptorSetLayout
+	for _, b := range r.Bindings {
+		if b.Count == 0 {
+			return nil, fmt.Errorf("binding %s (set %d binding %d) is a runtime sized array, which is not supported", b.Name, b.Set, b.Binding)
+		}
+		for uint32(len(ret)) <= b.Set {
+			ret = append(ret, &DescriptorSetLayout{})
+		}
+		ret[b.Set].AddBinding(vk.DescriptorSetLayoutBinding{
+			Binding:         b.Binding,
+			DescriptorType:  b.Type,
+			DescriptorCount: b.Count,
+			StageFlags:      b.Stages,
+		})
+	}
+	return ret, nil
+}
+
+// CheckDescriptorSetLayouts returns ReflectionConflicts if any binding is not declared by the descriptor
+// set layouts, indexed by set number, with the same type, at least as many descriptors and all of the
+// stages using it
+func (r *PipelineReflection) CheckDescriptorSetLayouts(layouts []*DescriptorSetLayout) error {
+	var conflicts ReflectionConflicts
+	for _, b := range r.Bindings {
+		var declared *vk.DescriptorSetLayoutBinding
+		if b.Set < uint32(len(layouts)) && layouts[b.Set] != nil {
+			for i := range layouts[b.Set].VKDescriptorSetLayoutBindings {
+				if l := &layouts[b.Set].VKDescriptorSetLayoutBindings[i]; l.Binding == b.Binding {
+					declared = l
+					break
+				}
+			}
+		}
+		switch {
+		case declared == nil:
+			conflicts = append(conflicts, fmt.Sprintf("set %d binding %d (%s) is not in the descriptor set layouts", b.Set, b.Binding, b.Name))
+		case declared.DescriptorType != b.Type:
+			conflicts = append(conflicts, fmt.Sprintf("set %d binding %d (%s) is a %s but the layout has a %s",
+				b.Set, b.Binding, b.Name, descriptorTypeString(b.Type), descriptorTypeString(declared.DescriptorType)))
+		case b.Count > declared.DescriptorCount:
+			conflicts = append(conflicts, fmt.Sprintf("set %d binding %d (%s) has %d descriptors but the layout has %d",
+				b.Set, b.Binding, b.Name, b.Count, declared.DescriptorCount))
+		case b.Stages&^declared.StageFlags != 0:
+			conflicts = append(conflicts, fmt.Sprintf("set %d binding %d (%s) is used by %s but the layout is only for %s",
+				b.Set, b.Binding, b.Name, shaderStagesString(b.Stages), shaderStagesString(declared.StageFlags)))
+		}
+	}
+	if len(conflicts) > 0 {
+		return conflicts
+	}
+	return nil
+}
+
+// PushConstantRanges returns the push constant ranges for a pipeline layout
+func (r *PipelineReflection) PushConstantRanges() []vk.PushConstantRange {
+	ret := make([]vk.PushConstantRange, len(r.PushConstants))
+	for i, pc := range r.PushConstants {
+		ret[i] = vk.PushConstantRange{
+			StageFlags: pc.Stages,
+			Offset:     pc.Offset,
+			Size:       pc.Size,
+		}
+	}
+	return ret
+}
+
+// VertexInputDescriptions describes the vertex inputs as attributes of a single binding, interleaved in
+// the order of their locations with no padding
+func (r *PipelineReflection) VertexInputDescriptions(binding uint32, rate vk.VertexInputRate) (vk.VertexInputBindingDescription, []vk.VertexInputAttributeDescription) {
+	attributes := make([]vk.VertexInputAttributeDescription, 0, len(r.VertexInputs))
+	var offset uint32
+	for _, in := range r.VertexInputs {
+		attributes = append(attributes, vk.VertexInputAttributeDescription{
+			Binding:  binding,
+			Location: in.Location,
+			Format:   in.Format,
+			Offset:   offset,
+		})
+		offset += in.Size
+	}
+	return vk.VertexInputBindingDescription{
+		Binding:   binding,
+		Stride:    offset,
+		InputRate: rate,
+	}, attributes
+}
+
+// CreateReflectedDescriptorSetLayouts creates the descriptor set layouts described by the reflection,
+// see PipelineReflection.DescriptorSetLayouts
+func (d *Device) CreateReflectedDescriptorSetLayouts(r *PipelineReflection) ([]*DescriptorSetLayout, error) {
+	layouts, err := r.DescriptorSetLayouts()
+	if err != nil {
+		return nil, err
+	}
+	for i, l := range layouts {
+		_, err := d.CreateDescriptorSetLayout(l)
+		if err != nil {
+			for _, created := range layouts[:i] {
+				created.Destroy()
+			}
+			return nil, err
+		}
+	}
+	return layouts, nil
+}
+
+// CreateReflectedPipelineLayout creates a pipeline layout and its descriptor set layouts from the
+// reflection, all of which must be destroyed by the caller
+func (d *Device) CreateReflectedPipelineLayout(r *PipelineReflection) (*PipelineLayout, []*DescriptorSetLayout, error) {
+	layouts, err := d.CreateReflectedDescriptorSetLayouts(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	layout, err := d.CreatePipelineLayoutWithPushConstants(layouts, r.PushConstantRanges())
+	if err != nil {
+		for _, l := range layouts {
+			l.Destroy()
+		}
+		return nil, nil, err
+	}
+	return layout, layouts, nil
+}
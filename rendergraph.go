@@ -0,0 +1,1301 @@
+package vkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// RenderGraphPassKind is the type of work a render graph pass records
+type RenderGraphPassKind int
+
+const (
+	// GraphicsPass records draw commands inside a render pass created from its attachments
+	GraphicsPass RenderGraphPassKind = iota
+	// ComputePass records dispatches
+	ComputePass
+	// TransferPass records copies, blits and clears
+	TransferPass
+)
+
+func (k RenderGraphPassKind) String() string {
+	switch k {
+	case GraphicsPass:
+		return "graphics"
+	case ComputePass:
+		return "compute"
+	case TransferPass:
+		return "transfer"
+	}
+	return "unknown"
+}
+
+// RenderGraph describes a frame as a series of passes which declare the images and buffers they read and
+// write. Compiling the graph culls passes whose results are not used, creates a render pass and framebuffers
+// for each graphics pass, allocates transient resources, reusing the same image or buffer for transient
+// resources whose lifetimes do not overlap, and works out the minimal set of barriers and layout transitions
+// needed between the passes. Passes are executed in the order they were added.
+type RenderGraph struct {
+	Device          *Device
+	ResourceManager *ResourceManager
+	// PipelineCache is used when creating the pipelines added to passes, it is optional
+	PipelineCache *PipelineCache
+	// Profiler if set measures each pass as a scope when the graph is executed, the frame must have
+	// been begun with Profiler.BeginFrame
+	Profiler *Profiler
+
+	// OnCompiled is called each time the graph is compiled, which recreates transient resources, render
+	// passes and pipelines, so descriptor sets referencing graph resources can be updated
+	OnCompiled func(g *RenderGraph) error
+
+	passes     []*RenderGraphPass
+	resources  []*RenderGraphResource
+	backbuffer *RenderGraphResource
+	errors     []error
+
+	target     RenderGraphTarget
+	order      []*compiledPass
+	final      []plannedBarrier
+	physical   []*graphPhysical
+	bufferPool *BufferResourcePool
+	compiled   bool
+}
+
+// RenderGraphTarget is the swapchain the graph renders to, Images and Views are indexed by the
+// swapchain image index. A graph which does not use the backbuffer only needs the extent.
+type RenderGraphTarget struct {
+	Extent vk.Extent2D
+	Format vk.Format
+	Images []*Image
+	Views  []*ImageView
+}
+
+// RenderGraphResource is an image or buffer used by the passes of a render graph. Resources are either imported
+// from outside of the graph, the swapchain backbuffer, or transient resources which are created by the graph and
+// whose contents are only valid between the first pass which writes them and the last pass which reads them.
+type RenderGraphResource struct {
+	Name string
+
+	graph      *RenderGraph
+	isImage    bool
+	transient  bool
+	backbuffer bool
+
+	// Description of a transient image, a zero extent is the extent of the graph's target
+	format  vk.Format
+	extent  vk.Extent2D
+	samples vk.SampleCountFlagBits
+	// Description of a transient buffer
+	size uint64
+
+	image  *ImageResource
+	view   *ImageView
+	buffer *BufferResource
+	// usage of an imported resource before and after the graph executes
+	usage ResourceUsage
+
+	imageUsage  vk.ImageUsageFlagBits
+	bufferUsage vk.BufferUsageFlagBits
+	first, last int
+	physical    *graphPhysical
+}
+
+// RenderGraphPass is a single pass of a render graph, see RenderGraph.AddPass
+type RenderGraphPass struct {
+	Name string
+	Kind RenderGraphPassKind
+	// SideEffects prevents the pass being culled when nothing uses the resources it writes, for
+	// example a pass which writes to a host visible buffer which is read back
+	SideEffects bool
+	// Execute records the commands of the pass, graphics passes are recorded inside the pass's render pass
+	Execute func(cmd CommandRecorder, ctx *RenderGraphContext)
+
+	graph           *RenderGraph
+	accesses        []graphAccess
+	clears          map[*RenderGraphResource]vk.ClearValue
+	pipelineConfigs map[string]IGraphicsPipelineConfig
+	compiled        *compiledPass
+}
+
+// RenderGraphContext is passed to a pass when it is executed
+type RenderGraphContext struct {
+	Graph *RenderGraph
+	Pass  *RenderGraphPass
+	// ImageIndex is the index of the swapchain image being rendered
+	ImageIndex int
+	// Extent of the pass's attachments, or of the graph's target for passes without attachments
+	Extent       vk.Extent2D
+	VKRenderPass vk.RenderPass
+}
+
+type graphAccess struct {
+	resource *RenderGraphResource
+	usage    ResourceUsage
+}
+
+// graphPhysical is an image or buffer backing one or more resources, imported resources and the backbuffer
+// always have their own physical resource while transient resources may share one
+type graphPhysical struct {
+	index     int
+	resources []*RenderGraphResource
+	external  bool
+	isImage   bool
+	last      int
+
+	format      vk.Format
+	extent      vk.Extent2D
+	samples     vk.SampleCountFlagBits
+	size        uint64
+	imageUsage  vk.ImageUsageFlagBits
+	bufferUsage vk.BufferUsageFlagBits
+
+	image       *ImageResource
+	view        *ImageView
+	sampledView *ImageView
+	buffer      *BufferResource
+}
+
+type plannedBarrier struct {
+	resource  *RenderGraphResource
+	srcStages vk.PipelineStageFlags
+	dstStages vk.PipelineStageFlags
+	srcAccess vk.AccessFlags
+	dstAccess vk.AccessFlags
+	oldLayout vk.ImageLayout
+	newLayout vk.ImageLayout
+}
+
+type compiledAttachment struct {
+	resource      *RenderGraphResource
+	usage         ResourceUsage
+	loadOp        vk.AttachmentLoadOp
+	storeOp       vk.AttachmentStoreOp
+	initialLayout vk.ImageLayout
+	finalLayout   vk.ImageLayout
+}
+
+type compiledPass struct {
+	pass        *RenderGraphPass
+	index       int
+	barriers    []plannedBarrier
+	attachments []compiledAttachment
+	clearValues []vk.ClearValue
+	extent      vk.Extent2D
+	samples     vk.SampleCountFlagBits
+	// Dependencies on work before and after the render pass, which replace barriers for attachments
+	dependencies []vk.SubpassDependency
+	backbuffer   bool
+
+	renderPass   vk.RenderPass
+	framebuffers []vk.Framebuffer
+	pipelines    map[string]vk.Pipeline
+}
+
+// graphResourceState tracks the accesses to a physical resource while planning barriers
+type graphResourceState struct {
+	ResourceState
+
+	lastPass       *compiledPass
+	lastAttachment int
+}
+
+const writeAccessBits = vk.AccessShaderWriteBit | vk.AccessColorAttachmentWriteBit | vk.AccessDepthStencilAttachmentWriteBit |
+	vk.AccessTransferWriteBit | vk.AccessHostWriteBit | vk.AccessMemoryWriteBit
+
+// NewRenderGraph creates an empty render graph which allocates transient resources from this resource manager
+func (r *ResourceManager) NewRenderGraph() *RenderGraph {
+	var ret RenderGraph
+	ret.Device = r.Device
+	ret.ResourceManager = r
+	return &ret
+}
+
+func (g *RenderGraph) errorf(format string, args ...interface{}) {
+	g.errors = append(g.errors, fmt.Errorf(format, args...))
+}
+
+func (g *RenderGraph) addResource(r *RenderGraphResource) *RenderGraphResource {
+	for _, e := range g.resources {
+		if e.Name == r.Name {
+			g.errorf("render graph resource '%s' already exists", r.Name)
+		}
+	}
+	r.graph = g
+	if g.resources == nil {
+		g.resources = make([]*RenderGraphResource, 0)
+	}
+	g.resources = append(g.resources, r)
+	return r
+}
+
+// CreateImage declares a transient image, a zero extent uses the extent of the graph's target and zero
+// samples is a single sample per pixel. The usage flags are derived from how passes use the image.
+func (g *RenderGraph) CreateImage(name string, format vk.Format, extent vk.Extent2D, samples vk.SampleCountFlagBits) *RenderGraphResource {
+	if samples == 0 {
+		samples = vk.SampleCount1Bit
+	}
+	return g.addResource(&RenderGraphResource{Name: name, isImage: true, transient: true, format: format, extent: extent, samples: samples})
+}
+
+// CreateBuffer declares a transient device local buffer
+func (g *RenderGraph) CreateBuffer(name string, size uint64) *RenderGraphResource {
+	return g.addResource(&RenderGraphResource{Name: name, transient: true, size: size})
+}
+
+// ImportImage adds an image created outside of the graph, the image is expected to be in the layout for the
+// specified usage when the graph executes and is returned to that layout at the end of the graph. A view is
+// only needed if the image is used as an attachment.
+func (g *RenderGraph) ImportImage(name string, image *ImageResource, view *ImageView, usage ResourceUsage) *RenderGraphResource {
+	return g.addResource(&RenderGraphResource{
+		Name:    name,
+		isImage: true,
+		image:   image,
+		view:    view,
+		usage:   usage,
+		format:  image.VKFormat,
+		extent:  image.Extent,
+		samples: image.Samples,
+	})
+}
+
+// ImportBuffer adds a buffer created outside of the graph, accesses before the graph executes are assumed to
+// be of the specified usage
+func (g *RenderGraph) ImportBuffer(name string, buffer *BufferResource, usage ResourceUsage) *RenderGraphResource {
+	return g.addResource(&RenderGraphResource{Name: name, buffer: buffer, usage: usage, size: buffer.Size})
+}
+
+// Backbuffer returns the swapchain image the graph renders to, it is presented once the graph has executed
+func (g *RenderGraph) Backbuffer() *RenderGraphResource {
+	if g.backbuffer == nil {
+		g.backbuffer = g.addResource(&RenderGraphResource{Name: "backbuffer", isImage: true, backbuffer: true, samples: vk.SampleCount1Bit})
+	}
+	return g.backbuffer
+}
+
+// AddPass adds a pass to the graph, passes execute in the order they are added
+func (g *RenderGraph) AddPass(name string, kind RenderGraphPassKind, execute func(cmd CommandRecorder, ctx *RenderGraphContext)) *RenderGraphPass {
+	for _, p := range g.passes {
+		if p.Name == name {
+			g.errorf("render graph pass '%s' already exists", name)
+		}
+	}
+	p := &RenderGraphPass{Name: name, Kind: kind, Execute: execute, graph: g}
+	if g.passes == nil {
+		g.passes = make([]*RenderGraphPass, 0)
+	}
+	g.passes = append(g.passes, p)
+	return p
+}
+
+// Passes returns the passes of the graph in the order they were added
+func (g *RenderGraph) Passes() []*RenderGraphPass {
+	return g.passes
+}
+
+func (p *RenderGraphPass) access(r *RenderGraphResource, usage ResourceUsage) *RenderGraphPass {
+	g := p.graph
+	switch {
+	case r == nil || r.graph != g:
+		g.errorf("pass '%s' uses a resource which does not belong to the graph", p.Name)
+		return p
+	case usage == UsageNone || usage == UsagePresent || usage == UsageHostRead || usage == UsageHostWrite:
+		g.errorf("pass '%s' can not use '%s' as %s", p.Name, r.Name, usage)
+		return p
+	case r.isImage && usage.VKImageUsage() == 0:
+		g.errorf("pass '%s' uses image '%s' as %s which is only valid for buffers", p.Name, r.Name, usage)
+		return p
+	case !r.isImage && usage.VKBufferUsage() == 0:
+		g.errorf("pass '%s' uses buffer '%s' as %s which is only valid for images", p.Name, r.Name, usage)
+		return p
+	case usage.IsAttachment() && p.Kind != GraphicsPass:
+		g.errorf("%s pass '%s' can not use '%s' as an attachment", p.Kind, p.Name, r.Name)
+		return p
+	}
+
+	for _, a := range p.accesses {
+		if a.resource == r {
+			if a.usage != usage {
+				g.errorf("pass '%s' uses '%s' as both %s and %s", p.Name, r.Name, a.usage, usage)
+			}
+			return p
+		}
+	}
+	if p.accesses == nil {
+		p.accesses = make([]graphAccess, 0)
+	}
+	p.accesses = append(p.accesses, graphAccess{resource: r, usage: usage})
+	return p
+}
+
+// Read declares that the pass reads the resource, usage must be a read only usage
+func (p *RenderGraphPass) Read(r *RenderGraphResource, usage ResourceUsage) *RenderGraphPass {
+	if usage.IsWrite() {
+		p.graph.errorf("pass '%s' reads '%s' with the write usage %s", p.Name, r.Name, usage)
+		return p
+	}
+	return p.access(r, usage)
+}
+
+// Write declares that the pass writes the resource, usage must be a usage which writes
+func (p *RenderGraphPass) Write(r *RenderGraphResource, usage ResourceUsage) *RenderGraphPass {
+	if !usage.IsWrite() {
+		p.graph.errorf("pass '%s' writes '%s' with the read only usage %s", p.Name, r.Name, usage)
+		return p
+	}
+	return p.access(r, usage)
+}
+
+// Sampled declares that the pass samples the image
+func (p *RenderGraphPass) Sampled(r *RenderGraphResource) *RenderGraphPass {
+	return p.Read(r, UsageSampled)
+}
+
+// Color adds a color attachment to the pass, the previous contents are loaded
+func (p *RenderGraphPass) Color(r *RenderGraphResource) *RenderGraphPass {
+	return p.access(r, UsageColorAttachment)
+}
+
+// ClearColor adds a color attachment which is cleared to the specified color at the start of the pass
+func (p *RenderGraphPass) ClearColor(r *RenderGraphResource, red, green, blue, alpha float32) *RenderGraphPass {
+	p.setClear(r, vk.NewClearValue([]float32{red, green, blue, alpha}))
+	return p.access(r, UsageColorAttachment)
+}
+
+// Depth adds a depth/stencil attachment to the pass, the previous contents are loaded
+func (p *RenderGraphPass) Depth(r *RenderGraphResource) *RenderGraphPass {
+	return p.access(r, UsageDepthStencilAttachment)
+}
+
+// ClearDepth adds a depth/stencil attachment which is cleared at the start of the pass
+func (p *RenderGraphPass) ClearDepth(r *RenderGraphResource, depth float32, stencil uint32) *RenderGraphPass {
+	p.setClear(r, vk.NewClearDepthStencil(depth, stencil))
+	return p.access(r, UsageDepthStencilAttachment)
+}
+
+// DepthReadOnly adds a depth/stencil attachment which is tested against but not written
+func (p *RenderGraphPass) DepthReadOnly(r *RenderGraphResource) *RenderGraphPass {
+	return p.access(r, UsageDepthStencilReadOnly)
+}
+
+func (p *RenderGraphPass) setClear(r *RenderGraphResource, value vk.ClearValue) {
+	if p.clears == nil {
+		p.clears = make(map[*RenderGraphResource]vk.ClearValue)
+	}
+	p.clears[r] = value
+}
+
+// AddPipeline adds a graphics pipeline which is created for the pass's render pass each time the graph is
+// compiled, it can be retrieved while the pass executes with RenderGraphContext.Pipeline
+func (p *RenderGraphPass) AddPipeline(name string, config IGraphicsPipelineConfig) *RenderGraphPass {
+	if p.Kind != GraphicsPass {
+		p.graph.errorf("pipeline '%s' can only be added to a graphics pass, '%s' is a %s pass", name, p.Name, p.Kind)
+		return p
+	}
+	if p.pipelineConfigs == nil {
+		p.pipelineConfigs = make(map[string]IGraphicsPipelineConfig)
+	}
+	p.pipelineConfigs[name] = config
+	return p
+}
+
+// Culled returns true if the pass was removed when the graph was compiled because its results are not used
+func (p *RenderGraphPass) Culled() bool {
+	return p.graph.compiled && p.compiled == nil
+}
+
+// VKRenderPass returns the render pass created for this pass when the graph was compiled
+func (p *RenderGraphPass) VKRenderPass() vk.RenderPass {
+	if p.compiled == nil {
+		return vk.NullRenderPass
+	}
+	return p.compiled.renderPass
+}
+
+func (r *RenderGraphResource) external() bool {
+	return !r.transient
+}
+
+func (r *RenderGraphResource) resolvedExtent(target vk.Extent2D) vk.Extent2D {
+	if r.backbuffer || (r.extent.Width == 0 && r.extent.Height == 0) {
+		return target
+	}
+	return r.extent
+}
+
+func (r *RenderGraphResource) resolvedFormat(target vk.Format) vk.Format {
+	if r.backbuffer {
+		return target
+	}
+	return r.format
+}
+
+// passStages narrows the shader stages of a usage to those which the pass kind can execute
+func passStages(usage ResourceUsage, kind RenderGraphPassKind) vk.PipelineStageFlags {
+	stages := usage.StageMask()
+	if stages&vk.PipelineStageFlags(shaderStages) == 0 {
+		return stages
+	}
+	stages &^= vk.PipelineStageFlags(shaderStages)
+	switch kind {
+	case ComputePass:
+		stages |= vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit)
+	default:
+		stages |= vk.PipelineStageFlags(vk.PipelineStageVertexShaderBit | vk.PipelineStageFragmentShaderBit)
+	}
+	return stages
+}
+
+// cull returns the passes which contribute to the output of the graph, in the order they were added
+func (g *RenderGraph) cull() []*RenderGraphPass {
+	needed := make(map[*RenderGraphResource]bool)
+	live := make([]bool, len(g.passes))
+
+	for i := len(g.passes) - 1; i >= 0; i-- {
+		p := g.passes[i]
+		live[i] = p.SideEffects
+		for _, a := range p.accesses {
+			if a.usage.IsWrite() && (a.resource.external() || needed[a.resource]) {
+				live[i] = true
+			}
+		}
+		if !live[i] {
+			continue
+		}
+		for _, a := range p.accesses {
+			if _, cleared := p.clears[a.resource]; cleared {
+				// Earlier contents are discarded
+				delete(needed, a.resource)
+				continue
+			}
+			needed[a.resource] = true
+		}
+	}
+
+	ret := make([]*RenderGraphPass, 0, len(g.passes))
+	for i, p := range g.passes {
+		if live[i] {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// plan compiles the graph without creating any vulkan objects
+func (g *RenderGraph) plan(target RenderGraphTarget) error {
+	g.compiled = false
+	g.order = nil
+	g.final = nil
+	g.physical = nil
+	for _, p := range g.passes {
+		p.compiled = nil
+	}
+
+	if len(g.errors) > 0 {
+		return g.errors[0]
+	}
+	g.target = target
+
+	live := g.cull()
+
+	for _, r := range g.resources {
+		r.first, r.last = -1, -1
+		r.imageUsage, r.bufferUsage = 0, 0
+		r.physical = nil
+	}
+
+	g.order = make([]*compiledPass, len(live))
+	for i, p := range live {
+		cp := &compiledPass{pass: p, index: i, extent: target.Extent}
+		p.compiled = cp
+		g.order[i] = cp
+
+		for _, a := range p.accesses {
+			r := a.resource
+			if r.first < 0 {
+				r.first = i
+				if r.transient && !a.usage.IsWrite() {
+					return fmt.Errorf("pass '%s' reads '%s' before it has been written", p.Name, r.Name)
+				}
+			}
+			r.last = i
+			r.imageUsage |= a.usage.VKImageUsage()
+			r.bufferUsage |= a.usage.VKBufferUsage()
+		}
+	}
+
+	err := g.assignPhysical()
+	if err != nil {
+		return err
+	}
+
+	// Start each physical resource in the state it is left in at the end of the previous frame
+	states := make([]*graphResourceState, len(g.physical))
+	for i, ph := range g.physical {
+		s := &graphResourceState{}
+		r := ph.resources[0]
+		switch {
+		case r.backbuffer:
+			// Matches the stage the image acquisition semaphore is waited on
+			s.ReadStages = vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)
+		case r.external():
+			s.Layout = r.usage.ImageLayout()
+			if r.usage.IsWrite() {
+				s.WriteStages = r.usage.StageMask()
+				s.WriteAccess = r.usage.AccessMask() & vk.AccessFlags(writeAccessBits)
+			} else {
+				s.ReadStages = r.usage.StageMask()
+			}
+		default:
+			for _, cp := range g.order {
+				for _, a := range cp.pass.accesses {
+					if a.resource.physical != ph {
+						continue
+					}
+					stages := passStages(a.usage, cp.pass.Kind)
+					s.ReadStages |= stages
+					if a.usage.IsWrite() {
+						s.WriteStages |= stages
+						s.WriteAccess |= a.usage.AccessMask() & vk.AccessFlags(writeAccessBits)
+					}
+				}
+			}
+		}
+		states[i] = s
+	}
+
+	for _, cp := range g.order {
+		err := g.planPass(cp, states)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Return external resources to the state they were imported in
+	for _, ph := range g.physical {
+		r := ph.resources[0]
+		if !ph.external || r.last < 0 {
+			continue
+		}
+		usage := r.usage
+		if r.backbuffer {
+			usage = UsagePresent
+		}
+		s := states[ph.index]
+		lastPass, lastAttachment := s.lastPass, s.lastAttachment
+		b, needed := s.access(usage, usage.StageMask(), ph.isImage, false)
+		if !needed {
+			continue
+		}
+		b.resource = r
+		if lastPass != nil && lastAttachment >= 0 {
+			// Let the render pass perform the final transition
+			lastPass.attachments[lastAttachment].finalLayout = b.newLayout
+			lastPass.dependencies = append(lastPass.dependencies, vk.SubpassDependency{
+				SrcSubpass:    0,
+				DstSubpass:    vk.SubpassExternal,
+				SrcStageMask:  b.srcStages,
+				SrcAccessMask: b.srcAccess,
+				DstStageMask:  b.dstStages,
+				DstAccessMask: b.dstAccess,
+			})
+			continue
+		}
+		g.final = append(g.final, b)
+	}
+
+	g.compiled = true
+	return nil
+}
+
+// assignPhysical gives each resource a physical resource, transient resources of the same description
+// share a physical resource when their lifetimes do not overlap
+func (g *RenderGraph) assignPhysical() error {
+	g.physical = make([]*graphPhysical, 0, len(g.resources))
+
+	newPhysical := func(r *RenderGraphResource) *graphPhysical {
+		ph := &graphPhysical{
+			index:    len(g.physical),
+			external: r.external(),
+			isImage:  r.isImage,
+			last:     r.last,
+			format:   r.resolvedFormat(g.target.Format),
+			extent:   r.resolvedExtent(g.target.Extent),
+			samples:  r.samples,
+			size:     r.size,
+		}
+		g.physical = append(g.physical, ph)
+		return ph
+	}
+
+	// Resources are considered in order of first use so lifetimes are packed greedily
+	used := make([]*RenderGraphResource, 0, len(g.resources))
+	for _, cp := range g.order {
+		for _, a := range cp.pass.accesses {
+			if a.resource.first == cp.index && a.resource.physical == nil {
+				a.resource.physical = &graphPhysical{}
+				used = append(used, a.resource)
+			}
+		}
+	}
+
+	for _, r := range used {
+		var ph *graphPhysical
+		if r.transient {
+			for _, c := range g.physical {
+				if c.external || c.isImage != r.isImage || c.last >= r.first {
+					continue
+				}
+				if r.isImage {
+					extent := r.resolvedExtent(g.target.Extent)
+					if c.format != r.format || c.samples != r.samples || c.extent.Width != extent.Width || c.extent.Height != extent.Height {
+						continue
+					}
+				}
+				ph = c
+				break
+			}
+		}
+		if ph == nil {
+			ph = newPhysical(r)
+		}
+		if r.size > ph.size {
+			ph.size = r.size
+		}
+		ph.last = r.last
+		ph.imageUsage |= r.imageUsage
+		ph.bufferUsage |= r.bufferUsage
+		ph.resources = append(ph.resources, r)
+		r.physical = ph
+
+		if r.backbuffer && g.target.Format == vk.FormatUndefined {
+			return fmt.Errorf("the backbuffer is used but the graph's target has no format")
+		}
+	}
+
+	return nil
+}
+
+func (g *RenderGraph) planPass(cp *compiledPass, states []*graphResourceState) error {
+	p := cp.pass
+
+	var in vk.SubpassDependency
+	in.SrcSubpass = vk.SubpassExternal
+	in.DstSubpass = 0
+
+	extentSet := false
+	var depth *compiledAttachment
+	depthClear := vk.ClearValue{}
+
+	for _, a := range p.accesses {
+		r := a.resource
+		ph := r.physical
+		s := states[ph.index]
+
+		_, cleared := p.clears[r]
+		discard := (r.transient && r.first == cp.index) || cleared
+
+		initialLayout := s.Layout
+		b, needed := s.access(a.usage, passStages(a.usage, p.Kind), ph.isImage, discard)
+		s.lastPass, s.lastAttachment = nil, -1
+
+		if !a.usage.IsAttachment() {
+			if needed {
+				b.resource = r
+				cp.barriers = append(cp.barriers, b)
+			}
+			continue
+		}
+
+		extent := r.resolvedExtent(g.target.Extent)
+		if extentSet && (extent.Width != cp.extent.Width || extent.Height != cp.extent.Height) {
+			return fmt.Errorf("attachments of pass '%s' have different extents", p.Name)
+		}
+		if extentSet && r.samples != cp.samples {
+			return fmt.Errorf("attachments of pass '%s' have different sample counts", p.Name)
+		}
+		cp.extent, cp.samples, extentSet = extent, r.samples, true
+		cp.backbuffer = cp.backbuffer || r.backbuffer
+
+		if needed {
+			// The render pass performs the transition
+			initialLayout = b.oldLayout
+			in.SrcStageMask |= b.srcStages
+			in.SrcAccessMask |= b.srcAccess
+			in.DstStageMask |= b.dstStages
+			in.DstAccessMask |= b.dstAccess
+		}
+
+		att := compiledAttachment{
+			resource:      r,
+			usage:         a.usage,
+			loadOp:        vk.AttachmentLoadOpLoad,
+			storeOp:       vk.AttachmentStoreOpDontCare,
+			initialLayout: initialLayout,
+			finalLayout:   a.usage.ImageLayout(),
+		}
+		switch {
+		case cleared:
+			att.loadOp = vk.AttachmentLoadOpClear
+			att.initialLayout = vk.ImageLayoutUndefined
+		case initialLayout == vk.ImageLayoutUndefined:
+			att.loadOp = vk.AttachmentLoadOpDontCare
+		}
+		if r.external() || r.last > cp.index {
+			att.storeOp = vk.AttachmentStoreOpStore
+		}
+
+		if a.usage == UsageColorAttachment {
+			s.lastPass, s.lastAttachment = cp, len(cp.attachments)
+			cp.attachments = append(cp.attachments, att)
+			cp.clearValues = append(cp.clearValues, p.clears[r])
+		} else {
+			if depth != nil {
+				return fmt.Errorf("pass '%s' has more than one depth attachment", p.Name)
+			}
+			depth = &att
+			depthClear = p.clears[r]
+		}
+	}
+
+	if depth != nil {
+		states[depth.resource.physical.index].lastPass = cp
+		states[depth.resource.physical.index].lastAttachment = len(cp.attachments)
+		cp.attachments = append(cp.attachments, *depth)
+		cp.clearValues = append(cp.clearValues, depthClear)
+	}
+
+	if p.Kind == GraphicsPass && len(cp.attachments) == 0 {
+		return fmt.Errorf("graphics pass '%s' has no attachments", p.Name)
+	}
+	if in.DstStageMask != 0 {
+		cp.dependencies = append(cp.dependencies, in)
+	}
+
+	return nil
+}
+
+// Compile plans the graph and creates the transient resources, render passes, framebuffers and pipelines it
+// needs, anything created by an earlier compile is destroyed first so the graph must not be in use by the device.
+func (g *RenderGraph) Compile(target RenderGraphTarget) error {
+	g.release()
+
+	err := g.plan(target)
+	if err != nil {
+		return err
+	}
+
+	err = g.createPhysical()
+	if err != nil {
+		g.release()
+		return err
+	}
+
+	for _, cp := range g.order {
+		if cp.pass.Kind != GraphicsPass {
+			continue
+		}
+		err = g.createRenderPass(cp)
+		if err != nil {
+			g.release()
+			return fmt.Errorf("unable to create render pass for '%s': %w", cp.pass.Name, err)
+		}
+	}
+
+	if g.OnCompiled != nil {
+		return g.OnCompiled(g)
+	}
+	return nil
+}
+
+func (g *RenderGraph) createPhysical() error {
+	var bufferSize uint64
+	var bufferUsage vk.BufferUsageFlagBits
+	for _, ph := range g.physical {
+		if ph.external {
+			continue
+		}
+		if ph.isImage {
+			var err error
+			ph.image, err = g.ResourceManager.NewImageResource(ph.extent, ph.format, vk.ImageTilingOptimal, ph.imageUsage,
+				vk.SharingModeExclusive, vk.MemoryPropertyDeviceLocalBit, &CreateImageOptions{Samples: ph.samples})
+			if err != nil {
+				return err
+			}
+			ph.view, err = ph.image.CreateImageViewWithAspectMask(FormatAspectMask(ph.format))
+			if err != nil {
+				return err
+			}
+			if ph.imageUsage&vk.ImageUsageSampledBit != 0 && IsDepthFormat(ph.format) && HasStencilComponent(ph.format) {
+				// Only a single aspect of a depth/stencil image can be sampled
+				ph.sampledView, err = ph.image.CreateImageViewWithAspectMask(vk.ImageAspectFlags(vk.ImageAspectDepthBit))
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		// Leave room for each buffer to be aligned
+		bufferSize += ph.size + 256
+		bufferUsage |= ph.bufferUsage
+	}
+
+	if bufferSize == 0 {
+		return nil
+	}
+
+	var err error
+	g.bufferPool, err = g.ResourceManager.AllocateBufferPoolWithOptions(fmt.Sprintf("rendergraph-%p", g), bufferSize,
+		vk.MemoryPropertyDeviceLocalBit, bufferUsage, vk.SharingModeExclusive)
+	if err != nil {
+		return err
+	}
+	for _, ph := range g.physical {
+		if ph.external || ph.isImage {
+			continue
+		}
+		ph.buffer, err = g.bufferPool.AllocateBuffer(ph.size, ph.bufferUsage)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderPassCreateInfo creates the info for the render pass of a compiled graphics pass
+func (cp *compiledPass) renderPassCreateInfo(targetFormat vk.Format) vk.RenderPassCreateInfo {
+	attachments := make([]vk.AttachmentDescription, len(cp.attachments))
+	subpass := vk.SubpassDescription{
+		PipelineBindPoint: vk.PipelineBindPointGraphics,
+	}
+	colors := make([]vk.AttachmentReference, 0, len(cp.attachments))
+
+	for i, a := range cp.attachments {
+		format := a.resource.resolvedFormat(targetFormat)
+		stencilLoad, stencilStore := vk.AttachmentLoadOpDontCare, vk.AttachmentStoreOpDontCare
+		if HasStencilComponent(format) {
+			stencilLoad, stencilStore = a.loadOp, a.storeOp
+		}
+		attachments[i] = vk.AttachmentDescription{
+			Format:         format,
+			Samples:        a.resource.samples,
+			LoadOp:         a.loadOp,
+			StoreOp:        a.storeOp,
+			StencilLoadOp:  stencilLoad,
+			StencilStoreOp: stencilStore,
+			InitialLayout:  a.initialLayout,
+			FinalLayout:    a.finalLayout,
+		}
+		ref := vk.AttachmentReference{Attachment: uint32(i), Layout: a.usage.ImageLayout()}
+		if a.usage == UsageColorAttachment {
+			colors = append(colors, ref)
+		} else {
+			subpass.PDepthStencilAttachment = &ref
+		}
+	}
+	subpass.ColorAttachmentCount = uint32(len(colors))
+	subpass.PColorAttachments = colors
+
+	return vk.RenderPassCreateInfo{
+		SType:           vk.StructureTypeRenderPassCreateInfo,
+		AttachmentCount: uint32(len(attachments)),
+		PAttachments:    attachments,
+		SubpassCount:    1,
+		PSubpasses:      []vk.SubpassDescription{subpass},
+		DependencyCount: uint32(len(cp.dependencies)),
+		PDependencies:   cp.dependencies,
+	}
+}
+
+func (g *RenderGraph) createRenderPass(cp *compiledPass) error {
+	renderPassCreateInfo := cp.renderPassCreateInfo(g.target.Format)
+	var renderPass vk.RenderPass
+	err := vk.Error(vk.CreateRenderPass(g.Device.VKDevice, &renderPassCreateInfo, nil, &renderPass))
+	if err != nil {
+		return err
+	}
+	cp.renderPass = renderPass
+
+	count := 1
+	if cp.backbuffer {
+		count = len(g.target.Views)
+		if count == 0 {
+			return fmt.Errorf("the graph's target has no image views for the backbuffer")
+		}
+	}
+
+	cp.framebuffers = make([]vk.Framebuffer, count)
+	for i := range cp.framebuffers {
+		views := make([]vk.ImageView, len(cp.attachments))
+		for j, a := range cp.attachments {
+			view := g.view(a.resource, i)
+			if view == nil {
+				return fmt.Errorf("attachment '%s' has no image view", a.resource.Name)
+			}
+			views[j] = view.VKImageView
+		}
+
+		fbCreateInfo := vk.FramebufferCreateInfo{
+			SType:           vk.StructureTypeFramebufferCreateInfo,
+			RenderPass:      cp.renderPass,
+			Layers:          1,
+			AttachmentCount: uint32(len(views)),
+			PAttachments:    views,
+			Width:           cp.extent.Width,
+			Height:          cp.extent.Height,
+		}
+		err = vk.Error(vk.CreateFramebuffer(g.Device.VKDevice, &fbCreateInfo, nil, &cp.framebuffers[i]))
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(cp.pass.pipelineConfigs) == 0 {
+		return nil
+	}
+
+	var cache vk.PipelineCache
+	if g.PipelineCache != nil {
+		cache = g.PipelineCache.VKPipelineCache
+	}
+	cp.pipelines = make(map[string]vk.Pipeline)
+	for name, config := range cp.pass.pipelineConfigs {
+		createInfo, err := config.VKGraphicsPipelineCreateInfo(cp.extent)
+		if err != nil {
+			return fmt.Errorf("error generating graphics pipeline config '%s': %w", name, err)
+		}
+		createInfo.RenderPass = cp.renderPass
+		if createInfo.PMultisampleState != nil {
+			createInfo.PMultisampleState.RasterizationSamples = cp.samples
+		}
+		pipelines := make([]vk.Pipeline, 1)
+		err = vk.Error(vk.CreateGraphicsPipelines(g.Device.VKDevice, cache, 1, []vk.GraphicsPipelineCreateInfo{createInfo}, nil, pipelines))
+		if err != nil {
+			return fmt.Errorf("unable to create graphics pipeline '%s': %w", name, err)
+		}
+		cp.pipelines[name] = pipelines[0]
+	}
+
+	return nil
+}
+
+func (g *RenderGraph) image(r *RenderGraphResource, imageIndex int) *Image {
+	if r.backbuffer {
+		if imageIndex >= 0 && imageIndex < len(g.target.Images) {
+			return g.target.Images[imageIndex]
+		}
+		return nil
+	}
+	if r.image != nil {
+		return &r.image.Image
+	}
+	if r.physical != nil && r.physical.image != nil {
+		return &r.physical.image.Image
+	}
+	return nil
+}
+
+func (g *RenderGraph) view(r *RenderGraphResource, imageIndex int) *ImageView {
+	if r.backbuffer {
+		if imageIndex >= 0 && imageIndex < len(g.target.Views) {
+			return g.target.Views[imageIndex]
+		}
+		return nil
+	}
+	if r.view != nil {
+		return r.view
+	}
+	if r.physical != nil {
+		return r.physical.view
+	}
+	return nil
+}
+
+func (g *RenderGraph) buffer(r *RenderGraphResource) *BufferResource {
+	if r.buffer != nil {
+		return r.buffer
+	}
+	if r.physical != nil {
+		return r.physical.buffer
+	}
+	return nil
+}
+
+func (g *RenderGraph) recordBarriers(cmd CommandRecorder, barriers []plannedBarrier, imageIndex int) {
+	if len(barriers) == 0 {
+		return
+	}
+
+	var srcStages, dstStages vk.PipelineStageFlags
+	imageBarriers := make([]vk.ImageMemoryBarrier, 0, len(barriers))
+	bufferBarriers := make([]vk.BufferMemoryBarrier, 0, len(barriers))
+
+	for _, b := range barriers {
+		srcStages |= b.srcStages
+		dstStages |= b.dstStages
+		if b.resource.isImage {
+			img := g.image(b.resource, imageIndex)
+			imageBarriers = append(imageBarriers, vk.ImageMemoryBarrier{
+				SType:               vk.StructureTypeImageMemoryBarrier,
+				SrcAccessMask:       b.srcAccess,
+				DstAccessMask:       b.dstAccess,
+				OldLayout:           b.oldLayout,
+				NewLayout:           b.newLayout,
+				SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+				DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+				Image:               img.VKImage,
+				SubresourceRange:    img.FullSubresourceRange(),
+			})
+			continue
+		}
+		buf := g.buffer(b.resource)
+		bufferBarriers = append(bufferBarriers, vk.BufferMemoryBarrier{
+			SType:               vk.StructureTypeBufferMemoryBarrier,
+			SrcAccessMask:       b.srcAccess,
+			DstAccessMask:       b.dstAccess,
+			SrcQueueFamilyIndex: vk.QueueFamilyIgnored,
+			DstQueueFamilyIndex: vk.QueueFamilyIgnored,
+			Buffer:              buf.VKBuffer,
+			Offset:              0,
+			Size:                vk.DeviceSize(vk.WholeSize),
+		})
+	}
+
+	cmd.CmdPipelineBarrier(srcStages, dstStages, 0, nil, bufferBarriers, imageBarriers)
+}
+
+// Execute records all of the passes of a compiled graph into the command buffer, imageIndex is the index of the
+// swapchain image being rendered
+func (g *RenderGraph) Execute(cmd CommandRecorder, imageIndex int) error {
+	if !g.compiled {
+		return fmt.Errorf("render graph has not been compiled")
+	}
+
+	for _, cp := range g.order {
+		g.recordBarriers(cmd, cp.barriers, imageIndex)
+
+		if g.Profiler != nil {
+			g.Profiler.Begin(cmd, cp.pass.Name)
+		}
+
+		ctx := &RenderGraphContext{
+			Graph:        g,
+			Pass:         cp.pass,
+			ImageIndex:   imageIndex,
+			Extent:       cp.extent,
+			VKRenderPass: cp.renderPass,
+		}
+
+		if cp.pass.Kind == GraphicsPass {
+			fb := cp.framebuffers[0]
+			if cp.backbuffer {
+				if imageIndex >= len(cp.framebuffers) {
+					return fmt.Errorf("image index %d is out of range of the graph's target", imageIndex)
+				}
+				fb = cp.framebuffers[imageIndex]
+			}
+			cmd.CmdBeginRenderPass(cp.renderPass, fb, cp.extent, vk.SubpassContentsInline, cp.clearValues...)
+		}
+
+		if cp.pass.Execute != nil {
+			cp.pass.Execute(cmd, ctx)
+		}
+
+		if cp.pass.Kind == GraphicsPass {
+			cmd.CmdEndRenderPass()
+		}
+
+		if g.Profiler != nil {
+			g.Profiler.End(cmd)
+		}
+	}
+
+	g.recordBarriers(cmd, g.final, imageIndex)
+
+	return nil
+}
+
+// Image returns the image backing the resource
+func (c *RenderGraphContext) Image(r *RenderGraphResource) *Image {
+	return c.Graph.image(r, c.ImageIndex)
+}
+
+// View returns the image view of the resource, which covers all aspects of the image
+func (c *RenderGraphContext) View(r *RenderGraphResource) *ImageView {
+	return c.Graph.view(r, c.ImageIndex)
+}
+
+// SampledView returns a view of the resource suitable for sampling, which for depth/stencil images
+// only includes the depth aspect
+func (c *RenderGraphContext) SampledView(r *RenderGraphResource) *ImageView {
+	if r.physical != nil && r.physical.sampledView != nil {
+		return r.physical.sampledView
+	}
+	return c.View(r)
+}
+
+// Buffer returns the buffer backing the resource
+func (c *RenderGraphContext) Buffer(r *RenderGraphResource) *BufferResource {
+	return c.Graph.buffer(r)
+}
+
+// Pipeline returns a pipeline added to the pass with AddPipeline
+func (c *RenderGraphContext) Pipeline(name string) vk.Pipeline {
+	if c.Pass.compiled == nil {
+		return vk.NullPipeline
+	}
+	return c.Pass.compiled.pipelines[name]
+}
+
+// Image returns the image backing the resource, the backbuffer is not available outside of Execute
+func (g *RenderGraph) Image(r *RenderGraphResource) *Image {
+	return g.image(r, -1)
+}
+
+// View returns the image view of the resource, see RenderGraphContext.View
+func (g *RenderGraph) View(r *RenderGraphResource) *ImageView {
+	return g.view(r, -1)
+}
+
+// SampledView returns a view of the resource suitable for sampling, see RenderGraphContext.SampledView
+func (g *RenderGraph) SampledView(r *RenderGraphResource) *ImageView {
+	if r.physical != nil && r.physical.sampledView != nil {
+		return r.physical.sampledView
+	}
+	return g.view(r, -1)
+}
+
+// Buffer returns the buffer backing the resource
+func (g *RenderGraph) Buffer(r *RenderGraphResource) *BufferResource {
+	return g.buffer(r)
+}
+
+// release destroys everything created when the graph was compiled
+func (g *RenderGraph) release() {
+	for _, cp := range g.order {
+		for _, p := range cp.pipelines {
+			vk.DestroyPipeline(g.Device.VKDevice, p, nil)
+		}
+		cp.pipelines = nil
+		for _, fb := range cp.framebuffers {
+			if fb != vk.NullFramebuffer {
+				vk.DestroyFramebuffer(g.Device.VKDevice, fb, nil)
+			}
+		}
+		cp.framebuffers = nil
+		if cp.renderPass != vk.NullRenderPass {
+			vk.DestroyRenderPass(g.Device.VKDevice, cp.renderPass, nil)
+			cp.renderPass = vk.NullRenderPass
+		}
+	}
+
+	for _, ph := range g.physical {
+		if ph.sampledView != nil {
+			ph.sampledView.Destroy()
+			ph.sampledView = nil
+		}
+		if ph.view != nil && !ph.external {
+			ph.view.Destroy()
+			ph.view = nil
+		}
+		if ph.image != nil && !ph.external {
+			ph.image.Destroy()
+			ph.image = nil
+		}
+		ph.buffer = nil
+	}
+
+	if g.bufferPool != nil {
+		g.bufferPool.Destroy()
+		g.bufferPool = nil
+	}
+
+	g.compiled = false
+}
+
+// Destroy destroys everything created by the graph along with the pipeline configs added to its passes,
+// imported resources are not destroyed
+func (g *RenderGraph) Destroy() {
+	g.release()
+	for _, p := range g.passes {
+		for _, config := range p.pipelineConfigs {
+			config.Destroy()
+		}
+	}
+}
+
+// WriteDOT writes the graph in the Graphviz DOT format, passes are boxes and resources are ellipses. Once the
+// graph is compiled culled passes are dashed, the number of barriers recorded before a pass is shown and
+// resources are labelled with the physical resource they were assigned, transient resources with the same
+// physical resource reuse the same image or buffer.
+func (g *RenderGraph) WriteDOT(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("digraph rendergraph {\n")
+	b.WriteString("\trankdir=LR;\n")
+
+	for _, p := range g.passes {
+		label := fmt.Sprintf("%s\\n%s", p.Name, p.Kind)
+		style := "solid"
+		if p.compiled != nil {
+			label += fmt.Sprintf("\\n#%d", p.compiled.index)
+			if n := len(p.compiled.barriers); n > 0 {
+				label += fmt.Sprintf(", %d barriers", n)
+			}
+		} else if g.compiled {
+			label += "\\nculled"
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "\t%s [shape=box, style=%s, label=\"%s\"];\n", dotID("pass", p.Name), style, label)
+	}
+
+	for _, r := range g.resources {
+		kind := "buffer"
+		if r.isImage {
+			kind = "image"
+		}
+		switch {
+		case r.backbuffer:
+			kind = "backbuffer"
+		case r.transient:
+			kind = "transient " + kind
+		default:
+			kind = "imported " + kind
+		}
+		label := fmt.Sprintf("%s\\n%s", r.Name, kind)
+		if g.compiled && r.physical != nil {
+			label += fmt.Sprintf("\\nphysical %d", r.physical.index)
+		}
+		style := "solid"
+		if r.transient {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "\t%s [shape=ellipse, style=%s, label=\"%s\"];\n", dotID("resource", r.Name), style, label)
+	}
+
+	for _, p := range g.passes {
+		for _, a := range p.accesses {
+			from, to := dotID("resource", a.resource.Name), dotID("pass", p.Name)
+			if a.usage.IsWrite() {
+				from, to = to, from
+			}
+			fmt.Fprintf(&b, "\t%s -> %s [label=\"%s\"];\n", from, to, a.usage)
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// DOT returns the graph in the Graphviz DOT format, see WriteDOT
+func (g *RenderGraph) DOT() string {
+	var sb strings.Builder
+	g.WriteDOT(&sb)
+	return sb.String()
+}
+
+func dotID(kind, name string) string {
+	return fmt.Sprintf("\"%s:%s\"", kind, strings.ReplaceAll(name, "\"", "\\\""))
+}
@@ -0,0 +1,157 @@
+package vkg
+
+import (
+	"strings"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestRenderGraphPlan(t *testing.T) {
+	g := (&ResourceManager{}).NewRenderGraph()
+
+	backbuffer := g.Backbuffer()
+	gbuffer := g.CreateImage("gbuffer", vk.FormatR16g16b16a16Sfloat, vk.Extent2D{}, 0)
+	depth := g.CreateImage("depth", vk.FormatD32Sfloat, vk.Extent2D{}, 0)
+	bloom := g.CreateImage("bloom", vk.FormatR16g16b16a16Sfloat, vk.Extent2D{}, 0)
+	unused := g.CreateImage("unused", vk.FormatR8g8b8a8Unorm, vk.Extent2D{}, 0)
+
+	g.AddPass("geometry", GraphicsPass, nil).ClearColor(gbuffer, 0, 0, 0, 1).ClearDepth(depth, 1, 0)
+	g.AddPass("debug", GraphicsPass, nil).ClearColor(unused, 0, 0, 0, 1)
+	g.AddPass("bloom", ComputePass, nil).Sampled(gbuffer).Write(bloom, UsageStorageWrite)
+	g.AddPass("composite", GraphicsPass, nil).Sampled(bloom).ClearColor(backbuffer, 0, 0, 0, 1)
+
+	err := g.plan(RenderGraphTarget{Extent: vk.Extent2D{Width: 640, Height: 480}, Format: vk.FormatB8g8r8a8Unorm})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !g.passes[1].Culled() {
+		t.Error("expected the debug pass to be culled")
+	}
+	if len(g.order) != 3 {
+		t.Fatalf("expected 3 passes, got %d", len(g.order))
+	}
+
+	// Bloom is written while the gbuffer is read so they must not share an image
+	if gbuffer.physical == bloom.physical {
+		t.Error("resources with overlapping lifetimes must not share an image")
+	}
+
+	geometry, bloomPass, composite := g.order[0], g.order[1], g.order[2]
+	if len(geometry.attachments) != 2 || geometry.attachments[1].resource != depth {
+		t.Fatalf("expected color then depth attachments, got %+v", geometry.attachments)
+	}
+	if geometry.attachments[1].storeOp != vk.AttachmentStoreOpDontCare {
+		t.Error("depth is not used after the geometry pass so should not be stored")
+	}
+	if geometry.attachments[0].storeOp != vk.AttachmentStoreOpStore {
+		t.Error("the gbuffer is read by a later pass so should be stored")
+	}
+
+	// gbuffer needs a transition to shader read only, bloom a transition to general
+	if len(bloomPass.barriers) != 2 {
+		t.Fatalf("expected 2 barriers before the bloom pass, got %d", len(bloomPass.barriers))
+	}
+	for _, b := range bloomPass.barriers {
+		if b.dstStages != vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit) {
+			t.Errorf("expected barrier for '%s' to only wait in the compute stage", b.resource.Name)
+		}
+		if b.resource == gbuffer && (b.srcAccess != vk.AccessFlags(vk.AccessColorAttachmentWriteBit) || b.newLayout != vk.ImageLayoutShaderReadOnlyOptimal) {
+			t.Errorf("unexpected gbuffer barrier %+v", b)
+		}
+	}
+
+	if len(composite.barriers) != 1 || composite.barriers[0].resource != bloom {
+		t.Errorf("expected a single barrier for bloom before the composite pass, got %+v", composite.barriers)
+	}
+
+	// The backbuffer is transitioned for presentation by the render pass
+	if composite.attachments[0].finalLayout != vk.ImageLayoutPresentSrc || len(g.final) != 0 {
+		t.Error("expected the composite render pass to transition the backbuffer for presentation")
+	}
+
+	dot := g.DOT()
+	if !strings.Contains(dot, "culled") || !strings.Contains(dot, "\"pass:geometry\" -> \"resource:gbuffer\"") {
+		t.Errorf("unexpected DOT output\n%s", dot)
+	}
+}
+
+func TestRenderGraphTransientReuse(t *testing.T) {
+	g := (&ResourceManager{}).NewRenderGraph()
+
+	a := g.CreateImage("a", vk.FormatR8g8b8a8Unorm, vk.Extent2D{}, 0)
+	b := g.CreateImage("b", vk.FormatR8g8b8a8Unorm, vk.Extent2D{}, 0)
+	c := g.CreateImage("c", vk.FormatR8g8b8a8Unorm, vk.Extent2D{}, 0)
+	out := g.CreateBuffer("out", 1024)
+
+	g.AddPass("write a", ComputePass, nil).Write(a, UsageStorageWrite)
+	g.AddPass("a to b", ComputePass, nil).Read(a, UsageStorageRead).Write(b, UsageStorageWrite)
+	g.AddPass("b to c", ComputePass, nil).Read(b, UsageStorageRead).Write(c, UsageStorageWrite)
+	g.AddPass("c to out", ComputePass, nil).Read(c, UsageStorageRead).Write(out, UsageStorageWrite).SideEffects = true
+
+	err := g.plan(RenderGraphTarget{Extent: vk.Extent2D{Width: 64, Height: 64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.physical != c.physical {
+		t.Error("expected c to reuse the image of a")
+	}
+	if a.physical == b.physical {
+		t.Error("a and b have overlapping lifetimes")
+	}
+
+	// c reuses a so must wait for the reads of a to complete before being written
+	barriers := g.order[2].barriers
+	found := false
+	for _, barrier := range barriers {
+		if barrier.resource == c {
+			found = true
+			if barrier.oldLayout != vk.ImageLayoutUndefined || barrier.srcStages&vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit) == 0 {
+				t.Errorf("unexpected barrier for reused image %+v", barrier)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected a barrier before the reused image is written")
+	}
+}
+
+func TestRenderGraphErrors(t *testing.T) {
+	g := (&ResourceManager{}).NewRenderGraph()
+	img := g.CreateImage("img", vk.FormatR8g8b8a8Unorm, vk.Extent2D{}, 0)
+	g.AddPass("read", ComputePass, nil).Sampled(img).SideEffects = true
+
+	if err := g.plan(RenderGraphTarget{}); err == nil {
+		t.Error("expected reading an image before it is written to fail")
+	}
+
+	g = (&ResourceManager{}).NewRenderGraph()
+	img = g.CreateImage("img", vk.FormatR8g8b8a8Unorm, vk.Extent2D{}, 0)
+	g.AddPass("color", ComputePass, nil).Color(img)
+	if err := g.plan(RenderGraphTarget{}); err == nil {
+		t.Error("expected an attachment on a compute pass to fail")
+	}
+}
+
+func TestRenderGraphExecuteRecorder(t *testing.T) {
+	g := (&ResourceManager{}).NewRenderGraph()
+	g.AddPass("dispatch", ComputePass, func(cmd CommandRecorder, ctx *RenderGraphContext) {
+		cmd.CmdDispatch(1, 1, 1)
+	}).SideEffects = true
+
+	err := g.plan(RenderGraphTarget{Extent: vk.Extent2D{Width: 64, Height: 64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := NewRecorder()
+	err = g.Execute(rec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Commands) != 1 || rec.Commands[0].Name != "CmdDispatch" {
+		t.Errorf("unexpected commands %v", rec.Commands)
+	}
+}
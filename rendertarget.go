@@ -0,0 +1,382 @@
+package vkg
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// RenderTargetConfig describes the attachments of an offscreen render target
+type RenderTargetConfig struct {
+	Extent vk.Extent2D
+
+	// ColorFormat of the color attachment, vk.FormatUndefined for a depth only target
+	ColorFormat vk.Format
+	// DepthFormat of the depth attachment, vk.FormatUndefined for a color only target
+	DepthFormat vk.Format
+
+	// Samples per pixel, defaults to vk.SampleCount1Bit. When multisampling the color
+	// attachment is resolved into a single sampled image at the end of the render pass
+	Samples vk.SampleCountFlagBits
+
+	// DepthSampled keeps the depth attachment after the render pass so it can be sampled
+	// in a later pass, for example as a shadow map
+	DepthSampled bool
+
+	// ColorUsage and DepthUsage are additional usage flags for the attachments, for example
+	// vk.ImageUsageTransferSrcBit to allow the result to be copied
+	ColorUsage vk.ImageUsageFlagBits
+	DepthUsage vk.ImageUsageFlagBits
+}
+
+// RenderTarget is an offscreen target which can be rendered to and then sampled from in a later
+// pass. The render pass clears all attachments when it begins, and leaves the color (or resolve)
+// attachment in vk.ImageLayoutShaderReadOnlyOptimal when it ends.
+type RenderTarget struct {
+	Device          *Device
+	ResourceManager *ResourceManager
+	Config          RenderTargetConfig
+
+	ColorImage     *ImageResource
+	ColorImageView *ImageView
+	// ResolveImage is the single sampled image the color attachment is resolved into
+	// when multisampling
+	ResolveImage     *ImageResource
+	ResolveImageView *ImageView
+	DepthImage       *ImageResource
+	DepthImageView   *ImageView
+	// DepthSampledView is a depth only view of the depth image, only created if the
+	// depth attachment is sampled
+	DepthSampledView *ImageView
+
+	VKRenderPass  vk.RenderPass
+	VKFramebuffer vk.Framebuffer
+}
+
+// NewRenderTarget creates an offscreen render target along with a compatible render pass and framebuffer
+func (r *ResourceManager) NewRenderTarget(config RenderTargetConfig) (*RenderTarget, error) {
+	if config.ColorFormat == vk.FormatUndefined && config.DepthFormat == vk.FormatUndefined {
+		return nil, fmt.Errorf("render target requires a color or depth format")
+	}
+	if config.DepthFormat != vk.FormatUndefined && !IsDepthFormat(config.DepthFormat) {
+		return nil, fmt.Errorf("render target depth format %d is not a depth format", config.DepthFormat)
+	}
+	if config.Samples == 0 {
+		config.Samples = vk.SampleCount1Bit
+	}
+
+	var ret RenderTarget
+	ret.Device = r.Device
+	ret.ResourceManager = r
+	ret.Config = config
+
+	err := ret.createRenderPass()
+	if err != nil {
+		return nil, err
+	}
+
+	err = ret.createAttachments()
+	if err != nil {
+		ret.Destroy()
+		return nil, err
+	}
+
+	return &ret, nil
+}
+
+// Extent returns the current size of the render target
+func (t *RenderTarget) Extent() vk.Extent2D {
+	return t.Config.Extent
+}
+
+// Multisampled indicates the color attachment is resolved into a separate image
+func (t *RenderTarget) Multisampled() bool {
+	return t.Config.Samples != vk.SampleCount1Bit
+}
+
+// SampledImage returns the image which holds the result of rendering to this target, which is
+// the resolve image when multisampling, or the depth image for depth only targets
+func (t *RenderTarget) SampledImage() *ImageResource {
+	if t.ResolveImage != nil {
+		return t.ResolveImage
+	}
+	if t.ColorImage != nil {
+		return t.ColorImage
+	}
+	return t.DepthImage
+}
+
+// SampledView returns a view which can be used to sample the result of rendering to this target
+// in a later pass, it is nil for depth only targets where the depth is not sampled.
+func (t *RenderTarget) SampledView() *ImageView {
+	if t.ResolveImageView != nil {
+		return t.ResolveImageView
+	}
+	if t.ColorImageView != nil {
+		return t.ColorImageView
+	}
+	return t.DepthSampledView
+}
+
+// VKRenderPassBeginInfo creates the info needed to begin the render pass of this target, the
+// clear values must match the attachments, color first and then depth
+func (t *RenderTarget) VKRenderPassBeginInfo(clearValues []vk.ClearValue) vk.RenderPassBeginInfo {
+	return vk.RenderPassBeginInfo{
+		SType:       vk.StructureTypeRenderPassBeginInfo,
+		RenderPass:  t.VKRenderPass,
+		Framebuffer: t.VKFramebuffer,
+		RenderArea: vk.Rect2D{
+			Offset: vk.Offset2D{X: 0, Y: 0},
+			Extent: t.Config.Extent,
+		},
+		ClearValueCount: uint32(len(clearValues)),
+		PClearValues:    clearValues,
+	}
+}
+
+// Resize recreates the attachments and framebuffer of the render target, the render pass is kept
+// so pipelines created for this target remain valid. The target must not be in use by the device.
+func (t *RenderTarget) Resize(extent vk.Extent2D) error {
+	if extent.Width == t.Config.Extent.Width && extent.Height == t.Config.Extent.Height {
+		return nil
+	}
+	t.destroyAttachments()
+	t.Config.Extent = extent
+	return t.createAttachments()
+}
+
+// Destroy the render target and all of its attachments
+func (t *RenderTarget) Destroy() {
+	t.destroyAttachments()
+	if t.VKRenderPass != vk.NullRenderPass {
+		vk.DestroyRenderPass(t.Device.VKDevice, t.VKRenderPass, nil)
+		t.VKRenderPass = vk.NullRenderPass
+	}
+}
+
+// VKRenderPassCreateInfo creates the info for a render pass compatible with this target, the color
+// attachment comes first, followed by the depth attachment and then the resolve attachment
+func (t *RenderTarget) VKRenderPassCreateInfo() vk.RenderPassCreateInfo {
+	attachments := make([]vk.AttachmentDescription, 0, 3)
+
+	var subpass = vk.SubpassDescription{
+		PipelineBindPoint: vk.PipelineBindPointGraphics,
+	}
+
+	colorFinalLayout := vk.ImageLayoutShaderReadOnlyOptimal
+	if t.Multisampled() {
+		// The multisampled attachment is discarded once it is resolved
+		colorFinalLayout = vk.ImageLayoutColorAttachmentOptimal
+	}
+
+	if t.Config.ColorFormat != vk.FormatUndefined {
+		storeOp := vk.AttachmentStoreOpStore
+		if t.Multisampled() {
+			storeOp = vk.AttachmentStoreOpDontCare
+		}
+		subpass.ColorAttachmentCount = 1
+		subpass.PColorAttachments = []vk.AttachmentReference{{
+			Attachment: uint32(len(attachments)),
+			Layout:     vk.ImageLayoutColorAttachmentOptimal,
+		}}
+		attachments = append(attachments, vk.AttachmentDescription{
+			Format:         t.Config.ColorFormat,
+			Samples:        t.Config.Samples,
+			LoadOp:         vk.AttachmentLoadOpClear,
+			StoreOp:        storeOp,
+			StencilLoadOp:  vk.AttachmentLoadOpDontCare,
+			StencilStoreOp: vk.AttachmentStoreOpDontCare,
+			InitialLayout:  vk.ImageLayoutUndefined,
+			FinalLayout:    colorFinalLayout,
+		})
+	}
+
+	if t.Config.DepthFormat != vk.FormatUndefined {
+		storeOp := vk.AttachmentStoreOpDontCare
+		finalLayout := vk.ImageLayoutDepthStencilAttachmentOptimal
+		if t.Config.DepthSampled {
+			storeOp = vk.AttachmentStoreOpStore
+			finalLayout = vk.ImageLayoutDepthStencilReadOnlyOptimal
+		}
+		subpass.PDepthStencilAttachment = &vk.AttachmentReference{
+			Attachment: uint32(len(attachments)),
+			Layout:     vk.ImageLayoutDepthStencilAttachmentOptimal,
+		}
+		attachments = append(attachments, vk.AttachmentDescription{
+			Format:         t.Config.DepthFormat,
+			Samples:        t.Config.Samples,
+			LoadOp:         vk.AttachmentLoadOpClear,
+			StoreOp:        storeOp,
+			StencilLoadOp:  vk.AttachmentLoadOpClear,
+			StencilStoreOp: vk.AttachmentStoreOpDontCare,
+			InitialLayout:  vk.ImageLayoutUndefined,
+			FinalLayout:    finalLayout,
+		})
+	}
+
+	if t.Config.ColorFormat != vk.FormatUndefined && t.Multisampled() {
+		subpass.PResolveAttachments = []vk.AttachmentReference{{
+			Attachment: uint32(len(attachments)),
+			Layout:     vk.ImageLayoutColorAttachmentOptimal,
+		}}
+		attachments = append(attachments, vk.AttachmentDescription{
+			Format:         t.Config.ColorFormat,
+			Samples:        vk.SampleCount1Bit,
+			LoadOp:         vk.AttachmentLoadOpDontCare,
+			StoreOp:        vk.AttachmentStoreOpStore,
+			StencilLoadOp:  vk.AttachmentLoadOpDontCare,
+			StencilStoreOp: vk.AttachmentStoreOpDontCare,
+			InitialLayout:  vk.ImageLayoutUndefined,
+			FinalLayout:    vk.ImageLayoutShaderReadOnlyOptimal,
+		})
+	}
+
+	// Reads of the previous frame's result must complete before it is written again, and writes
+	// must complete before the result is read by a later pass
+	dependencies := []vk.SubpassDependency{
+		{
+			SrcSubpass:      vk.SubpassExternal,
+			DstSubpass:      0,
+			SrcStageMask:    vk.PipelineStageFlags(vk.PipelineStageFragmentShaderBit),
+			SrcAccessMask:   vk.AccessFlags(vk.AccessShaderReadBit),
+			DstStageMask:    vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit | vk.PipelineStageEarlyFragmentTestsBit),
+			DstAccessMask:   vk.AccessFlags(vk.AccessColorAttachmentWriteBit | vk.AccessDepthStencilAttachmentWriteBit),
+			DependencyFlags: vk.DependencyFlags(vk.DependencyByRegionBit),
+		},
+		{
+			SrcSubpass:      0,
+			DstSubpass:      vk.SubpassExternal,
+			SrcStageMask:    vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit | vk.PipelineStageLateFragmentTestsBit),
+			SrcAccessMask:   vk.AccessFlags(vk.AccessColorAttachmentWriteBit | vk.AccessDepthStencilAttachmentWriteBit),
+			DstStageMask:    vk.PipelineStageFlags(vk.PipelineStageFragmentShaderBit),
+			DstAccessMask:   vk.AccessFlags(vk.AccessShaderReadBit),
+			DependencyFlags: vk.DependencyFlags(vk.DependencyByRegionBit),
+		},
+	}
+
+	return vk.RenderPassCreateInfo{
+		SType:           vk.StructureTypeRenderPassCreateInfo,
+		AttachmentCount: uint32(len(attachments)),
+		PAttachments:    attachments,
+		SubpassCount:    1,
+		PSubpasses:      []vk.SubpassDescription{subpass},
+		DependencyCount: uint32(len(dependencies)),
+		PDependencies:   dependencies,
+	}
+}
+
+func (t *RenderTarget) createRenderPass() error {
+	renderPassCreateInfo := t.VKRenderPassCreateInfo()
+
+	var renderPass vk.RenderPass
+	err := vk.Error(vk.CreateRenderPass(t.Device.VKDevice, &renderPassCreateInfo, nil, &renderPass))
+	if err != nil {
+		return err
+	}
+	t.VKRenderPass = renderPass
+	return nil
+}
+
+func (t *RenderTarget) newAttachment(format vk.Format, usage vk.ImageUsageFlagBits, samples vk.SampleCountFlagBits) (*ImageResource, error) {
+	return t.ResourceManager.NewImageResource(t.Config.Extent, format, vk.ImageTilingOptimal, usage,
+		vk.SharingModeExclusive, vk.MemoryPropertyDeviceLocalBit, &CreateImageOptions{Samples: samples})
+}
+
+func (t *RenderTarget) createAttachments() error {
+	var err error
+	attachments := make([]vk.ImageView, 0, 3)
+
+	if t.Config.ColorFormat != vk.FormatUndefined {
+		usage := vk.ImageUsageColorAttachmentBit | vk.ImageUsageSampledBit | t.Config.ColorUsage
+		if t.Multisampled() {
+			usage = vk.ImageUsageColorAttachmentBit | vk.ImageUsageTransientAttachmentBit
+		}
+		t.ColorImage, err = t.newAttachment(t.Config.ColorFormat, usage, t.Config.Samples)
+		if err != nil {
+			return err
+		}
+		t.ColorImageView, err = t.ColorImage.CreateImageView()
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, t.ColorImageView.VKImageView)
+	}
+
+	if t.Config.DepthFormat != vk.FormatUndefined {
+		usage := vk.ImageUsageDepthStencilAttachmentBit | t.Config.DepthUsage
+		if t.Config.DepthSampled {
+			usage |= vk.ImageUsageSampledBit
+		}
+		t.DepthImage, err = t.newAttachment(t.Config.DepthFormat, usage, t.Config.Samples)
+		if err != nil {
+			return err
+		}
+		t.DepthImageView, err = t.DepthImage.CreateImageViewWithAspectMask(FormatAspectMask(t.Config.DepthFormat))
+		if err != nil {
+			return err
+		}
+		if t.Config.DepthSampled {
+			// Only a single aspect of a depth/stencil image can be sampled
+			t.DepthSampledView, err = t.DepthImage.CreateImageViewWithAspectMask(vk.ImageAspectFlags(vk.ImageAspectDepthBit))
+			if err != nil {
+				return err
+			}
+		}
+		attachments = append(attachments, t.DepthImageView.VKImageView)
+	}
+
+	if t.Config.ColorFormat != vk.FormatUndefined && t.Multisampled() {
+		usage := vk.ImageUsageColorAttachmentBit | vk.ImageUsageSampledBit | t.Config.ColorUsage
+		t.ResolveImage, err = t.newAttachment(t.Config.ColorFormat, usage, vk.SampleCount1Bit)
+		if err != nil {
+			return err
+		}
+		t.ResolveImageView, err = t.ResolveImage.CreateImageView()
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, t.ResolveImageView.VKImageView)
+	}
+
+	fbCreateInfo := vk.FramebufferCreateInfo{
+		SType:           vk.StructureTypeFramebufferCreateInfo,
+		RenderPass:      t.VKRenderPass,
+		Layers:          1,
+		AttachmentCount: uint32(len(attachments)),
+		PAttachments:    attachments,
+		Width:           t.Config.Extent.Width,
+		Height:          t.Config.Extent.Height,
+	}
+
+	var framebuffer vk.Framebuffer
+	err = vk.Error(vk.CreateFramebuffer(t.Device.VKDevice, &fbCreateInfo, nil, &framebuffer))
+	if err != nil {
+		return err
+	}
+	t.VKFramebuffer = framebuffer
+
+	return nil
+}
+
+func (t *RenderTarget) destroyAttachments() {
+	if t.VKFramebuffer != vk.NullFramebuffer {
+		vk.DestroyFramebuffer(t.Device.VKDevice, t.VKFramebuffer, nil)
+		t.VKFramebuffer = vk.NullFramebuffer
+	}
+
+	views := []**ImageView{&t.ColorImageView, &t.ResolveImageView, &t.DepthImageView, &t.DepthSampledView}
+	for _, v := range views {
+		if *v != nil {
+			(*v).Destroy()
+			*v = nil
+		}
+	}
+
+	images := []**ImageResource{&t.ColorImage, &t.ResolveImage, &t.DepthImage}
+	for _, i := range images {
+		if *i != nil {
+			(*i).Destroy()
+			*i = nil
+		}
+	}
+}
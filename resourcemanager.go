@@ -58,13 +58,10 @@ func (p *ImageResourcePool) AllocateImage(extent vk.Extent2D, format vk.Format,
 	}
 
 	img := &ImageResource{}
-	img.VKImage = i.VKImage
-	img.Device = i.Device
-	img.VKFormat = i.VKFormat
+	img.Image = *i
 	img.Size = uint64(mr.Size)
 	img.Allocation = allocation
 	img.ResourcePool = p
-	img.Extent = extent
 
 	allocation.Object = img
 
@@ -0,0 +1,327 @@
+package vkg
+
+import (
+	"fmt"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// ResourceState is the synchronization state of an image or buffer, it records how the resource was last accessed
+// so that the barrier needed before the next access can be derived, see CommandBuffer.Require. The state is updated
+// as commands are recorded, so command buffers using the same resource must be submitted in the order they were
+// recorded.
+type ResourceState struct {
+	// Layout of an image
+	Layout vk.ImageLayout
+	// WriteStages and WriteAccess of the last write to the resource
+	WriteStages vk.PipelineStageFlags
+	WriteAccess vk.AccessFlags
+	// ReadStages which have read the resource since it was last written
+	ReadStages vk.PipelineStageFlags
+	// VisibleStages and VisibleAccess which the last write has been made visible to
+	VisibleStages vk.PipelineStageFlags
+	VisibleAccess vk.AccessFlags
+
+	// QueueFamily which owns the resource, it is nil until the resource is first required by a command buffer
+	// allocated from a known queue family
+	QueueFamily *QueueFamily
+	// Concurrent resources are shared between queue families so ownership is not tracked
+	Concurrent bool
+
+	release *resourceRelease
+}
+
+// resourceRelease is the first half of a queue family ownership transfer
+type resourceRelease struct {
+	src, dst  *QueueFamily
+	usage     ResourceUsage
+	oldLayout vk.ImageLayout
+	newLayout vk.ImageLayout
+}
+
+// Discard marks the contents of the resource as no longer needed, so the next layout transition of an image
+// can start from vk.ImageLayoutUndefined, which is cheaper on some devices
+func (s *ResourceState) Discard() {
+	s.Layout = vk.ImageLayoutUndefined
+}
+
+// Released returns true if the resource has been released to another queue family and not yet acquired
+func (s *ResourceState) Released() bool {
+	return s.release != nil
+}
+
+// access updates the state for an access to the resource, returning the barrier required before the
+// access if one is needed. Discard indicates the previous contents of an image are not needed.
+func (s *ResourceState) access(usage ResourceUsage, stages vk.PipelineStageFlags, image, discard bool) (plannedBarrier, bool) {
+	access := usage.AccessMask()
+	oldLayout, newLayout := s.Layout, s.Layout
+	if image {
+		newLayout = usage.ImageLayout()
+		if discard {
+			oldLayout = vk.ImageLayoutUndefined
+		}
+	}
+	transition := image && oldLayout != newLayout
+
+	var b plannedBarrier
+	needed := transition
+
+	if s.WriteStages != 0 && (stages&^s.VisibleStages != 0 || access&^s.VisibleAccess != 0) {
+		// Read or write after write, the previous write must be made visible
+		needed = true
+		b.srcStages |= s.WriteStages
+		b.srcAccess |= s.WriteAccess
+	}
+	if (usage.IsWrite() || transition) && s.ReadStages != 0 {
+		// Write after read only requires the reads to have completed
+		needed = true
+		b.srcStages |= s.ReadStages
+	}
+
+	b.oldLayout = oldLayout
+	b.newLayout = newLayout
+
+	if needed {
+		if b.srcStages == 0 {
+			b.srcStages = vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit)
+		}
+		b.dstStages = stages
+		b.dstAccess = access
+		s.VisibleStages |= stages
+		s.VisibleAccess |= access
+	}
+
+	if transition {
+		// A layout transition is itself a write, which is only visible to the barrier's destination
+		s.VisibleStages = stages
+		s.VisibleAccess = access
+		s.ReadStages = 0
+	}
+
+	if usage.IsWrite() {
+		s.WriteStages = stages
+		s.WriteAccess = access & vk.AccessFlags(writeAccessBits)
+		s.ReadStages = 0
+		s.VisibleStages = 0
+		s.VisibleAccess = 0
+	} else {
+		s.ReadStages |= stages
+	}
+	s.Layout = newLayout
+
+	return b, needed
+}
+
+// TrackedState returns the synchronization state of the image
+func (r *ImageResource) TrackedState() *ResourceState {
+	return &r.State
+}
+
+// TrackedState returns the synchronization state of the buffer
+func (r *BufferResource) TrackedState() *ResourceState {
+	return &r.State
+}
+
+// barrierBatch collects barriers so they can be recorded with a single vkCmdPipelineBarrier
+type barrierBatch struct {
+	srcStages vk.PipelineStageFlags
+	dstStages vk.PipelineStageFlags
+	images    []vk.ImageMemoryBarrier
+	buffers   []vk.BufferMemoryBarrier
+	// The resources of the barriers, in the same order
+	imageResources  []*ImageResource
+	bufferResources []*BufferResource
+}
+
+func (b *barrierBatch) empty() bool {
+	return len(b.images) == 0 && len(b.buffers) == 0
+}
+
+func (b *barrierBatch) reset() {
+	*b = barrierBatch{}
+}
+
+func (b *barrierBatch) contains(resource TrackedResource) bool {
+	for _, r := range b.imageResources {
+		if TrackedResource(r) == resource {
+			return true
+		}
+	}
+	for _, r := range b.bufferResources {
+		if TrackedResource(r) == resource {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *barrierBatch) add(resource TrackedResource, pb plannedBarrier, srcFamily, dstFamily uint32) error {
+	b.srcStages |= pb.srcStages
+	b.dstStages |= pb.dstStages
+
+	switch r := resource.(type) {
+	case *ImageResource:
+		b.images = append(b.images, vk.ImageMemoryBarrier{
+			SType:               vk.StructureTypeImageMemoryBarrier,
+			SrcAccessMask:       pb.srcAccess,
+			DstAccessMask:       pb.dstAccess,
+			OldLayout:           pb.oldLayout,
+			NewLayout:           pb.newLayout,
+			SrcQueueFamilyIndex: srcFamily,
+			DstQueueFamilyIndex: dstFamily,
+			Image:               r.VKImage,
+			SubresourceRange:    r.FullSubresourceRange(),
+		})
+		b.imageResources = append(b.imageResources, r)
+	case *BufferResource:
+		b.buffers = append(b.buffers, vk.BufferMemoryBarrier{
+			SType:               vk.StructureTypeBufferMemoryBarrier,
+			SrcAccessMask:       pb.srcAccess,
+			DstAccessMask:       pb.dstAccess,
+			SrcQueueFamilyIndex: srcFamily,
+			DstQueueFamilyIndex: dstFamily,
+			Buffer:              r.VKBuffer,
+			Offset:              0,
+			Size:                vk.DeviceSize(vk.WholeSize),
+		})
+		b.bufferResources = append(b.bufferResources, r)
+	default:
+		return fmt.Errorf("unsupported tracked resource %T", resource)
+	}
+	return nil
+}
+
+func isTrackedImage(resource TrackedResource) bool {
+	_, ok := resource.(*ImageResource)
+	return ok
+}
+
+// require adds the barriers needed before the resource is used by a command buffer of the specified queue family,
+// flush is called when the batch already holds a barrier for the resource, since barriers within a single
+// vkCmdPipelineBarrier are not ordered
+func (b *barrierBatch) require(family *QueueFamily, resource TrackedResource, usage ResourceUsage, stages vk.PipelineStageFlags, flush func()) error {
+	if usage == UsageNone {
+		return fmt.Errorf("a resource can not be required for no usage")
+	}
+	if stages == 0 {
+		stages = usage.StageMask()
+	}
+
+	s := resource.TrackedState()
+	image := isTrackedImage(resource)
+
+	if b.contains(resource) {
+		flush()
+	}
+
+	if s.release != nil {
+		if family == nil || family.Index != s.release.dst.Index {
+			return fmt.Errorf("resource has been released to queue family %d and must be acquired there", s.release.dst.Index)
+		}
+		// The acquire must match the release, other than the source half of the barrier which the release performed
+		rel := s.release
+		err := b.add(resource, plannedBarrier{
+			srcStages: vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit),
+			dstStages: rel.usage.StageMask(),
+			dstAccess: rel.usage.AccessMask(),
+			oldLayout: rel.oldLayout,
+			newLayout: rel.newLayout,
+		}, uint32(rel.src.Index), uint32(rel.dst.Index))
+		if err != nil {
+			return err
+		}
+		s.release = nil
+		s.QueueFamily = family
+	} else if !s.Concurrent && s.QueueFamily != nil && family != nil && s.QueueFamily.Index != family.Index {
+		return fmt.Errorf("resource is owned by queue family %d, it must be released before it is used by queue family %d", s.QueueFamily.Index, family.Index)
+	}
+
+	pb, needed := s.access(usage, stages, image, false)
+	if !s.Concurrent && family != nil {
+		s.QueueFamily = family
+	}
+	if !needed {
+		return nil
+	}
+	if b.contains(resource) {
+		// Only the acquire above can be pending for this resource
+		flush()
+	}
+	return b.add(resource, pb, vk.QueueFamilyIgnored, vk.QueueFamilyIgnored)
+}
+
+// release adds the release half of a queue family ownership transfer, the resource is transitioned for the usage
+// it will have once acquired by the destination family
+func (b *barrierBatch) release(family *QueueFamily, resource TrackedResource, dst *QueueFamily, usage ResourceUsage, flush func()) error {
+	if family == nil || dst == nil {
+		return fmt.Errorf("releasing a resource requires both a source and destination queue family")
+	}
+
+	s := resource.TrackedState()
+	if s.Concurrent {
+		return fmt.Errorf("concurrent resources do not need to be released")
+	}
+	if s.release != nil {
+		return fmt.Errorf("resource has already been released to queue family %d", s.release.dst.Index)
+	}
+	if s.QueueFamily != nil && s.QueueFamily.Index != family.Index {
+		return fmt.Errorf("resource is owned by queue family %d, not %d", s.QueueFamily.Index, family.Index)
+	}
+	if family.Index == dst.Index {
+		return nil
+	}
+
+	if b.contains(resource) {
+		flush()
+	}
+
+	pb, needed := s.access(usage, usage.StageMask(), isTrackedImage(resource), false)
+	if !needed {
+		pb.srcStages = s.WriteStages | s.ReadStages
+		if pb.srcStages == 0 {
+			pb.srcStages = vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit)
+		}
+	}
+	// The destination half of the barrier is executed by the acquire
+	pb.dstStages = vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit)
+	pb.dstAccess = 0
+
+	err := b.add(resource, pb, uint32(family.Index), uint32(dst.Index))
+	if err != nil {
+		return err
+	}
+
+	s.release = &resourceRelease{src: family, dst: dst, usage: usage, oldLayout: pb.oldLayout, newLayout: pb.newLayout}
+	s.QueueFamily = dst
+	return nil
+}
+
+// Require records the barrier needed before the resource can be used as specified and updates its tracked state.
+// Barriers are batched and recorded with a single vkCmdPipelineBarrier before the next command, or when
+// FlushBarriers is called. A resource released by another queue family is acquired by the first Require.
+func (c *CommandBuffer) Require(resource TrackedResource, usage ResourceUsage) error {
+	return c.RequireWithStages(resource, usage, 0)
+}
+
+// RequireWithStages is like Require but limits the stages which wait on the barrier, for example to only the
+// fragment shader for a sampled image, zero uses all of the stages of the usage
+func (c *CommandBuffer) RequireWithStages(resource TrackedResource, usage ResourceUsage, stages vk.PipelineStageFlags) error {
+	return c.barriers.require(c.QueueFamily, resource, usage, stages, c.FlushBarriers)
+}
+
+// Release records the release of the resource to another queue family, the resource is transitioned to the
+// layout needed for the specified usage. The resource must then be required by a command buffer of the
+// destination family, which is submitted after this command buffer with a semaphore between the two.
+func (c *CommandBuffer) Release(resource TrackedResource, dst *QueueFamily, usage ResourceUsage) error {
+	return c.barriers.release(c.QueueFamily, resource, dst, usage, c.FlushBarriers)
+}
+
+// FlushBarriers records any barriers batched by Require or Release
+func (c *CommandBuffer) FlushBarriers() {
+	if c.barriers.empty() {
+		return
+	}
+	b := c.barriers
+	c.barriers.reset()
+	c.cmdPipelineBarrier(b.srcStages, b.dstStages, 0, nil, b.buffers, b.images)
+}
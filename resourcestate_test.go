@@ -0,0 +1,97 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestResourceStateRequire(t *testing.T) {
+	compute := &QueueFamily{Index: 1}
+	particles := &BufferResource{}
+	var b barrierBatch
+	flushes := 0
+	flush := func() {
+		flushes++
+		b.reset()
+	}
+
+	require := func(r TrackedResource, usage ResourceUsage) {
+		if err := b.require(compute, r, usage, vk.PipelineStageFlags(vk.PipelineStageComputeShaderBit), flush); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first write needs no barrier as there is nothing to wait for
+	require(particles, UsageStorageWrite)
+	if !b.empty() {
+		t.Errorf("expected no barrier for the first write")
+	}
+
+	require(particles, UsageStorageRead)
+	if len(b.buffers) != 1 {
+		t.Fatalf("expected a barrier for read after write, got %d", len(b.buffers))
+	}
+	barrier := b.buffers[0]
+	if barrier.SrcAccessMask != vk.AccessFlags(vk.AccessShaderWriteBit) || barrier.DstAccessMask != vk.AccessFlags(vk.AccessShaderReadBit) {
+		t.Errorf("unexpected access masks %x -> %x", barrier.SrcAccessMask, barrier.DstAccessMask)
+	}
+	flush()
+
+	// The write is already visible to the compute shader
+	require(particles, UsageStorageRead)
+	if !b.empty() {
+		t.Errorf("expected no barrier for a repeated read")
+	}
+
+	// Barriers for different resources share a single batch
+	image := &ImageResource{}
+	require(particles, UsageStorageWrite)
+	require(image, UsageStorageWrite)
+	if len(b.buffers) != 1 || len(b.images) != 1 {
+		t.Fatalf("expected a batched buffer and image barrier, got %d and %d", len(b.buffers), len(b.images))
+	}
+	if b.images[0].OldLayout != vk.ImageLayoutUndefined || b.images[0].NewLayout != vk.ImageLayoutGeneral {
+		t.Errorf("unexpected image transition %v -> %v", b.images[0].OldLayout, b.images[0].NewLayout)
+	}
+
+	// Requiring a resource already in the batch flushes it first
+	before := flushes
+	require(particles, UsageStorageRead)
+	if flushes != before+1 {
+		t.Errorf("expected the batch to be flushed")
+	}
+}
+
+func TestResourceStateOwnership(t *testing.T) {
+	graphics := &QueueFamily{Index: 0}
+	compute := &QueueFamily{Index: 1}
+	buffer := &BufferResource{}
+	var b barrierBatch
+	flush := func() { b.reset() }
+
+	if err := b.require(compute, buffer, UsageStorageWrite, 0, flush); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.require(graphics, buffer, UsageVertexBuffer, 0, flush); err == nil {
+		t.Errorf("expected an error using a resource owned by another queue family")
+	}
+
+	if err := b.release(compute, buffer, graphics, UsageVertexBuffer, flush); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.buffers) != 1 || b.buffers[0].SrcQueueFamilyIndex != 1 || b.buffers[0].DstQueueFamilyIndex != 0 {
+		t.Fatalf("expected a release barrier, got %+v", b.buffers)
+	}
+	flush()
+
+	if err := b.require(graphics, buffer, UsageVertexBuffer, 0, flush); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.buffers) != 1 || b.buffers[0].DstAccessMask != vk.AccessFlags(vk.AccessVertexAttributeReadBit) {
+		t.Fatalf("expected an acquire barrier, got %+v", b.buffers)
+	}
+	if buffer.State.QueueFamily != graphics || buffer.State.Released() {
+		t.Errorf("expected the buffer to be owned by the graphics queue family")
+	}
+}
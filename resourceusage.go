@@ -0,0 +1,206 @@
+package vkg
+
+import (
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// ResourceUsage describes how an image or buffer is accessed by a command, it is used to derive the
+// layouts, access masks and pipeline stages needed to synchronize access to the resource
+type ResourceUsage int
+
+const (
+	// UsageNone is the state of a resource which has not been accessed, its contents are undefined
+	UsageNone ResourceUsage = iota
+	UsageColorAttachment
+	UsageDepthStencilAttachment
+	UsageDepthStencilReadOnly
+	// UsageSampled is an image sampled, or a uniform texel buffer read, from a shader
+	UsageSampled
+	UsageStorageRead
+	// UsageStorageWrite is a storage image or buffer written, and possibly read, by a shader
+	UsageStorageWrite
+	UsageUniformBuffer
+	UsageVertexBuffer
+	UsageIndexBuffer
+	UsageIndirectBuffer
+	UsageTransferSrc
+	UsageTransferDst
+	UsageHostRead
+	UsageHostWrite
+	// UsagePresent is a swapchain image which is being presented
+	UsagePresent
+)
+
+type resourceUsageInfo struct {
+	Name        string
+	Access      vk.AccessFlags
+	Stages      vk.PipelineStageFlags
+	Layout      vk.ImageLayout
+	Write       bool
+	ImageUsage  vk.ImageUsageFlagBits
+	BufferUsage vk.BufferUsageFlagBits
+}
+
+const shaderStages = vk.PipelineStageVertexShaderBit | vk.PipelineStageFragmentShaderBit | vk.PipelineStageComputeShaderBit
+
+var resourceUsages = map[ResourceUsage]resourceUsageInfo{
+	UsageNone: {
+		Name:   "None",
+		Stages: vk.PipelineStageFlags(vk.PipelineStageTopOfPipeBit),
+		Layout: vk.ImageLayoutUndefined,
+	},
+	UsageColorAttachment: {
+		Name:       "ColorAttachment",
+		Access:     vk.AccessFlags(vk.AccessColorAttachmentReadBit | vk.AccessColorAttachmentWriteBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit),
+		Layout:     vk.ImageLayoutColorAttachmentOptimal,
+		Write:      true,
+		ImageUsage: vk.ImageUsageColorAttachmentBit,
+	},
+	UsageDepthStencilAttachment: {
+		Name:       "DepthStencilAttachment",
+		Access:     vk.AccessFlags(vk.AccessDepthStencilAttachmentReadBit | vk.AccessDepthStencilAttachmentWriteBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageEarlyFragmentTestsBit | vk.PipelineStageLateFragmentTestsBit),
+		Layout:     vk.ImageLayoutDepthStencilAttachmentOptimal,
+		Write:      true,
+		ImageUsage: vk.ImageUsageDepthStencilAttachmentBit,
+	},
+	UsageDepthStencilReadOnly: {
+		Name:       "DepthStencilReadOnly",
+		Access:     vk.AccessFlags(vk.AccessDepthStencilAttachmentReadBit),
+		Stages:     vk.PipelineStageFlags(vk.PipelineStageEarlyFragmentTestsBit | vk.PipelineStageLateFragmentTestsBit),
+		Layout:     vk.ImageLayoutDepthStencilReadOnlyOptimal,
+		ImageUsage: vk.ImageUsageDepthStencilAttachmentBit,
+	},
+	UsageSampled: {
+		Name:        "Sampled",
+		Access:      vk.AccessFlags(vk.AccessShaderReadBit),
+		Stages:      vk.PipelineStageFlags(shaderStages),
+		Layout:      vk.ImageLayoutShaderReadOnlyOptimal,
+		ImageUsage:  vk.ImageUsageSampledBit,
+		BufferUsage: vk.BufferUsageUniformTexelBufferBit,
+	},
+	UsageStorageRead: {
+		Name:        "StorageRead",
+		Access:      vk.AccessFlags(vk.AccessShaderReadBit),
+		Stages:      vk.PipelineStageFlags(shaderStages),
+		Layout:      vk.ImageLayoutGeneral,
+		ImageUsage:  vk.ImageUsageStorageBit,
+		BufferUsage: vk.BufferUsageStorageBufferBit,
+	},
+	UsageStorageWrite: {
+		Name:        "StorageWrite",
+		Access:      vk.AccessFlags(vk.AccessShaderReadBit | vk.AccessShaderWriteBit),
+		Stages:      vk.PipelineStageFlags(shaderStages),
+		Layout:      vk.ImageLayoutGeneral,
+		Write:       true,
+		ImageUsage:  vk.ImageUsageStorageBit,
+		BufferUsage: vk.BufferUsageStorageBufferBit,
+	},
+	UsageUniformBuffer: {
+		Name:        "UniformBuffer",
+		Access:      vk.AccessFlags(vk.AccessUniformReadBit),
+		Stages:      vk.PipelineStageFlags(shaderStages),
+		BufferUsage: vk.BufferUsageUniformBufferBit,
+	},
+	UsageVertexBuffer: {
+		Name:        "VertexBuffer",
+		Access:      vk.AccessFlags(vk.AccessVertexAttributeReadBit),
+		Stages:      vk.PipelineStageFlags(vk.PipelineStageVertexInputBit),
+		BufferUsage: vk.BufferUsageVertexBufferBit,
+	},
+	UsageIndexBuffer: {
+		Name:        "IndexBuffer",
+		Access:      vk.AccessFlags(vk.AccessIndexReadBit),
+		Stages:      vk.PipelineStageFlags(vk.PipelineStageVertexInputBit),
+		BufferUsage: vk.BufferUsageIndexBufferBit,
+	},
+	UsageIndirectBuffer: {
+		Name:        "IndirectBuffer",
+		Access:      vk.AccessFlags(vk.AccessIndirectCommandReadBit),
+		Stages:      vk.PipelineStageFlags(vk.PipelineStageDrawIndirectBit),
+		BufferUsage: vk.BufferUsageIndirectBufferBit,
+	},
+	UsageTransferSrc: {
+		Name:        "TransferSrc",
+		Access:      vk.AccessFlags(vk.AccessTransferReadBit),
+		Stages:      vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+		Layout:      vk.ImageLayoutTransferSrcOptimal,
+		ImageUsage:  vk.ImageUsageTransferSrcBit,
+		BufferUsage: vk.BufferUsageTransferSrcBit,
+	},
+	UsageTransferDst: {
+		Name:        "TransferDst",
+		Access:      vk.AccessFlags(vk.AccessTransferWriteBit),
+		Stages:      vk.PipelineStageFlags(vk.PipelineStageTransferBit),
+		Layout:      vk.ImageLayoutTransferDstOptimal,
+		Write:       true,
+		ImageUsage:  vk.ImageUsageTransferDstBit,
+		BufferUsage: vk.BufferUsageTransferDstBit,
+	},
+	UsageHostRead: {
+		Name:   "HostRead",
+		Access: vk.AccessFlags(vk.AccessHostReadBit),
+		Stages: vk.PipelineStageFlags(vk.PipelineStageHostBit),
+		Layout: vk.ImageLayoutGeneral,
+	},
+	UsageHostWrite: {
+		Name:   "HostWrite",
+		Access: vk.AccessFlags(vk.AccessHostWriteBit),
+		Stages: vk.PipelineStageFlags(vk.PipelineStageHostBit),
+		Layout: vk.ImageLayoutGeneral,
+		Write:  true,
+	},
+	UsagePresent: {
+		Name:   "Present",
+		Stages: vk.PipelineStageFlags(vk.PipelineStageBottomOfPipeBit),
+		Layout: vk.ImageLayoutPresentSrc,
+	},
+}
+
+func (u ResourceUsage) info() resourceUsageInfo {
+	return resourceUsages[u]
+}
+
+func (u ResourceUsage) String() string {
+	if i, ok := resourceUsages[u]; ok {
+		return i.Name
+	}
+	return "Unknown"
+}
+
+// AccessMask returns the memory accesses performed by this usage
+func (u ResourceUsage) AccessMask() vk.AccessFlags {
+	return u.info().Access
+}
+
+// StageMask returns the pipeline stages which access the resource for this usage, shader accesses
+// include the vertex, fragment and compute stages
+func (u ResourceUsage) StageMask() vk.PipelineStageFlags {
+	return u.info().Stages
+}
+
+// ImageLayout returns the layout an image must be in for this usage
+func (u ResourceUsage) ImageLayout() vk.ImageLayout {
+	return u.info().Layout
+}
+
+// IsWrite returns true if this usage may modify the contents of the resource
+func (u ResourceUsage) IsWrite() bool {
+	return u.info().Write
+}
+
+// IsAttachment returns true if this usage is as a render pass attachment
+func (u ResourceUsage) IsAttachment() bool {
+	return u == UsageColorAttachment || u == UsageDepthStencilAttachment || u == UsageDepthStencilReadOnly
+}
+
+// VKImageUsage returns the usage flags an image must be created with to be used this way
+func (u ResourceUsage) VKImageUsage() vk.ImageUsageFlagBits {
+	return u.info().ImageUsage
+}
+
+// VKBufferUsage returns the usage flags a buffer must be created with to be used this way
+func (u ResourceUsage) VKBufferUsage() vk.BufferUsageFlagBits {
+	return u.info().BufferUsage
+}
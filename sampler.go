@@ -0,0 +1,179 @@
+package vkg
+
+import (
+	"sync"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// SamplerConfig describes how a sampler reads from an image, identical configs share a
+// single sampler when created via Device.CreateSampler, see
+// https://www.khronos.org/registry/vulkan/specs/1.1-extensions/man/html/VkSamplerCreateInfo.html
+type SamplerConfig struct {
+	MagFilter  vk.Filter
+	MinFilter  vk.Filter
+	MipmapMode vk.SamplerMipmapMode
+
+	AddressModeU vk.SamplerAddressMode
+	AddressModeV vk.SamplerAddressMode
+	AddressModeW vk.SamplerAddressMode
+
+	// MaxAnisotropy enables anisotropic filtering when greater than 1, it is clamped
+	// to the maximum supported by the device, and disabled if the device does not
+	// support anisotropic filtering
+	MaxAnisotropy float32
+
+	// CompareEnable enables comparison against a reference value, primarily used for shadow maps
+	CompareEnable bool
+	CompareOp     vk.CompareOp
+
+	// BorderColor used when an address mode is vk.SamplerAddressModeClampToBorder
+	BorderColor vk.BorderColor
+
+	MipLodBias float32
+	MinLod     float32
+	MaxLod     float32
+
+	UnnormalizedCoordinates bool
+}
+
+// DefaultSamplerConfig returns a sampler configuration with linear filtering which repeats
+// in all directions
+func DefaultSamplerConfig() SamplerConfig {
+	return SamplerConfig{
+		MagFilter:    vk.FilterLinear,
+		MinFilter:    vk.FilterLinear,
+		MipmapMode:   vk.SamplerMipmapModeLinear,
+		AddressModeU: vk.SamplerAddressModeRepeat,
+		AddressModeV: vk.SamplerAddressModeRepeat,
+		AddressModeW: vk.SamplerAddressModeRepeat,
+		CompareOp:    vk.CompareOpAlways,
+		BorderColor:  vk.BorderColorIntOpaqueBlack,
+	}
+}
+
+// Sampler wraps a vulkan sampler which describes how shaders read image data
+type Sampler struct {
+	Device    *Device
+	Config    SamplerConfig
+	VKSampler vk.Sampler
+
+	refs int
+}
+
+// samplerCache is used to share identical samplers on a device
+type samplerCache struct {
+	lock     sync.Mutex
+	samplers map[SamplerConfig]*Sampler
+}
+
+// VKSamplerCreateInfo creates the native vulkan structure for this config, anisotropy is
+// clamped to the limits of the specified physical device
+func (s SamplerConfig) VKSamplerCreateInfo(p *PhysicalDevice) vk.SamplerCreateInfo {
+	info := vk.SamplerCreateInfo{
+		SType:                   vk.StructureTypeSamplerCreateInfo,
+		MagFilter:               s.MagFilter,
+		MinFilter:               s.MinFilter,
+		MipmapMode:              s.MipmapMode,
+		AddressModeU:            s.AddressModeU,
+		AddressModeV:            s.AddressModeV,
+		AddressModeW:            s.AddressModeW,
+		MipLodBias:              s.MipLodBias,
+		AnisotropyEnable:        vk.False,
+		MaxAnisotropy:           1,
+		CompareEnable:           vk.False,
+		CompareOp:               s.CompareOp,
+		MinLod:                  s.MinLod,
+		MaxLod:                  s.MaxLod,
+		BorderColor:             s.BorderColor,
+		UnnormalizedCoordinates: vk.False,
+	}
+
+	if s.MaxAnisotropy > 1 && p != nil && p.VKPhysicalDeviceFeatures().SamplerAnisotropy == vk.True {
+		p.VKPhysicalDeviceProperties.Limits.Deref()
+		max := p.VKPhysicalDeviceProperties.Limits.MaxSamplerAnisotropy
+		info.AnisotropyEnable = vk.True
+		info.MaxAnisotropy = s.MaxAnisotropy
+		if info.MaxAnisotropy > max {
+			info.MaxAnisotropy = max
+		}
+	}
+
+	if s.CompareEnable {
+		info.CompareEnable = vk.True
+	}
+
+	if s.UnnormalizedCoordinates {
+		info.UnnormalizedCoordinates = vk.True
+	}
+
+	return info
+}
+
+// CreateSampler returns a sampler for the specified config, samplers are cached by the device
+// so identical configs will share the same underlying vulkan sampler. Each sampler returned
+// must be destroyed once it is no longer used.
+func (d *Device) CreateSampler(config SamplerConfig) (*Sampler, error) {
+	d.samplers.lock.Lock()
+	defer d.samplers.lock.Unlock()
+
+	if d.samplers.samplers == nil {
+		d.samplers.samplers = make(map[SamplerConfig]*Sampler)
+	}
+
+	if s, ok := d.samplers.samplers[config]; ok {
+		s.refs++
+		return s, nil
+	}
+
+	createInfo := config.VKSamplerCreateInfo(d.PhysicalDevice)
+
+	var sampler vk.Sampler
+	err := vk.Error(vk.CreateSampler(d.VKDevice, &createInfo, nil, &sampler))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret Sampler
+	ret.Device = d
+	ret.Config = config
+	ret.VKSampler = sampler
+	ret.refs = 1
+
+	d.samplers.samplers[config] = &ret
+
+	return &ret, nil
+}
+
+// Destroy releases this sampler, the underlying vulkan sampler is destroyed once all
+// users of the sampler have released it
+func (s *Sampler) Destroy() {
+	c := &s.Device.samplers
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if s.refs == 0 {
+		return
+	}
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+
+	vk.DestroySampler(s.Device.VKDevice, s.VKSampler, nil)
+	s.VKSampler = vk.NullSampler
+	delete(c.samplers, s.Config)
+}
+
+func (d *Device) destroySamplers() {
+	d.samplers.lock.Lock()
+	defer d.samplers.lock.Unlock()
+
+	for _, s := range d.samplers.samplers {
+		vk.DestroySampler(d.VKDevice, s.VKSampler, nil)
+		s.VKSampler = vk.NullSampler
+		s.refs = 0
+	}
+	d.samplers.samplers = nil
+}
@@ -1,6 +1,8 @@
 package vkg
 
 import (
+	"crypto/sha256"
+	"fmt"
 	vk "github.com/vulkan-go/vulkan"
 	"io/ioutil"
 	"unsafe"
@@ -10,6 +12,15 @@ type ShaderModule struct {
 	Device         *Device
 	Description    string
 	VKShaderModule vk.ShaderModule
+
+	// File is the file the module was loaded from, which is watched for changes when shaders are hot
+	// reloaded, see GraphicsApp.EnableShaderHotReload
+	File string
+
+	reflection *ShaderReflection
+	reflectErr error
+	// codeHash identifies the code of the module, which changes when it is reloaded
+	codeHash [sha256.Size]byte
 }
 
 func (d *Device) LoadShaderModuleFromFile(file string) (*ShaderModule, error) {
@@ -17,11 +28,23 @@ func (d *Device) LoadShaderModuleFromFile(file string) (*ShaderModule, error) {
 	if err != nil {
 		return nil, err
 	}
+	ret, err := d.CreateShaderModule(data)
+	if err != nil {
+		return nil, err
+	}
+	ret.Description = file
+	ret.File = file
+	return ret, nil
+}
+
+// CreateShaderModule creates a shader module from SPIR-V code, the code is reflected as well but a
+// module which can't be reflected is still created, see Reflect
+func (d *Device) CreateShaderModule(code []byte) (*ShaderModule, error) {
 	var module vk.ShaderModule
-	err = vk.Error(vk.CreateShaderModule(d.VKDevice, &vk.ShaderModuleCreateInfo{
+	err := vk.Error(vk.CreateShaderModule(d.VKDevice, &vk.ShaderModuleCreateInfo{
 		SType:    vk.StructureTypeShaderModuleCreateInfo,
-		CodeSize: uint(len(data)),
-		PCode:    sliceUint32(data),
+		CodeSize: uint(len(code)),
+		PCode:    sliceUint32(code),
 	}, nil, &module))
 
 	if err != nil {
@@ -31,9 +54,47 @@ func (d *Device) LoadShaderModuleFromFile(file string) (*ShaderModule, error) {
 	var ret ShaderModule
 	ret.VKShaderModule = module
 	ret.Device = d
+	ret.reflection, ret.reflectErr = ReflectSPIRV(code)
+	ret.codeHash = sha256.Sum256(code)
 	return &ret, nil
 }
 
+// Reflect returns the reflection of the module's SPIR-V code, or the error which prevented it from being reflected
+func (s *ShaderModule) Reflect() (*ShaderReflection, error) {
+	return s.reflection, s.reflectErr
+}
+
+// StageReflection returns the reflection of an entry point of the module used as a pipeline stage
+func (s *ShaderModule) StageReflection(stage vk.ShaderStageFlagBits, entryPoint string) (ShaderStageReflection, error) {
+	if s.reflectErr != nil {
+		return ShaderStageReflection{}, s.reflectErr
+	}
+	return ShaderStageReflection{Reflection: s.reflection, EntryPoint: entryPoint, Stage: stage}, nil
+}
+
+// pipelineShaderStage is an entry point of a shader module used by a pipeline, it is kept so the stage
+// can be reflected and recreated when the module is reloaded
+type pipelineShaderStage struct {
+	module     *ShaderModule
+	entryPoint string
+	stage      vk.ShaderStageFlagBits
+	constants  *SpecializationConstants
+}
+
+func (s pipelineShaderStage) reflect() (ShaderStageReflection, error) {
+	ret, err := s.module.StageReflection(s.stage, s.entryPoint)
+	if err != nil {
+		return ret, fmt.Errorf("unable to reflect %s: %w", s.module.Description, err)
+	}
+	return ret, nil
+}
+
+func (s pipelineShaderStage) createInfo() vk.PipelineShaderStageCreateInfo {
+	ret := s.module.VKPipelineShaderStageCreateInfo(s.stage, s.entryPoint)
+	ret.PSpecializationInfo = s.constants.VKSpecializationInfo()
+	return ret
+}
+
 func (s *ShaderModule) VKPipelineShaderStageCreateInfo(stage vk.ShaderStageFlagBits, entryPoint string) vk.PipelineShaderStageCreateInfo {
 	var shaderStageCreateInfo = vk.PipelineShaderStageCreateInfo{}
 	shaderStageCreateInfo.SType = vk.StructureTypePipelineShaderStageCreateInfo
@@ -0,0 +1,427 @@
+package vkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// DefaultShaderReloadInterval is the default time between polls of watched shader files
+const DefaultShaderReloadInterval = 250 * time.Millisecond
+
+// ShaderChange is a watched file which has changed, with the code compiled from it
+type ShaderChange struct {
+	File    string
+	Modules []*ShaderModule
+	Code    []byte
+	Err     error
+}
+
+// ShaderReloader polls the files shader modules are loaded from and recompiles them when they change,
+// see GraphicsApp.EnableShaderHotReload
+type ShaderReloader struct {
+	// Interval is the minimum time between polls, defaults to DefaultShaderReloadInterval
+	Interval time.Duration
+
+	// Compile turns a watched file into SPIR-V, by default the file is read as SPIR-V. See GLSLCompiler
+	// for watching GLSL sources.
+	Compile func(file string) ([]byte, error)
+
+	// Report is called with the result of reloading each changed file, by default results are logged
+	Report func(file string, err error)
+
+	files    map[string]*watchedShaderFile
+	lastPoll time.Time
+}
+
+type watchedShaderFile struct {
+	modules []*ShaderModule
+	modTime time.Time
+	size    int64
+}
+
+// NewShaderReloader creates a shader reloader which isn't watching any files
+func NewShaderReloader() *ShaderReloader {
+	return &ShaderReloader{
+		Interval: DefaultShaderReloadInterval,
+		files:    make(map[string]*watchedShaderFile),
+	}
+}
+
+// Watch reloads the module when the file it was loaded from changes
+func (r *ShaderReloader) Watch(m *ShaderModule) error {
+	if m.File == "" {
+		return fmt.Errorf("shader module %s was not loaded from a file", m.Description)
+	}
+	r.WatchFile(m.File, m)
+	return nil
+}
+
+// WatchFile reloads the module when the file changes, the file is compiled with Compile so it may
+// be a source file the module was compiled from. A file which doesn't exist yet is reloaded once it
+// has been created.
+func (r *ShaderReloader) WatchFile(file string, m *ShaderModule) {
+	w, ok := r.files[file]
+	if !ok {
+		w = &watchedShaderFile{}
+		w.modTime, w.size = shaderFileStat(file)
+		r.files[file] = w
+	}
+	for _, wm := range w.modules {
+		if wm == m {
+			return
+		}
+	}
+	w.modules = append(w.modules, m)
+}
+
+// Unwatch stops reloading the module
+func (r *ShaderReloader) Unwatch(m *ShaderModule) {
+	for file, w := range r.files {
+		for i, wm := range w.modules {
+			if wm == m {
+				w.modules = append(w.modules[:i], w.modules[i+1:]...)
+				break
+			}
+		}
+		if len(w.modules) == 0 {
+			delete(r.files, file)
+		}
+	}
+}
+
+// Watching reports if the module is being watched
+func (r *ShaderReloader) Watching(m *ShaderModule) bool {
+	for _, w := range r.files {
+		for _, wm := range w.modules {
+			if wm == m {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Poll returns the watched files which have changed since the last poll, ordered by file name. Files
+// are only checked once per Interval, earlier polls return nothing.
+func (r *ShaderReloader) Poll() []ShaderChange {
+	now := time.Now()
+	if !r.due(now) {
+		return nil
+	}
+	return r.poll(now)
+}
+
+func (r *ShaderReloader) due(now time.Time) bool {
+	interval := r.Interval
+	if interval == 0 {
+		interval = DefaultShaderReloadInterval
+	}
+	return now.Sub(r.lastPoll) >= interval
+}
+
+func (r *ShaderReloader) poll(now time.Time) []ShaderChange {
+	r.lastPoll = now
+
+	var ret []ShaderChange
+	for file, w := range r.files {
+		modTime, size := shaderFileStat(file)
+		if modTime.IsZero() || (modTime.Equal(w.modTime) && size == w.size) {
+			continue
+		}
+		w.modTime, w.size = modTime, size
+
+		change := ShaderChange{File: file, Modules: append([]*ShaderModule(nil), w.modules...)}
+		change.Code, change.Err = r.compile(file)
+		ret = append(ret, change)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].File < ret[j].File })
+	return ret
+}
+
+func (r *ShaderReloader) compile(file string) ([]byte, error) {
+	if r.Compile != nil {
+		return r.Compile(file)
+	}
+	return ioutil.ReadFile(file)
+}
+
+func (r *ShaderReloader) report(file string, err error) {
+	if r.Report != nil {
+		r.Report(file, err)
+		return
+	}
+	if err != nil {
+		log.Printf("unable to reload shader %s: %v", file, err)
+		return
+	}
+	log.Printf("reloaded shader %s", file)
+}
+
+// shaderFileStat returns the modification time and size of a file, or a zero time if it can't be read
+func shaderFileStat(file string) (time.Time, int64) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+// GLSLCompiler returns a ShaderReloader.Compile function which runs glslc, or a compatible compiler,
+// with the specified arguments to compile GLSL sources. The compiler's output is returned as the error
+// if compilation fails.
+func GLSLCompiler(glslc string, args ...string) func(file string) ([]byte, error) {
+	return func(file string) ([]byte, error) {
+		cmdArgs := append(append([]string(nil), args...), "-o", "-", file)
+		cmd := exec.Command(glslc, cmdArgs...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		if err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%v\n%s", err, msg)
+		}
+		return stdout.Bytes(), nil
+	}
+}
+
+// shaderModuleState is the code of a shader module, which is swapped when the module is reloaded
+type shaderModuleState struct {
+	handle     vk.ShaderModule
+	reflection *ShaderReflection
+	reflectErr error
+	codeHash   [sha256.Size]byte
+}
+
+func (s *ShaderModule) state() shaderModuleState {
+	return shaderModuleState{handle: s.VKShaderModule, reflection: s.reflection, reflectErr: s.reflectErr, codeHash: s.codeHash}
+}
+
+func (s *ShaderModule) setState(state shaderModuleState) {
+	s.VKShaderModule = state.handle
+	s.reflection = state.reflection
+	s.reflectErr = state.reflectErr
+	s.codeHash = state.codeHash
+}
+
+// replaceCode creates a new module from the code and swaps it into the shader module, returning the
+// previous state which must be destroyed or restored by the caller
+func (s *ShaderModule) replaceCode(code []byte) (shaderModuleState, error) {
+	// Don't hand a partially written or corrupt file to the driver
+	_, err := parseSPIRV(code)
+	if err != nil {
+		return shaderModuleState{}, err
+	}
+	module, err := s.Device.CreateShaderModule(code)
+	if err != nil {
+		return shaderModuleState{}, err
+	}
+	old := s.state()
+	s.setState(module.state())
+	return old, nil
+}
+
+// EnableShaderHotReload watches the files the shader modules of the graphics pipeline configs and
+// compute pipelines were loaded from. When a file changes the module is reloaded and the pipelines
+// using it are rebuilt between frames by DrawFrameSync. If the new code can't be compiled or a
+// pipeline can't be built the error is reported and the previous pipelines are kept. Modules added
+// to the app later are watched as well, other files can be watched with ShaderReloader.WatchFile.
+func (p *GraphicsApp) EnableShaderHotReload() *ShaderReloader {
+	if p.ShaderReloader == nil {
+		p.ShaderReloader = NewShaderReloader()
+	}
+	return p.ShaderReloader
+}
+
+// AddComputePipeline adds a compute pipeline to the app, which destroys it and rebuilds it when its
+// shader is hot reloaded
+func (p *GraphicsApp) AddComputePipeline(name string, pipeline *ComputePipeline) {
+	if p.ComputePipelines == nil {
+		p.ComputePipelines = make(map[string]*ComputePipeline)
+	}
+	p.ComputePipelines[name] = pipeline
+}
+
+// reloadShaders reloads changed shader files, it must only be called while the device is idle
+func (p *GraphicsApp) reloadShaders() {
+	r := p.ShaderReloader
+	now := time.Now()
+	if !r.due(now) {
+		return
+	}
+
+	for _, m := range p.shaderModules() {
+		if m.File != "" && !r.Watching(m) {
+			r.WatchFile(m.File, m)
+		}
+	}
+
+	for _, c := range r.poll(now) {
+		err := c.Err
+		for _, m := range c.Modules {
+			if err != nil {
+				break
+			}
+			err = p.ReloadShaderModule(m, c.Code)
+		}
+		r.report(c.File, err)
+	}
+}
+
+// shaderModules returns the modules used by the graphics pipeline configs and compute pipelines
+func (p *GraphicsApp) shaderModules() []*ShaderModule {
+	var ret []*ShaderModule
+	for _, c := range p.GraphicsPipelineConfigs {
+		if g, ok := c.(*GraphicsPipelineConfig); ok {
+			for _, s := range g.stages {
+				ret = append(ret, s.module)
+			}
+		}
+	}
+	for _, c := range p.ComputePipelines {
+		if c.stage.module != nil {
+			ret = append(ret, c.stage.module)
+		}
+	}
+	return ret
+}
+
+// ReloadShaderModule replaces the code of a shader module and rebuilds the graphics pipelines and compute
+// pipelines of the app which use it. It must be called between frames while the device is idle. If any of
+// the pipelines can't be built the module and all of the pipelines are left unchanged. Variants in
+// PipelineLibrary using the module are destroyed and created again when next requested.
+func (p *GraphicsApp) ReloadShaderModule(m *ShaderModule, code []byte) error {
+	old, err := m.replaceCode(code)
+	if err != nil {
+		return err
+	}
+
+	graphics, compute, err := p.rebuildPipelines(m)
+	if err != nil {
+		vk.DestroyShaderModule(p.Device.VKDevice, m.VKShaderModule, nil)
+		m.setState(old)
+		p.refreshShaderStages(m)
+		return err
+	}
+	vk.DestroyShaderModule(p.Device.VKDevice, old.handle, nil)
+
+	for name, pipeline := range graphics {
+		vk.DestroyPipeline(p.Device.VKDevice, p.GraphicsPipelines[name], nil)
+		p.GraphicsPipelines[name] = pipeline
+	}
+	for name, pipeline := range compute {
+		c := p.ComputePipelines[name]
+		vk.DestroyPipeline(p.Device.VKDevice, c.VKPipeline, nil)
+		c.VKPipeline = pipeline
+	}
+	if p.PipelineLibrary != nil {
+		p.PipelineLibrary.EvictShaderModule(m)
+	}
+	return nil
+}
+
+// refreshShaderStages updates the stages of the pipelines using the module after it has been swapped
+func (p *GraphicsApp) refreshShaderStages(m *ShaderModule) {
+	for _, c := range p.GraphicsPipelineConfigs {
+		if g, ok := c.(*GraphicsPipelineConfig); ok && g.usesShaderModule(m) {
+			g.refreshShaderStages()
+		}
+	}
+	for _, c := range p.ComputePipelines {
+		if c.stage.module == m {
+			c.VKPipelineShaderStageCreateInfo = c.stage.createInfo()
+		}
+	}
+}
+
+// rebuildPipelines creates new pipelines for those using the module, if any fail the new pipelines
+// are destroyed
+func (p *GraphicsApp) rebuildPipelines(m *ShaderModule) (map[string]vk.Pipeline, map[string]vk.Pipeline, error) {
+	graphics := make(map[string]vk.Pipeline)
+	compute := make(map[string]vk.Pipeline)
+	fail := func(err error) (map[string]vk.Pipeline, map[string]vk.Pipeline, error) {
+		for _, pipeline := range graphics {
+			vk.DestroyPipeline(p.Device.VKDevice, pipeline, nil)
+		}
+		for _, pipeline := range compute {
+			vk.DestroyPipeline(p.Device.VKDevice, pipeline, nil)
+		}
+		return nil, nil, err
+	}
+
+	p.refreshShaderStages(m)
+
+	for name, c := range p.GraphicsPipelineConfigs {
+		g, ok := c.(*GraphicsPipelineConfig)
+		if !ok || !g.usesShaderModule(m) {
+			continue
+		}
+		err := checkReloadedLayout(g.Reflect, g.DescriptorSetLayouts, g.stages...)
+		if err != nil {
+			return fail(fmt.Errorf("graphics pipeline '%s': %w", name, err))
+		}
+		if _, ok := p.GraphicsPipelines[name]; !ok {
+			// The pipeline hasn't been created yet
+			continue
+		}
+		pipeline, err := p.createGraphicsPipeline(name, g)
+		if err != nil {
+			return fail(err)
+		}
+		graphics[name] = pipeline
+	}
+
+	for name, c := range p.ComputePipelines {
+		if c.stage.module != m {
+			continue
+		}
+		err := checkReloadedLayout(c.Reflect, nil, c.stage)
+		if err != nil {
+			return fail(fmt.Errorf("compute pipeline '%s': %w", name, err))
+		}
+		if c.VKPipeline == vk.NullPipeline {
+			continue
+		}
+		rebuilt := *c
+		err = p.Device.CreateComputePipelines(p.PipelineCache, &rebuilt)
+		if err != nil {
+			return fail(fmt.Errorf("error creating compute pipeline '%s': %w", name, err))
+		}
+		compute[name] = rebuilt.VKPipeline
+	}
+
+	return graphics, compute, nil
+}
+
+// checkReloadedLayout checks that reloaded shader stages are still compatible with each other, with
+// the descriptor set layouts of the pipeline, which are not recreated, and with their specialization
+// constants. Stages which can't be reflected are not checked.
+func checkReloadedLayout(reflect func() (*PipelineReflection, error), layouts []*DescriptorSetLayout, stages ...pipelineShaderStage) error {
+	for _, s := range stages {
+		err := s.validateConstants()
+		if err != nil {
+			return err
+		}
+	}
+	r, err := reflect()
+	if conflicts, ok := err.(ReflectionConflicts); ok {
+		return conflicts
+	}
+	if err != nil || len(layouts) == 0 {
+		return nil
+	}
+	return r.CheckDescriptorSetLayouts(layouts)
+}
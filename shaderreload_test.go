@@ -0,0 +1,73 @@
+package vkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShaderReloaderPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vkg-shaders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	vert := filepath.Join(dir, "vert.spv")
+	frag := filepath.Join(dir, "frag.spv")
+	if err := ioutil.WriteFile(vert, []byte("vert"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vertModule := &ShaderModule{File: vert}
+	fragModule := &ShaderModule{File: frag}
+
+	r := NewShaderReloader()
+	if err := r.Watch(vertModule); err != nil {
+		t.Fatal(err)
+	}
+	// The fragment shader doesn't exist yet
+	if err := r.Watch(fragModule); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Watch(&ShaderModule{}); err == nil {
+		t.Errorf("expected an error watching a module without a file")
+	}
+
+	now := time.Now()
+	if changes := r.poll(now); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+	if r.due(now.Add(time.Millisecond)) || !r.due(now.Add(DefaultShaderReloadInterval)) {
+		t.Errorf("polls should be limited to the interval")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := ioutil.WriteFile(vert, []byte("vert2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(vert, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(frag, []byte("frag"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := r.poll(now)
+	if len(changes) != 2 || changes[0].File != frag || changes[1].File != vert {
+		t.Fatalf("expected both files to change, got %+v", changes)
+	}
+	if string(changes[1].Code) != "vert2" || len(changes[1].Modules) != 1 || changes[1].Modules[0] != vertModule {
+		t.Errorf("unexpected change %+v", changes[1])
+	}
+	if changes := r.poll(now); len(changes) != 0 {
+		t.Errorf("expected changes to be reported once, got %+v", changes)
+	}
+
+	r.Unwatch(fragModule)
+	if r.Watching(fragModule) || !r.Watching(vertModule) {
+		t.Errorf("expected only the vertex module to be watched")
+	}
+}
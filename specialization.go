@@ -0,0 +1,183 @@
+package vkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// SpecializationConstants are the values of the specialization constants of a shader stage, which allow
+// a single SPIR-V module to be compiled into pipelines with different constants, such as workgroup sizes.
+// Every value is 32 bits, bools are stored as vk.Bool32.
+type SpecializationConstants struct {
+	values map[uint32]specializationValue
+}
+
+type specializationValue struct {
+	typ  ScalarType
+	bits uint32
+}
+
+// NewSpecializationConstants creates an empty set of specialization constants
+func NewSpecializationConstants() *SpecializationConstants {
+	return &SpecializationConstants{values: make(map[uint32]specializationValue)}
+}
+
+func (s *SpecializationConstants) set(id uint32, typ ScalarType, bits uint32) *SpecializationConstants {
+	if s.values == nil {
+		s.values = make(map[uint32]specializationValue)
+	}
+	s.values[id] = specializationValue{typ: typ, bits: bits}
+	return s
+}
+
+// SetBool sets the bool constant with the specified constant ID
+func (s *SpecializationConstants) SetBool(id uint32, value bool) *SpecializationConstants {
+	if value {
+		return s.set(id, ScalarBool, uint32(vk.True))
+	}
+	return s.set(id, ScalarBool, uint32(vk.False))
+}
+
+// SetInt32 sets the int constant with the specified constant ID
+func (s *SpecializationConstants) SetInt32(id uint32, value int32) *SpecializationConstants {
+	return s.set(id, ScalarInt, uint32(value))
+}
+
+// SetUint32 sets the uint constant with the specified constant ID
+func (s *SpecializationConstants) SetUint32(id uint32, value uint32) *SpecializationConstants {
+	return s.set(id, ScalarUint, value)
+}
+
+// SetFloat32 sets the float constant with the specified constant ID
+func (s *SpecializationConstants) SetFloat32(id uint32, value float32) *SpecializationConstants {
+	return s.set(id, ScalarFloat, math.Float32bits(value))
+}
+
+// Len returns the number of constants which have been set
+func (s *SpecializationConstants) Len() int {
+	return len(s.values)
+}
+
+// IDs returns the IDs of the constants which have been set in ascending order
+func (s *SpecializationConstants) IDs() []uint32 {
+	ret := make([]uint32, 0, len(s.values))
+	for id := range s.values {
+		ret = append(ret, id)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// Validate checks that every constant is declared by the shader with the same type, the declared
+// constants are typically ShaderReflection.SpecializationConstants
+func (s *SpecializationConstants) Validate(declared []SpecializationConstant) error {
+	for _, id := range s.IDs() {
+		v := s.values[id]
+		d, ok := findSpecializationConstant(declared, id)
+		if !ok {
+			return fmt.Errorf("shader has no specialization constant %d", id)
+		}
+		if d.Type != v.typ || d.Width != 32 {
+			return fmt.Errorf("specialization constant %d (%s) is a %d bit %s not a 32 bit %s", id, d.Name, d.Width, d.Type, v.typ)
+		}
+	}
+	return nil
+}
+
+// Data returns the map entries and data of the constants ordered by ID
+func (s *SpecializationConstants) Data() ([]vk.SpecializationMapEntry, []byte) {
+	ids := s.IDs()
+	entries := make([]vk.SpecializationMapEntry, len(ids))
+	data := make([]byte, len(ids)*4)
+	for i, id := range ids {
+		entries[i] = vk.SpecializationMapEntry{
+			ConstantID: id,
+			Offset:     uint32(i * 4),
+			Size:       4,
+		}
+		binary.LittleEndian.PutUint32(data[i*4:], s.values[id].bits)
+	}
+	return entries, data
+}
+
+// VKSpecializationInfo returns the specialization info for a shader stage, or nil if no constants have
+// been set
+func (s *SpecializationConstants) VKSpecializationInfo() *vk.SpecializationInfo {
+	if s == nil || len(s.values) == 0 {
+		return nil
+	}
+	entries, data := s.Data()
+	return &vk.SpecializationInfo{
+		MapEntryCount: uint32(len(entries)),
+		PMapEntries:   entries,
+		DataSize:      uint(len(data)),
+		PData:         unsafe.Pointer(&data[0]),
+	}
+}
+
+// validateConstants checks the specialization constants of the stage against the shader, if it could be reflected
+func (s pipelineShaderStage) validateConstants() error {
+	if s.constants == nil || s.module.reflection == nil {
+		return nil
+	}
+	err := s.constants.Validate(s.module.reflection.SpecializationConstants)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.module.Description, err)
+	}
+	return nil
+}
+
+// SetSpecializationConstants sets the specialization constants of the stages of the specified type added
+// with AddShaderStage or AddShaderStageFromFile, replacing any set previously. The constants are validated
+// against the shader if it could be reflected.
+func (g *GraphicsPipelineConfig) SetSpecializationConstants(stage vk.ShaderStageFlagBits, constants *SpecializationConstants) error {
+	if len(g.stages) != len(g.ShaderStages) {
+		return fmt.Errorf("specialization constants can't be set on shader stages set with SetShaderStages")
+	}
+	found := false
+	for i := range g.stages {
+		s := &g.stages[i]
+		if s.stage != stage {
+			continue
+		}
+		found = true
+		candidate := *s
+		candidate.constants = constants
+		err := candidate.validateConstants()
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("pipeline has no %s stage", shaderStagesString(vk.ShaderStageFlags(stage)))
+	}
+	for i := range g.stages {
+		if g.stages[i].stage == stage {
+			g.stages[i].constants = constants
+			g.ShaderStages[i] = g.stages[i].createInfo()
+		}
+	}
+	return nil
+}
+
+// SetSpecializationConstants sets the specialization constants of the shader stage, it must be called after
+// SetShaderStage. The constants are validated against the shader if it could be reflected.
+func (c *ComputePipeline) SetSpecializationConstants(constants *SpecializationConstants) error {
+	if c.stage.module == nil {
+		return fmt.Errorf("compute pipeline has no shader stage")
+	}
+	candidate := c.stage
+	candidate.constants = constants
+	err := candidate.validateConstants()
+	if err != nil {
+		return err
+	}
+	c.stage = candidate
+	c.VKPipelineShaderStageCreateInfo = c.stage.createInfo()
+	return nil
+}
@@ -0,0 +1,80 @@
+package vkg
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestSpecializationConstants(t *testing.T) {
+	if NewSpecializationConstants().VKSpecializationInfo() != nil {
+		t.Errorf("expected no specialization info without constants")
+	}
+
+	c := NewSpecializationConstants().
+		SetFloat32(7, 0.5).
+		SetBool(3, true).
+		SetInt32(1, -2)
+
+	entries, data := c.Data()
+	if len(entries) != 3 || len(data) != 12 {
+		t.Fatalf("unexpected entries %+v data %v", entries, data)
+	}
+	for i, id := range []uint32{1, 3, 7} {
+		if entries[i] != (vk.SpecializationMapEntry{ConstantID: id, Offset: uint32(i * 4), Size: 4}) {
+			t.Errorf("unexpected entry %+v", entries[i])
+		}
+	}
+	if int32(binary.LittleEndian.Uint32(data[0:])) != -2 ||
+		binary.LittleEndian.Uint32(data[4:]) != uint32(vk.True) ||
+		math.Float32frombits(binary.LittleEndian.Uint32(data[8:])) != 0.5 {
+		t.Errorf("unexpected data %v", data)
+	}
+
+	vertex, err := ReflectSPIRV(vertexModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// COUNT is int constant 1 and ENABLED is bool constant 3
+	valid := NewSpecializationConstants().SetInt32(1, 3).SetBool(3, false)
+	if err := valid.Validate(vertex.SpecializationConstants); err != nil {
+		t.Errorf("expected constants to be valid, got %v", err)
+	}
+	if err := NewSpecializationConstants().SetUint32(1, 3).Validate(vertex.SpecializationConstants); err == nil {
+		t.Errorf("expected an error for a uint value of an int constant")
+	}
+	if err := c.Validate(vertex.SpecializationConstants); err == nil {
+		t.Errorf("expected an error for an undeclared constant")
+	}
+}
+
+func TestComputePipelineSpecializationConstants(t *testing.T) {
+	reflection, err := ReflectSPIRV(computeModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shader := &ShaderModule{Description: "compute", reflection: reflection}
+
+	p := &ComputePipeline{}
+	if err := p.SetSpecializationConstants(NewSpecializationConstants()); err == nil {
+		t.Errorf("expected an error without a shader stage")
+	}
+	p.SetShaderStage("main", shader)
+
+	if err := p.SetSpecializationConstants(NewSpecializationConstants().SetFloat32(0, 1)); err == nil {
+		t.Errorf("expected an error for a float value of the uint local size constant")
+	}
+	if p.VKPipelineShaderStageCreateInfo.PSpecializationInfo != nil {
+		t.Errorf("invalid constants should not be applied")
+	}
+
+	if err := p.SetSpecializationConstants(NewSpecializationConstants().SetUint32(0, 64)); err != nil {
+		t.Fatal(err)
+	}
+	info := p.VKPipelineShaderStageCreateInfo.PSpecializationInfo
+	if info == nil || info.MapEntryCount != 1 || info.DataSize != 4 {
+		t.Errorf("unexpected specialization info %+v", info)
+	}
+}
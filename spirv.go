@@ -0,0 +1,991 @@
+package vkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// SPIR-V opcodes and enumerants used for reflection, see https://registry.khronos.org/SPIR-V/specs/unified1/SPIRV.html
+const (
+	spvMagic = 0x07230203
+
+	spvOpName               = 5
+	spvOpEntryPoint         = 15
+	spvOpExecutionMode      = 16
+	spvOpTypeVoid           = 19
+	spvOpTypeBool           = 20
+	spvOpTypeInt            = 21
+	spvOpTypeFloat          = 22
+	spvOpTypeVector         = 23
+	spvOpTypeMatrix         = 24
+	spvOpTypeImage          = 25
+	spvOpTypeSampler        = 26
+	spvOpTypeSampledImage   = 27
+	spvOpTypeArray          = 28
+	spvOpTypeRuntimeArray   = 29
+	spvOpTypeStruct         = 30
+	spvOpTypePointer        = 32
+	spvOpConstantTrue       = 41
+	spvOpConstantFalse      = 42
+	spvOpConstant           = 43
+	spvOpConstantComposite  = 44
+	spvOpSpecConstantTrue   = 48
+	spvOpSpecConstantFalse  = 49
+	spvOpSpecConstant       = 50
+	spvOpSpecConstantComp   = 51
+	spvOpVariable           = 59
+	spvOpDecorate           = 71
+	spvOpMemberDecorate     = 72
+	spvOpExecutionModeID    = 331
+	spvOpTypeAccelStructure = 5341
+
+	spvDecorationSpecID        = 1
+	spvDecorationBlock         = 2
+	spvDecorationBufferBlock   = 3
+	spvDecorationRowMajor      = 4
+	spvDecorationArrayStride   = 6
+	spvDecorationMatrixStride  = 7
+	spvDecorationBuiltIn       = 11
+	spvDecorationNonWritable   = 24
+	spvDecorationLocation      = 30
+	spvDecorationBinding       = 33
+	spvDecorationDescriptorSet = 34
+	spvDecorationOffset        = 35
+
+	spvBuiltInWorkgroupSize = 25
+
+	spvExecutionModeLocalSize   = 17
+	spvExecutionModeLocalSizeID = 38
+
+	spvStorageUniformConstant = 0
+	spvStorageInput           = 1
+	spvStorageUniform         = 2
+	spvStoragePushConstant    = 9
+	spvStorageStorageBuffer   = 12
+
+	spvDimBuffer      = 5
+	spvDimSubpassData = 6
+)
+
+// ScalarType is the type of a scalar, or the components of a vector, in a shader
+type ScalarType int
+
+const (
+	ScalarUnknown ScalarType = iota
+	ScalarBool
+	ScalarInt
+	ScalarUint
+	ScalarFloat
+)
+
+func (s ScalarType) String() string {
+	switch s {
+	case ScalarBool:
+		return "bool"
+	case ScalarInt:
+		return "int"
+	case ScalarUint:
+		return "uint"
+	case ScalarFloat:
+		return "float"
+	}
+	return "unknown"
+}
+
+// ShaderReflection describes the interface of a SPIR-V shader module
+type ShaderReflection struct {
+	EntryPoints []EntryPoint
+	// Bindings are the descriptors declared by the module ordered by set and binding, their stages are
+	// those of all of the module's entry points
+	Bindings []DescriptorBinding
+	// PushConstants are the ranges of the module's push constant blocks
+	PushConstants []PushConstantRange
+	// SpecializationConstants are ordered by constant ID
+	SpecializationConstants []SpecializationConstant
+}
+
+// EntryPoint is an entry point of a shader module
+type EntryPoint struct {
+	Name  string
+	Stage vk.ShaderStageFlagBits
+	// LocalSize is the workgroup size of a compute entry point
+	LocalSize [3]uint32
+	// LocalSizeSpecIDs are the specialization constant IDs which override each dimension of LocalSize,
+	// or -1 for dimensions which can not be specialized
+	LocalSizeSpecIDs [3]int
+	// Inputs are the user defined input variables of the entry point ordered by location, for a vertex
+	// shader they are the vertex attributes
+	Inputs []ShaderInput
+}
+
+// DescriptorBinding is a descriptor declared by a shader
+type DescriptorBinding struct {
+	Set     uint32
+	Binding uint32
+	// Name is the name of the variable, or of its block type if the variable is anonymous
+	Name string
+	Type vk.DescriptorType
+	// Count is the number of descriptors in an array, or 0 for a runtime sized array
+	Count  uint32
+	Stages vk.ShaderStageFlags
+	// Size is the size in bytes of a uniform or storage block, excluding any runtime sized array
+	Size uint32
+	// ReadOnly is true for storage buffers and images which are not written
+	ReadOnly bool
+}
+
+// PushConstantRange is the range of push constants used by a shader
+type PushConstantRange struct {
+	Name   string
+	Offset uint32
+	Size   uint32
+	Stages vk.ShaderStageFlags
+}
+
+// SpecializationConstant is a constant whose value can be specified when a pipeline is created
+type SpecializationConstant struct {
+	ID   uint32
+	Name string
+	Type ScalarType
+	// Width is the width of the constant in bits
+	Width uint32
+	// Default is the raw bits of the constant's default value
+	Default uint64
+	Stages  vk.ShaderStageFlags
+}
+
+// ShaderInput is an input variable of a shader stage, variables occupying several locations such as
+// matrices and arrays have an input for each location
+type ShaderInput struct {
+	Location uint32
+	Name     string
+	Format   vk.Format
+	// Size is the size in bytes of the input's format
+	Size uint32
+}
+
+// EntryPoint returns the entry point with the specified name
+func (r *ShaderReflection) EntryPoint(name string) (*EntryPoint, bool) {
+	for i := range r.EntryPoints {
+		if r.EntryPoints[i].Name == name {
+			return &r.EntryPoints[i], true
+		}
+	}
+	return nil, false
+}
+
+// Binding returns the binding with the specified name
+func (r *ShaderReflection) Binding(name string) (*DescriptorBinding, bool) {
+	return findBinding(r.Bindings, name)
+}
+
+// SpecializationConstant returns the specialization constant with the specified ID
+func (r *ShaderReflection) SpecializationConstant(id uint32) (*SpecializationConstant, bool) {
+	return findSpecializationConstant(r.SpecializationConstants, id)
+}
+
+func findBinding(bindings []DescriptorBinding, name string) (*DescriptorBinding, bool) {
+	for i := range bindings {
+		if bindings[i].Name == name {
+			return &bindings[i], true
+		}
+	}
+	return nil, false
+}
+
+func findSpecializationConstant(constants []SpecializationConstant, id uint32) (*SpecializationConstant, bool) {
+	for i := range constants {
+		if constants[i].ID == id {
+			return &constants[i], true
+		}
+	}
+	return nil, false
+}
+
+type spirvType struct {
+	op uint32
+	// width and signed describe scalars
+	width, signed uint32
+	// elem is the component type of vectors and matrices, the element type of arrays and the type
+	// pointed to by pointers
+	elem uint32
+	// count is the number of components of a vector or columns of a matrix
+	count uint32
+	// length is the id of the constant holding an array's length
+	length  uint32
+	members []uint32
+	storage uint32
+	// dim and sampled describe images
+	dim, sampled uint32
+}
+
+type spirvConstant struct {
+	typ        uint32
+	value      []uint32
+	spec       bool
+	components []uint32
+}
+
+type spirvVariable struct {
+	id, typ, storage uint32
+}
+
+type spirvEntryPoint struct {
+	model        uint32
+	id           uint32
+	name         string
+	interfaceIDs []uint32
+}
+
+type spirvExecutionMode struct {
+	entry, mode uint32
+	operands    []uint32
+	ids         bool
+}
+
+type spirvModule struct {
+	names             map[uint32]string
+	decorations       map[uint32]map[uint32][]uint32
+	memberDecorations map[uint32]map[uint32]map[uint32][]uint32
+	types             map[uint32]*spirvType
+	constants         map[uint32]*spirvConstant
+	constantOrder     []uint32
+	variables         []spirvVariable
+	entryPoints       []spirvEntryPoint
+	executionModes    []spirvExecutionMode
+}
+
+// ReflectSPIRV parses SPIR-V code and describes the interface of its entry points
+func ReflectSPIRV(code []byte) (*ShaderReflection, error) {
+	m, err := parseSPIRV(code)
+	if err != nil {
+		return nil, err
+	}
+	return m.reflect()
+}
+
+func parseSPIRV(code []byte) (*spirvModule, error) {
+	if len(code)%4 != 0 || len(code) < 20 {
+		return nil, fmt.Errorf("spir-v code must be a multiple of 4 bytes and at least 20 bytes long")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	switch {
+	case binary.LittleEndian.Uint32(code) == spvMagic:
+	case binary.BigEndian.Uint32(code) == spvMagic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid spir-v magic number %#x", binary.LittleEndian.Uint32(code))
+	}
+	words := make([]uint32, len(code)/4)
+	for i := range words {
+		words[i] = order.Uint32(code[i*4:])
+	}
+
+	m := &spirvModule{
+		names:             make(map[uint32]string),
+		decorations:       make(map[uint32]map[uint32][]uint32),
+		memberDecorations: make(map[uint32]map[uint32]map[uint32][]uint32),
+		types:             make(map[uint32]*spirvType),
+		constants:         make(map[uint32]*spirvConstant),
+	}
+
+	for i := 5; i < len(words); {
+		count := int(words[i] >> 16)
+		op := words[i] & 0xffff
+		if count == 0 || i+count > len(words) {
+			return nil, fmt.Errorf("invalid spir-v instruction at word %d", i)
+		}
+		err := m.instruction(op, words[i+1:i+count])
+		if err != nil {
+			return nil, fmt.Errorf("spir-v instruction at word %d: %w", i, err)
+		}
+		i += count
+	}
+	return m, nil
+}
+
+// spirvString decodes a nul terminated literal string, returning it and the number of words it occupies
+func spirvString(words []uint32) (string, int) {
+	var b []byte
+	for i, w := range words {
+		for j := 0; j < 4; j++ {
+			c := byte(w >> (8 * uint(j)))
+			if c == 0 {
+				return string(b), i + 1
+			}
+			b = append(b, c)
+		}
+	}
+	return string(b), len(words)
+}
+
+func (m *spirvModule) instruction(op uint32, args []uint32) error {
+	need := func(n int) error {
+		if len(args) < n {
+			return fmt.Errorf("opcode %d has %d operands, expected at least %d", op, len(args), n)
+		}
+		return nil
+	}
+
+	switch op {
+	case spvOpName:
+		if err := need(2); err != nil {
+			return err
+		}
+		m.names[args[0]], _ = spirvString(args[1:])
+	case spvOpEntryPoint:
+		if err := need(3); err != nil {
+			return err
+		}
+		name, n := spirvString(args[2:])
+		m.entryPoints = append(m.entryPoints, spirvEntryPoint{
+			model:        args[0],
+			id:           args[1],
+			name:         name,
+			interfaceIDs: args[2+n:],
+		})
+	case spvOpExecutionMode, spvOpExecutionModeID:
+		if err := need(2); err != nil {
+			return err
+		}
+		m.executionModes = append(m.executionModes, spirvExecutionMode{
+			entry: args[0], mode: args[1], operands: args[2:], ids: op == spvOpExecutionModeID,
+		})
+	case spvOpDecorate:
+		if err := need(2); err != nil {
+			return err
+		}
+		d := m.decorations[args[0]]
+		if d == nil {
+			d = make(map[uint32][]uint32)
+			m.decorations[args[0]] = d
+		}
+		d[args[1]] = args[2:]
+	case spvOpMemberDecorate:
+		if err := need(3); err != nil {
+			return err
+		}
+		members := m.memberDecorations[args[0]]
+		if members == nil {
+			members = make(map[uint32]map[uint32][]uint32)
+			m.memberDecorations[args[0]] = members
+		}
+		d := members[args[1]]
+		if d == nil {
+			d = make(map[uint32][]uint32)
+			members[args[1]] = d
+		}
+		d[args[2]] = args[3:]
+	case spvOpTypeVoid, spvOpTypeBool, spvOpTypeSampler, spvOpTypeAccelStructure:
+		if err := need(1); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op}
+	case spvOpTypeInt:
+		if err := need(3); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, width: args[1], signed: args[2]}
+	case spvOpTypeFloat:
+		if err := need(2); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, width: args[1]}
+	case spvOpTypeVector, spvOpTypeMatrix:
+		if err := need(3); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, elem: args[1], count: args[2]}
+	case spvOpTypeImage:
+		if err := need(8); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, elem: args[1], dim: args[2], sampled: args[6]}
+	case spvOpTypeSampledImage, spvOpTypeRuntimeArray:
+		if err := need(2); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, elem: args[1]}
+	case spvOpTypeArray:
+		if err := need(3); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, elem: args[1], length: args[2]}
+	case spvOpTypeStruct:
+		if err := need(1); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, members: args[1:]}
+	case spvOpTypePointer:
+		if err := need(3); err != nil {
+			return err
+		}
+		m.types[args[0]] = &spirvType{op: op, storage: args[1], elem: args[2]}
+	case spvOpConstantTrue, spvOpConstantFalse, spvOpSpecConstantTrue, spvOpSpecConstantFalse:
+		if err := need(2); err != nil {
+			return err
+		}
+		value := uint32(0)
+		if op == spvOpConstantTrue || op == spvOpSpecConstantTrue {
+			value = 1
+		}
+		m.addConstant(args[1], &spirvConstant{typ: args[0], value: []uint32{value}, spec: op >= spvOpSpecConstantTrue})
+	case spvOpConstant, spvOpSpecConstant:
+		if err := need(3); err != nil {
+			return err
+		}
+		m.addConstant(args[1], &spirvConstant{typ: args[0], value: args[2:], spec: op == spvOpSpecConstant})
+	case spvOpConstantComposite, spvOpSpecConstantComp:
+		if err := need(2); err != nil {
+			return err
+		}
+		m.addConstant(args[1], &spirvConstant{typ: args[0], components: args[2:], spec: op == spvOpSpecConstantComp})
+	case spvOpVariable:
+		if err := need(3); err != nil {
+			return err
+		}
+		m.variables = append(m.variables, spirvVariable{typ: args[0], id: args[1], storage: args[2]})
+	}
+	return nil
+}
+
+func (m *spirvModule) addConstant(id uint32, c *spirvConstant) {
+	m.constants[id] = c
+	m.constantOrder = append(m.constantOrder, id)
+}
+
+func (m *spirvModule) decoration(id, decoration uint32) ([]uint32, bool) {
+	v, ok := m.decorations[id][decoration]
+	return v, ok
+}
+
+func (m *spirvModule) decorationValue(id, decoration uint32) (uint32, bool) {
+	v, ok := m.decoration(id, decoration)
+	if !ok || len(v) == 0 {
+		return 0, false
+	}
+	return v[0], true
+}
+
+func (m *spirvModule) memberDecorationValue(id, member, decoration uint32) (uint32, bool) {
+	v, ok := m.memberDecorations[id][member][decoration]
+	if !ok || len(v) == 0 {
+		return 0, ok
+	}
+	return v[0], true
+}
+
+func (m *spirvModule) typ(id uint32) (*spirvType, error) {
+	t, ok := m.types[id]
+	if !ok {
+		return nil, fmt.Errorf("undefined type %d", id)
+	}
+	return t, nil
+}
+
+func (m *spirvModule) constantValue(id uint32) (uint32, error) {
+	c, ok := m.constants[id]
+	if !ok || len(c.value) == 0 {
+		return 0, fmt.Errorf("%d is not a scalar constant", id)
+	}
+	return c.value[0], nil
+}
+
+func spirvStage(model uint32) (vk.ShaderStageFlagBits, bool) {
+	switch model {
+	case 0:
+		return vk.ShaderStageVertexBit, true
+	case 1:
+		return vk.ShaderStageTessellationControlBit, true
+	case 2:
+		return vk.ShaderStageTessellationEvaluationBit, true
+	case 3:
+		return vk.ShaderStageGeometryBit, true
+	case 4:
+		return vk.ShaderStageFragmentBit, true
+	case 5:
+		return vk.ShaderStageComputeBit, true
+	}
+	return 0, false
+}
+
+func (m *spirvModule) reflect() (*ShaderReflection, error) {
+	var ret ShaderReflection
+	var stages vk.ShaderStageFlags
+
+	for _, e := range m.entryPoints {
+		stage, ok := spirvStage(e.model)
+		if !ok {
+			continue
+		}
+		stages |= vk.ShaderStageFlags(stage)
+		entry := EntryPoint{Name: e.name, Stage: stage, LocalSizeSpecIDs: [3]int{-1, -1, -1}}
+		err := m.reflectLocalSize(e.id, &entry)
+		if err != nil {
+			return nil, err
+		}
+		entry.Inputs, err = m.reflectInputs(e.interfaceIDs)
+		if err != nil {
+			return nil, fmt.Errorf("entry point %s: %w", e.name, err)
+		}
+		ret.EntryPoints = append(ret.EntryPoints, entry)
+	}
+	if len(ret.EntryPoints) == 0 {
+		return nil, fmt.Errorf("spir-v module has no shader entry points")
+	}
+
+	for _, v := range m.variables {
+		switch v.storage {
+		case spvStorageUniformConstant, spvStorageUniform, spvStorageStorageBuffer:
+			binding, ok, err := m.reflectBinding(v)
+			if err != nil {
+				return nil, fmt.Errorf("variable %s: %w", m.names[v.id], err)
+			}
+			if ok {
+				binding.Stages = stages
+				ret.Bindings = append(ret.Bindings, binding)
+			}
+		case spvStoragePushConstant:
+			pc, err := m.reflectPushConstants(v)
+			if err != nil {
+				return nil, fmt.Errorf("push constants %s: %w", m.names[v.id], err)
+			}
+			if pc.Size > 0 {
+				pc.Stages = stages
+				ret.PushConstants = append(ret.PushConstants, pc)
+			}
+		}
+	}
+	sort.SliceStable(ret.Bindings, func(i, j int) bool {
+		a, b := ret.Bindings[i], ret.Bindings[j]
+		if a.Set != b.Set {
+			return a.Set < b.Set
+		}
+		return a.Binding < b.Binding
+	})
+
+	for _, id := range m.constantOrder {
+		c := m.constants[id]
+		specID, ok := m.decorationValue(id, spvDecorationSpecID)
+		if !c.spec || !ok {
+			continue
+		}
+		sc := SpecializationConstant{ID: specID, Name: m.names[id], Stages: stages}
+		t, err := m.typ(c.typ)
+		if err != nil {
+			return nil, err
+		}
+		sc.Type, sc.Width = m.scalarType(t)
+		if sc.Type == ScalarUnknown {
+			return nil, fmt.Errorf("specialization constant %d is not a scalar", specID)
+		}
+		for i, w := range c.value {
+			if i < 2 {
+				sc.Default |= uint64(w) << (32 * uint(i))
+			}
+		}
+		ret.SpecializationConstants = append(ret.SpecializationConstants, sc)
+	}
+	sort.SliceStable(ret.SpecializationConstants, func(i, j int) bool {
+		return ret.SpecializationConstants[i].ID < ret.SpecializationConstants[j].ID
+	})
+
+	return &ret, nil
+}
+
+func (m *spirvModule) scalarType(t *spirvType) (ScalarType, uint32) {
+	switch t.op {
+	case spvOpTypeBool:
+		return ScalarBool, 32
+	case spvOpTypeInt:
+		if t.signed != 0 {
+			return ScalarInt, t.width
+		}
+		return ScalarUint, t.width
+	case spvOpTypeFloat:
+		return ScalarFloat, t.width
+	}
+	return ScalarUnknown, 0
+}
+
+// reflectLocalSize finds the workgroup size of an entry point, a constant decorated as the WorkgroupSize
+// built in takes precedence over the execution mode
+func (m *spirvModule) reflectLocalSize(entry uint32, e *EntryPoint) error {
+	for _, mode := range m.executionModes {
+		if mode.entry != entry || len(mode.operands) < 3 {
+			continue
+		}
+		switch {
+		case mode.mode == spvExecutionModeLocalSize && !mode.ids:
+			copy(e.LocalSize[:], mode.operands[:3])
+		case mode.mode == spvExecutionModeLocalSizeID && mode.ids:
+			for i, id := range mode.operands[:3] {
+				err := m.localSizeComponent(id, e, i)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if e.Stage != vk.ShaderStageComputeBit {
+		return nil
+	}
+	for _, id := range m.constantOrder {
+		builtIn, ok := m.decorationValue(id, spvDecorationBuiltIn)
+		if !ok || builtIn != spvBuiltInWorkgroupSize {
+			continue
+		}
+		c := m.constants[id]
+		if len(c.components) != 3 {
+			return fmt.Errorf("workgroup size constant must have 3 components")
+		}
+		for i, component := range c.components {
+			err := m.localSizeComponent(component, e, i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *spirvModule) localSizeComponent(id uint32, e *EntryPoint, i int) error {
+	v, err := m.constantValue(id)
+	if err != nil {
+		return fmt.Errorf("workgroup size: %w", err)
+	}
+	e.LocalSize[i] = v
+	e.LocalSizeSpecIDs[i] = -1
+	if specID, ok := m.decorationValue(id, spvDecorationSpecID); ok && m.constants[id].spec {
+		e.LocalSizeSpecIDs[i] = int(specID)
+	}
+	return nil
+}
+
+func (m *spirvModule) reflectInputs(ids []uint32) ([]ShaderInput, error) {
+	var ret []ShaderInput
+	for _, v := range m.variables {
+		if v.storage != spvStorageInput || !containsID(ids, v.id) {
+			continue
+		}
+		if _, ok := m.decoration(v.id, spvDecorationBuiltIn); ok {
+			continue
+		}
+		location, ok := m.decorationValue(v.id, spvDecorationLocation)
+		if !ok {
+			// Blocks of built ins such as gl_PerVertex have no location
+			continue
+		}
+		ptr, err := m.typ(v.typ)
+		if err != nil {
+			return nil, err
+		}
+		inputs, _, err := m.inputLocations(ptr.elem, m.names[v.id], location)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", m.names[v.id], err)
+		}
+		ret = append(ret, inputs...)
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Location < ret[j].Location })
+	return ret, nil
+}
+
+func containsID(ids []uint32, id uint32) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// inputLocations splits an input of the specified type into the locations it occupies, returning the
+// inputs and the number of locations
+func (m *spirvModule) inputLocations(typeID uint32, name string, location uint32) ([]ShaderInput, uint32, error) {
+	t, err := m.typ(typeID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var elem uint32
+	var count uint32
+	switch t.op {
+	case spvOpTypeArray:
+		elem = t.elem
+		if count, err = m.constantValue(t.length); err != nil {
+			return nil, 0, err
+		}
+	case spvOpTypeMatrix:
+		elem, count = t.elem, t.count
+	}
+	if count > 0 {
+		var ret []ShaderInput
+		var used uint32
+		for i := uint32(0); i < count; i++ {
+			element, n, err := m.inputLocations(elem, fmt.Sprintf("%s[%d]", name, i), location+used)
+			if err != nil {
+				return nil, 0, err
+			}
+			ret = append(ret, element...)
+			used += n
+		}
+		return ret, used, nil
+	}
+
+	components := uint32(1)
+	scalar := t
+	if t.op == spvOpTypeVector {
+		components = t.count
+		if scalar, err = m.typ(t.elem); err != nil {
+			return nil, 0, err
+		}
+	}
+	kind, width := m.scalarType(scalar)
+	format, ok := vertexFormat(kind, width, components)
+	if !ok {
+		return nil, 0, fmt.Errorf("no vertex format for %d component %d bit %s", components, width, kind)
+	}
+	input := ShaderInput{Location: location, Name: name, Format: format, Size: components * width / 8}
+	// 64 bit vectors with more than two components occupy two locations
+	if width == 64 && components > 2 {
+		return []ShaderInput{input}, 2, nil
+	}
+	return []ShaderInput{input}, 1, nil
+}
+
+type vertexFormatKey struct {
+	kind       ScalarType
+	width      uint32
+	components uint32
+}
+
+var vertexFormats = map[vertexFormatKey]vk.Format{
+	{ScalarFloat, 32, 1}: vk.FormatR32Sfloat,
+	{ScalarFloat, 32, 2}: vk.FormatR32g32Sfloat,
+	{ScalarFloat, 32, 3}: vk.FormatR32g32b32Sfloat,
+	{ScalarFloat, 32, 4}: vk.FormatR32g32b32a32Sfloat,
+	{ScalarInt, 32, 1}:   vk.FormatR32Sint,
+	{ScalarInt, 32, 2}:   vk.FormatR32g32Sint,
+	{ScalarInt, 32, 3}:   vk.FormatR32g32b32Sint,
+	{ScalarInt, 32, 4}:   vk.FormatR32g32b32a32Sint,
+	{ScalarUint, 32, 1}:  vk.FormatR32Uint,
+	{ScalarUint, 32, 2}:  vk.FormatR32g32Uint,
+	{ScalarUint, 32, 3}:  vk.FormatR32g32b32Uint,
+	{ScalarUint, 32, 4}:  vk.FormatR32g32b32a32Uint,
+	{ScalarFloat, 16, 1}: vk.FormatR16Sfloat,
+	{ScalarFloat, 16, 2}: vk.FormatR16g16Sfloat,
+	{ScalarFloat, 16, 3}: vk.FormatR16g16b16Sfloat,
+	{ScalarFloat, 16, 4}: vk.FormatR16g16b16a16Sfloat,
+	{ScalarInt, 16, 1}:   vk.FormatR16Sint,
+	{ScalarInt, 16, 2}:   vk.FormatR16g16Sint,
+	{ScalarInt, 16, 4}:   vk.FormatR16g16b16a16Sint,
+	{ScalarUint, 16, 1}:  vk.FormatR16Uint,
+	{ScalarUint, 16, 2}:  vk.FormatR16g16Uint,
+	{ScalarUint, 16, 4}:  vk.FormatR16g16b16a16Uint,
+	{ScalarFloat, 64, 1}: vk.FormatR64Sfloat,
+	{ScalarFloat, 64, 2}: vk.FormatR64g64Sfloat,
+	{ScalarFloat, 64, 3}: vk.FormatR64g64b64Sfloat,
+	{ScalarFloat, 64, 4}: vk.FormatR64g64b64a64Sfloat,
+}
+
+func vertexFormat(kind ScalarType, width, components uint32) (vk.Format, bool) {
+	f, ok := vertexFormats[vertexFormatKey{kind, width, components}]
+	return f, ok
+}
+
+func (m *spirvModule) reflectBinding(v spirvVariable) (DescriptorBinding, bool, error) {
+	var ret DescriptorBinding
+	binding, ok := m.decorationValue(v.id, spvDecorationBinding)
+	if !ok {
+		return ret, false, nil
+	}
+	set, _ := m.decorationValue(v.id, spvDecorationDescriptorSet)
+	ret.Set = set
+	ret.Binding = binding
+	ret.Count = 1
+
+	ptr, err := m.typ(v.typ)
+	if err != nil {
+		return ret, false, err
+	}
+	typeID := ptr.elem
+	t, err := m.typ(typeID)
+	if err != nil {
+		return ret, false, err
+	}
+	for t.op == spvOpTypeArray || t.op == spvOpTypeRuntimeArray {
+		if t.op == spvOpTypeRuntimeArray {
+			ret.Count = 0
+		} else {
+			length, err := m.constantValue(t.length)
+			if err != nil {
+				return ret, false, err
+			}
+			ret.Count *= length
+		}
+		typeID = t.elem
+		if t, err = m.typ(typeID); err != nil {
+			return ret, false, err
+		}
+	}
+
+	ret.Name = m.names[v.id]
+	if ret.Name == "" {
+		ret.Name = m.names[typeID]
+	}
+	_, nonWritable := m.decoration(v.id, spvDecorationNonWritable)
+
+	switch t.op {
+	case spvOpTypeStruct:
+		_, bufferBlock := m.decoration(typeID, spvDecorationBufferBlock)
+		if v.storage == spvStorageStorageBuffer || bufferBlock {
+			ret.Type = vk.DescriptorTypeStorageBuffer
+			ret.ReadOnly = nonWritable || m.allMembersNonWritable(typeID, t)
+		} else {
+			ret.Type = vk.DescriptorTypeUniformBuffer
+		}
+		ret.Size, err = m.size(typeID)
+		if err != nil {
+			return ret, false, err
+		}
+	case spvOpTypeSampler:
+		ret.Type = vk.DescriptorTypeSampler
+	case spvOpTypeSampledImage:
+		ret.Type = vk.DescriptorTypeCombinedImageSampler
+		image, err := m.typ(t.elem)
+		if err == nil && image.dim == spvDimBuffer {
+			ret.Type = vk.DescriptorTypeUniformTexelBuffer
+		}
+	case spvOpTypeImage:
+		switch {
+		case t.dim == spvDimSubpassData:
+			ret.Type = vk.DescriptorTypeInputAttachment
+		case t.dim == spvDimBuffer && t.sampled == 2:
+			ret.Type = vk.DescriptorTypeStorageTexelBuffer
+			ret.ReadOnly = nonWritable
+		case t.dim == spvDimBuffer:
+			ret.Type = vk.DescriptorTypeUniformTexelBuffer
+		case t.sampled == 2:
+			ret.Type = vk.DescriptorTypeStorageImage
+			ret.ReadOnly = nonWritable
+		default:
+			ret.Type = vk.DescriptorTypeSampledImage
+		}
+	default:
+		return ret, false, fmt.Errorf("unsupported descriptor type (opcode %d)", t.op)
+	}
+	return ret, true, nil
+}
+
+func (m *spirvModule) allMembersNonWritable(id uint32, t *spirvType) bool {
+	if len(t.members) == 0 {
+		return false
+	}
+	for i := range t.members {
+		if _, ok := m.memberDecorations[id][uint32(i)][spvDecorationNonWritable]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *spirvModule) reflectPushConstants(v spirvVariable) (PushConstantRange, error) {
+	var ret PushConstantRange
+	ptr, err := m.typ(v.typ)
+	if err != nil {
+		return ret, err
+	}
+	t, err := m.typ(ptr.elem)
+	if err != nil {
+		return ret, err
+	}
+	if t.op != spvOpTypeStruct || len(t.members) == 0 {
+		return ret, nil
+	}
+	ret.Name = m.names[v.id]
+	if ret.Name == "" {
+		ret.Name = m.names[ptr.elem]
+	}
+
+	size, err := m.size(ptr.elem)
+	if err != nil {
+		return ret, err
+	}
+	ret.Offset = size
+	for i := range t.members {
+		offset, _ := m.memberDecorationValue(ptr.elem, uint32(i), spvDecorationOffset)
+		if offset < ret.Offset {
+			ret.Offset = offset
+		}
+	}
+	ret.Size = size - ret.Offset
+	return ret, nil
+}
+
+// size returns the size of a type laid out with explicit offsets and strides, runtime arrays have no size
+func (m *spirvModule) size(id uint32) (uint32, error) {
+	t, err := m.typ(id)
+	if err != nil {
+		return 0, err
+	}
+	switch t.op {
+	case spvOpTypeBool:
+		return 4, nil
+	case spvOpTypeInt, spvOpTypeFloat:
+		return t.width / 8, nil
+	case spvOpTypeVector, spvOpTypeMatrix:
+		elem, err := m.size(t.elem)
+		return t.count * elem, err
+	case spvOpTypeArray:
+		length, err := m.constantValue(t.length)
+		if err != nil {
+			return 0, err
+		}
+		stride, ok := m.decorationValue(id, spvDecorationArrayStride)
+		if !ok {
+			if stride, err = m.size(t.elem); err != nil {
+				return 0, err
+			}
+		}
+		return length * stride, nil
+	case spvOpTypeRuntimeArray:
+		return 0, nil
+	case spvOpTypeStruct:
+		var size uint32
+		for i, member := range t.members {
+			offset, _ := m.memberDecorationValue(id, uint32(i), spvDecorationOffset)
+			memberSize, err := m.memberSize(id, uint32(i), member)
+			if err != nil {
+				return 0, err
+			}
+			if offset+memberSize > size {
+				size = offset + memberSize
+			}
+		}
+		return size, nil
+	}
+	return 0, fmt.Errorf("type %d (opcode %d) has no size", id, t.op)
+}
+
+// memberSize returns the size of a struct member, matrices use the member's matrix stride
+func (m *spirvModule) memberSize(structID, member, typeID uint32) (uint32, error) {
+	t, err := m.typ(typeID)
+	if err != nil {
+		return 0, err
+	}
+	stride, ok := m.memberDecorationValue(structID, member, spvDecorationMatrixStride)
+	if t.op != spvOpTypeMatrix || !ok {
+		return m.size(typeID)
+	}
+	if _, rowMajor := m.memberDecorations[structID][member][spvDecorationRowMajor]; rowMajor {
+		column, err := m.typ(t.elem)
+		if err != nil {
+			return 0, err
+		}
+		return column.count * stride, nil
+	}
+	return t.count * stride, nil
+}
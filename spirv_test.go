@@ -0,0 +1,407 @@
+package vkg
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// spirvAssembler builds SPIR-V modules for tests
+type spirvAssembler struct {
+	words []uint32
+}
+
+func newSPIRVAssembler() *spirvAssembler {
+	return &spirvAssembler{words: []uint32{spvMagic, 0x00010000, 0, 100, 0}}
+}
+
+func (a *spirvAssembler) op(op uint32, args ...uint32) {
+	a.words = append(a.words, uint32(len(args)+1)<<16|op)
+	a.words = append(a.words, args...)
+}
+
+func (a *spirvAssembler) bytes() []byte {
+	ret := make([]byte, len(a.words)*4)
+	for i, w := range a.words {
+		binary.LittleEndian.PutUint32(ret[i*4:], w)
+	}
+	return ret
+}
+
+func spirvLiteral(s string) []uint32 {
+	b := append([]byte(s), 0)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	ret := make([]uint32, len(b)/4)
+	for i := range ret {
+		ret[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+	return ret
+}
+
+func concat(words ...interface{}) []uint32 {
+	var ret []uint32
+	for _, w := range words {
+		switch v := w.(type) {
+		case uint32:
+			ret = append(ret, v)
+		case int:
+			ret = append(ret, uint32(v))
+		case []uint32:
+			ret = append(ret, v...)
+		}
+	}
+	return ret
+}
+
+// vertexModule is equivalent to
+//
+//	layout(location = 0) in vec3 position;
+//	layout(location = 1) in vec2 uv;
+//	layout(set = 0, binding = 0) uniform UBO { mat4 mvp; vec4 tint; } ubo;
+//	layout(set = 1, binding = 1) uniform sampler2D textures[2];
+//	layout(push_constant) uniform Push { layout(offset = 16) vec4 color; } push;
+//	layout(constant_id = 1) const int COUNT = 7;
+//	layout(constant_id = 3) const bool ENABLED = true;
+func vertexModule() []byte {
+	a := newSPIRVAssembler()
+	a.op(spvOpEntryPoint, concat(0, 1, spirvLiteral("main"), 20, 21)...)
+	a.op(spvOpName, concat(20, spirvLiteral("position"))...)
+	a.op(spvOpName, concat(21, spirvLiteral("uv"))...)
+	a.op(spvOpName, concat(31, spirvLiteral("ubo"))...)
+	a.op(spvOpName, concat(41, spirvLiteral("textures"))...)
+	a.op(spvOpName, concat(52, spirvLiteral("push"))...)
+	a.op(spvOpName, concat(60, spirvLiteral("COUNT"))...)
+	a.op(spvOpName, concat(61, spirvLiteral("ENABLED"))...)
+
+	a.op(spvOpDecorate, 20, spvDecorationLocation, 0)
+	a.op(spvOpDecorate, 21, spvDecorationLocation, 1)
+	a.op(spvOpDecorate, 30, spvDecorationBlock)
+	a.op(spvOpMemberDecorate, 30, 0, spvDecorationOffset, 0)
+	a.op(spvOpMemberDecorate, 30, 0, spvDecorationMatrixStride, 16)
+	a.op(spvOpMemberDecorate, 30, 1, spvDecorationOffset, 64)
+	a.op(spvOpDecorate, 31, spvDecorationDescriptorSet, 0)
+	a.op(spvOpDecorate, 31, spvDecorationBinding, 0)
+	a.op(spvOpDecorate, 41, spvDecorationDescriptorSet, 1)
+	a.op(spvOpDecorate, 41, spvDecorationBinding, 1)
+	a.op(spvOpDecorate, 50, spvDecorationBlock)
+	a.op(spvOpMemberDecorate, 50, 0, spvDecorationOffset, 16)
+	a.op(spvOpDecorate, 60, spvDecorationSpecID, 1)
+	a.op(spvOpDecorate, 61, spvDecorationSpecID, 3)
+
+	a.op(spvOpTypeVoid, 2)
+	a.op(spvOpTypeFloat, 3, 32)
+	a.op(spvOpTypeInt, 4, 32, 1)
+	a.op(spvOpTypeInt, 5, 32, 0)
+	a.op(spvOpTypeBool, 6)
+	a.op(spvOpTypeVector, 7, 3, 2)
+	a.op(spvOpTypeVector, 8, 3, 3)
+	a.op(spvOpTypeVector, 9, 3, 4)
+	a.op(spvOpTypeMatrix, 10, 9, 4)
+	a.op(spvOpConstant, 5, 11, 2)
+
+	a.op(spvOpTypePointer, 12, spvStorageInput, 8)
+	a.op(spvOpTypePointer, 13, spvStorageInput, 7)
+	a.op(spvOpVariable, 12, 20, spvStorageInput)
+	a.op(spvOpVariable, 13, 21, spvStorageInput)
+
+	a.op(spvOpTypeStruct, 30, 10, 9)
+	a.op(spvOpTypePointer, 32, spvStorageUniform, 30)
+	a.op(spvOpVariable, 32, 31, spvStorageUniform)
+
+	a.op(spvOpTypeImage, 42, 3, 1, 0, 0, 0, 1, 0)
+	a.op(spvOpTypeSampledImage, 43, 42)
+	a.op(spvOpTypeArray, 44, 43, 11)
+	a.op(spvOpTypePointer, 45, spvStorageUniformConstant, 44)
+	a.op(spvOpVariable, 45, 41, spvStorageUniformConstant)
+
+	a.op(spvOpTypeStruct, 50, 9)
+	a.op(spvOpTypePointer, 51, spvStoragePushConstant, 50)
+	a.op(spvOpVariable, 51, 52, spvStoragePushConstant)
+
+	a.op(spvOpSpecConstant, 4, 60, 7)
+	a.op(spvOpSpecConstantTrue, 6, 61)
+	return a.bytes()
+}
+
+// computeModule is equivalent to
+//
+//	layout(local_size_x_id = 0, local_size_y = 4) in;
+//	layout(set = 0, binding = 0) readonly buffer Data { float values[]; };
+func computeModule() []byte {
+	a := newSPIRVAssembler()
+	a.op(spvOpEntryPoint, concat(5, 1, spirvLiteral("main"))...)
+	a.op(spvOpExecutionMode, 1, spvExecutionModeLocalSize, 8, 8, 1)
+	a.op(spvOpName, concat(30, spirvLiteral("Data"))...)
+
+	a.op(spvOpDecorate, 12, spvDecorationSpecID, 0)
+	a.op(spvOpDecorate, 15, spvDecorationBuiltIn, spvBuiltInWorkgroupSize)
+	a.op(spvOpDecorate, 20, spvDecorationArrayStride, 4)
+	a.op(spvOpDecorate, 30, spvDecorationBlock)
+	a.op(spvOpMemberDecorate, 30, 0, spvDecorationOffset, 0)
+	a.op(spvOpMemberDecorate, 30, 0, spvDecorationNonWritable)
+	a.op(spvOpDecorate, 31, spvDecorationDescriptorSet, 0)
+	a.op(spvOpDecorate, 31, spvDecorationBinding, 0)
+
+	a.op(spvOpTypeFloat, 3, 32)
+	a.op(spvOpTypeInt, 10, 32, 0)
+	a.op(spvOpTypeVector, 11, 10, 3)
+	a.op(spvOpSpecConstant, 10, 12, 16)
+	a.op(spvOpConstant, 10, 13, 4)
+	a.op(spvOpConstant, 10, 14, 1)
+	a.op(spvOpSpecConstantComp, 11, 15, 12, 13, 14)
+
+	a.op(spvOpTypeRuntimeArray, 20, 3)
+	a.op(spvOpTypeStruct, 30, 20)
+	a.op(spvOpTypePointer, 32, spvStorageStorageBuffer, 30)
+	a.op(spvOpVariable, 32, 31, spvStorageStorageBuffer)
+	return a.bytes()
+}
+
+// fragmentModule declares a storage image at set 0 binding 0 and the same push constants as the vertex module
+func fragmentModule() []byte {
+	a := newSPIRVAssembler()
+	a.op(spvOpEntryPoint, concat(4, 1, spirvLiteral("main"))...)
+	a.op(spvOpDecorate, 31, spvDecorationDescriptorSet, 0)
+	a.op(spvOpDecorate, 31, spvDecorationBinding, 0)
+	a.op(spvOpDecorate, 50, spvDecorationBlock)
+	a.op(spvOpMemberDecorate, 50, 0, spvDecorationOffset, 16)
+	a.op(spvOpTypeFloat, 3, 32)
+	a.op(spvOpTypeVector, 9, 3, 4)
+	a.op(spvOpTypeImage, 30, 3, 1, 0, 0, 0, 2, 1)
+	a.op(spvOpTypePointer, 32, spvStorageUniformConstant, 30)
+	a.op(spvOpVariable, 32, 31, spvStorageUniformConstant)
+	a.op(spvOpTypeStruct, 50, 9)
+	a.op(spvOpTypePointer, 51, spvStoragePushConstant, 50)
+	a.op(spvOpVariable, 51, 52, spvStoragePushConstant)
+	return a.bytes()
+}
+
+func TestReflectSPIRV(t *testing.T) {
+	r, err := ReflectSPIRV(vertexModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, ok := r.EntryPoint("main")
+	if !ok || entry.Stage != vk.ShaderStageVertexBit {
+		t.Fatalf("unexpected entry points %+v", r.EntryPoints)
+	}
+	if len(entry.Inputs) != 2 ||
+		entry.Inputs[0] != (ShaderInput{Location: 0, Name: "position", Format: vk.FormatR32g32b32Sfloat, Size: 12}) ||
+		entry.Inputs[1] != (ShaderInput{Location: 1, Name: "uv", Format: vk.FormatR32g32Sfloat, Size: 8}) {
+		t.Errorf("unexpected inputs %+v", entry.Inputs)
+	}
+
+	stages := vk.ShaderStageFlags(vk.ShaderStageVertexBit)
+	expected := []DescriptorBinding{
+		{Set: 0, Binding: 0, Name: "ubo", Type: vk.DescriptorTypeUniformBuffer, Count: 1, Stages: stages, Size: 80},
+		{Set: 1, Binding: 1, Name: "textures", Type: vk.DescriptorTypeCombinedImageSampler, Count: 2, Stages: stages},
+	}
+	if len(r.Bindings) != len(expected) {
+		t.Fatalf("unexpected bindings %+v", r.Bindings)
+	}
+	for i := range expected {
+		if r.Bindings[i] != expected[i] {
+			t.Errorf("expected binding %+v got %+v", expected[i], r.Bindings[i])
+		}
+	}
+
+	if len(r.PushConstants) != 1 || r.PushConstants[0] != (PushConstantRange{Name: "push", Offset: 16, Size: 16, Stages: stages}) {
+		t.Errorf("unexpected push constants %+v", r.PushConstants)
+	}
+
+	if len(r.SpecializationConstants) != 2 ||
+		r.SpecializationConstants[0] != (SpecializationConstant{ID: 1, Name: "COUNT", Type: ScalarInt, Width: 32, Default: 7, Stages: stages}) ||
+		r.SpecializationConstants[1] != (SpecializationConstant{ID: 3, Name: "ENABLED", Type: ScalarBool, Width: 32, Default: 1, Stages: stages}) {
+		t.Errorf("unexpected specialization constants %+v", r.SpecializationConstants)
+	}
+
+	if _, err := ReflectSPIRV([]byte{1, 2, 3, 4}); err == nil {
+		t.Error("expected invalid code to fail")
+	}
+}
+
+func TestReflectSPIRVCompute(t *testing.T) {
+	r, err := ReflectSPIRV(computeModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := r.EntryPoint("main")
+	if entry == nil || entry.Stage != vk.ShaderStageComputeBit {
+		t.Fatalf("unexpected entry points %+v", r.EntryPoints)
+	}
+	if entry.LocalSize != [3]uint32{16, 4, 1} || entry.LocalSizeSpecIDs != [3]int{0, -1, -1} {
+		t.Errorf("unexpected local size %v %v", entry.LocalSize, entry.LocalSizeSpecIDs)
+	}
+	b, ok := r.Binding("Data")
+	if !ok || b.Type != vk.DescriptorTypeStorageBuffer || b.Count != 1 || !b.ReadOnly || b.Size != 0 {
+		t.Errorf("unexpected binding %+v", r.Bindings)
+	}
+
+	p, err := MergeShaderStages(ShaderStageReflection{Reflection: r, EntryPoint: "main", Stage: vk.ShaderStageComputeBit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.LocalSize != entry.LocalSize || p.LocalSizeSpecIDs != entry.LocalSizeSpecIDs {
+		t.Errorf("unexpected merged local size %v", p.LocalSize)
+	}
+	if _, err := MergeShaderStages(ShaderStageReflection{Reflection: r, EntryPoint: "main", Stage: vk.ShaderStageVertexBit}); err == nil {
+		t.Error("expected a mismatched stage to fail")
+	}
+}
+
+// TestReflectExampleShaders reflects the shaders compiled by glslc for the examples
+func TestReflectExampleShaders(t *testing.T) {
+	reflect := func(file string) *ShaderReflection {
+		code, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := ReflectSPIRV(code)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		return r
+	}
+
+	computeTests := []struct {
+		file      string
+		localSize [3]uint32
+		specIDs   [3]int
+		bindings  []string
+	}{
+		{"examples/mandelbrot/shaders/comp.spv", [3]uint32{32, 32, 1}, [3]int{0, 1, -1}, []string{"buf"}},
+		{"examples/sdf/shaders/sdf.comp.spv", [3]uint32{10, 10, 10}, [3]int{-1, -1, -1}, []string{"ExecutionIn", "b1", "b2"}},
+	}
+	for _, test := range computeTests {
+		r := reflect(test.file)
+		entry, _ := r.EntryPoint("main")
+		if entry == nil || entry.Stage != vk.ShaderStageComputeBit {
+			t.Fatalf("%s: unexpected entry points %+v", test.file, r.EntryPoints)
+		}
+		if entry.LocalSize != test.localSize || entry.LocalSizeSpecIDs != test.specIDs {
+			t.Errorf("%s: unexpected local size %v %v", test.file, entry.LocalSize, entry.LocalSizeSpecIDs)
+		}
+		if len(r.Bindings) != len(test.bindings) {
+			t.Fatalf("%s: unexpected bindings %+v", test.file, r.Bindings)
+		}
+		for i, name := range test.bindings {
+			b := r.Bindings[i]
+			if b.Name != name || b.Set != 0 || b.Binding != uint32(i) || b.Type != vk.DescriptorTypeStorageBuffer ||
+				b.Count != 1 || b.ReadOnly || b.Stages != vk.ShaderStageFlags(vk.ShaderStageComputeBit) {
+				t.Errorf("%s: unexpected binding %+v", test.file, b)
+			}
+		}
+	}
+
+	p, err := MergeShaderStages(
+		ShaderStageReflection{Reflection: reflect("examples/texture/shaders/vert.spv"), EntryPoint: "main", Stage: vk.ShaderStageVertexBit},
+		ShaderStageReflection{Reflection: reflect("examples/texture/shaders/frag.spv"), EntryPoint: "main", Stage: vk.ShaderStageFragmentBit},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Bindings) != 2 {
+		t.Fatalf("unexpected texture bindings %+v", p.Bindings)
+	}
+	if b := p.Bindings[0]; b.Name != "ubo" || b.Binding != 0 || b.Type != vk.DescriptorTypeUniformBuffer ||
+		b.Size != 192 || b.Stages != vk.ShaderStageFlags(vk.ShaderStageVertexBit) {
+		t.Errorf("unexpected uniform buffer %+v", b)
+	}
+	if b := p.Bindings[1]; b.Name != "texSampler" || b.Binding != 1 || b.Type != vk.DescriptorTypeCombinedImageSampler ||
+		b.Stages != vk.ShaderStageFlags(vk.ShaderStageFragmentBit) {
+		t.Errorf("unexpected sampler %+v", b)
+	}
+	if len(p.VertexInputs) != 3 || p.VertexInputs[0].Name != "inPosition" || p.VertexInputs[2].Name != "inTexCoord" {
+		t.Errorf("unexpected vertex inputs %+v", p.VertexInputs)
+	}
+}
+
+func TestMergeShaderStages(t *testing.T) {
+	vertex, err := ReflectSPIRV(vertexModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment, err := ReflectSPIRV(fragmentModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := MergeShaderStages(
+		ShaderStageReflection{Reflection: vertex, EntryPoint: "main", Stage: vk.ShaderStageVertexBit},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binding, attributes := p.VertexInputDescriptions(0, vk.VertexInputRateVertex)
+	if binding.Stride != 20 || len(attributes) != 2 || attributes[1].Offset != 12 || attributes[1].Location != 1 {
+		t.Errorf("unexpected vertex input %+v %+v", binding, attributes)
+	}
+	layouts, err := p.DescriptorSetLayouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layouts) != 2 || len(layouts[0].VKDescriptorSetLayoutBindings) != 1 || layouts[1].VKDescriptorSetLayoutBindings[0].DescriptorCount != 2 {
+		t.Errorf("unexpected layouts %+v", layouts)
+	}
+
+	_, err = MergeShaderStages(
+		ShaderStageReflection{Reflection: vertex, EntryPoint: "main", Stage: vk.ShaderStageVertexBit},
+		ShaderStageReflection{Reflection: fragment, EntryPoint: "main", Stage: vk.ShaderStageFragmentBit},
+	)
+	conflicts, ok := err.(ReflectionConflicts)
+	if !ok || len(conflicts) != 1 {
+		t.Fatalf("expected a conflict for set 0 binding 0, got %v", err)
+	}
+
+	// Without the conflicting uniform buffer the push constants of both stages share a range
+	fragment.Bindings = nil
+	p, err = MergeShaderStages(
+		ShaderStageReflection{Reflection: vertex, EntryPoint: "main", Stage: vk.ShaderStageVertexBit},
+		ShaderStageReflection{Reflection: fragment, EntryPoint: "main", Stage: vk.ShaderStageFragmentBit},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranges := p.PushConstantRanges()
+	if len(ranges) != 1 || ranges[0].StageFlags != vk.ShaderStageFlags(vk.ShaderStageVertexBit|vk.ShaderStageFragmentBit) {
+		t.Errorf("unexpected push constant ranges %+v", ranges)
+	}
+	if p.Bindings[0].Stages != vk.ShaderStageFlags(vk.ShaderStageVertexBit) {
+		t.Errorf("unexpected binding stages %v", p.Bindings[0].Stages)
+	}
+}
+
+func TestCheckDescriptorSetLayouts(t *testing.T) {
+	vertex, err := ReflectSPIRV(vertexModule())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := MergeShaderStages(ShaderStageReflection{Reflection: vertex, EntryPoint: "main", Stage: vk.ShaderStageVertexBit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layouts, err := p.DescriptorSetLayouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CheckDescriptorSetLayouts(layouts); err != nil {
+		t.Errorf("layouts generated from the reflection should match, got %v", err)
+	}
+
+	layouts[1].VKDescriptorSetLayoutBindings[0].DescriptorCount = 1
+	layouts[0].VKDescriptorSetLayoutBindings[0].StageFlags = vk.ShaderStageFlags(vk.ShaderStageFragmentBit)
+	conflicts, ok := p.CheckDescriptorSetLayouts(layouts).(ReflectionConflicts)
+	if !ok || len(conflicts) != 2 {
+		t.Errorf("expected stage and count conflicts, got %v", conflicts)
+	}
+
+	conflicts, ok = p.CheckDescriptorSetLayouts(layouts[:1]).(ReflectionConflicts)
+	if !ok || len(conflicts) != 2 {
+		t.Errorf("expected missing set 1 and a stage conflict, got %v", conflicts)
+	}
+}
@@ -0,0 +1,232 @@
+package vkg
+
+import (
+	"fmt"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// SubmitBatch is a group of command buffers which wait on the same semaphores and signal the same
+// semaphores once they complete, it corresponds to a single vk.SubmitInfo
+type SubmitBatch struct {
+	Waits   []SemaphoreWait
+	Buffers []*CommandBuffer
+	Signals []SemaphoreSignal
+}
+
+// Submit builds a queue submission of one or more batches, all of which are submitted with a single
+// vkQueueSubmit. Batches are started with Batch, waits, command buffers and signals are added to the
+// most recent batch.
+//
+//	err := queue.NewSubmit().
+//		Wait(imageAvailable, vk.PipelineStageFlags(vk.PipelineStageColorAttachmentOutputBit)).
+//		Buffers(cmd).
+//		Signal(renderComplete).
+//		Fence(fence).
+//		Submit()
+type Submit struct {
+	Queue   *Queue
+	Batches []*SubmitBatch
+	fence   *Fence
+}
+
+// NewSubmit starts building a submission to this queue
+func (q *Queue) NewSubmit() *Submit {
+	return &Submit{Queue: q}
+}
+
+func (s *Submit) current() *SubmitBatch {
+	if len(s.Batches) == 0 {
+		s.Batch()
+	}
+	return s.Batches[len(s.Batches)-1]
+}
+
+// Batch starts a new batch, batches execute in order but may overlap unless ordered by semaphores
+func (s *Submit) Batch() *Submit {
+	s.Batches = append(s.Batches, &SubmitBatch{})
+	return s
+}
+
+// Wait makes the batch wait for a binary semaphore before executing the stages in stageMask
+func (s *Submit) Wait(semaphore vk.Semaphore, stageMask vk.PipelineStageFlags) *Submit {
+	b := s.current()
+	b.Waits = append(b.Waits, SemaphoreWait{Semaphore: semaphore, StageMask: stageMask})
+	return s
+}
+
+// WaitTimeline makes the batch wait for a timeline point before executing the stages in stageMask,
+// a point without a semaphore is ignored
+func (s *Submit) WaitTimeline(point TimelinePoint, stageMask vk.PipelineStageFlags) *Submit {
+	if point.Semaphore == nil {
+		return s
+	}
+	b := s.current()
+	b.Waits = append(b.Waits, point.WaitInfo(stageMask))
+	return s
+}
+
+// Waits adds waits on binary or timeline semaphores to the batch
+func (s *Submit) Waits(waits ...SemaphoreWait) *Submit {
+	b := s.current()
+	b.Waits = append(b.Waits, waits...)
+	return s
+}
+
+// Buffers adds command buffers to the batch
+func (s *Submit) Buffers(buffers ...*CommandBuffer) *Submit {
+	b := s.current()
+	b.Buffers = append(b.Buffers, buffers...)
+	return s
+}
+
+// Signal makes the batch signal a binary semaphore once it completes
+func (s *Submit) Signal(semaphore vk.Semaphore) *Submit {
+	b := s.current()
+	b.Signals = append(b.Signals, SemaphoreSignal{Semaphore: semaphore})
+	return s
+}
+
+// SignalTimeline makes the batch set a timeline semaphore to the point's value once it completes
+func (s *Submit) SignalTimeline(point TimelinePoint) *Submit {
+	b := s.current()
+	b.Signals = append(b.Signals, point.SignalInfo())
+	return s
+}
+
+// Fence sets the fence which is signaled once all of the batches complete
+func (s *Submit) Fence(fence *Fence) *Submit {
+	s.fence = fence
+	return s
+}
+
+// usesTimeline returns true if any of the waits or signals have a timeline value
+func (s *Submit) usesTimeline() bool {
+	for _, b := range s.Batches {
+		for _, w := range b.Waits {
+			if w.Value != 0 {
+				return true
+			}
+		}
+		for _, sig := range b.Signals {
+			if sig.Value != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vkSubmitInfo creates the submit info for the batch along with the timeline values of its waits and signals
+func (b *SubmitBatch) vkSubmitInfo() (vk.SubmitInfo, vk.TimelineSemaphoreSubmitInfo) {
+	buffers := make([]vk.CommandBuffer, len(b.Buffers))
+	for j := range b.Buffers {
+		buffers[j] = b.Buffers[j].VKCommandBuffer
+	}
+
+	waitSemaphores := make([]vk.Semaphore, len(b.Waits))
+	waitStages := make([]vk.PipelineStageFlags, len(b.Waits))
+	waitValues := make([]uint64, len(b.Waits))
+	for j, w := range b.Waits {
+		waitSemaphores[j] = w.Semaphore
+		waitStages[j] = w.StageMask
+		if waitStages[j] == 0 {
+			waitStages[j] = vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit)
+		}
+		waitValues[j] = w.Value
+	}
+
+	signalSemaphores := make([]vk.Semaphore, len(b.Signals))
+	signalValues := make([]uint64, len(b.Signals))
+	for j, sig := range b.Signals {
+		signalSemaphores[j] = sig.Semaphore
+		signalValues[j] = sig.Value
+	}
+
+	submitInfo := vk.SubmitInfo{
+		SType:                vk.StructureTypeSubmitInfo,
+		WaitSemaphoreCount:   uint32(len(waitSemaphores)),
+		PWaitSemaphores:      waitSemaphores,
+		PWaitDstStageMask:    waitStages,
+		CommandBufferCount:   uint32(len(buffers)),
+		PCommandBuffers:      buffers,
+		SignalSemaphoreCount: uint32(len(signalSemaphores)),
+		PSignalSemaphores:    signalSemaphores,
+	}
+	timelineInfo := vk.TimelineSemaphoreSubmitInfo{
+		SType:                     vk.StructureTypeTimelineSemaphoreSubmitInfo,
+		WaitSemaphoreValueCount:   uint32(len(waitValues)),
+		PWaitSemaphoreValues:      waitValues,
+		SignalSemaphoreValueCount: uint32(len(signalValues)),
+		PSignalSemaphoreValues:    signalValues,
+	}
+	return submitInfo, timelineInfo
+}
+
+// Submit submits the batches to the queue, a submission without any batches only signals the fence
+func (s *Submit) Submit() error {
+	timeline := s.Queue.Device.TimelineSemaphores
+	if !timeline && s.usesTimeline() {
+		return fmt.Errorf("timeline semaphore values require timeline semaphores to be enabled on the device")
+	}
+
+	submitInfos := make([]vk.SubmitInfo, len(s.Batches))
+	for i, b := range s.Batches {
+		var timelineInfo vk.TimelineSemaphoreSubmitInfo
+		submitInfos[i], timelineInfo = b.vkSubmitInfo()
+		if timeline {
+			// Binary semaphores ignore their values, so every batch can carry timeline values
+			defer timelineInfo.Free()
+			submitInfos[i].PNext = unsafe.Pointer(timelineInfo.Ref())
+		}
+	}
+
+	vkFence := vk.NullFence
+	if s.fence != nil {
+		vkFence = s.fence.VKFence
+	}
+
+	return vk.Error(vk.QueueSubmit(s.Queue.VKQueue, uint32(len(submitInfos)), submitInfos, vkFence))
+}
+
+// Present builds a presentation of one or more swapchain images
+type Present struct {
+	Queue      *Queue
+	waits      []vk.Semaphore
+	swapchains []vk.Swapchain
+	indices    []uint32
+}
+
+// NewPresent starts building a presentation on this queue, which must support presenting to the
+// surface of each swapchain
+func (q *Queue) NewPresent() *Present {
+	return &Present{Queue: q}
+}
+
+// Wait makes the presentation wait for a binary semaphore, timeline semaphores can not be waited on
+func (p *Present) Wait(semaphore vk.Semaphore) *Present {
+	p.waits = append(p.waits, semaphore)
+	return p
+}
+
+// Image adds the swapchain image with the specified index to the presentation
+func (p *Present) Image(swapchain *Swapchain, imageIndex uint32) *Present {
+	p.swapchains = append(p.swapchains, swapchain.VKSwapchain)
+	p.indices = append(p.indices, imageIndex)
+	return p
+}
+
+// Present queues the images for presentation. The result is returned rather than an error so that
+// vk.ErrorOutOfDate and vk.Suboptimal can be handled by recreating the swapchain, see vk.Error.
+func (p *Present) Present() vk.Result {
+	presentInfo := vk.PresentInfo{
+		SType:              vk.StructureTypePresentInfo,
+		WaitSemaphoreCount: uint32(len(p.waits)),
+		PWaitSemaphores:    p.waits,
+		SwapchainCount:     uint32(len(p.swapchains)),
+		PSwapchains:        p.swapchains,
+		PImageIndices:      p.indices,
+	}
+	return vk.QueuePresent(p.Queue.VKQueue, &presentInfo)
+}
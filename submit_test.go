@@ -0,0 +1,37 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestSubmitBatches(t *testing.T) {
+	q := &Queue{Device: &Device{}}
+	timeline := &TimelineSemaphore{}
+	a, b := &CommandBuffer{}, &CommandBuffer{}
+
+	s := q.NewSubmit().
+		Wait(nil, vk.PipelineStageFlags(vk.PipelineStageTransferBit)).
+		Buffers(a).
+		SignalTimeline(timeline.Point(1)).
+		Batch().
+		WaitTimeline(timeline.Point(1), vk.PipelineStageFlags(vk.PipelineStageVertexInputBit)).
+		WaitTimeline(TimelinePoint{}, 0).
+		Buffers(b)
+
+	if len(s.Batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(s.Batches))
+	}
+	if len(s.Batches[0].Waits) != 1 || len(s.Batches[0].Signals) != 1 || s.Batches[0].Buffers[0] != a {
+		t.Errorf("unexpected first batch %+v", s.Batches[0])
+	}
+	if len(s.Batches[1].Waits) != 1 || s.Batches[1].Waits[0].Value != 1 || s.Batches[1].Buffers[0] != b {
+		t.Errorf("unexpected second batch %+v", s.Batches[1])
+	}
+
+	// Timeline values can not be submitted unless the device has enabled timeline semaphores
+	if err := s.Submit(); err == nil {
+		t.Errorf("expected an error submitting timeline values")
+	}
+}
@@ -0,0 +1,414 @@
+package vkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultAtlasMaxSize is the default maximum width and height of a texture atlas
+const DefaultAtlasMaxSize = 4096
+
+// AtlasBuilder packs many small images into a single texture atlas using a skyline
+// bottom-left bin packer
+type AtlasBuilder struct {
+	// Padding is the number of transparent pixels left between packed images
+	Padding int
+	// Gutter is the number of pixels each image's edges are extended by, which prevents
+	// neighbouring images bleeding in when sampling with linear filtering
+	Gutter int
+	// MipSafeLevels aligns every packed image to a multiple of 2^MipSafeLevels pixels so
+	// images do not share texels in the first MipSafeLevels mip levels of the atlas
+	MipSafeLevels int
+	// MaxSize is the maximum width and height of the atlas, defaults to DefaultAtlasMaxSize
+	MaxSize int
+	// PowerOfTwo rounds the height of the atlas up to a power of two, the width is always
+	// a power of two
+	PowerOfTwo bool
+
+	entries []atlasEntry
+}
+
+type atlasEntry struct {
+	name string
+	img  image.Image
+}
+
+// AtlasRegion is the location of a single image within an atlas, both in pixels and as
+// normalized texture coordinates
+type AtlasRegion struct {
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	U0     float32 `json:"u0"`
+	V0     float32 `json:"v0"`
+	U1     float32 `json:"u1"`
+	V1     float32 `json:"v1"`
+}
+
+// Atlas is the result of packing images, the regions can be serialized to JSON alongside
+// the image saved as a PNG to allow atlases to be baked offline
+type Atlas struct {
+	Image   *image.RGBA            `json:"-"`
+	Width   int                    `json:"width"`
+	Height  int                    `json:"height"`
+	Regions map[string]AtlasRegion `json:"regions"`
+}
+
+// NewAtlasBuilder creates a new atlas builder with a single pixel of padding between images
+func NewAtlasBuilder() *AtlasBuilder {
+	return &AtlasBuilder{
+		Padding: 1,
+		MaxSize: DefaultAtlasMaxSize,
+	}
+}
+
+// Add an image to the atlas with a unique name
+func (b *AtlasBuilder) Add(name string, img image.Image) error {
+	for _, e := range b.entries {
+		if e.name == name {
+			return fmt.Errorf("image '%s' has already been added to the atlas", name)
+		}
+	}
+	if img.Bounds().Empty() {
+		return fmt.Errorf("image '%s' is empty", name)
+	}
+	if b.entries == nil {
+		b.entries = make([]atlasEntry, 0)
+	}
+	b.entries = append(b.entries, atlasEntry{name: name, img: img})
+	return nil
+}
+
+// AddFromFile adds an image loaded from disk, named after the file
+func (b *AtlasBuilder) AddFromFile(name, filename string) error {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return fmt.Errorf("unable to decode '%s': %w", filename, err)
+	}
+	return b.Add(name, img)
+}
+
+// cellSize returns the space occupied by an image within the atlas
+func (b *AtlasBuilder) cellSize(img image.Image) (int, int) {
+	w := img.Bounds().Dx() + 2*b.Gutter + b.Padding
+	h := img.Bounds().Dy() + 2*b.Gutter + b.Padding
+	align := 1 << uint(b.MipSafeLevels)
+	w = (w + align - 1) / align * align
+	h = (h + align - 1) / align * align
+	return w, h
+}
+
+// Build packs the images into a single atlas, the smallest power of two width which fits all of
+// the images is used
+func (b *AtlasBuilder) Build() (*Atlas, error) {
+	if len(b.entries) == 0 {
+		return nil, fmt.Errorf("no images have been added to the atlas")
+	}
+
+	maxSize := b.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultAtlasMaxSize
+	}
+
+	// Pack the tallest images first, which gives a tighter skyline
+	entries := make([]atlasEntry, len(b.entries))
+	copy(entries, b.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		bi, bj := entries[i].img.Bounds(), entries[j].img.Bounds()
+		if bi.Dy() != bj.Dy() {
+			return bi.Dy() > bj.Dy()
+		}
+		return bi.Dx() > bj.Dx()
+	})
+
+	area, widest := 0, 0
+	for _, e := range entries {
+		w, h := b.cellSize(e.img)
+		area += w * h
+		if w > widest {
+			widest = w
+		}
+	}
+
+	width := 1
+	for width < widest || width*width < area {
+		width *= 2
+	}
+
+	for ; width <= maxSize; width *= 2 {
+		positions, height, ok := b.pack(entries, width, maxSize)
+		if ok {
+			return b.compose(entries, positions, width, height), nil
+		}
+	}
+
+	return nil, fmt.Errorf("images do not fit in an atlas of %dx%d", maxSize, maxSize)
+}
+
+// pack places the entries using the skyline algorithm, returning the position of each entry's cell
+func (b *AtlasBuilder) pack(entries []atlasEntry, width, maxHeight int) ([]image.Point, int, bool) {
+	sky := newSkyline(width, maxHeight)
+	positions := make([]image.Point, len(entries))
+	for i, e := range entries {
+		w, h := b.cellSize(e.img)
+		p, ok := sky.insert(w, h)
+		if !ok {
+			return nil, 0, false
+		}
+		positions[i] = p
+	}
+
+	height := sky.height()
+	if b.PowerOfTwo {
+		h := 1
+		for h < height {
+			h *= 2
+		}
+		height = h
+	}
+	return positions, height, height <= maxHeight
+}
+
+// compose draws the packed entries and their gutters into the atlas image
+func (b *AtlasBuilder) compose(entries []atlasEntry, positions []image.Point, width, height int) *Atlas {
+	atlas := &Atlas{
+		Image:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		Width:   width,
+		Height:  height,
+		Regions: make(map[string]AtlasRegion),
+	}
+
+	for i, e := range entries {
+		src := e.img.Bounds()
+		x, y := positions[i].X+b.Gutter, positions[i].Y+b.Gutter
+
+		draw.Draw(atlas.Image, image.Rect(x, y, x+src.Dx(), y+src.Dy()), e.img, src.Min, draw.Src)
+
+		if b.Gutter > 0 {
+			// Extend the edge pixels of the image into the gutter
+			for gy := -b.Gutter; gy < src.Dy()+b.Gutter; gy++ {
+				for gx := -b.Gutter; gx < src.Dx()+b.Gutter; gx++ {
+					if gx >= 0 && gx < src.Dx() && gy >= 0 && gy < src.Dy() {
+						continue
+					}
+					sx := clampInt(gx, 0, src.Dx()-1)
+					sy := clampInt(gy, 0, src.Dy()-1)
+					atlas.Image.Set(x+gx, y+gy, e.img.At(src.Min.X+sx, src.Min.Y+sy))
+				}
+			}
+		}
+
+		atlas.Regions[e.name] = AtlasRegion{
+			X:      x,
+			Y:      y,
+			Width:  src.Dx(),
+			Height: src.Dy(),
+			U0:     float32(x) / float32(width),
+			V0:     float32(y) / float32(height),
+			U1:     float32(x+src.Dx()) / float32(width),
+			V1:     float32(y+src.Dy()) / float32(height),
+		}
+	}
+
+	return atlas
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// Region returns the region of the named image
+func (a *Atlas) Region(name string) (AtlasRegion, bool) {
+	r, ok := a.Regions[name]
+	return r, ok
+}
+
+// WriteJSON writes the regions of the atlas as JSON
+func (a *Atlas) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// WritePNG writes the atlas image as a PNG
+func (a *Atlas) WritePNG(w io.Writer) error {
+	return png.Encode(w, a.Image)
+}
+
+// Save writes the atlas image and its regions to disk so it can be loaded with LoadAtlas
+func (a *Atlas) Save(pngFile, jsonFile string) error {
+	pf, err := os.Create(pngFile)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	err = a.WritePNG(pf)
+	if err != nil {
+		return err
+	}
+
+	jf, err := os.Create(jsonFile)
+	if err != nil {
+		return err
+	}
+	defer jf.Close()
+
+	return a.WriteJSON(jf)
+}
+
+// LoadAtlas loads an atlas which was previously baked with Atlas.Save
+func LoadAtlas(pngFile, jsonFile string) (*Atlas, error) {
+	jf, err := os.Open(jsonFile)
+	if err != nil {
+		return nil, err
+	}
+	defer jf.Close()
+
+	var atlas Atlas
+	err = json.NewDecoder(jf).Decode(&atlas)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode atlas '%s': %w", jsonFile, err)
+	}
+
+	pf, err := os.Open(pngFile)
+	if err != nil {
+		return nil, err
+	}
+	defer pf.Close()
+
+	src, err := png.Decode(pf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode atlas image '%s': %w", pngFile, err)
+	}
+
+	b := src.Bounds()
+	if b.Dx() != atlas.Width || b.Dy() != atlas.Height {
+		return nil, fmt.Errorf("atlas image is %dx%d but regions expect %dx%d", b.Dx(), b.Dy(), atlas.Width, atlas.Height)
+	}
+
+	atlas.Image = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(atlas.Image, atlas.Image.Bounds(), src, b.Min, draw.Src)
+
+	return &atlas, nil
+}
+
+// StageAtlas uploads the atlas image into an image allocated from this pool
+func (p *ImageResourcePool) StageAtlas(atlas *Atlas, cmd *CommandBuffer, queue *Queue) (*ImageResource, error) {
+	return p.StageTextureFromImage(atlas.Image, cmd, queue)
+}
+
+// skyline tracks the top edge of the packed area as a series of horizontal segments
+type skyline struct {
+	width     int
+	maxHeight int
+	nodes     []skylineNode
+}
+
+type skylineNode struct {
+	x, y, width int
+}
+
+func newSkyline(width, maxHeight int) *skyline {
+	return &skyline{
+		width:     width,
+		maxHeight: maxHeight,
+		nodes:     []skylineNode{{x: 0, y: 0, width: width}},
+	}
+}
+
+// fit returns the y coordinate a rectangle placed at node i would rest at
+func (s *skyline) fit(i, w, h int) (int, bool) {
+	x := s.nodes[i].x
+	if x+w > s.width {
+		return 0, false
+	}
+	y := 0
+	remaining := w
+	for j := i; remaining > 0; j++ {
+		if s.nodes[j].y > y {
+			y = s.nodes[j].y
+		}
+		if y+h > s.maxHeight {
+			return 0, false
+		}
+		remaining -= s.nodes[j].width
+	}
+	return y, true
+}
+
+// insert places a rectangle at the lowest available position, preferring the left most
+func (s *skyline) insert(w, h int) (image.Point, bool) {
+	best, bestY := -1, 0
+	for i := range s.nodes {
+		y, ok := s.fit(i, w, h)
+		if ok && (best < 0 || y < bestY) {
+			best, bestY = i, y
+		}
+	}
+	if best < 0 {
+		return image.Point{}, false
+	}
+
+	p := image.Point{X: s.nodes[best].x, Y: bestY}
+	node := skylineNode{x: p.X, y: bestY + h, width: w}
+
+	nodes := make([]skylineNode, 0, len(s.nodes)+1)
+	nodes = append(nodes, s.nodes[:best]...)
+	nodes = append(nodes, node)
+	for _, n := range s.nodes[best:] {
+		end := n.x + n.width
+		if end <= node.x+node.width {
+			// Entirely covered by the new node
+			continue
+		}
+		if n.x < node.x+node.width {
+			n.width = end - (node.x + node.width)
+			n.x = node.x + node.width
+		}
+		nodes = append(nodes, n)
+	}
+
+	// Merge neighbouring nodes at the same height
+	s.nodes = nodes[:1]
+	for _, n := range nodes[1:] {
+		last := &s.nodes[len(s.nodes)-1]
+		if last.y == n.y {
+			last.width += n.width
+		} else {
+			s.nodes = append(s.nodes, n)
+		}
+	}
+
+	return p, true
+}
+
+// height returns the height of the highest point of the skyline
+func (s *skyline) height() int {
+	h := 0
+	for _, n := range s.nodes {
+		if n.y > h {
+			h = n.y
+		}
+	}
+	return h
+}
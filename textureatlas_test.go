@@ -0,0 +1,92 @@
+package vkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestAtlasBuilder(t *testing.T) {
+	b := NewAtlasBuilder()
+	b.Gutter = 2
+
+	sizes := [][2]int{{16, 16}, {32, 8}, {8, 32}, {5, 7}, {64, 3}, {1, 1}, {20, 20}}
+	for i, s := range sizes {
+		err := b.Add(fmt.Sprintf("img%d", i), solidImage(s[0], s[1], color.RGBA{uint8(i * 30), 0, 0, 255}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Add("img0", solidImage(1, 1, color.RGBA{})); err == nil {
+		t.Error("expected duplicate name to fail")
+	}
+
+	atlas, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rects := make([]image.Rectangle, 0)
+	for i, s := range sizes {
+		r, ok := atlas.Region(fmt.Sprintf("img%d", i))
+		if !ok {
+			t.Fatalf("missing region for img%d", i)
+		}
+		if r.Width != s[0] || r.Height != s[1] {
+			t.Errorf("img%d has size %dx%d, expected %dx%d", i, r.Width, r.Height, s[0], s[1])
+		}
+		if r.U0 != float32(r.X)/float32(atlas.Width) || r.V1 != float32(r.Y+r.Height)/float32(atlas.Height) {
+			t.Errorf("img%d has incorrect uvs %+v", i, r)
+		}
+		// Include the gutter, which must not overlap any other image
+		rect := image.Rect(r.X-b.Gutter, r.Y-b.Gutter, r.X+r.Width+b.Gutter, r.Y+r.Height+b.Gutter)
+		if !rect.In(atlas.Image.Bounds()) {
+			t.Errorf("img%d is outside of the atlas", i)
+		}
+		for j, o := range rects {
+			if rect.Overlaps(o) {
+				t.Errorf("img%d overlaps img%d", i, j)
+			}
+		}
+		rects = append(rects, rect)
+
+		// The gutter should repeat the edge color
+		if atlas.Image.RGBAAt(r.X-1, r.Y-1) != atlas.Image.RGBAAt(r.X, r.Y) {
+			t.Errorf("img%d gutter was not filled", i)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := atlas.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Atlas
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Regions["img3"] != atlas.Regions["img3"] || decoded.Width != atlas.Width {
+		t.Error("atlas did not round trip through json")
+	}
+}
+
+func TestAtlasBuilderTooLarge(t *testing.T) {
+	b := NewAtlasBuilder()
+	b.MaxSize = 32
+	b.Add("big", solidImage(40, 4, color.RGBA{}))
+	if _, err := b.Build(); err == nil {
+		t.Error("expected image larger than the atlas to fail")
+	}
+}
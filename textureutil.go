@@ -85,7 +85,10 @@ func (p *ImageResourcePool) StageTextureFromImage(srcImg *image.RGBA, cmd *Comma
 		return nil, err
 	}
 
-	p.Device.WaitForFences(true, 100*time.Second, f)
+	err = p.Device.WaitForFences(true, 100*time.Second, f)
+	if err != nil {
+		return nil, fmt.Errorf("texture upload did not complete: %w", err)
+	}
 
 	return img, nil
 
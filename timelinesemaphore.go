@@ -0,0 +1,293 @@
+package vkg
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+// TimelineSemaphoreExtension is the extension which provides timeline semaphores prior to Vulkan 1.2
+const TimelineSemaphoreExtension = "VK_KHR_timeline_semaphore"
+
+// TimelineSemaphore is a semaphore with a 64 bit value which only ever increases, it can be
+// signaled and waited on both from the host and from queue submissions. The device must be
+// created with CreateDeviceOptions.EnableTimelineSemaphores.
+type TimelineSemaphore struct {
+	Device      *Device
+	VKSemaphore vk.Semaphore
+}
+
+// TimelinePoint is a value on a timeline semaphore, it is reached once the semaphore's value is
+// greater than or equal to Value
+type TimelinePoint struct {
+	Semaphore *TimelineSemaphore
+	Value     uint64
+}
+
+// CreateTimelineSemaphore creates a timeline semaphore starting at the specified value
+func (d *Device) CreateTimelineSemaphore(initialValue uint64) (*TimelineSemaphore, error) {
+	if !d.TimelineSemaphores {
+		return nil, fmt.Errorf("timeline semaphores are not enabled on this device")
+	}
+
+	typeCreateInfo := vk.SemaphoreTypeCreateInfo{
+		SType:         vk.StructureTypeSemaphoreTypeCreateInfo,
+		SemaphoreType: vk.SemaphoreTypeTimeline,
+		InitialValue:  initialValue,
+	}
+	defer typeCreateInfo.Free()
+
+	semaphoreCreateInfo := vk.SemaphoreCreateInfo{
+		SType: vk.StructureTypeSemaphoreCreateInfo,
+		PNext: unsafe.Pointer(typeCreateInfo.Ref()),
+	}
+
+	var sema vk.Semaphore
+	err := vk.Error(vk.CreateSemaphore(d.VKDevice, &semaphoreCreateInfo, nil, &sema))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret TimelineSemaphore
+	ret.Device = d
+	ret.VKSemaphore = sema
+	return &ret, nil
+}
+
+// Value returns the current value of the semaphore
+func (t *TimelineSemaphore) Value() (uint64, error) {
+	var value uint64
+	var err error
+	if t.Device.timelineSemaphoresKHR {
+		err = vk.Error(vk.GetSemaphoreCounterValueKHR(t.Device.VKDevice, t.VKSemaphore, &value))
+	} else {
+		err = vk.Error(vk.GetSemaphoreCounterValue(t.Device.VKDevice, t.VKSemaphore, &value))
+	}
+	return value, err
+}
+
+// Signal sets the value of the semaphore from the host, the value must be greater than the current value
+func (t *TimelineSemaphore) Signal(value uint64) error {
+	signalInfo := vk.SemaphoreSignalInfo{
+		SType:     vk.StructureTypeSemaphoreSignalInfo,
+		Semaphore: t.VKSemaphore,
+		Value:     value,
+	}
+	if t.Device.timelineSemaphoresKHR {
+		return vk.Error(vk.SignalSemaphoreKHR(t.Device.VKDevice, &signalInfo))
+	}
+	return vk.Error(vk.SignalSemaphore(t.Device.VKDevice, &signalInfo))
+}
+
+// Wait blocks until the semaphore reaches the specified value or the timeout expires
+func (t *TimelineSemaphore) Wait(value uint64, timeout time.Duration) error {
+	return t.Device.WaitTimelineSemaphores(true, timeout, TimelinePoint{Semaphore: t, Value: value})
+}
+
+// Point returns the point on this semaphore with the specified value
+func (t *TimelineSemaphore) Point(value uint64) TimelinePoint {
+	return TimelinePoint{Semaphore: t, Value: value}
+}
+
+// Destroy destroys the semaphore
+func (t *TimelineSemaphore) Destroy() {
+	vk.DestroySemaphore(t.Device.VKDevice, t.VKSemaphore, nil)
+}
+
+// WaitTimelineSemaphores blocks until all, or any, of the points have been reached or the timeout expires
+func (d *Device) WaitTimelineSemaphores(waitForAll bool, timeout time.Duration, points ...TimelinePoint) error {
+	waitInfo, ok := timelineWaitInfo(waitForAll, points)
+	if !ok {
+		return nil
+	}
+	if d.timelineSemaphoresKHR {
+		return vk.Error(vk.WaitSemaphoresKHR(d.VKDevice, &waitInfo, uint64(timeout.Nanoseconds())))
+	}
+	return vk.Error(vk.WaitSemaphores(d.VKDevice, &waitInfo, uint64(timeout.Nanoseconds())))
+}
+
+// timelineWaitInfo creates the wait info for the points, false is returned if there is nothing to wait on
+func timelineWaitInfo(waitForAll bool, points []TimelinePoint) (vk.SemaphoreWaitInfo, bool) {
+	points = mergeTimelinePoints(points)
+	if len(points) == 0 {
+		return vk.SemaphoreWaitInfo{}, false
+	}
+
+	semaphores := make([]vk.Semaphore, len(points))
+	values := make([]uint64, len(points))
+	for i, p := range points {
+		semaphores[i] = p.Semaphore.VKSemaphore
+		values[i] = p.Value
+	}
+
+	waitInfo := vk.SemaphoreWaitInfo{
+		SType:          vk.StructureTypeSemaphoreWaitInfo,
+		SemaphoreCount: uint32(len(points)),
+		PSemaphores:    semaphores,
+		PValues:        values,
+	}
+	if !waitForAll {
+		waitInfo.Flags = vk.SemaphoreWaitFlags(vk.SemaphoreWaitAnyBit)
+	}
+	return waitInfo, true
+}
+
+// Reached returns true if the semaphore has reached the point's value
+func (p TimelinePoint) Reached() (bool, error) {
+	if p.Semaphore == nil {
+		return true, nil
+	}
+	value, err := p.Semaphore.Value()
+	if err != nil {
+		return false, err
+	}
+	return value >= p.Value, nil
+}
+
+// Wait blocks until the point has been reached or the timeout expires
+func (p TimelinePoint) Wait(timeout time.Duration) error {
+	if p.Semaphore == nil {
+		return nil
+	}
+	return p.Semaphore.Wait(p.Value, timeout)
+}
+
+// WaitInfo returns a wait for this point which blocks the specified stages of a submission
+func (p TimelinePoint) WaitInfo(stageMask vk.PipelineStageFlags) SemaphoreWait {
+	return SemaphoreWait{Semaphore: p.Semaphore.VKSemaphore, Value: p.Value, StageMask: stageMask}
+}
+
+// SignalInfo returns a signal which sets the semaphore to this point when a submission completes
+func (p TimelinePoint) SignalInfo() SemaphoreSignal {
+	return SemaphoreSignal{Semaphore: p.Semaphore.VKSemaphore, Value: p.Value}
+}
+
+// mergeTimelinePoints drops empty points and keeps only the highest point for each semaphore,
+// since reaching it implies the lower points have been reached
+func mergeTimelinePoints(points []TimelinePoint) []TimelinePoint {
+	ret := make([]TimelinePoint, 0, len(points))
+	index := make(map[*TimelineSemaphore]int)
+	for _, p := range points {
+		if p.Semaphore == nil {
+			continue
+		}
+		if i, ok := index[p.Semaphore]; ok {
+			if p.Value > ret[i].Value {
+				ret[i].Value = p.Value
+			}
+			continue
+		}
+		index[p.Semaphore] = len(ret)
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+// GPUTimeline orders submissions across queues without fence bookkeeping. Each queue is given a
+// timeline semaphore which every submission to that queue signals with the next value, the returned
+// TimelinePoint can be passed as a dependency of later submissions to any queue or waited on by the host.
+type GPUTimeline struct {
+	Device *Device
+
+	mutex  sync.Mutex
+	queues map[*Queue]*gpuTimelineQueue
+}
+
+type gpuTimelineQueue struct {
+	semaphore *TimelineSemaphore
+	value     uint64
+}
+
+// CreateGPUTimeline creates a new timeline, semaphores are created as queues are first submitted to
+func (d *Device) CreateGPUTimeline() (*GPUTimeline, error) {
+	if !d.TimelineSemaphores {
+		return nil, fmt.Errorf("timeline semaphores are not enabled on this device")
+	}
+
+	var ret GPUTimeline
+	ret.Device = d
+	ret.queues = make(map[*Queue]*gpuTimelineQueue)
+	return &ret, nil
+}
+
+// Submit submits the command buffers to the queue once all of the dependencies have been reached
+func (t *GPUTimeline) Submit(queue *Queue, buffers []*CommandBuffer, after ...TimelinePoint) (TimelinePoint, error) {
+	return t.SubmitWithStage(queue, vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit), buffers, after...)
+}
+
+// SubmitWithStage is like Submit but only blocks the specified stages of the submission on the dependencies
+func (t *GPUTimeline) SubmitWithStage(queue *Queue, waitStage vk.PipelineStageFlags, buffers []*CommandBuffer, after ...TimelinePoint) (TimelinePoint, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	q, err := t.queue(queue)
+	if err != nil {
+		return TimelinePoint{}, err
+	}
+
+	deps := mergeTimelinePoints(after)
+	waits := make([]SemaphoreWait, len(deps))
+	for i, d := range deps {
+		waits[i] = d.WaitInfo(waitStage)
+	}
+
+	point := q.semaphore.Point(q.value + 1)
+	err = queue.SubmitWithSemaphores(waits, []SemaphoreSignal{point.SignalInfo()}, nil, buffers...)
+	if err != nil {
+		return TimelinePoint{}, err
+	}
+	q.value = point.Value
+
+	return point, nil
+}
+
+// Last returns the point reached once all work submitted to the queue so far has completed
+func (t *GPUTimeline) Last(queue *Queue) TimelinePoint {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	q, ok := t.queues[queue]
+	if !ok {
+		return TimelinePoint{}
+	}
+	return q.semaphore.Point(q.value)
+}
+
+// WaitIdle blocks until all work submitted through the timeline has completed or the timeout expires
+func (t *GPUTimeline) WaitIdle(timeout time.Duration) error {
+	t.mutex.Lock()
+	points := make([]TimelinePoint, 0, len(t.queues))
+	for _, q := range t.queues {
+		points = append(points, q.semaphore.Point(q.value))
+	}
+	t.mutex.Unlock()
+
+	return t.Device.WaitTimelineSemaphores(true, timeout, points...)
+}
+
+// Destroy destroys the semaphores used by the timeline, the device should be idle
+func (t *GPUTimeline) Destroy() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, q := range t.queues {
+		q.semaphore.Destroy()
+	}
+	t.queues = make(map[*Queue]*gpuTimelineQueue)
+}
+
+func (t *GPUTimeline) queue(queue *Queue) (*gpuTimelineQueue, error) {
+	if q, ok := t.queues[queue]; ok {
+		return q, nil
+	}
+	sema, err := t.Device.CreateTimelineSemaphore(0)
+	if err != nil {
+		return nil, err
+	}
+	q := &gpuTimelineQueue{semaphore: sema}
+	t.queues[queue] = q
+	return q, nil
+}
@@ -0,0 +1,70 @@
+package vkg
+
+import (
+	"testing"
+
+	vk "github.com/vulkan-go/vulkan"
+)
+
+func TestMergeTimelinePoints(t *testing.T) {
+	a, b := &TimelineSemaphore{}, &TimelineSemaphore{}
+
+	points := mergeTimelinePoints([]TimelinePoint{
+		{Semaphore: a, Value: 2},
+		{},
+		{Semaphore: b, Value: 1},
+		{Semaphore: a, Value: 5},
+		{Semaphore: a, Value: 3},
+	})
+
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if points[0].Semaphore != a || points[0].Value != 5 {
+		t.Errorf("expected the highest value of the first semaphore, got %+v", points[0])
+	}
+	if points[1].Semaphore != b || points[1].Value != 1 {
+		t.Errorf("unexpected point %+v", points[1])
+	}
+}
+
+func TestTimelineWaitInfo(t *testing.T) {
+	if _, ok := timelineWaitInfo(true, []TimelinePoint{{}}); ok {
+		t.Errorf("expected nothing to wait on for empty points")
+	}
+
+	a, b := &TimelineSemaphore{}, &TimelineSemaphore{}
+	info, ok := timelineWaitInfo(false, []TimelinePoint{a.Point(3), b.Point(1), a.Point(7)})
+	if !ok {
+		t.Fatalf("expected a wait info")
+	}
+	if info.SemaphoreCount != 2 || len(info.PValues) != 2 || info.PValues[0] != 7 || info.PValues[1] != 1 {
+		t.Errorf("expected the highest value for each semaphore, got %v", info.PValues)
+	}
+	if info.Flags != vk.SemaphoreWaitFlags(vk.SemaphoreWaitAnyBit) {
+		t.Errorf("expected a wait for any semaphore")
+	}
+}
+
+func TestTimelineSubmitInfo(t *testing.T) {
+	timeline := &TimelineSemaphore{}
+	batch := &SubmitBatch{
+		Waits:   []SemaphoreWait{timeline.Point(2).WaitInfo(0), {}},
+		Buffers: []*CommandBuffer{{}},
+		Signals: []SemaphoreSignal{timeline.Point(3).SignalInfo()},
+	}
+
+	info, timelineInfo := batch.vkSubmitInfo()
+	if info.WaitSemaphoreCount != 2 || info.SignalSemaphoreCount != 1 || info.CommandBufferCount != 1 {
+		t.Fatalf("unexpected submit info %+v", info)
+	}
+	if info.PWaitDstStageMask[0] != vk.PipelineStageFlags(vk.PipelineStageAllCommandsBit) {
+		t.Errorf("expected waits without a stage to block all commands")
+	}
+	if timelineInfo.WaitSemaphoreValueCount != 2 || timelineInfo.PWaitSemaphoreValues[0] != 2 || timelineInfo.PWaitSemaphoreValues[1] != 0 {
+		t.Errorf("unexpected wait values %v", timelineInfo.PWaitSemaphoreValues)
+	}
+	if timelineInfo.SignalSemaphoreValueCount != 1 || timelineInfo.PSignalSemaphoreValues[0] != 3 {
+		t.Errorf("unexpected signal values %v", timelineInfo.PSignalSemaphoreValues)
+	}
+}
@@ -61,3 +61,12 @@ func safeStrings(list []string) []string {
 	}
 	return list
 }
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}